@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"runtime/debug"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -17,12 +19,16 @@ import (
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/managedfields"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	authType1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
@@ -75,6 +81,20 @@ type apiMeta struct {
 	watchCancel context.CancelFunc
 }
 
+// watchStatus holds the freshness of the data cached by a single watch
+type watchStatus struct {
+	// lastSyncTime is the time of the most recent successful list or watch event
+	lastSyncTime time.Time
+	// healthy is false if the watch has failed and has not been restarted yet
+	healthy bool
+}
+
+// watchKey identifies a single watch: watches are started per group kind and per namespace (or cluster-wide if namespace is empty)
+type watchKey struct {
+	gk schema.GroupKind
+	ns string
+}
+
 // ClusterInfo holds cluster cache stats
 type ClusterInfo struct {
 	// Server holds cluster API server URL
@@ -119,6 +139,12 @@ type ClusterCache interface {
 	Invalidate(opts ...UpdateSettingsFunc)
 	// FindResources returns resources that matches given list of predicates from specified namespace or everywhere if specified namespace is empty
 	FindResources(namespace string, predicates ...func(r *Resource) bool) map[kube.ResourceKey]*Resource
+	// GetResourcesByLabel returns resources from all namespaces whose labels match the given selector.
+	// The lookup uses the label index instead of scanning all resources whenever the selector allows it.
+	GetResourcesByLabel(selector labels.Selector) []*Resource
+	// GetChildren returns the resources whose owner references point to the resource with the given UID.
+	// The lookup uses the owner index and does not scan all resources.
+	GetChildren(ownerUID types.UID) []*Resource
 	// IterateHierarchy iterates resource tree starting from the specified top level resource and executes callback for each resource in the tree.
 	// The action callback returns true if iteration should continue and false otherwise.
 	IterateHierarchy(key kube.ResourceKey, action func(resource *Resource, namespaceResources map[kube.ResourceKey]*Resource) bool)
@@ -129,7 +155,8 @@ type ClusterCache interface {
 	IsNamespaced(gk schema.GroupKind) (bool, error)
 	// GetManagedLiveObjs helps finding matching live K8S resources for a given resources list.
 	// The function returns all resources from cache for those `isManaged` function returns true and resources
-	// specified in targetObjs list.
+	// specified in targetObjs list. The returned cached manifests are transformed by the resource transformer if one is
+	// set using SetResourceTransformer.
 	GetManagedLiveObjs(targetObjs []*unstructured.Unstructured, isManaged func(r *Resource) bool) (map[kube.ResourceKey]*unstructured.Unstructured, error)
 	// GetClusterInfo returns cluster cache statistics
 	GetClusterInfo() ClusterInfo
@@ -137,6 +164,22 @@ type ClusterCache interface {
 	OnResourceUpdated(handler OnResourceUpdatedHandler) Unsubscribe
 	// OnEvent register event handler that is executed every time when new K8S event received
 	OnEvent(handler OnEventHandler) Unsubscribe
+	// LastSyncTime returns the time of the most recent successful list or watch event of the given resource type. If the
+	// type is watched in multiple namespaces, the least recent time is returned. Zero time is returned if the type is not watched.
+	LastSyncTime(gvk schema.GroupVersionKind) time.Time
+	// WatchHealthy returns true if all watches of the given resource type are running, i.e. the cached data is kept up
+	// to date. False is returned if the type is not watched.
+	WatchHealthy(gvk schema.GroupVersionKind) bool
+	// OnInitialSyncComplete register handler that is executed once when the cache completes the first successful sync.
+	// The handler is executed immediately if the initial sync has already completed.
+	OnInitialSyncComplete(handler func()) Unsubscribe
+	// Pause stops applying watch events to the cache until Resume is called. Events received while paused are dropped,
+	// so the cached state becomes stale: resources created, updated or deleted in the meantime are not reflected and
+	// the event handlers are not notified about them.
+	Pause()
+	// Resume resumes applying watch events and relists all resources to reconcile the changes missed while paused.
+	// Nothing happens if the cache is not paused.
+	Resume() error
 }
 
 type WeightedSemaphore interface {
@@ -147,6 +190,10 @@ type WeightedSemaphore interface {
 
 type ListRetryFunc func(err error) bool
 
+// ResourceTransformer returns the manifest to store in the cache for the given resource, e.g. a projection that keeps
+// only some of the fields. The given resource must not be modified.
+type ResourceTransformer func(un *unstructured.Unstructured) *unstructured.Unstructured
+
 // NewClusterCache creates new instance of cluster cache
 func NewClusterCache(config *rest.Config, opts ...UpdateSettingsFunc) *clusterCache {
 	log := textlogger.NewLogger(textlogger.NewConfig())
@@ -158,6 +205,8 @@ func NewClusterCache(config *rest.Config, opts ...UpdateSettingsFunc) *clusterCa
 		listSemaphore:      semaphore.NewWeighted(defaultListSemaphoreWeight),
 		resources:          make(map[kube.ResourceKey]*Resource),
 		nsIndex:            make(map[string]map[kube.ResourceKey]*Resource),
+		labelIndex:         make(map[string]map[string]map[kube.ResourceKey]*Resource),
+		ownerIndex:         make(map[types.UID]map[kube.ResourceKey]*Resource),
 		config:             config,
 		kubectl: &kube.KubectlCmd{
 			Log:    log,
@@ -171,6 +220,9 @@ func NewClusterCache(config *rest.Config, opts ...UpdateSettingsFunc) *clusterCa
 		clusterSyncRetryTimeout: ClusterRetryTimeout,
 		resourceUpdatedHandlers: map[uint64]OnResourceUpdatedHandler{},
 		eventHandlers:           map[uint64]OnEventHandler{},
+		initialSyncHandlers:     map[uint64]func(){},
+		watchBookmarks:          map[watchKey]string{},
+		watchStatuses:           map[watchKey]*watchStatus{},
 		log:                     log,
 		listRetryLimit:          1,
 		listRetryUseBackoff:     false,
@@ -193,8 +245,20 @@ type clusterCache struct {
 
 	// maximum time we allow watches to run before relisting the group/kind and restarting the watch
 	watchResyncTimeout time.Duration
+	// interval of the periodic reconciliation of the cached resources against a full list, without restarting the watch
+	reconcileInterval time.Duration
 	// sync retry timeout for cluster when sync error happens
 	clusterSyncRetryTimeout time.Duration
+	// if true then restarted watches are resumed from the most recent bookmark instead of relisting the group/kind
+	resumeWatchFromBookmark bool
+	// watchBookmarksLock protects watchBookmarks
+	watchBookmarksLock sync.Mutex
+	// watchBookmarks holds the resource version of the most recent bookmark received by each watch
+	watchBookmarks map[watchKey]string
+	// watchStatusesLock protects watchStatuses
+	watchStatusesLock sync.Mutex
+	// watchStatuses holds the freshness of the data cached by each watch
+	watchStatuses map[watchKey]*watchStatus
 
 	// size of a page for list operations pager.
 	listPageSize int64
@@ -211,6 +275,10 @@ type clusterCache struct {
 	lock      sync.RWMutex
 	resources map[kube.ResourceKey]*Resource
 	nsIndex   map[string]map[kube.ResourceKey]*Resource
+	// labelIndex indexes resources by label key and label value
+	labelIndex map[string]map[string]map[kube.ResourceKey]*Resource
+	// ownerIndex indexes resources by the UIDs of their owner references
+	ownerIndex map[types.UID]map[kube.ResourceKey]*Resource
 
 	kubectl          kube.Kubectl
 	log              logr.Logger
@@ -219,15 +287,28 @@ type clusterCache struct {
 	clusterResources bool
 	settings         Settings
 
+	// dynamicClient and discoveryClient override the clients created by kubectl if set
+	dynamicClient   dynamic.Interface
+	discoveryClient discovery.DiscoveryInterface
+
 	handlersLock                sync.Mutex
 	handlerKey                  uint64
 	populateResourceInfoHandler OnPopulateResourceInfoHandler
+	resourceTransformer         ResourceTransformer
+	fieldSelectors              map[schema.GroupKind]fields.Selector
 	resourceUpdatedHandlers     map[uint64]OnResourceUpdatedHandler
 	eventHandlers               map[uint64]OnEventHandler
+	initialSyncHandlers         map[uint64]func()
 	openAPISchema               openapi.Resources
 	gvkParser                   *managedfields.GvkParser
 
+	// initialSyncCompleted is true once the first successful sync has completed; protected by handlersLock
+	initialSyncCompleted bool
+
 	respectRBAC int
+
+	// paused is true while watch events are dropped, see Pause
+	paused atomic.Bool
 }
 
 type clusterCacheSync struct {
@@ -299,6 +380,45 @@ func (c *clusterCache) getEventHandlers() []OnEventHandler {
 	return handlers
 }
 
+// OnInitialSyncComplete register handler that is executed once when the cache completes the first successful sync
+func (c *clusterCache) OnInitialSyncComplete(handler func()) Unsubscribe {
+	c.handlersLock.Lock()
+	if c.initialSyncCompleted {
+		c.handlersLock.Unlock()
+		handler()
+		return func() {}
+	}
+	key := c.handlerKey
+	c.handlerKey++
+	c.initialSyncHandlers[key] = handler
+	c.handlersLock.Unlock()
+	return func() {
+		c.handlersLock.Lock()
+		defer c.handlersLock.Unlock()
+		delete(c.initialSyncHandlers, key)
+	}
+}
+
+// notifyInitialSyncComplete executes the initial sync handlers if this is the first successful sync.
+// Must not be called while holding the cluster cache lock, so that handlers are free to query the cache.
+func (c *clusterCache) notifyInitialSyncComplete() {
+	c.handlersLock.Lock()
+	if c.initialSyncCompleted {
+		c.handlersLock.Unlock()
+		return
+	}
+	c.initialSyncCompleted = true
+	handlers := make([]func(), 0, len(c.initialSyncHandlers))
+	for _, h := range c.initialSyncHandlers {
+		handlers = append(handlers, h)
+	}
+	c.initialSyncHandlers = map[uint64]func(){}
+	c.handlersLock.Unlock()
+	for _, h := range handlers {
+		h()
+	}
+}
+
 // GetServerVersion returns observed cluster version
 func (c *clusterCache) GetServerVersion() string {
 	return c.serverVersion
@@ -388,14 +508,20 @@ func (c *clusterCache) newResource(un *unstructured.Unstructured) *Resource {
 	}
 	resource := &Resource{
 		ResourceVersion:    un.GetResourceVersion(),
+		Generation:         un.GetGeneration(),
 		Ref:                kube.GetObjectRef(un),
+		Labels:             un.GetLabels(),
 		OwnerRefs:          ownerRefs,
 		Info:               info,
 		CreationTimestamp:  creationTimestamp,
 		isInferredParentOf: isInferredParentOf,
 	}
 	if cacheManifest {
-		resource.Resource = un
+		if c.resourceTransformer != nil {
+			resource.Resource = c.resourceTransformer(un)
+		} else {
+			resource.Resource = un
+		}
 	}
 
 	return resource
@@ -403,7 +529,13 @@ func (c *clusterCache) newResource(un *unstructured.Unstructured) *Resource {
 
 func (c *clusterCache) setNode(n *Resource) {
 	key := n.ResourceKey()
+	if existing, ok := c.resources[key]; ok {
+		c.removeFromLabelIndex(key, existing)
+		c.removeFromOwnerIndex(key, existing)
+	}
 	c.resources[key] = n
+	c.addToLabelIndex(key, n)
+	c.addToOwnerIndex(key, n)
 	ns, ok := c.nsIndex[key.Namespace]
 	if !ok {
 		ns = make(map[kube.ResourceKey]*Resource)
@@ -416,10 +548,10 @@ func (c *clusterCache) setNode(n *Resource) {
 		for k, v := range ns {
 			// update child resource owner references
 			if n.isInferredParentOf != nil && mightHaveInferredOwner(v) {
-				v.setOwnerRef(n.toOwnerRef(), n.isInferredParentOf(k))
+				c.setOwnerRef(k, v, n.toOwnerRef(), n.isInferredParentOf(k))
 			}
 			if mightHaveInferredOwner(n) && v.isInferredParentOf != nil {
-				n.setOwnerRef(v.toOwnerRef(), v.isInferredParentOf(n.ResourceKey()))
+				c.setOwnerRef(key, n, v.toOwnerRef(), v.isInferredParentOf(n.ResourceKey()))
 			}
 		}
 	}
@@ -442,6 +574,7 @@ func (c *clusterCache) Invalidate(opts ...UpdateSettingsFunc) {
 	}
 	c.apisMeta = nil
 	c.namespacedResources = nil
+	c.resetWatchStatuses()
 	c.log.Info("Invalidated cluster")
 }
 
@@ -473,13 +606,45 @@ func (c *clusterCache) stopWatching(gk schema.GroupKind, ns string) {
 	}
 }
 
+func (c *clusterCache) newDynamicClient() (dynamic.Interface, error) {
+	if c.dynamicClient != nil {
+		return c.dynamicClient, nil
+	}
+	return c.kubectl.NewDynamicClient(c.config)
+}
+
+func (c *clusterCache) getServerVersion() (string, error) {
+	if c.discoveryClient == nil {
+		return c.kubectl.GetServerVersion(c.config)
+	}
+	v, err := c.discoveryClient.ServerVersion()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s.%s", v.Major, v.Minor), nil
+}
+
+func (c *clusterCache) getAPIResources(preferred bool, resourceFilter kube.ResourceFilter) ([]kube.APIResourceInfo, error) {
+	if c.discoveryClient == nil {
+		return c.kubectl.GetAPIResources(c.config, preferred, resourceFilter)
+	}
+	return kube.GetAPIResourcesFromDiscovery(c.discoveryClient, c.config.Host, preferred, resourceFilter, c.log)
+}
+
+func (c *clusterCache) loadOpenAPISchema() (openapi.Resources, *managedfields.GvkParser, error) {
+	if c.discoveryClient == nil {
+		return c.kubectl.LoadOpenAPISchema(c.config)
+	}
+	return kube.LoadOpenAPISchemaFromDiscovery(c.discoveryClient, c.log)
+}
+
 // startMissingWatches lists supported cluster resources and starts watching for changes unless watch is already running
 func (c *clusterCache) startMissingWatches() error {
-	apis, err := c.kubectl.GetAPIResources(c.config, true, c.settings.ResourcesFilter)
+	apis, err := c.getAPIResources(true, c.settings.ResourcesFilter)
 	if err != nil {
 		return err
 	}
-	client, err := c.kubectl.NewDynamicClient(c.config)
+	client, err := c.newDynamicClient()
 	if err != nil {
 		return err
 	}
@@ -556,6 +721,11 @@ func (c *clusterCache) listResources(ctx context.Context, resClient dynamic.Reso
 		err := retry.OnError(listRetry, c.listRetryFunc, func() error {
 			var ierr error
 			res, ierr = resClient.List(ctx, opts)
+			if ierr != nil && opts.FieldSelector != "" && errors.IsBadRequest(ierr) {
+				// the field is not selectable, the items are filtered by matchesFieldSelector instead
+				opts.FieldSelector = ""
+				res, ierr = resClient.List(ctx, opts)
+			}
 			if ierr != nil {
 				// Log out a retry
 				if c.listRetryLimit > 1 && c.listRetryFunc(ierr) {
@@ -575,14 +745,40 @@ func (c *clusterCache) listResources(ctx context.Context, resClient dynamic.Reso
 	return resourceVersion, callback(listPager)
 }
 
+// listOptions returns the options of the list and watch requests for resources of the given group kind
+func (c *clusterCache) listOptions(gk schema.GroupKind) metav1.ListOptions {
+	if selector, ok := c.fieldSelectors[gk]; ok && selector != nil && !selector.Empty() {
+		return metav1.ListOptions{FieldSelector: selector.String()}
+	}
+	return metav1.ListOptions{}
+}
+
+// matchesFieldSelector returns true if the given object matches the field selector of its group kind, if any. The
+// values of the selected fields are looked up in the object, so fields the API server cannot select on are supported.
+func (c *clusterCache) matchesFieldSelector(gk schema.GroupKind, un *unstructured.Unstructured) bool {
+	selector, ok := c.fieldSelectors[gk]
+	if !ok || selector == nil || selector.Empty() {
+		return true
+	}
+	values := fields.Set{}
+	for _, req := range selector.Requirements() {
+		if val, found, err := unstructured.NestedFieldNoCopy(un.Object, strings.Split(req.Field, ".")...); found && err == nil && val != nil {
+			values[req.Field] = fmt.Sprint(val)
+		} else {
+			values[req.Field] = ""
+		}
+	}
+	return selector.Matches(values)
+}
+
 // loadInitialState loads the state of all the resources retrieved by the given resource client.
 func (c *clusterCache) loadInitialState(ctx context.Context, api kube.APIResourceInfo, resClient dynamic.ResourceInterface, ns string, lock bool) (string, error) {
 	var items []*Resource
 	resourceVersion, err := c.listResources(ctx, resClient, func(listPager *pager.ListPager) error {
-		return listPager.EachListItem(ctx, metav1.ListOptions{}, func(obj runtime.Object) error {
+		return listPager.EachListItem(ctx, c.listOptions(api.GroupKind), func(obj runtime.Object) error {
 			if un, ok := obj.(*unstructured.Unstructured); !ok {
 				return fmt.Errorf("object %s/%s has an unexpected type", un.GroupVersionKind().String(), un.GetName())
-			} else {
+			} else if c.matchesFieldSelector(api.GroupKind, un) {
 				items = append(items, c.newResource(un))
 			}
 			return nil
@@ -592,6 +788,7 @@ func (c *clusterCache) loadInitialState(ctx context.Context, api kube.APIResourc
 	if err != nil {
 		return "", fmt.Errorf("failed to load initial state of resource %s: %w", api.GroupKind.String(), err)
 	}
+	c.setWatchSynced(api.GroupKind, ns)
 
 	if lock {
 		return resourceVersion, runSynced(&c.lock, func() error {
@@ -604,8 +801,151 @@ func (c *clusterCache) loadInitialState(ctx context.Context, api kube.APIResourc
 	}
 }
 
+// reconcileResources lists the resources retrieved by the given resource client and corrects the cached resources that
+// differ from the list, e.g. because watch events were missed. Cached resources that are newer than the list, e.g.
+// because a watch event was processed while the list was retrieved, are kept. Nothing happens while the cache is paused.
+func (c *clusterCache) reconcileResources(ctx context.Context, api kube.APIResourceInfo, resClient dynamic.ResourceInterface, ns string) error {
+	if c.paused.Load() {
+		return nil
+	}
+	c.log.V(1).Info("Reconciling cached resources", "groupKind", api.GroupKind.String(), "namespace", ns)
+	var items []*unstructured.Unstructured
+	listResourceVersion, err := c.listResources(ctx, resClient, func(listPager *pager.ListPager) error {
+		return listPager.EachListItem(ctx, c.listOptions(api.GroupKind), func(obj runtime.Object) error {
+			if un, ok := obj.(*unstructured.Unstructured); !ok {
+				return fmt.Errorf("object %s/%s has an unexpected type", un.GroupVersionKind().String(), un.GetName())
+			} else if c.matchesFieldSelector(api.GroupKind, un) {
+				items = append(items, un)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reconcile resources of %s: %w", api.GroupKind.String(), err)
+	}
+	c.setWatchSynced(api.GroupKind, ns)
+
+	return runSynced(&c.lock, func() error {
+		listed := make(map[kube.ResourceKey]bool, len(items))
+		for _, un := range items {
+			key := kube.GetResourceKey(un)
+			listed[key] = true
+			existing, exists := c.resources[key]
+			if exists && (existing.ResourceVersion == un.GetResourceVersion() || isStaleUpdate(existing, un)) {
+				continue
+			}
+			c.onNodeUpdated(existing, c.newResource(un))
+		}
+		for key, res := range c.resources {
+			if key.Kind != api.GroupKind.Kind || key.Group != api.GroupKind.Group || ns != "" && key.Namespace != ns || listed[key] {
+				continue
+			}
+			if isNewerThanResourceVersion(res, listResourceVersion) {
+				// the resource has been created after the list was retrieved
+				continue
+			}
+			c.onNodeRemoved(key)
+		}
+		return nil
+	})
+}
+
+// isNewerThanResourceVersion returns true if the given resource is newer than the given resource version, e.g. the
+// resource version of a list. The resource versions are only compared if both are numeric.
+func isNewerThanResourceVersion(res *Resource, resourceVersion string) bool {
+	version, err := strconv.ParseUint(resourceVersion, 10, 64)
+	if err != nil {
+		return false
+	}
+	resVersion, err := strconv.ParseUint(res.ResourceVersion, 10, 64)
+	return err == nil && resVersion > version
+}
+
+func (c *clusterCache) setWatchBookmark(gk schema.GroupKind, ns string, resourceVersion string) {
+	c.watchBookmarksLock.Lock()
+	defer c.watchBookmarksLock.Unlock()
+	c.watchBookmarks[watchKey{gk: gk, ns: ns}] = resourceVersion
+}
+
+func (c *clusterCache) getWatchBookmark(gk schema.GroupKind, ns string) string {
+	c.watchBookmarksLock.Lock()
+	defer c.watchBookmarksLock.Unlock()
+	return c.watchBookmarks[watchKey{gk: gk, ns: ns}]
+}
+
+func (c *clusterCache) setWatchSynced(gk schema.GroupKind, ns string) {
+	c.watchStatusesLock.Lock()
+	defer c.watchStatusesLock.Unlock()
+	c.watchStatuses[watchKey{gk: gk, ns: ns}] = &watchStatus{lastSyncTime: time.Now(), healthy: true}
+}
+
+func (c *clusterCache) setWatchUnhealthy(gk schema.GroupKind, ns string) {
+	c.watchStatusesLock.Lock()
+	defer c.watchStatusesLock.Unlock()
+	if status, ok := c.watchStatuses[watchKey{gk: gk, ns: ns}]; ok {
+		status.healthy = false
+	} else {
+		c.watchStatuses[watchKey{gk: gk, ns: ns}] = &watchStatus{healthy: false}
+	}
+}
+
+func (c *clusterCache) resetWatchStatuses() {
+	c.watchStatusesLock.Lock()
+	defer c.watchStatusesLock.Unlock()
+	c.watchStatuses = map[watchKey]*watchStatus{}
+}
+
+// LastSyncTime returns the time of the most recent successful list or watch event of the given resource type
+func (c *clusterCache) LastSyncTime(gvk schema.GroupVersionKind) time.Time {
+	c.watchStatusesLock.Lock()
+	defer c.watchStatusesLock.Unlock()
+	var lastSyncTime time.Time
+	found := false
+	for key, status := range c.watchStatuses {
+		if key.gk != gvk.GroupKind() {
+			continue
+		}
+		if !found || status.lastSyncTime.Before(lastSyncTime) {
+			lastSyncTime = status.lastSyncTime
+			found = true
+		}
+	}
+	return lastSyncTime
+}
+
+// WatchHealthy returns true if all watches of the given resource type are running
+func (c *clusterCache) WatchHealthy(gvk schema.GroupVersionKind) bool {
+	c.watchStatusesLock.Lock()
+	defer c.watchStatusesLock.Unlock()
+	found := false
+	for key, status := range c.watchStatuses {
+		if key.gk != gvk.GroupKind() {
+			continue
+		}
+		if !status.healthy {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+func (c *clusterCache) clearWatchBookmark(gk schema.GroupKind, ns string) {
+	c.watchBookmarksLock.Lock()
+	defer c.watchBookmarksLock.Unlock()
+	delete(c.watchBookmarks, watchKey{gk: gk, ns: ns})
+}
+
 func (c *clusterCache) watchEvents(ctx context.Context, api kube.APIResourceInfo, resClient dynamic.ResourceInterface, ns string, resourceVersion string) {
+	// bookmarks received before the resource version we start with are stale
+	c.clearWatchBookmark(api.GroupKind, ns)
 	kube.RetryUntilSucceed(ctx, watchResourcesRetryTimeout, fmt.Sprintf("watch %s on %s", api.GroupKind, c.config.Host), c.log, func() (err error) {
+		defer func() {
+			if err != nil {
+				// the cached data is stale until the watch is restarted
+				c.setWatchUnhealthy(api.GroupKind, ns)
+			}
+		}()
 		defer func() {
 			if r := recover(); r != nil {
 				err = fmt.Errorf("Recovered from panic: %+v\n%s", r, debug.Stack())
@@ -618,14 +958,32 @@ func (c *clusterCache) watchEvents(ctx context.Context, api kube.APIResourceInfo
 			if err != nil {
 				return err
 			}
+			c.clearWatchBookmark(api.GroupKind, ns)
 		}
 
 		w, err := watchutil.NewRetryWatcher(resourceVersion, &cache.ListWatch{
 			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.FieldSelector = c.listOptions(api.GroupKind).FieldSelector
 				res, err := resClient.Watch(ctx, options)
+				if err != nil && options.FieldSelector != "" && errors.IsBadRequest(err) {
+					// the field is not selectable, the events are filtered by matchesFieldSelector instead
+					options.FieldSelector = ""
+					res, err = resClient.Watch(ctx, options)
+				}
 				if errors.IsNotFound(err) {
 					c.stopWatching(api.GroupKind, ns)
 				}
+				if err == nil && c.resumeWatchFromBookmark {
+					// bookmark events are not propagated by the retry watcher, so record them before they reach it
+					res = watch.Filter(res, func(event watch.Event) (watch.Event, bool) {
+						if event.Type == watch.Bookmark {
+							if obj, ok := event.Object.(metav1.Object); ok && obj.GetResourceVersion() != "" {
+								c.setWatchBookmark(api.GroupKind, ns, obj.GetResourceVersion())
+							}
+						}
+						return event, true
+					})
+				}
 				return res, err
 			},
 		})
@@ -633,9 +991,16 @@ func (c *clusterCache) watchEvents(ctx context.Context, api kube.APIResourceInfo
 			return err
 		}
 
+		// only a watch that has been closed normally can be resumed, the resources are relisted after any failure and
+		// whenever the resync timeout expires
+		closed := false
 		defer func() {
 			w.Stop()
 			resourceVersion = ""
+			if c.resumeWatchFromBookmark && closed {
+				// resume from the most recent bookmark if any, otherwise relist
+				resourceVersion = c.getWatchBookmark(api.GroupKind, ns)
+			}
 		}()
 
 		var watchResyncTimeoutCh <-chan time.Time
@@ -645,6 +1010,13 @@ func (c *clusterCache) watchEvents(ctx context.Context, api kube.APIResourceInfo
 			watchResyncTimeoutCh = shouldResync.C
 		}
 
+		var reconcileCh <-chan time.Time
+		if c.reconcileInterval > 0 {
+			reconcile := time.NewTicker(c.reconcileInterval)
+			defer reconcile.Stop()
+			reconcileCh = reconcile.C
+		}
+
 		for {
 			select {
 			// stop watching when parent context got cancelled
@@ -655,21 +1027,41 @@ func (c *clusterCache) watchEvents(ctx context.Context, api kube.APIResourceInfo
 			case <-watchResyncTimeoutCh:
 				return fmt.Errorf("Resyncing %s on %s due to timeout", api.GroupKind, c.config.Host)
 
+			// correct the drift of the cached state caused by missed events while the watch keeps running
+			case <-reconcileCh:
+				if err := c.reconcileResources(ctx, api, resClient, ns); err != nil {
+					c.log.Error(err, "Failed to reconcile cached resources", "groupKind", api.GroupKind.String(), "namespace", ns)
+				}
+
 			// re-synchronize API state and restart watch if retry watcher failed to continue watching using provided resource version
 			case <-w.Done():
+				closed = true
 				return fmt.Errorf("Watch %s on %s has closed", api.GroupKind, c.config.Host)
 
 			case event, ok := <-w.ResultChan():
 				if !ok {
+					closed = true
 					return fmt.Errorf("Watch %s on %s has closed", api.GroupKind, c.config.Host)
 				}
 
+				// the retry watcher stops after propagating an error event (e.g. 410 Gone if the resource version
+				// is too old), so the state must be relisted
+				if event.Type == watch.Error {
+					c.clearWatchBookmark(api.GroupKind, ns)
+					return fmt.Errorf("Watch %s on %s failed: %w", api.GroupKind, c.config.Host, errors.FromObject(event.Object))
+				}
+
 				obj, ok := event.Object.(*unstructured.Unstructured)
 				if !ok {
 					return fmt.Errorf("Failed to convert to *unstructured.Unstructured: %v", event.Object)
 				}
 
+				if event.Type != watch.Deleted && !c.matchesFieldSelector(api.GroupKind, obj) {
+					// the resource stopped matching the field selector, e.g. a pod that succeeded
+					event.Type = watch.Deleted
+				}
 				c.processEvent(event.Type, obj)
+				c.setWatchSynced(api.GroupKind, ns)
 				if kube.IsCRD(obj) {
 					var resources []kube.APIResourceInfo
 					crd := v1.CustomResourceDefinition{}
@@ -715,7 +1107,7 @@ func (c *clusterCache) watchEvents(ctx context.Context, api kube.APIResourceInfo
 						}
 					}
 					err = runSynced(&c.lock, func() error {
-						openAPISchema, gvkParser, err := c.kubectl.LoadOpenAPISchema(c.config)
+						openAPISchema, gvkParser, err := c.loadOpenAPISchema()
 						if err != nil {
 							return fmt.Errorf("failed to load open api schema while handling CRD change: %w", err)
 						}
@@ -825,21 +1217,24 @@ func (c *clusterCache) sync() error {
 	}
 	c.apisMeta = make(map[schema.GroupKind]*apiMeta)
 	c.resources = make(map[kube.ResourceKey]*Resource)
+	c.labelIndex = make(map[string]map[string]map[kube.ResourceKey]*Resource)
+	c.ownerIndex = make(map[types.UID]map[kube.ResourceKey]*Resource)
+	c.resetWatchStatuses()
 	c.namespacedResources = make(map[schema.GroupKind]bool)
 	config := c.config
-	version, err := c.kubectl.GetServerVersion(config)
+	version, err := c.getServerVersion()
 
 	if err != nil {
 		return err
 	}
 	c.serverVersion = version
-	apiResources, err := c.kubectl.GetAPIResources(config, false, NewNoopSettings())
+	apiResources, err := c.getAPIResources(false, NewNoopSettings())
 	if err != nil {
 		return err
 	}
 	c.apiResources = apiResources
 
-	openAPISchema, gvkParser, err := c.kubectl.LoadOpenAPISchema(config)
+	openAPISchema, gvkParser, err := c.loadOpenAPISchema()
 	if err != nil {
 		return fmt.Errorf("failed to load open api schema while syncing cluster cache: %w", err)
 	}
@@ -850,12 +1245,12 @@ func (c *clusterCache) sync() error {
 
 	c.openAPISchema = openAPISchema
 
-	apis, err := c.kubectl.GetAPIResources(c.config, true, c.settings.ResourcesFilter)
+	apis, err := c.getAPIResources(true, c.settings.ResourcesFilter)
 
 	if err != nil {
 		return err
 	}
-	client, err := c.kubectl.NewDynamicClient(c.config)
+	client, err := c.newDynamicClient()
 	if err != nil {
 		return err
 	}
@@ -878,10 +1273,10 @@ func (c *clusterCache) sync() error {
 
 		return c.processApi(client, api, func(resClient dynamic.ResourceInterface, ns string) error {
 			resourceVersion, err := c.listResources(ctx, resClient, func(listPager *pager.ListPager) error {
-				return listPager.EachListItem(context.Background(), metav1.ListOptions{}, func(obj runtime.Object) error {
+				return listPager.EachListItem(context.Background(), c.listOptions(api.GroupKind), func(obj runtime.Object) error {
 					if un, ok := obj.(*unstructured.Unstructured); !ok {
 						return fmt.Errorf("object %s/%s has an unexpected type", un.GroupVersionKind().String(), un.GetName())
-					} else {
+					} else if c.matchesFieldSelector(api.GroupKind, un) {
 						lock.Lock()
 						c.setNode(c.newResource(un))
 						lock.Unlock()
@@ -910,6 +1305,7 @@ func (c *clusterCache) sync() error {
 				}
 				return fmt.Errorf("failed to load initial state of resource %s: %w", api.GroupKind.String(), err)
 			}
+			c.setWatchSynced(api.GroupKind, ns)
 
 			go c.watchEvents(ctx, api, resClient, ns, resourceVersion)
 
@@ -939,6 +1335,14 @@ func (c *clusterCache) EnsureSynced() error {
 	}
 	syncStatus.lock.Unlock() // release the lock, so that we can acquire the parent lock (see struct comment re: lock acquisition ordering)
 
+	synced := false
+	// notify handlers only after both locks are released
+	defer func() {
+		if synced {
+			c.notifyInitialSyncComplete()
+		}
+	}()
+
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	syncStatus.lock.Lock()
@@ -953,6 +1357,7 @@ func (c *clusterCache) EnsureSynced() error {
 	syncTime := time.Now()
 	syncStatus.syncTime = &syncTime
 	syncStatus.syncError = err
+	synced = err == nil
 	return syncStatus.syncError
 }
 
@@ -986,6 +1391,140 @@ func (c *clusterCache) FindResources(namespace string, predicates ...func(r *Res
 	return result
 }
 
+// GetResourcesByLabel returns resources from all namespaces whose labels match the given selector
+func (c *clusterCache) GetResourcesByLabel(selector labels.Selector) []*Resource {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	var result []*Resource
+	for _, resources := range c.labelIndexCandidates(selector) {
+		for _, r := range resources {
+			if selector.Matches(labels.Set(r.Labels)) {
+				result = append(result, r)
+			}
+		}
+	}
+	return result
+}
+
+// labelIndexCandidates returns the smallest set of label index buckets that contains all resources possibly matching
+// the given selector. All resources are returned if no selector requirement can be served by the index.
+func (c *clusterCache) labelIndexCandidates(selector labels.Selector) []map[kube.ResourceKey]*Resource {
+	requirements, selectable := selector.Requirements()
+	if !selectable {
+		return nil
+	}
+	var candidates []map[kube.ResourceKey]*Resource
+	candidatesSize := -1
+	for _, req := range requirements {
+		values := c.labelIndex[req.Key()]
+		var buckets []map[kube.ResourceKey]*Resource
+		switch req.Operator() {
+		case selection.Equals, selection.DoubleEquals, selection.In:
+			for _, v := range req.Values().List() {
+				if bucket, ok := values[v]; ok {
+					buckets = append(buckets, bucket)
+				}
+			}
+		case selection.Exists:
+			for _, bucket := range values {
+				buckets = append(buckets, bucket)
+			}
+		default:
+			continue
+		}
+		size := 0
+		for _, bucket := range buckets {
+			size += len(bucket)
+		}
+		if candidatesSize == -1 || size < candidatesSize {
+			candidates = buckets
+			candidatesSize = size
+		}
+	}
+	if candidatesSize == -1 {
+		return []map[kube.ResourceKey]*Resource{c.resources}
+	}
+	return candidates
+}
+
+func (c *clusterCache) addToLabelIndex(key kube.ResourceKey, r *Resource) {
+	for k, v := range r.Labels {
+		values, ok := c.labelIndex[k]
+		if !ok {
+			values = make(map[string]map[kube.ResourceKey]*Resource)
+			c.labelIndex[k] = values
+		}
+		bucket, ok := values[v]
+		if !ok {
+			bucket = make(map[kube.ResourceKey]*Resource)
+			values[v] = bucket
+		}
+		bucket[key] = r
+	}
+}
+
+func (c *clusterCache) removeFromLabelIndex(key kube.ResourceKey, r *Resource) {
+	for k, v := range r.Labels {
+		values, ok := c.labelIndex[k]
+		if !ok {
+			continue
+		}
+		if bucket, ok := values[v]; ok {
+			delete(bucket, key)
+			if len(bucket) == 0 {
+				delete(values, v)
+			}
+		}
+		if len(values) == 0 {
+			delete(c.labelIndex, k)
+		}
+	}
+}
+
+// GetChildren returns the resources whose owner references point to the resource with the given UID
+func (c *clusterCache) GetChildren(ownerUID types.UID) []*Resource {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	children := c.ownerIndex[ownerUID]
+	result := make([]*Resource, 0, len(children))
+	for _, child := range children {
+		result = append(result, child)
+	}
+	return result
+}
+
+func (c *clusterCache) addToOwnerIndex(key kube.ResourceKey, r *Resource) {
+	for _, ref := range r.OwnerRefs {
+		if ref.UID == "" {
+			continue
+		}
+		children, ok := c.ownerIndex[ref.UID]
+		if !ok {
+			children = make(map[kube.ResourceKey]*Resource)
+			c.ownerIndex[ref.UID] = children
+		}
+		children[key] = r
+	}
+}
+
+func (c *clusterCache) removeFromOwnerIndex(key kube.ResourceKey, r *Resource) {
+	for _, ref := range r.OwnerRefs {
+		if children, ok := c.ownerIndex[ref.UID]; ok {
+			delete(children, key)
+			if len(children) == 0 {
+				delete(c.ownerIndex, ref.UID)
+			}
+		}
+	}
+}
+
+// setOwnerRef adds or removes the given inferred owner reference of the resource and keeps the owner index up to date
+func (c *clusterCache) setOwnerRef(key kube.ResourceKey, r *Resource, ref metav1.OwnerReference, add bool) {
+	c.removeFromOwnerIndex(key, r)
+	r.setOwnerRef(ref, add)
+	c.addToOwnerIndex(key, r)
+}
+
 // IterateHierarchy iterates resource tree starting from the specified top level resource and executes callback for each resource in the tree
 func (c *clusterCache) IterateHierarchy(key kube.ResourceKey, action func(resource *Resource, namespaceResources map[kube.ResourceKey]*Resource) bool) {
 	c.lock.RLock()
@@ -1231,7 +1770,27 @@ func (c *clusterCache) GetManagedLiveObjs(targetObjs []*unstructured.Unstructure
 	return managedObjs, nil
 }
 
+// Pause stops applying watch events to the cache until Resume is called
+func (c *clusterCache) Pause() {
+	if !c.paused.Swap(true) {
+		c.log.Info("Paused cluster cache updates")
+	}
+}
+
+// Resume resumes applying watch events and relists all resources to reconcile the changes missed while paused
+func (c *clusterCache) Resume() error {
+	if !c.paused.Swap(false) {
+		return nil
+	}
+	c.log.Info("Resumed cluster cache updates, relisting resources")
+	c.Invalidate()
+	return c.EnsureSynced()
+}
+
 func (c *clusterCache) processEvent(event watch.EventType, un *unstructured.Unstructured) {
+	if c.paused.Load() {
+		return
+	}
 	for _, h := range c.getEventHandlers() {
 		h(event, un)
 	}
@@ -1248,10 +1807,26 @@ func (c *clusterCache) processEvent(event watch.EventType, un *unstructured.Unst
 			c.onNodeRemoved(key)
 		}
 	} else if event != watch.Deleted {
+		if exists && isStaleUpdate(existingNode, un) {
+			c.log.V(1).Info("Ignoring out-of-order update", "key", key, "resourceVersion", un.GetResourceVersion(), "cachedResourceVersion", existingNode.ResourceVersion)
+			return
+		}
 		c.onNodeUpdated(existingNode, c.newResource(un))
 	}
 }
 
+// isStaleUpdate returns true if the given object is older than the cached resource, e.g. because watch events were
+// delivered out of order after a reconnect. Resource versions are compared numerically if possible, otherwise the
+// generations of the same object are compared.
+func isStaleUpdate(existing *Resource, un *unstructured.Unstructured) bool {
+	existingVersion, existingErr := strconv.ParseUint(existing.ResourceVersion, 10, 64)
+	version, err := strconv.ParseUint(un.GetResourceVersion(), 10, 64)
+	if existingErr == nil && err == nil {
+		return version < existingVersion
+	}
+	return existing.Ref.UID == un.GetUID() && un.GetGeneration() < existing.Generation
+}
+
 func (c *clusterCache) onNodeUpdated(oldRes *Resource, newRes *Resource) {
 	c.setNode(newRes)
 	for _, h := range c.getResourceUpdatedHandlers() {
@@ -1263,6 +1838,8 @@ func (c *clusterCache) onNodeRemoved(key kube.ResourceKey) {
 	existing, ok := c.resources[key]
 	if ok {
 		delete(c.resources, key)
+		c.removeFromLabelIndex(key, existing)
+		c.removeFromOwnerIndex(key, existing)
 		ns, ok := c.nsIndex[key.Namespace]
 		if ok {
 			delete(ns, key)
@@ -1273,7 +1850,7 @@ func (c *clusterCache) onNodeRemoved(key kube.ResourceKey) {
 			if existing.isInferredParentOf != nil {
 				for k, v := range ns {
 					if mightHaveInferredOwner(v) && existing.isInferredParentOf(k) {
-						v.setOwnerRef(existing.toOwnerRef(), false)
+						c.setOwnerRef(k, v, existing.toOwnerRef(), false)
 					}
 				}
 			}
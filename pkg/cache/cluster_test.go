@@ -19,12 +19,18 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
 	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/apimachinery/pkg/watch"
+	fakedisco "k8s.io/client-go/discovery/fake"
 	"k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
@@ -183,6 +189,117 @@ func TestEnsureSynced(t *testing.T) {
 	assert.ElementsMatch(t, []string{"helm-guestbook1", "helm-guestbook2"}, names)
 }
 
+func TestOnInitialSyncComplete(t *testing.T) {
+	cluster := newCluster(t, testPod1())
+	calls := 0
+	var resources map[kube.ResourceKey]*Resource
+	cluster.OnInitialSyncComplete(func() {
+		calls++
+		// the cache must be unlocked when the handler is executed
+		resources = cluster.FindResources("")
+	})
+
+	require.NoError(t, cluster.EnsureSynced())
+	assert.Equal(t, 1, calls)
+	assert.Len(t, resources, 1)
+
+	require.NoError(t, cluster.EnsureSynced())
+	cluster.Invalidate()
+	require.NoError(t, cluster.EnsureSynced())
+	assert.Equal(t, 1, calls)
+
+	lateCalls := 0
+	cluster.OnInitialSyncComplete(func() {
+		lateCalls++
+	})
+	assert.Equal(t, 1, lateCalls)
+	assert.Equal(t, 1, calls)
+}
+
+func TestGetChildrenByOwnerUID(t *testing.T) {
+	cluster := newCluster(t)
+	newResource := func(name string, ownerUIDs ...types.UID) *Resource {
+		r := &Resource{Ref: v1.ObjectReference{APIVersion: "v1", Kind: kube.PodKind, Namespace: "default", Name: name}}
+		for _, uid := range ownerUIDs {
+			r.OwnerRefs = append(r.OwnerRefs, metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: string(uid), UID: uid})
+		}
+		return r
+	}
+	getNames := func(ownerUID types.UID) []string {
+		names := []string{}
+		for _, r := range cluster.GetChildren(ownerUID) {
+			names = append(names, r.Ref.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	cluster.setNode(newResource("a", "rs-1"))
+	cluster.setNode(newResource("b", "rs-1", "rs-2"))
+	cluster.setNode(newResource("c"))
+
+	assert.Equal(t, []string{"a", "b"}, getNames("rs-1"))
+	assert.Equal(t, []string{"b"}, getNames("rs-2"))
+	assert.Equal(t, []string{}, getNames("rs-3"))
+
+	t.Run("UpdatedOwnerReferences", func(t *testing.T) {
+		cluster.setNode(newResource("a", "rs-2"))
+		assert.Equal(t, []string{"b"}, getNames("rs-1"))
+		assert.Equal(t, []string{"a", "b"}, getNames("rs-2"))
+	})
+
+	t.Run("RemovedResource", func(t *testing.T) {
+		cluster.onNodeRemoved(kube.NewResourceKey("", kube.PodKind, "default", "b"))
+		assert.Equal(t, []string{}, getNames("rs-1"))
+		assert.Equal(t, []string{"a"}, getNames("rs-2"))
+		assert.NotContains(t, cluster.ownerIndex, types.UID("rs-1"))
+	})
+}
+
+func TestGetResourcesByLabel(t *testing.T) {
+	cluster := newCluster(t)
+	newResource := func(namespace, name string, resourceLabels map[string]string) *Resource {
+		return &Resource{
+			Ref:    v1.ObjectReference{APIVersion: "v1", Kind: kube.PodKind, Namespace: namespace, Name: name},
+			Labels: resourceLabels,
+		}
+	}
+	getNames := func(selector string) []string {
+		sel, err := labels.Parse(selector)
+		require.NoError(t, err)
+		names := []string{}
+		for _, r := range cluster.GetResourcesByLabel(sel) {
+			names = append(names, r.Ref.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	cluster.setNode(newResource("ns1", "a", map[string]string{"app": "foo", "tier": "web"}))
+	cluster.setNode(newResource("ns2", "b", map[string]string{"app": "foo", "tier": "db"}))
+	cluster.setNode(newResource("ns1", "c", map[string]string{"app": "bar"}))
+
+	assert.Equal(t, []string{"a", "b"}, getNames("app=foo"))
+	assert.Equal(t, []string{"b"}, getNames("app=foo,tier=db"))
+	assert.Equal(t, []string{"a", "b", "c"}, getNames("app in (foo,bar)"))
+	assert.Equal(t, []string{"a", "b"}, getNames("tier"))
+	assert.Equal(t, []string{"c"}, getNames("app!=foo"))
+	assert.Equal(t, []string{}, getNames("app=baz"))
+
+	t.Run("UpdatedLabels", func(t *testing.T) {
+		cluster.setNode(newResource("ns1", "a", map[string]string{"app": "bar"}))
+		assert.Equal(t, []string{"b"}, getNames("app=foo"))
+		assert.Equal(t, []string{"a", "c"}, getNames("app=bar"))
+		assert.Equal(t, []string{"b"}, getNames("tier"))
+	})
+
+	t.Run("RemovedResource", func(t *testing.T) {
+		cluster.onNodeRemoved(kube.NewResourceKey("", kube.PodKind, "ns2", "b"))
+		assert.Equal(t, []string{}, getNames("app=foo"))
+		assert.NotContains(t, cluster.labelIndex, "tier")
+	})
+}
+
 func TestStatefulSetOwnershipInferred(t *testing.T) {
 	sts := &appsv1.StatefulSet{
 		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: kube.StatefulSetKind},
@@ -662,6 +779,58 @@ func TestProcessNewChildEvent(t *testing.T) {
 	}}, rsChildren)
 }
 
+func TestProcessOutOfOrderEvent(t *testing.T) {
+	cluster := newCluster(t, testPod1())
+	require.NoError(t, cluster.EnsureSynced())
+	newPod := func(resourceVersion string, generation int64) *unstructured.Unstructured {
+		pod := mustToUnstructured(testPod1())
+		pod.SetResourceVersion(resourceVersion)
+		pod.SetGeneration(generation)
+		return pod
+	}
+	key := kube.GetResourceKey(mustToUnstructured(testPod1()))
+
+	t.Run("NumericResourceVersion", func(t *testing.T) {
+		cluster.processEvent(watch.Modified, newPod("200", 0))
+		cluster.processEvent(watch.Modified, newPod("150", 0))
+		assert.Equal(t, "200", cluster.resources[key].ResourceVersion)
+
+		cluster.processEvent(watch.Modified, newPod("201", 0))
+		assert.Equal(t, "201", cluster.resources[key].ResourceVersion)
+	})
+
+	t.Run("Generation", func(t *testing.T) {
+		cluster.processEvent(watch.Modified, newPod("b", 3))
+		cluster.processEvent(watch.Modified, newPod("a", 2))
+		assert.Equal(t, "b", cluster.resources[key].ResourceVersion)
+		assert.Equal(t, int64(3), cluster.resources[key].Generation)
+	})
+}
+
+func TestPauseAndResume(t *testing.T) {
+	cluster := newCluster(t, testPod1())
+	require.NoError(t, cluster.EnsureSynced())
+	pod := mustToUnstructured(testPod1())
+	key := kube.GetResourceKey(pod)
+
+	cluster.Pause()
+	// the pod is deleted while updates are paused
+	client := cluster.kubectl.(*kubetest.MockKubectlCmd).DynamicClient
+	err := client.Resource(schema.GroupVersionResource{Version: "v1", Resource: "pods"}).Namespace(pod.GetNamespace()).Delete(context.Background(), pod.GetName(), metav1.DeleteOptions{})
+	require.NoError(t, err)
+	cluster.processEvent(watch.Deleted, pod)
+	cluster.lock.RLock()
+	_, exists := cluster.resources[key]
+	cluster.lock.RUnlock()
+	assert.True(t, exists, "update must be suppressed while paused")
+
+	require.NoError(t, cluster.Resume())
+	cluster.lock.RLock()
+	_, exists = cluster.resources[key]
+	cluster.lock.RUnlock()
+	assert.False(t, exists, "missed update must be reconciled on resume")
+}
+
 func TestWatchCacheUpdated(t *testing.T) {
 	removed := testPod1()
 	removed.SetName(removed.GetName() + "-removed-pod")
@@ -1252,6 +1421,356 @@ func Test_watchEvents_Deadlock(t *testing.T) {
 	}
 }
 
+func TestWatchResumeFromBookmark(t *testing.T) {
+	cluster := newClusterWithOptions(t, []UpdateSettingsFunc{
+		SetResumeWatchFromBookmark(true),
+		SetWatchResyncTimeout(3 * time.Second),
+	}, testPod1())
+	defer cluster.Invalidate()
+	client := cluster.kubectl.(*kubetest.MockKubectlCmd).DynamicClient.(*fake.FakeDynamicClient)
+
+	var lock sync.Mutex
+	podLists := 0
+	var watchVersions []string
+	watchers := make(chan *watch.FakeWatcher, 10)
+	client.PrependReactor("list", "pods", func(action testcore.Action) (bool, runtime.Object, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		podLists++
+		return false, nil, nil
+	})
+	client.PrependWatchReactor("pods", func(action testcore.Action) (bool, watch.Interface, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		watchVersions = append(watchVersions, action.(testcore.WatchAction).GetWatchRestrictions().ResourceVersion)
+		w := watch.NewFakeWithChanSize(10, false)
+		watchers <- w
+		return true, w, nil
+	})
+	nextWatcher := func() *watch.FakeWatcher {
+		select {
+		case w := <-watchers:
+			return w
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for pods watch")
+			return nil
+		}
+	}
+	assertState := func(expectedLists int, expectedVersions []string) {
+		lock.Lock()
+		defer lock.Unlock()
+		assert.Equal(t, expectedLists, podLists)
+		assert.Equal(t, expectedVersions, watchVersions)
+	}
+
+	err := cluster.EnsureSynced()
+	require.NoError(t, err)
+
+	// the watch is closed and resumed from the bookmark
+	w := nextWatcher()
+	bookmark := &unstructured.Unstructured{}
+	bookmark.SetAPIVersion("v1")
+	bookmark.SetKind("Pod")
+	bookmark.SetResourceVersion("200")
+	w.Action(watch.Bookmark, bookmark)
+	w.Stop()
+
+	_ = nextWatcher()
+	assertState(1, []string{"123", "200"})
+
+	// the watch is restarted due to the resync timeout, so the resources are relisted despite the bookmark
+	w = nextWatcher()
+	assertState(2, []string{"123", "200", "123"})
+
+	// the bookmark is too old, so the resources are relisted
+	bookmark.SetResourceVersion("300")
+	w.Action(watch.Bookmark, bookmark)
+	status := apierrors.NewResourceExpired("too old resource version").ErrStatus
+	w.Error(&status)
+
+	_ = nextWatcher()
+	assertState(3, []string{"123", "200", "123", "123"})
+}
+
+func TestFieldSelectors(t *testing.T) {
+	running := testPod1()
+	running.Status.Phase = corev1.PodRunning
+	succeeded := testPod2()
+	succeeded.Status.Phase = corev1.PodSucceeded
+	podGK := schema.GroupKind{Kind: kube.PodKind}
+	cluster := newClusterWithOptions(t, []UpdateSettingsFunc{
+		SetFieldSelectors(map[schema.GroupKind]fields.Selector{podGK: fields.OneTermNotEqualSelector("status.phase", string(corev1.PodSucceeded))}),
+	}, running, succeeded)
+	defer cluster.Invalidate()
+	client := cluster.kubectl.(*kubetest.MockKubectlCmd).DynamicClient.(*fake.FakeDynamicClient)
+
+	var lock sync.Mutex
+	var listSelectors, watchSelectors []string
+	watchers := make(chan *watch.FakeWatcher, 10)
+	client.PrependReactor("list", "pods", func(action testcore.Action) (bool, runtime.Object, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		selector := action.(testcore.ListAction).GetListRestrictions().Fields.String()
+		listSelectors = append(listSelectors, selector)
+		if selector != "" {
+			return true, nil, apierrors.NewBadRequest("field label not supported: status.phase")
+		}
+		return false, nil, nil
+	})
+	client.PrependWatchReactor("pods", func(action testcore.Action) (bool, watch.Interface, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		watchSelectors = append(watchSelectors, action.(testcore.WatchAction).GetWatchRestrictions().Fields.String())
+		w := watch.NewFakeWithChanSize(10, false)
+		watchers <- w
+		return true, w, nil
+	})
+
+	err := cluster.EnsureSynced()
+	require.NoError(t, err)
+	hasPod := func(pod *corev1.Pod) bool {
+		cluster.lock.RLock()
+		defer cluster.lock.RUnlock()
+		_, ok := cluster.resources[kube.GetResourceKey(mustToUnstructured(pod))]
+		return ok
+	}
+	// the API server rejects the field selector, so the listed pods are filtered by the cache
+	assert.True(t, hasPod(running))
+	assert.False(t, hasPod(succeeded))
+	lock.Lock()
+	assert.Equal(t, []string{"status.phase!=Succeeded", ""}, listSelectors)
+	lock.Unlock()
+
+	var w *watch.FakeWatcher
+	select {
+	case w = <-watchers:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for pods watch")
+	}
+	lock.Lock()
+	assert.Equal(t, []string{"status.phase!=Succeeded"}, watchSelectors)
+	lock.Unlock()
+
+	// a pod that stops matching the selector is removed and pods that do not match are never added
+	added := testPod1()
+	added.SetName("helm-guestbook-pod-3")
+	added.Status.Phase = corev1.PodSucceeded
+	w.Add(mustToUnstructured(added))
+	completed := running.DeepCopy()
+	completed.SetResourceVersion("124")
+	completed.Status.Phase = corev1.PodSucceeded
+	w.Modify(mustToUnstructured(completed))
+	assert.Eventually(t, func() bool {
+		return !hasPod(running)
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.False(t, hasPod(added))
+}
+
+func TestReconcileInterval(t *testing.T) {
+	cluster := newClusterWithOptions(t, []UpdateSettingsFunc{SetReconcileInterval(50 * time.Millisecond)}, testPod1())
+	defer cluster.Invalidate()
+	client := cluster.kubectl.(*kubetest.MockKubectlCmd).DynamicClient.(*fake.FakeDynamicClient)
+	// the watch never delivers any event, so the cache is only updated by the reconciliation
+	client.PrependWatchReactor("pods", func(_ testcore.Action) (bool, watch.Interface, error) {
+		return true, watch.NewFakeWithChanSize(10, false), nil
+	})
+
+	var lock sync.Mutex
+	updated := map[kube.ResourceKey]bool{}
+	removed := map[kube.ResourceKey]bool{}
+	cluster.OnResourceUpdated(func(newRes *Resource, oldRes *Resource, _ map[kube.ResourceKey]*Resource) {
+		lock.Lock()
+		defer lock.Unlock()
+		if newRes == nil {
+			removed[oldRes.ResourceKey()] = true
+		} else {
+			updated[newRes.ResourceKey()] = true
+		}
+	})
+	require.NoError(t, cluster.EnsureSynced())
+
+	pod1Key := kube.GetResourceKey(mustToUnstructured(testPod1()))
+	pod2Key := kube.GetResourceKey(mustToUnstructured(testPod2()))
+	podGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	require.NoError(t, client.Tracker().Delete(podGVR, pod1Key.Namespace, pod1Key.Name))
+	require.NoError(t, client.Tracker().Create(podGVR, mustToUnstructured(testPod2()), pod2Key.Namespace))
+
+	hasPod := func(key kube.ResourceKey) bool {
+		cluster.lock.RLock()
+		defer cluster.lock.RUnlock()
+		_, ok := cluster.resources[key]
+		return ok
+	}
+	assert.Eventually(t, func() bool {
+		return !hasPod(pod1Key) && hasPod(pod2Key)
+	}, 5*time.Second, 10*time.Millisecond)
+	lock.Lock()
+	defer lock.Unlock()
+	assert.True(t, removed[pod1Key])
+	assert.True(t, updated[pod2Key])
+}
+
+func TestReconcileResourcesKeepsNewerResources(t *testing.T) {
+	cluster := newCluster(t, testPod1())
+	require.NoError(t, cluster.EnsureSynced())
+	client := cluster.kubectl.(*kubetest.MockKubectlCmd).DynamicClient.(*fake.FakeDynamicClient)
+	podGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	pod1 := mustToUnstructured(testPod1())
+	pod2 := mustToUnstructured(testPod2())
+	pod3 := mustToUnstructured(testPod2())
+	pod3.SetName("pod3")
+	pod3.SetUID("5")
+	pod3.SetResourceVersion("100")
+
+	// the list is retrieved at resource version 150 while newer watch events are processed
+	client.PrependReactor("list", "pods", func(action testcore.Action) (bool, runtime.Object, error) {
+		list := &unstructured.UnstructuredList{Object: map[string]interface{}{"apiVersion": "v1", "kind": "PodList"}}
+		list.SetResourceVersion("150")
+		list.Items = []unstructured.Unstructured{*pod1, *pod3}
+		return true, list, nil
+	})
+	updatedPod1 := pod1.DeepCopy()
+	updatedPod1.SetResourceVersion("200")
+	cluster.processEvent(watch.Modified, updatedPod1)
+	createdPod2 := pod2.DeepCopy()
+	createdPod2.SetResourceVersion("201")
+	cluster.processEvent(watch.Added, createdPod2)
+
+	api := kube.APIResourceInfo{
+		GroupKind:            schema.GroupKind{Kind: kube.PodKind},
+		GroupVersionResource: podGVR,
+		Meta:                 metav1.APIResource{Namespaced: true},
+	}
+	require.NoError(t, cluster.reconcileResources(context.Background(), api, client.Resource(podGVR), ""))
+
+	cluster.lock.RLock()
+	defer cluster.lock.RUnlock()
+	// the list contains an older version of pod1
+	require.Contains(t, cluster.resources, kube.GetResourceKey(pod1))
+	assert.Equal(t, "200", cluster.resources[kube.GetResourceKey(pod1)].ResourceVersion)
+	// pod2 has been created after the list was retrieved
+	require.Contains(t, cluster.resources, kube.GetResourceKey(pod2))
+	assert.Equal(t, "201", cluster.resources[kube.GetResourceKey(pod2)].ResourceVersion)
+	// pod3 was missing from the cache
+	assert.Contains(t, cluster.resources, kube.GetResourceKey(pod3))
+}
+
+func TestWatchStaleness(t *testing.T) {
+	cluster := newCluster(t, testPod1())
+	client := cluster.kubectl.(*kubetest.MockKubectlCmd).DynamicClient.(*fake.FakeDynamicClient)
+	podGVK := schema.GroupVersionKind{Version: "v1", Kind: kube.PodKind}
+
+	var lock sync.Mutex
+	listFails := false
+	watchers := make(chan *watch.FakeWatcher, 10)
+	client.PrependReactor("list", "pods", func(action testcore.Action) (bool, runtime.Object, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if listFails {
+			return true, nil, fmt.Errorf("connection refused")
+		}
+		return false, nil, nil
+	})
+	client.PrependWatchReactor("pods", func(action testcore.Action) (bool, watch.Interface, error) {
+		w := watch.NewFakeWithChanSize(10, false)
+		watchers <- w
+		return true, w, nil
+	})
+
+	assert.False(t, cluster.WatchHealthy(podGVK))
+	assert.True(t, cluster.LastSyncTime(podGVK).IsZero())
+
+	require.NoError(t, cluster.EnsureSynced())
+	var w *watch.FakeWatcher
+	select {
+	case w = <-watchers:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for pods watch")
+	}
+	assert.True(t, cluster.WatchHealthy(podGVK))
+	listedAt := cluster.LastSyncTime(podGVK)
+	assert.False(t, listedAt.IsZero())
+
+	// watch events refresh the last sync time
+	w.Modify(mustToUnstructured(testPod1()))
+	assert.Eventually(t, func() bool {
+		return cluster.LastSyncTime(podGVK).After(listedAt)
+	}, 5*time.Second, 10*time.Millisecond)
+	eventAt := cluster.LastSyncTime(podGVK)
+
+	// the watch is down and the resources cannot be relisted, so the data is stale
+	lock.Lock()
+	listFails = true
+	lock.Unlock()
+	status := apierrors.NewResourceExpired("too old resource version").ErrStatus
+	w.Error(&status)
+	assert.Eventually(t, func() bool {
+		return !cluster.WatchHealthy(podGVK)
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, eventAt, cluster.LastSyncTime(podGVK))
+	assert.True(t, cluster.WatchHealthy(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: kube.DeploymentKind}))
+}
+
+func TestClusterCacheWithFakeClients(t *testing.T) {
+	client := fake.NewSimpleDynamicClient(scheme.Scheme, testPod1())
+	reactor := client.ReactionChain[0]
+	client.PrependReactor("list", "*", func(action testcore.Action) (handled bool, ret runtime.Object, err error) {
+		handled, ret, err = reactor.React(action)
+		if err != nil || !handled {
+			return
+		}
+		// make sure list response have resource version
+		ret.(metav1.ListInterface).SetResourceVersion("123")
+		return
+	})
+	watchReactor := client.WatchReactionChain[0]
+	watchStarted := make(chan struct{}, 1)
+	client.PrependWatchReactor("*", func(action testcore.Action) (bool, watch.Interface, error) {
+		handled, w, err := watchReactor.React(action)
+		select {
+		case watchStarted <- struct{}{}:
+		default:
+		}
+		return handled, w, err
+	})
+	disco := &fakedisco.FakeDiscovery{
+		Fake: &testcore.Fake{Resources: []*metav1.APIResourceList{{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "watch"}}},
+		}}},
+		FakedServerVersion: &version.Info{Major: "1", Minor: "30"},
+	}
+	cluster := NewClusterCache(&rest.Config{Host: "https://test"}, SetDynamicClient(client), SetDiscoveryClient(disco))
+	t.Cleanup(func() { cluster.Invalidate() })
+
+	require.NoError(t, cluster.EnsureSynced())
+	assert.Equal(t, "1.30", cluster.GetServerVersion())
+	require.Len(t, cluster.GetAPIResources(), 1)
+	assert.Contains(t, cluster.FindResources("default"), kube.GetResourceKey(mustToUnstructured(testPod1())))
+	// the fake client only notifies watches that are already running
+	select {
+	case <-watchStarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for pods watch")
+	}
+
+	podGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	pod2 := mustToUnstructured(testPod2())
+	_, err := client.Resource(podGVR).Namespace("default").Create(context.Background(), pod2, metav1.CreateOptions{})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_, ok := cluster.FindResources("default")[kube.GetResourceKey(pod2)]
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	err = client.Resource(podGVR).Namespace("default").Delete(context.Background(), testPod1().Name, metav1.DeleteOptions{})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_, ok := cluster.FindResources("default")[kube.GetResourceKey(mustToUnstructured(testPod1()))]
+		return !ok
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
 func buildTestResourceMap() map[kube.ResourceKey]*Resource {
 	ns := make(map[kube.ResourceKey]*Resource)
 	for i := 0; i < 100000; i++ {
@@ -1313,6 +1832,61 @@ func BenchmarkIterateHierarchyV2(b *testing.B) {
 	}
 }
 
+func BenchmarkGetResourcesByLabel(b *testing.B) {
+	cluster := newCluster(b)
+	for i := 0; i < 10000; i++ {
+		cluster.setNode(&Resource{
+			Ref:    v1.ObjectReference{APIVersion: "v1", Kind: kube.PodKind, Namespace: "default", Name: fmt.Sprintf("test-%d", i)},
+			Labels: map[string]string{"app": fmt.Sprintf("app-%d", i%100)},
+		})
+	}
+	selector := labels.SelectorFromSet(labels.Set{"app": "app-1"})
+
+	b.Run("Indexed", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			cluster.GetResourcesByLabel(selector)
+		}
+	})
+
+	b.Run("FullScan", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			cluster.FindResources("", func(r *Resource) bool {
+				return selector.Matches(labels.Set(r.Labels))
+			})
+		}
+	})
+}
+
+func BenchmarkGetChildren(b *testing.B) {
+	cluster := newCluster(b)
+	for i := 0; i < 10000; i++ {
+		cluster.setNode(&Resource{
+			Ref:       v1.ObjectReference{APIVersion: "v1", Kind: kube.PodKind, Namespace: "default", Name: fmt.Sprintf("test-%d", i)},
+			OwnerRefs: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: fmt.Sprintf("rs-%d", i%100), UID: types.UID(fmt.Sprintf("rs-%d", i%100))}},
+		})
+	}
+	ownerUID := types.UID("rs-1")
+
+	b.Run("Indexed", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			cluster.GetChildren(ownerUID)
+		}
+	})
+
+	b.Run("FullScan", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			cluster.FindResources("", func(r *Resource) bool {
+				for _, ref := range r.OwnerRefs {
+					if ref.UID == ownerUID {
+						return true
+					}
+				}
+				return false
+			})
+		}
+	})
+}
+
 //func BenchmarkIterateHierarchy(b *testing.B) {
 //	cluster := newCluster(b)
 //	for _, resource := range testResources {
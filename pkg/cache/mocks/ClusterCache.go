@@ -6,6 +6,8 @@ import (
 	cache "github.com/argoproj/gitops-engine/pkg/cache"
 	kube "github.com/argoproj/gitops-engine/pkg/utils/kube"
 
+	labels "k8s.io/apimachinery/pkg/labels"
+
 	managedfields "k8s.io/apimachinery/pkg/util/managedfields"
 
 	mock "github.com/stretchr/testify/mock"
@@ -14,6 +16,10 @@ import (
 
 	schema "k8s.io/apimachinery/pkg/runtime/schema"
 
+	time "time"
+
+	types "k8s.io/apimachinery/pkg/types"
+
 	unstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
@@ -87,6 +93,26 @@ func (_m *ClusterCache) GetAPIResources() []kube.APIResourceInfo {
 	return r0
 }
 
+// GetChildren provides a mock function with given fields: ownerUID
+func (_m *ClusterCache) GetChildren(ownerUID types.UID) []*cache.Resource {
+	ret := _m.Called(ownerUID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetChildren")
+	}
+
+	var r0 []*cache.Resource
+	if rf, ok := ret.Get(0).(func(types.UID) []*cache.Resource); ok {
+		r0 = rf(ownerUID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*cache.Resource)
+		}
+	}
+
+	return r0
+}
+
 // GetClusterInfo provides a mock function with given fields:
 func (_m *ClusterCache) GetClusterInfo() cache.ClusterInfo {
 	ret := _m.Called()
@@ -175,6 +201,26 @@ func (_m *ClusterCache) GetOpenAPISchema() openapi.Resources {
 	return r0
 }
 
+// GetResourcesByLabel provides a mock function with given fields: selector
+func (_m *ClusterCache) GetResourcesByLabel(selector labels.Selector) []*cache.Resource {
+	ret := _m.Called(selector)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetResourcesByLabel")
+	}
+
+	var r0 []*cache.Resource
+	if rf, ok := ret.Get(0).(func(labels.Selector) []*cache.Resource); ok {
+		r0 = rf(selector)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*cache.Resource)
+		}
+	}
+
+	return r0
+}
+
 // GetServerVersion provides a mock function with given fields:
 func (_m *ClusterCache) GetServerVersion() string {
 	ret := _m.Called()
@@ -242,6 +288,24 @@ func (_m *ClusterCache) IterateHierarchyV2(keys []kube.ResourceKey, action func(
 	_m.Called(keys, action)
 }
 
+// LastSyncTime provides a mock function with given fields: gvk
+func (_m *ClusterCache) LastSyncTime(gvk schema.GroupVersionKind) time.Time {
+	ret := _m.Called(gvk)
+
+	if len(ret) == 0 {
+		panic("no return value specified for LastSyncTime")
+	}
+
+	var r0 time.Time
+	if rf, ok := ret.Get(0).(func(schema.GroupVersionKind) time.Time); ok {
+		r0 = rf(gvk)
+	} else {
+		r0 = ret.Get(0).(time.Time)
+	}
+
+	return r0
+}
+
 // OnEvent provides a mock function with given fields: handler
 func (_m *ClusterCache) OnEvent(handler cache.OnEventHandler) cache.Unsubscribe {
 	ret := _m.Called(handler)
@@ -262,6 +326,26 @@ func (_m *ClusterCache) OnEvent(handler cache.OnEventHandler) cache.Unsubscribe
 	return r0
 }
 
+// OnInitialSyncComplete provides a mock function with given fields: handler
+func (_m *ClusterCache) OnInitialSyncComplete(handler func()) cache.Unsubscribe {
+	ret := _m.Called(handler)
+
+	if len(ret) == 0 {
+		panic("no return value specified for OnInitialSyncComplete")
+	}
+
+	var r0 cache.Unsubscribe
+	if rf, ok := ret.Get(0).(func(func()) cache.Unsubscribe); ok {
+		r0 = rf(handler)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(cache.Unsubscribe)
+		}
+	}
+
+	return r0
+}
+
 // OnResourceUpdated provides a mock function with given fields: handler
 func (_m *ClusterCache) OnResourceUpdated(handler cache.OnResourceUpdatedHandler) cache.Unsubscribe {
 	ret := _m.Called(handler)
@@ -282,6 +366,47 @@ func (_m *ClusterCache) OnResourceUpdated(handler cache.OnResourceUpdatedHandler
 	return r0
 }
 
+// Pause provides a mock function with given fields:
+func (_m *ClusterCache) Pause() {
+	_m.Called()
+}
+
+// Resume provides a mock function with given fields:
+func (_m *ClusterCache) Resume() error {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Resume")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// WatchHealthy provides a mock function with given fields: gvk
+func (_m *ClusterCache) WatchHealthy(gvk schema.GroupVersionKind) bool {
+	ret := _m.Called(gvk)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WatchHealthy")
+	}
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(schema.GroupVersionKind) bool); ok {
+		r0 = rf(gvk)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // NewClusterCache creates a new instance of ClusterCache. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewClusterCache(t interface {
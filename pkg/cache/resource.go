@@ -15,15 +15,19 @@ import (
 type Resource struct {
 	// ResourceVersion holds most recent observed resource version
 	ResourceVersion string
+	// Generation holds most recent observed generation
+	Generation int64
 	// Resource reference
 	Ref v1.ObjectReference
+	// Resource labels
+	Labels map[string]string
 	// References to resource owners
 	OwnerRefs []metav1.OwnerReference
 	// Optional creation timestamp of the resource
 	CreationTimestamp *metav1.Time
 	// Optional additional information about the resource
 	Info interface{}
-	// Optional whole resource manifest
+	// Optional whole resource manifest, or its projection if a resource transformer is set using SetResourceTransformer
 	Resource *unstructured.Unstructured
 
 	// answers if resource is inferred parent of provided resource
@@ -5,6 +5,10 @@ import (
 
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
 
 	"github.com/argoproj/gitops-engine/pkg/health"
@@ -45,6 +49,23 @@ func SetKubectl(kubectl kube.Kubectl) UpdateSettingsFunc {
 	}
 }
 
+// SetDynamicClient allows to override the client used to list and watch resources. By default the client is created
+// by the kubectl wrapper from the cluster config. Useful for testing with k8s.io/client-go/dynamic/fake.
+func SetDynamicClient(client dynamic.Interface) UpdateSettingsFunc {
+	return func(cache *clusterCache) {
+		cache.dynamicClient = client
+	}
+}
+
+// SetDiscoveryClient allows to override the client used to discover the server version, API resources and OpenAPI
+// schema. By default the kubectl wrapper discovers them using the cluster config. Useful for testing with
+// k8s.io/client-go/discovery/fake.
+func SetDiscoveryClient(disco discovery.DiscoveryInterface) UpdateSettingsFunc {
+	return func(cache *clusterCache) {
+		cache.discoveryClient = disco
+	}
+}
+
 // SetPopulateResourceInfoHandler updates handler that populates resource info
 func SetPopulateResourceInfoHandler(handler OnPopulateResourceInfoHandler) UpdateSettingsFunc {
 	return func(cache *clusterCache) {
@@ -52,6 +73,26 @@ func SetPopulateResourceInfoHandler(handler OnPopulateResourceInfoHandler) Updat
 	}
 }
 
+// SetResourceTransformer sets a transformer that is applied to the manifests before they are stored in the cache, e.g.
+// to reduce the memory usage by keeping only the needed fields. The populate resource info handler still receives the
+// whole resource. See MetadataAndStatusOnly and DropManagedFields.
+func SetResourceTransformer(transformer ResourceTransformer) UpdateSettingsFunc {
+	return func(cache *clusterCache) {
+		cache.resourceTransformer = transformer
+	}
+}
+
+// SetFieldSelectors restricts the cached resources of the given kinds to the resources matching the field selector of
+// the kind, e.g. status.phase!=Succeeded for pods, to reduce the memory used for high-churn kinds. The selectors are
+// sent with the list and watch requests and are also evaluated against the received objects, so resources are filtered
+// even if the API server does not support selecting the field, in which case the requests are repeated without the
+// selector. A resource that stops matching the selector is removed from the cache.
+func SetFieldSelectors(selectors map[schema.GroupKind]fields.Selector) UpdateSettingsFunc {
+	return func(cache *clusterCache) {
+		cache.fieldSelectors = selectors
+	}
+}
+
 // SetSettings updates caching settings
 func SetSettings(settings Settings) UpdateSettingsFunc {
 	return func(cache *clusterCache) {
@@ -120,6 +161,25 @@ func SetWatchResyncTimeout(timeout time.Duration) UpdateSettingsFunc {
 	}
 }
 
+// SetReconcileInterval sets the interval of the periodic reconciliation of the cached resources against a full list of
+// each watched resource type. The reconciliation corrects the drift caused by missed watch events without restarting
+// the watch or dropping the cache: missing resources are added, stale resources are updated and deleted resources are
+// removed, and the resource updated handlers are notified about every correction. Zero disables the reconciliation.
+func SetReconcileInterval(interval time.Duration) UpdateSettingsFunc {
+	return func(cache *clusterCache) {
+		cache.reconcileInterval = interval
+	}
+}
+
+// SetResumeWatchFromBookmark specifies if watches that have been closed normally should resume from the most recently
+// received bookmark instead of relisting resources. The resources are still relisted if the watch fails, e.g. because
+// the bookmark is too old (410 Gone), and whenever the watch resync timeout expires.
+func SetResumeWatchFromBookmark(enabled bool) UpdateSettingsFunc {
+	return func(cache *clusterCache) {
+		cache.resumeWatchFromBookmark = enabled
+	}
+}
+
 // SetClusterSyncRetryTimeout updates cluster sync retry timeout when sync error happens
 func SetClusterSyncRetryTimeout(timeout time.Duration) UpdateSettingsFunc {
 	return func(cache *clusterCache) {
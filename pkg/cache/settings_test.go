@@ -55,3 +55,20 @@ func TestSetWatchResyncTimeout(t *testing.T) {
 	cache = NewClusterCache(&rest.Config{}, SetWatchResyncTimeout(timeout))
 	assert.Equal(t, timeout, cache.watchResyncTimeout)
 }
+
+func TestSetReconcileInterval(t *testing.T) {
+	cache := NewClusterCache(&rest.Config{})
+	assert.Zero(t, cache.reconcileInterval)
+
+	interval := 10 * time.Minute
+	cache = NewClusterCache(&rest.Config{}, SetReconcileInterval(interval))
+	assert.Equal(t, interval, cache.reconcileInterval)
+}
+
+func TestSetResumeWatchFromBookmark(t *testing.T) {
+	cache := NewClusterCache(&rest.Config{})
+	assert.False(t, cache.resumeWatchFromBookmark)
+
+	cache = NewClusterCache(&rest.Config{}, SetResumeWatchFromBookmark(true))
+	assert.True(t, cache.resumeWatchFromBookmark)
+}
@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// MetadataAndStatusOnly is a resource transformer that keeps only the type, metadata and status of the resources
+func MetadataAndStatusOnly(un *unstructured.Unstructured) *unstructured.Unstructured {
+	res := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	for _, field := range []string{"apiVersion", "kind", "metadata", "status"} {
+		if val, ok := un.Object[field]; ok {
+			res.Object[field] = val
+		}
+	}
+	return res
+}
+
+// DropManagedFields is a resource transformer that removes metadata.managedFields from the resources
+func DropManagedFields(un *unstructured.Unstructured) *unstructured.Unstructured {
+	metadata, ok := un.Object["metadata"].(map[string]interface{})
+	if !ok {
+		return un
+	}
+	if _, ok := metadata["managedFields"]; !ok {
+		return un
+	}
+	// the given resource must not be modified, so only the modified maps are copied
+	res := &unstructured.Unstructured{Object: make(map[string]interface{}, len(un.Object))}
+	for k, v := range un.Object {
+		res.Object[k] = v
+	}
+	resMetadata := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		if k != "managedFields" {
+			resMetadata[k] = v
+		}
+	}
+	res.Object["metadata"] = resMetadata
+	return res
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/argoproj/gitops-engine/pkg/utils/kube"
+)
+
+func TestResourceTransformer(t *testing.T) {
+	pod := mustToUnstructured(testPod1())
+	require.NoError(t, unstructured.SetNestedField(pod.Object, "Running", "status", "phase"))
+	var handled *unstructured.Unstructured
+	cluster := newClusterWithOptions(t, []UpdateSettingsFunc{
+		SetPopulateResourceInfoHandler(func(un *unstructured.Unstructured, isRoot bool) (info interface{}, cacheManifest bool) {
+			handled = un
+			return nil, true
+		}),
+		SetResourceTransformer(MetadataAndStatusOnly),
+	}, pod)
+	require.NoError(t, cluster.EnsureSynced())
+
+	res, ok := cluster.FindResources("default")[kube.GetResourceKey(pod)]
+	require.True(t, ok)
+	require.NotNil(t, res.Resource)
+	assert.ElementsMatch(t, []string{"apiVersion", "kind", "metadata", "status"}, mapKeys(res.Resource.Object))
+	assert.Equal(t, pod.GetName(), res.Resource.GetName())
+	phase, _, _ := unstructured.NestedString(res.Resource.Object, "status", "phase")
+	assert.Equal(t, "Running", phase)
+
+	// the info handler receives the whole resource
+	require.NotNil(t, handled)
+	assert.Contains(t, handled.Object, "spec")
+}
+
+func TestDropManagedFields(t *testing.T) {
+	un := strToUnstructured(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-config
+  managedFields:
+  - manager: kubectl
+data:
+  key: value`)
+
+	res := DropManagedFields(un)
+	assert.Equal(t, "my-config", res.GetName())
+	assert.Empty(t, res.GetManagedFields())
+	assert.Equal(t, map[string]interface{}{"key": "value"}, res.Object["data"])
+	// the given resource is not modified
+	assert.Len(t, un.GetManagedFields(), 1)
+
+	withoutManagedFields := strToUnstructured(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-config`)
+	assert.Same(t, withoutManagedFields, DropManagedFields(withoutManagedFields))
+}
+
+func mapKeys(m map[string]interface{}) []string {
+	res := make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	return res
+}
@@ -0,0 +1,105 @@
+package diff
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/managedfields"
+	smdschema "sigs.k8s.io/structured-merge-diff/v4/schema"
+)
+
+// BoolOrStringPath identifies a field of the resources of a kind that accepts both a boolean and its string
+// representation, e.g. true and "true"
+type BoolOrStringPath struct {
+	// GVK is the kind of the resources the path applies to. The path applies to all versions of the kind if the
+	// version is empty.
+	GVK schema.GroupVersionKind
+	// Path is a JSON pointer (RFC 6901) to the field, e.g. /spec/enabled
+	Path string
+}
+
+func (p BoolOrStringPath) matches(gvk schema.GroupVersionKind) bool {
+	return p.GVK.Group == gvk.Group && p.GVK.Kind == gvk.Kind && (p.GVK.Version == "" || p.GVK.Version == gvk.Version)
+}
+
+// normalizeBoolOrStrings canonicalizes the values of the given fields, so that a boolean and its string representation
+// are equal. The values are converted to the type declared by the schema of the resource if the schema is available
+// and declares the field as string, otherwise to booleans. Values other than true and false are left unchanged.
+func normalizeBoolOrStrings(un *unstructured.Unstructured, paths []BoolOrStringPath, gvkParser *managedfields.GvkParser) {
+	gvk := un.GroupVersionKind()
+	for _, p := range paths {
+		if !p.matches(gvk) {
+			continue
+		}
+		tokens, err := parseJSONPointer(p.Path)
+		if err != nil || len(tokens) == 0 {
+			continue
+		}
+		val, ok := lookupJSONPointer(un.Object, tokens)
+		if !ok {
+			continue
+		}
+		var b bool
+		switch v := val.(type) {
+		case bool:
+			b = v
+		case string:
+			if v != "true" && v != "false" {
+				continue
+			}
+			b = v == "true"
+		default:
+			continue
+		}
+		if schemaScalar(gvkParser, gvk, tokens) == smdschema.String {
+			if b {
+				setJSONPointer(un.Object, tokens, "true")
+			} else {
+				setJSONPointer(un.Object, tokens, "false")
+			}
+		} else {
+			setJSONPointer(un.Object, tokens, b)
+		}
+	}
+}
+
+// schemaScalar returns the scalar type of the field at the given path according to the schema of the kind, or an
+// empty string if the schema or the field is unknown
+func schemaScalar(gvkParser *managedfields.GvkParser, gvk schema.GroupVersionKind, tokens []string) smdschema.Scalar {
+	atom, ok := schemaAtom(gvkParser, gvk, tokens)
+	if !ok || atom.Scalar == nil {
+		return ""
+	}
+	return *atom.Scalar
+}
+
+// schemaAtom returns the schema of the field at the given path according to the schema of the kind. The path tokens
+// of list elements are ignored.
+func schemaAtom(gvkParser *managedfields.GvkParser, gvk schema.GroupVersionKind, tokens []string) (smdschema.Atom, bool) {
+	if gvkParser == nil {
+		return smdschema.Atom{}, false
+	}
+	pt := gvkParser.Type(gvk)
+	if pt == nil || pt.Schema == nil {
+		return smdschema.Atom{}, false
+	}
+	typeRef := pt.TypeRef
+	for _, token := range tokens {
+		atom, ok := pt.Schema.Resolve(typeRef)
+		if !ok {
+			return smdschema.Atom{}, false
+		}
+		switch {
+		case atom.Map != nil:
+			if field, ok := atom.Map.FindField(token); ok {
+				typeRef = field.Type
+			} else {
+				typeRef = atom.Map.ElementType
+			}
+		case atom.List != nil:
+			typeRef = atom.List.ElementType
+		default:
+			return smdschema.Atom{}, false
+		}
+	}
+	return pt.Schema.Resolve(typeRef)
+}
@@ -11,18 +11,24 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
 	jsonpatch "github.com/evanphx/json-patch"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/jsonmergepatch"
 	"k8s.io/apimachinery/pkg/util/managedfields"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
 	"sigs.k8s.io/structured-merge-diff/v4/merge"
+	smdschema "sigs.k8s.io/structured-merge-diff/v4/schema"
 	"sigs.k8s.io/structured-merge-diff/v4/typed"
 
 	"github.com/argoproj/gitops-engine/internal/kubernetes_vendor/pkg/api/v1/endpoints"
@@ -35,25 +41,73 @@ import (
 const (
 	couldNotMarshalErrMsg       = "Could not unmarshal to object of type %s: %v"
 	AnnotationLastAppliedConfig = "kubectl.kubernetes.io/last-applied-configuration"
-	replacement                 = "++++++++"
+	// AnnotationIgnoreReplicas set to "true" on a workload ignores differences in its spec.replicas, e.g. if the
+	// workload is scaled outside of GitOps
+	AnnotationIgnoreReplicas = "gitops-engine.io/ignore-replicas"
+	replacement              = "++++++++"
 )
 
 // Holds diffing result of two resources
 type DiffResult struct {
+	// Group, Kind, Namespace and Name identify the compared resource
+	Group     string
+	Kind      string
+	Namespace string
+	Name      string
 	// Modified is set to true if resources are not matching
 	Modified bool
 	// Contains YAML representation of a live resource with applied normalizations
 	NormalizedLive []byte
 	// Contains "expected" YAML representation of a live resource
 	PredictedLive []byte
+	// Contains the field level differences that cannot be represented by the diff, such as type mismatches
+	FieldDeltas []FieldDelta
+	// Contains the textual two-way comparison of the config (PredictedLive) and the live state (NormalizedLive) if
+	// requested using WithTwoWayDeltas. Its Modified flag reports whether the resources differ textually, including
+	// fields defaulted by the server, whereas the Modified flag of the result reports whether syncing would change
+	// the live state.
+	TwoWay *DiffResult
+	// Contains the changes of the elements of the lists identified by a merge key, such as containers, if the resource
+	// is modified, see WithKeyedListPaths. Unlike the textual diff, it reports elements that were moved to another index.
+	ListChanges []KeyedListChanges
+	// Contains descriptions of the normalizations and ignore rules that were applied when computing the result, see
+	// Explain
+	Normalizations []string
 }
 
-// Holds result of two resources sets comparison
-type DiffResultList struct {
-	Diffs    []DiffResult
+// MergePatch returns a JSON merge patch (RFC 7386) that transforms the normalized live state into the predicted live
+// state. Removed fields are represented as null. If the resource does not exist in one of the states, the patch is
+// the predicted live state itself, since merge patches can only describe changes between two objects.
+func (r *DiffResult) MergePatch() ([]byte, error) {
+	if isJSONNull(r.NormalizedLive) || isJSONNull(r.PredictedLive) {
+		return r.PredictedLive, nil
+	}
+	return jsonpatch.CreateMergePatch(r.NormalizedLive, r.PredictedLive)
+}
+
+func isJSONNull(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) == 0 || string(data) == "null"
+}
+
+// DiffArrayResults holds the results of DiffArray
+type DiffArrayResults struct {
+	// Results contains the result of each compared resource, in the order of the compared arrays
+	Results []*DiffResult
+	// Modified is set to true if any of the resources are not matching
 	Modified bool
 }
 
+// Get returns the result of the resource with the given identity, or nil if the resource was not compared
+func (r *DiffArrayResults) Get(group, kind, namespace, name string) *DiffResult {
+	for _, res := range r.Results {
+		if res.Group == group && res.Kind == kind && res.Namespace == namespace && res.Name == name {
+			return res
+		}
+	}
+	return nil
+}
+
 type noopNormalizer struct {
 }
 
@@ -74,7 +128,243 @@ func GetNoopNormalizer() Normalizer {
 // Diff performs a diff on two unstructured objects. If the live object happens to have a
 // "kubectl.kubernetes.io/last-applied-configuration", then perform a three way diff.
 func Diff(config, live *unstructured.Unstructured, opts ...Option) (*DiffResult, error) {
+	opts = diffOptions(config, live, opts)
+	o := applyOptions(opts)
+	normalizedConfig, normalizedLive, otherManagersFields, err := normalizeObjects(config, live, o, opts...)
+	if err != nil {
+		return nil, err
+	}
+	deltas, err := fieldDeltas(normalizedConfig, normalizedLive, o)
+	if err != nil {
+		return nil, err
+	}
+	dr, err := diffNormalizedObjects(normalizedConfig, normalizedLive, otherManagersFields, o, opts...)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := newSharedDiffResult(config, live, o, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return completeDiffResult(dr, config, live, deltas, shared, o)
+}
+
+// ThreeAndTwoWayDiff returns both the three-way and the two-way result of Diff for the given config and live state.
+// The three-way result is the result of Diff, which uses the last applied configuration of the live state if any. The
+// two-way result ignores the last applied configuration, i.e. it is the result of Diff for the live state without the
+// last applied configuration annotation. The config and live state are normalized, dry-run on the server and compared
+// textually only once for both results.
+func ThreeAndTwoWayDiff(config, live *unstructured.Unstructured, opts ...Option) (*DiffResult, *DiffResult, error) {
+	opts = diffOptions(config, live, opts)
 	o := applyOptions(opts)
+	normalizedConfig, normalizedLive, otherManagersFields, err := normalizeObjects(config, live, o, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	deltas, err := fieldDeltas(normalizedConfig, normalizedLive, o)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the server defaults and the two-way deltas do not depend on the last applied configuration
+	shared, err := newSharedDiffResult(config, live, o, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	threeWay, err := diffNormalizedObjects(normalizedConfig, normalizedLive, otherManagersFields, o, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if threeWay, err = completeDiffResult(threeWay, config, live, deltas, shared, o); err != nil {
+		return nil, nil, err
+	}
+	if normalizedLive != nil {
+		normalizedLive = normalizedLive.DeepCopy()
+		removeAnnotations(normalizedLive, []string{AnnotationLastAppliedConfig}, "metadata", "annotations")
+	}
+	twoWay, err := diffNormalizedObjects(normalizedConfig, normalizedLive, otherManagersFields, o, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if twoWay, err = completeDiffResult(twoWay, config, live, deltas, shared, o); err != nil {
+		return nil, nil, err
+	}
+	return threeWay, twoWay, nil
+}
+
+// diffOptions returns the given options completed with the options required by the given config and live state
+func diffOptions(config, live *unstructured.Unstructured, opts []Option) []Option {
+	// the replicas must be ignored on both sides, even if only one of them is annotated
+	if hasIgnoreReplicasAnnotation(config) || hasIgnoreReplicasAnnotation(live) {
+		opts = append(opts[:len(opts):len(opts)], WithIgnoreReplicas(true))
+	}
+	return opts
+}
+
+// fieldDeltas returns the fields of the normalized config and live state with incompatible types, or an error if there
+// are any and strict normalization is enabled
+func fieldDeltas(config, live *unstructured.Unstructured, o options) ([]FieldDelta, error) {
+	if config == nil || live == nil {
+		return nil, nil
+	}
+	gvk := config.GroupVersionKind()
+	deltas := typeMismatches(config.Object, live.Object, "", nil, func(tokens []string) smdschema.Scalar {
+		return schemaScalar(o.gvkParser, gvk, tokens)
+	})
+	if o.strictNormalization && len(deltas) > 0 {
+		return nil, fmt.Errorf("config and live state of %s/%s have incompatible types: %s", config.GetKind(), config.GetName(), deltas[0])
+	}
+	if delta, ok := secretTypeChange(config, live); ok {
+		deltas = append(deltas, delta)
+	}
+	return deltas, nil
+}
+
+// sharedDiffResult holds the parts of a diff result that only depend on the config and live state, so that they are
+// computed once if multiple results are compared, see ThreeAndTwoWayDiff
+type sharedDiffResult struct {
+	// serverDefaulted is the normalized result of the server-side dry run of the config, if any
+	serverDefaulted *unstructured.Unstructured
+	// twoWay is the masked textual two-way comparison of the config and live state, if enabled
+	twoWay *DiffResult
+}
+
+// newSharedDiffResult runs the server-side dry run of the given config and the textual two-way comparison of the given
+// config and live state if they are enabled
+func newSharedDiffResult(config, live *unstructured.Unstructured, o options, opts ...Option) (*sharedDiffResult, error) {
+	shared := &sharedDiffResult{}
+	if o.serverDefaultedPredictedLive && !o.serverSideDiff && config != nil {
+		shared.serverDefaulted = serverDefaults(config, o, opts...)
+	}
+	if o.twoWayDeltas && config != nil && live != nil {
+		twoWay, err := textualDiff(config, live, o, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("error calculating two-way deltas: %w", err)
+		}
+		if len(o.sensitivePaths) > 0 {
+			if err := maskSensitivePaths(twoWay, config.GroupVersionKind(), o.sensitivePaths); err != nil {
+				return nil, fmt.Errorf("error masking sensitive fields: %w", err)
+			}
+		}
+		shared.twoWay = twoWay
+	}
+	return shared, nil
+}
+
+// completeDiffResult adds the server defaults, field deltas, normalizations, identity, two-way deltas and list changes
+// to the given result of comparing the given config and live state, and masks its sensitive fields
+func completeDiffResult(dr *DiffResult, config, live *unstructured.Unstructured, deltas []FieldDelta, shared *sharedDiffResult, o options) (*DiffResult, error) {
+	dr, err := removeManagedFields(dr)
+	if err != nil {
+		return nil, fmt.Errorf("error removing managed fields: %w", err)
+	}
+	if shared.serverDefaulted != nil {
+		dr = addServerDefaults(dr, shared.serverDefaulted, o)
+	}
+	dr.FieldDeltas = deltas
+	for _, d := range deltas {
+		// the Secret must be replaced even if the masked or normalized states do not differ
+		if d.Type == FieldDeltaSecretTypeChange {
+			dr.Modified = true
+		}
+	}
+	dr.Normalizations = o.normalizations()
+	if config != nil {
+		setIdentity(dr, config)
+	} else if live != nil {
+		setIdentity(dr, live)
+	}
+	dr.TwoWay = shared.twoWay
+	var gvk schema.GroupVersionKind
+	if config != nil {
+		gvk = config.GroupVersionKind()
+	} else if live != nil {
+		gvk = live.GroupVersionKind()
+	}
+	// the list changes are computed before masking, so that changes of masked values are reported
+	dr.ListChanges, err = keyedListChanges(dr, gvk, o.keyedListPaths, o.gvkParser)
+	if err != nil {
+		return nil, fmt.Errorf("error comparing keyed lists: %w", err)
+	}
+	if len(o.sensitivePaths) > 0 {
+		if err := maskSensitivePaths(dr, gvk, o.sensitivePaths); err != nil {
+			return nil, fmt.Errorf("error masking sensitive fields: %w", err)
+		}
+	}
+	return dr, nil
+}
+
+// removeManagedFields returns a copy of the given diff result without the managed fields of the live and predicted live
+// state. The managed fields are maintained by the server and change whenever the ownership of fields is transferred,
+// e.g. when migrating to server-side apply, so they are never compared. A modified result is reported as not modified
+// if both states are semantically equal without their managed fields; other results are kept as they are.
+func removeManagedFields(dr *DiffResult) (*DiffResult, error) {
+	managedFields := []byte(`"managedFields"`)
+	if !bytes.Contains(dr.NormalizedLive, managedFields) && !bytes.Contains(dr.PredictedLive, managedFields) {
+		return dr, nil
+	}
+	strip := func(data []byte) (map[string]interface{}, []byte, error) {
+		if isJSONNull(data) {
+			return nil, data, nil
+		}
+		var obj map[string]interface{}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return nil, nil, err
+		}
+		unstructured.RemoveNestedField(obj, "metadata", "managedFields")
+		data, err := json.Marshal(obj)
+		return obj, data, err
+	}
+	liveObj, live, err := strip(dr.NormalizedLive)
+	if err != nil {
+		return nil, err
+	}
+	predictedLiveObj, predictedLive, err := strip(dr.PredictedLive)
+	if err != nil {
+		return nil, err
+	}
+	result := *dr
+	result.NormalizedLive, result.PredictedLive = live, predictedLive
+	if result.Modified && liveObj != nil && predictedLiveObj != nil && reflect.DeepEqual(liveObj, predictedLiveObj) {
+		result.Modified = false
+	}
+	return &result, nil
+}
+
+func setIdentity(dr *DiffResult, obj *unstructured.Unstructured) {
+	dr.Group = obj.GroupVersionKind().Group
+	dr.Kind = obj.GetKind()
+	dr.Namespace = obj.GetNamespace()
+	dr.Name = obj.GetName()
+}
+
+// textualDiff returns the result of comparing the normalized config and live state as they are, without predicting
+// the result of applying the config
+func textualDiff(config, live *unstructured.Unstructured, o options, opts ...Option) (*DiffResult, error) {
+	config = remarshal(config, o)
+	Normalize(config, opts...)
+	live = remarshal(live, o)
+	Normalize(live, opts...)
+	removeAnnotations(live, []string{AnnotationLastAppliedConfig}, "metadata", "annotations")
+	unstructured.RemoveNestedField(config.Object, "metadata", "managedFields")
+	unstructured.RemoveNestedField(live.Object, "metadata", "managedFields")
+	liveData, err := json.Marshal(live)
+	if err != nil {
+		return nil, err
+	}
+	configData, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	return &DiffResult{
+		Modified:       !reflect.DeepEqual(config.Object, live.Object),
+		NormalizedLive: liveData,
+		PredictedLive:  configData,
+	}, nil
+}
+
+// normalizeObjects returns normalized copies of the given config and live state and the fields owned by other managers
+// than the user managers, which are removed from the copies
+func normalizeObjects(config, live *unstructured.Unstructured, o options, opts ...Option) (*unstructured.Unstructured, *unstructured.Unstructured, *fieldpath.Set, error) {
 	if config != nil {
 		config = remarshal(config, o)
 		Normalize(config, opts...)
@@ -83,6 +373,32 @@ func Diff(config, live *unstructured.Unstructured, opts ...Option) (*DiffResult,
 		live = remarshal(live, o)
 		Normalize(live, opts...)
 	}
+	if config != nil && live != nil {
+		applyTimestampTolerance(config, live, o)
+		applyImageDigestTolerance(config, live, o)
+	}
+	var otherManagersFields *fieldpath.Set
+	if len(o.userManagers) > 0 && live != nil {
+		var err error
+		if otherManagersFields, err = getOtherManagersFields(live, o.userManagers); err != nil {
+			return nil, nil, nil, fmt.Errorf("error getting fields of other managers: %w", err)
+		}
+		if config, err = removeFields(config, otherManagersFields, o.gvkParser); err != nil {
+			return nil, nil, nil, fmt.Errorf("error removing fields of other managers from config: %w", err)
+		}
+		if live, err = removeFields(live, otherManagersFields, o.gvkParser); err != nil {
+			return nil, nil, nil, fmt.Errorf("error removing fields of other managers from live state: %w", err)
+		}
+	}
+	return config, live, otherManagersFields, nil
+}
+
+// diffNormalizedObjects compares the given normalized config and live state
+func diffNormalizedObjects(config, live *unstructured.Unstructured, otherManagersFields *fieldpath.Set, o options, opts ...Option) (*DiffResult, error) {
+
+	if o.metadataOnly {
+		return TwoWayDiff(metadataOnly(config), metadataOnly(live))
+	}
 
 	if o.serverSideDiff {
 		r, err := ServerSideDiff(config, live, opts...)
@@ -119,6 +435,9 @@ func Diff(config, live *unstructured.Unstructured, opts ...Option) (*DiffResult,
 	} else {
 		if orig != nil && config != nil {
 			Normalize(orig, opts...)
+			if orig, err = removeFields(orig, otherManagersFields, o.gvkParser); err != nil {
+				return nil, fmt.Errorf("error removing fields of other managers from last applied configuration: %w", err)
+			}
 			dr, err := ThreeWayDiff(orig, config, live)
 			if err == nil {
 				return dr, nil
@@ -129,6 +448,195 @@ func Diff(config, live *unstructured.Unstructured, opts ...Option) (*DiffResult,
 	return TwoWayDiff(config, live)
 }
 
+// serverManagedAnnotations holds annotations that are set by the server or by kubectl and should not be compared
+// when diffing only metadata
+var serverManagedAnnotations = []string{
+	AnnotationLastAppliedConfig,
+	"deployment.kubernetes.io/revision",
+}
+
+// metadataOnly returns a copy of the given resource that keeps only the identity, labels and annotations
+func metadataOnly(un *unstructured.Unstructured) *unstructured.Unstructured {
+	if un == nil {
+		return nil
+	}
+	res := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	res.SetAPIVersion(un.GetAPIVersion())
+	res.SetKind(un.GetKind())
+	res.SetName(un.GetName())
+	res.SetNamespace(un.GetNamespace())
+	if labels := un.GetLabels(); len(labels) > 0 {
+		res.SetLabels(labels)
+	}
+	annotations := un.GetAnnotations()
+	for _, key := range serverManagedAnnotations {
+		delete(annotations, key)
+	}
+	if len(annotations) > 0 {
+		res.SetAnnotations(annotations)
+	}
+	return res
+}
+
+// applyTimestampTolerance copies timestamps from live into config for the configured timestamp fields
+// if both values are valid RFC3339 timestamps within the configured tolerance, so the difference is ignored.
+func applyTimestampTolerance(config, live *unstructured.Unstructured, o options) {
+	if o.timestampTolerance <= 0 {
+		return
+	}
+	for _, fields := range o.timestampFields {
+		configVal, ok, err := unstructured.NestedString(config.Object, fields...)
+		if !ok || err != nil {
+			continue
+		}
+		liveVal, ok, err := unstructured.NestedString(live.Object, fields...)
+		if !ok || err != nil || configVal == liveVal {
+			continue
+		}
+		configTime, err := time.Parse(time.RFC3339, configVal)
+		if err != nil {
+			o.log.V(1).Info(fmt.Sprintf("Failed to parse timestamp %q in config: %v", configVal, err))
+			continue
+		}
+		liveTime, err := time.Parse(time.RFC3339, liveVal)
+		if err != nil {
+			o.log.V(1).Info(fmt.Sprintf("Failed to parse timestamp %q in live: %v", liveVal, err))
+			continue
+		}
+		delta := configTime.Sub(liveTime)
+		if delta < 0 {
+			delta = -delta
+		}
+		if delta <= o.timestampTolerance {
+			_ = unstructured.SetNestedField(config.Object, liveVal, fields...)
+		}
+	}
+}
+
+// imageDigestContainerFields holds the fields of a pod spec that contain lists of containers
+var imageDigestContainerFields = []string{"containers", "initContainers", "ephemeralContainers"}
+
+// applyImageDigestTolerance copies container images from live into config if the images only differ in the digest,
+// e.g. "nginx:1.2" and "nginx:1.2@sha256:...", so the difference is ignored.
+func applyImageDigestTolerance(config, live *unstructured.Unstructured, o options) {
+	if !o.ignoreImageDigests {
+		return
+	}
+	podSpecPaths := [][]string{{"spec"}}
+	for _, path := range podTemplatePaths {
+		podSpecPaths = append(podSpecPaths, append(append([]string{}, path...), "spec"))
+	}
+	for _, podSpecPath := range podSpecPaths {
+		for _, field := range imageDigestContainerFields {
+			fields := append(append([]string{}, podSpecPath...), field)
+			configContainers, ok, err := unstructured.NestedSlice(config.Object, fields...)
+			if !ok || err != nil {
+				continue
+			}
+			liveContainers, ok, err := unstructured.NestedSlice(live.Object, fields...)
+			if !ok || err != nil {
+				continue
+			}
+			liveImages := map[string]string{}
+			for _, c := range liveContainers {
+				if container, ok := c.(map[string]interface{}); ok {
+					name, _ := container["name"].(string)
+					image, _ := container["image"].(string)
+					liveImages[name] = image
+				}
+			}
+			changed := false
+			for _, c := range configContainers {
+				container, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				name, _ := container["name"].(string)
+				configImage, _ := container["image"].(string)
+				liveImage, ok := liveImages[name]
+				if !ok || configImage == liveImage || trimImageDigest(configImage) != trimImageDigest(liveImage) {
+					continue
+				}
+				container["image"] = liveImage
+				changed = true
+			}
+			if changed {
+				_ = unstructured.SetNestedSlice(config.Object, configContainers, fields...)
+			}
+		}
+	}
+}
+
+// trimImageDigest returns the given image reference without its digest
+func trimImageDigest(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[:i]
+	}
+	return image
+}
+
+// serverDefaults returns the normalized result of the server-side dry run of the given config, which includes the
+// fields defaulted by the server, or nil if the dry run fails or is not configured
+func serverDefaults(config *unstructured.Unstructured, o options, opts ...Option) *unstructured.Unstructured {
+	if o.serverSideDryRunner == nil {
+		o.log.V(1).Info("Server-side dry runner is not configured, predicted live state is not enriched with server defaults")
+		return nil
+	}
+	serverLiveStr, err := o.serverSideDryRunner.Run(context.Background(), config.DeepCopy(), o.manager)
+	if err != nil {
+		o.log.V(1).Info(fmt.Sprintf("Failed to run server side apply in dryrun mode for resource %s/%s: %v", config.GetKind(), config.GetName(), err))
+		return nil
+	}
+	serverLive, err := jsonStrToUnstructured(serverLiveStr)
+	if err != nil {
+		o.log.V(1).Info(fmt.Sprintf("Failed to unmarshal dryrun result for resource %s/%s: %v", config.GetKind(), config.GetName(), err))
+		return nil
+	}
+	Normalize(serverLive, opts...)
+	unstructured.RemoveNestedField(serverLive.Object, "metadata", "managedFields")
+	return serverLive
+}
+
+// addServerDefaults returns a copy of the given diff result whose predicted live state includes the fields of the
+// given server-side dry run result that it is missing, see serverDefaults. The original diff result is returned if
+// the predicted live state cannot be updated.
+func addServerDefaults(dr *DiffResult, serverLive *unstructured.Unstructured, o options) *DiffResult {
+	predictedLive, err := jsonStrToUnstructured(string(dr.PredictedLive))
+	if err != nil {
+		o.log.V(1).Info(fmt.Sprintf("Failed to unmarshal predicted live state: %v", err))
+		return dr
+	}
+	mergeMissingFields(predictedLive.Object, serverLive.Object)
+
+	predictedLiveBytes, err := json.Marshal(predictedLive)
+	if err != nil {
+		o.log.V(1).Info(fmt.Sprintf("Failed to marshal predicted live state: %v", err))
+		return dr
+	}
+	return &DiffResult{
+		Modified:       dr.Modified,
+		NormalizedLive: dr.NormalizedLive,
+		PredictedLive:  predictedLiveBytes,
+	}
+}
+
+// mergeMissingFields recursively copies the fields of src that are missing in dst. Lists are not merged
+// because their elements cannot be matched without the resource schema.
+func mergeMissingFields(dst, src map[string]interface{}) {
+	for k, srcVal := range src {
+		dstVal, ok := dst[k]
+		if !ok {
+			dst[k] = runtime.DeepCopyJSONValue(srcVal)
+			continue
+		}
+		dstMap, dstIsMap := dstVal.(map[string]interface{})
+		srcMap, srcIsMap := srcVal.(map[string]interface{})
+		if dstIsMap && srcIsMap {
+			mergeMissingFields(dstMap, srcMap)
+		}
+	}
+}
+
 // ServerSideDiff will execute a k8s server-side apply in dry-run mode with the
 // given config. The result will be compared with given live resource to determine
 // diff. If config or live are nil it means resource creation or deletion. In this
@@ -283,6 +791,59 @@ func removeWebhookMutation(predictedLive, live *unstructured.Unstructured, gvkPa
 	return &unstructured.Unstructured{Object: pl}, nil
 }
 
+// getOtherManagersFields returns the fields of the given live state that are owned by managers other than the given
+// ones and not by any of the given managers
+func getOtherManagersFields(live *unstructured.Unstructured, managers []string) (*fieldpath.Set, error) {
+	userFields := &fieldpath.Set{}
+	otherFields := &fieldpath.Set{}
+	for _, mfEntry := range live.GetManagedFields() {
+		if mfEntry.FieldsV1 == nil {
+			continue
+		}
+		mfs := &fieldpath.Set{}
+		if err := mfs.FromJSON(bytes.NewReader(mfEntry.FieldsV1.Raw)); err != nil {
+			return nil, fmt.Errorf("error building managedFields set of manager %s: %w", mfEntry.Manager, err)
+		}
+		isUserManager := false
+		for _, manager := range managers {
+			if mfEntry.Manager == manager {
+				isUserManager = true
+				break
+			}
+		}
+		if isUserManager {
+			userFields = userFields.Union(mfs)
+		} else {
+			otherFields = otherFields.Union(mfs)
+		}
+	}
+	return otherFields.Difference(userFields), nil
+}
+
+// removeFields returns a copy of the given resource without the given fields
+func removeFields(un *unstructured.Unstructured, fields *fieldpath.Set, gvkParser *managedfields.GvkParser) (*unstructured.Unstructured, error) {
+	if un == nil || fields == nil || fields.Empty() {
+		return un, nil
+	}
+	if gvkParser == nil {
+		return nil, errors.New("a GVK parser is required")
+	}
+	gvk := un.GroupVersionKind()
+	pt := gvkParser.Type(gvk)
+	if pt == nil {
+		return nil, fmt.Errorf("unable to resolve parseableType for GroupVersionKind: %s", gvk)
+	}
+	typed, err := pt.FromUnstructured(un.Object)
+	if err != nil {
+		return nil, fmt.Errorf("error converting %s/%s from unstructured to %s: %w", un.GetKind(), un.GetName(), gvk, err)
+	}
+	res, ok := typed.RemoveItems(fields).AsValue().Unstructured().(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("error converting typed value of %s/%s to unstructured", un.GetKind(), un.GetName())
+	}
+	return &unstructured.Unstructured{Object: res}, nil
+}
+
 func jsonStrToUnstructured(jsonString string) (*unstructured.Unstructured, error) {
 	res := make(map[string]interface{})
 	err := json.Unmarshal([]byte(jsonString), &res)
@@ -784,14 +1345,23 @@ func GetLastAppliedConfigAnnotation(live *unstructured.Unstructured) (*unstructu
 
 // DiffArray performs a diff on a list of unstructured objects. Objects are expected to match
 // environments
-func DiffArray(configArray, liveArray []*unstructured.Unstructured, opts ...Option) (*DiffResultList, error) {
+func DiffArray(configArray, liveArray []*unstructured.Unstructured, opts ...Option) (*DiffArrayResults, error) {
 	numItems := len(configArray)
 	if len(liveArray) != numItems {
 		return nil, errors.New("left and right arrays have mismatched lengths")
 	}
 
-	diffResultList := DiffResultList{
-		Diffs: make([]DiffResult, numItems),
+	results := DiffArrayResults{
+		Results: make([]*DiffResult, numItems),
+	}
+	var hpas []*unstructured.Unstructured
+	for _, obj := range append(append([]*unstructured.Unstructured{}, configArray...), liveArray...) {
+		if _, ok := getAutoscaledWorkload(obj); ok {
+			hpas = append(hpas, obj)
+		}
+	}
+	if len(hpas) > 0 {
+		opts = append(opts[:len(opts):len(opts)], WithHorizontalPodAutoscalers(hpas...))
 	}
 	for i := 0; i < numItems; i++ {
 		config := configArray[i]
@@ -800,12 +1370,45 @@ func DiffArray(configArray, liveArray []*unstructured.Unstructured, opts ...Opti
 		if err != nil {
 			return nil, err
 		}
-		diffResultList.Diffs[i] = *diffRes
+		results.Results[i] = diffRes
 		if diffRes.Modified {
-			diffResultList.Modified = true
+			results.Modified = true
 		}
 	}
-	return &diffResultList, nil
+	return &results, nil
+}
+
+// DiffBestMatch compares the config with each of the given candidate live objects and returns the candidate with the
+// fewest changed fields, see ChangeCount, along with its diff result. This allows detecting the drift of resources
+// whose name is generated by the server, e.g. using metadata.generateName, and therefore cannot be matched by name.
+// Candidates of a different group and kind than the config are ignored and ties are resolved in favor of the first
+// candidate. Nil is returned if there is no candidate.
+func DiffBestMatch(config *unstructured.Unstructured, candidates []*unstructured.Unstructured, opts ...Option) (*unstructured.Unstructured, *DiffResult, error) {
+	if config == nil {
+		return nil, nil, errors.New("config must not be nil")
+	}
+	var best *unstructured.Unstructured
+	var bestResult *DiffResult
+	bestCount := 0
+	for _, live := range candidates {
+		if live == nil || live.GroupVersionKind().GroupKind() != config.GroupVersionKind().GroupKind() {
+			continue
+		}
+		dr, err := Diff(config, live, opts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error comparing with %s: %w", live.GetName(), err)
+		}
+		count := 0
+		if dr.Modified {
+			if count, err = ChangeCount(dr); err != nil {
+				return nil, nil, err
+			}
+		}
+		if best == nil || count < bestCount {
+			best, bestResult, bestCount = live, dr, count
+		}
+	}
+	return best, bestResult, nil
 }
 
 func Normalize(un *unstructured.Unstructured, opts ...Option) {
@@ -814,9 +1417,45 @@ func Normalize(un *unstructured.Unstructured, opts ...Option) {
 	}
 	o := applyOptions(opts)
 
-	// creationTimestamp is sometimes set to null in the config when exported (e.g. SealedSecrets)
-	// Removing the field allows a cleaner diff.
-	unstructured.RemoveNestedField(un.Object, "metadata", "creationTimestamp")
+	// Metadata fields set by the server should never cause a difference, even if the config mistakenly includes them.
+	// creationTimestamp is e.g. sometimes set to null in the config when exported (e.g. SealedSecrets).
+	for _, field := range o.stripMetadataFields {
+		unstructured.RemoveNestedField(un.Object, "metadata", field)
+	}
+
+	// The deploy ID and manifest hash annotations are added by the sync engine to applied resources and are never
+	// part of the config, so they should never cause a difference.
+	removeIgnoredAnnotations(un)
+	removeAnnotations(un, o.ignoredAnnotationKeys, "metadata", "annotations")
+	removeAnnotations(un, o.ignoredLabelKeys, "metadata", "labels")
+	removePodTemplateAnnotations(un, o.ignoredPodTemplateAnnotations)
+
+	if o.generatedNamePattern != nil {
+		normalizeGeneratedNames(un, o.generatedNamePattern)
+	}
+
+	if len(o.boolOrStringPaths) > 0 {
+		normalizeBoolOrStrings(un, o.boolOrStringPaths, o.gvkParser)
+	}
+
+	if len(o.durationPaths) > 0 {
+		normalizeDurations(un, o.durationPaths)
+	}
+	if o.openAPISchema != nil {
+		normalizeDurations(un, durationPathsFromSchema(o.openAPISchema, un.GroupVersionKind()))
+	}
+
+	if o.normalizeScheduling {
+		normalizeScheduling(un)
+	}
+
+	if o.ignoreFinalizers {
+		unstructured.RemoveNestedField(un.Object, "metadata", "finalizers")
+	}
+
+	if o.ignoreReplicas || isAutoscaled(un, o.autoscaledWorkloads) || hasIgnoreReplicasAnnotation(un) {
+		unstructured.RemoveNestedField(un.Object, "spec", "replicas")
+	}
 
 	gvk := un.GroupVersionKind()
 	if gvk.Group == "" && gvk.Kind == "Secret" {
@@ -826,11 +1465,182 @@ func Normalize(un *unstructured.Unstructured, opts ...Option) {
 	} else if gvk.Group == "" && gvk.Kind == "Endpoints" {
 		normalizeEndpoint(un, o)
 	}
+	normalizeStrategicMergeLists(un, o)
 
+	// the type of a Secret is immutable, so it is always compared even if an ignore rule covers it
+	secretType, hasSecretType, _ := unstructured.NestedFieldCopy(un.Object, "type")
 	err := o.normalizer.Normalize(un)
 	if err != nil {
 		o.log.Error(err, fmt.Sprintf("Failed to normalize %s/%s/%s", un.GroupVersionKind(), un.GetNamespace(), un.GetName()))
 	}
+	if isSecret(un) && hasSecretType {
+		un.Object["type"] = secretType
+	}
+}
+
+// autoscaledWorkload identifies the scale target of a HorizontalPodAutoscaler
+type autoscaledWorkload struct {
+	group     string
+	kind      string
+	namespace string
+	name      string
+}
+
+// getAutoscaledWorkload returns the scale target of the given HorizontalPodAutoscaler
+func getAutoscaledWorkload(hpa *unstructured.Unstructured) (autoscaledWorkload, bool) {
+	if hpa == nil || hpa.GroupVersionKind().Group != "autoscaling" || hpa.GetKind() != "HorizontalPodAutoscaler" {
+		return autoscaledWorkload{}, false
+	}
+	ref, ok, err := unstructured.NestedStringMap(hpa.Object, "spec", "scaleTargetRef")
+	if !ok || err != nil || ref["kind"] == "" || ref["name"] == "" {
+		return autoscaledWorkload{}, false
+	}
+	gv, err := schema.ParseGroupVersion(ref["apiVersion"])
+	if err != nil {
+		return autoscaledWorkload{}, false
+	}
+	return autoscaledWorkload{group: gv.Group, kind: ref["kind"], namespace: hpa.GetNamespace(), name: ref["name"]}, true
+}
+
+// isAutoscaled returns true if the given resource is the scale target of one of the given workloads. The namespace
+// is not compared if it is not set, since config resources do not always specify it.
+func isAutoscaled(un *unstructured.Unstructured, workloads []autoscaledWorkload) bool {
+	gvk := un.GroupVersionKind()
+	for _, w := range workloads {
+		if w.group == gvk.Group && w.kind == gvk.Kind && w.name == un.GetName() &&
+			(w.namespace == "" || un.GetNamespace() == "" || w.namespace == un.GetNamespace()) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasIgnoreReplicasAnnotation returns true if the replicas of the given resource should be ignored
+func hasIgnoreReplicasAnnotation(un *unstructured.Unstructured) bool {
+	return un != nil && un.GetAnnotations()[AnnotationIgnoreReplicas] == "true"
+}
+
+// ignoredAnnotations holds annotations that are added by the sync engine and never compared.
+// TODO: use common.AnnotationDeployID and common.AnnotationManifestHash once the cyclic dependency with the kube package is resolved.
+var ignoredAnnotations = []string{
+	"gitops-engine.io/deploy-id",
+	"gitops-engine.io/manifest-hash",
+}
+
+// removeIgnoredAnnotations removes annotations that should never be compared and drops the
+// annotations map if it becomes empty
+func removeIgnoredAnnotations(un *unstructured.Unstructured) {
+	removeAnnotations(un, ignoredAnnotations, "metadata", "annotations")
+}
+
+// podTemplatePaths holds the paths of the pod templates of the built-in workload resources
+var podTemplatePaths = [][]string{
+	{"spec", "template"},
+	{"spec", "jobTemplate", "spec", "template"},
+}
+
+// removePodTemplateAnnotations removes the given annotations from the pod template of the resource
+func removePodTemplateAnnotations(un *unstructured.Unstructured, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	for _, path := range podTemplatePaths {
+		removeAnnotations(un, keys, append(append([]string{}, path...), "metadata", "annotations")...)
+	}
+}
+
+// generatedNameRefPaths holds the paths of the references to ConfigMaps and Secrets within a pod spec. A "[]" element
+// matches all items of a list.
+var generatedNameRefPaths = func() [][]string {
+	paths := [][]string{
+		{"volumes", "[]", "configMap", "name"},
+		{"volumes", "[]", "secret", "secretName"},
+		{"volumes", "[]", "projected", "sources", "[]", "configMap", "name"},
+		{"volumes", "[]", "projected", "sources", "[]", "secret", "name"},
+		{"imagePullSecrets", "[]", "name"},
+	}
+	for _, containers := range []string{"containers", "initContainers"} {
+		paths = append(paths,
+			[]string{containers, "[]", "env", "[]", "valueFrom", "configMapKeyRef", "name"},
+			[]string{containers, "[]", "env", "[]", "valueFrom", "secretKeyRef", "name"},
+			[]string{containers, "[]", "envFrom", "[]", "configMapRef", "name"},
+			[]string{containers, "[]", "envFrom", "[]", "secretRef", "name"},
+		)
+	}
+	return paths
+}()
+
+// normalizeGeneratedNames removes the matches of the given pattern from the name of the resource and from the
+// references to ConfigMaps and Secrets in its pod spec or pod template
+func normalizeGeneratedNames(un *unstructured.Unstructured, pattern *regexp.Regexp) {
+	if name := un.GetName(); name != "" {
+		un.SetName(pattern.ReplaceAllString(name, ""))
+	}
+	podSpecPaths := [][]string{{"spec"}}
+	for _, path := range podTemplatePaths {
+		podSpecPaths = append(podSpecPaths, append(append([]string{}, path...), "spec"))
+	}
+	for _, podSpecPath := range podSpecPaths {
+		podSpec, ok, err := unstructured.NestedFieldNoCopy(un.Object, podSpecPath...)
+		if !ok || err != nil {
+			continue
+		}
+		for _, refPath := range generatedNameRefPaths {
+			replaceNestedStrings(podSpec, refPath, func(val string) string {
+				return pattern.ReplaceAllString(val, "")
+			})
+		}
+	}
+}
+
+// replaceNestedStrings replaces the string values located at the given path using the given function
+func replaceNestedStrings(obj interface{}, path []string, replace func(string) string) {
+	if len(path) == 0 {
+		return
+	}
+	if path[0] == "[]" {
+		if items, ok := obj.([]interface{}); ok {
+			for _, item := range items {
+				replaceNestedStrings(item, path[1:], replace)
+			}
+		}
+		return
+	}
+	fields, ok := obj.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if len(path) == 1 {
+		if val, ok := fields[path[0]].(string); ok {
+			fields[path[0]] = replace(val)
+		}
+		return
+	}
+	replaceNestedStrings(fields[path[0]], path[1:], replace)
+}
+
+// removeAnnotations removes the given keys from the annotations map located at the given path and drops the map if
+// it becomes empty
+func removeAnnotations(un *unstructured.Unstructured, keys []string, fields ...string) {
+	annotations, ok, err := unstructured.NestedMap(un.Object, fields...)
+	if !ok || err != nil {
+		return
+	}
+	removed := false
+	for _, key := range keys {
+		if _, ok := annotations[key]; ok {
+			delete(annotations, key)
+			removed = true
+		}
+	}
+	if !removed {
+		return
+	}
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(un.Object, fields...)
+	} else {
+		_ = unstructured.SetNestedMap(un.Object, annotations, fields...)
+	}
 }
 
 // NormalizeSecret mutates the supplied object and encodes stringData to data, and converts nils to
@@ -953,6 +1763,80 @@ func normalizeRole(un *unstructured.Unstructured, o options) {
 
 }
 
+// strategicMergeListFields holds the list fields of built-in types that are sorted by their strategic merge key
+var strategicMergeListFields = map[string]bool{
+	"containers":       true,
+	"initContainers":   true,
+	"volumes":          true,
+	"env":              true,
+	"ports":            true,
+	"volumeMounts":     true,
+	"imagePullSecrets": true,
+}
+
+// normalizeStrategicMergeLists sorts the strategic merge lists of built-in types by their merge key. Such lists are
+// merged by key rather than by position, so reordering their elements should not cause a difference.
+func normalizeStrategicMergeLists(un *unstructured.Unstructured, o options) {
+	versionedObject, err := scheme.Scheme.New(un.GroupVersionKind())
+	if err != nil {
+		// not a built-in type, list merge semantics are unknown
+		return
+	}
+	lookupPatchMeta, err := strategicpatch.NewPatchMetaFromStruct(versionedObject)
+	if err != nil {
+		o.log.V(1).Info(fmt.Sprintf("Failed to get patch metadata of %s: %v", un.GroupVersionKind(), err))
+		return
+	}
+	sortStrategicMergeLists(un.Object, lookupPatchMeta)
+}
+
+func sortStrategicMergeLists(obj map[string]interface{}, meta strategicpatch.LookupPatchMeta) {
+	for key, val := range obj {
+		switch v := val.(type) {
+		case map[string]interface{}:
+			fieldMeta, _, err := meta.LookupPatchMetadataForStruct(key)
+			if err != nil {
+				continue
+			}
+			sortStrategicMergeLists(v, fieldMeta)
+		case []interface{}:
+			itemMeta, patchMeta, err := meta.LookupPatchMetadataForSlice(key)
+			if err != nil {
+				continue
+			}
+			for _, item := range v {
+				if itemMap, ok := item.(map[string]interface{}); ok {
+					sortStrategicMergeLists(itemMap, itemMeta)
+				}
+			}
+			mergeKey := patchMeta.GetPatchMergeKey()
+			if !strategicMergeListFields[key] || mergeKey == "" || !hasMergeStrategy(patchMeta.GetPatchStrategies()) {
+				continue
+			}
+			sort.SliceStable(v, func(i, j int) bool {
+				return mergeKeyValue(v[i], mergeKey) < mergeKeyValue(v[j], mergeKey)
+			})
+		}
+	}
+}
+
+func hasMergeStrategy(strategies []string) bool {
+	for _, strategy := range strategies {
+		if strategy == "merge" {
+			return true
+		}
+	}
+	return false
+}
+
+func mergeKeyValue(item interface{}, mergeKey string) string {
+	itemMap, ok := item.(map[string]interface{})
+	if !ok || itemMap[mergeKey] == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", itemMap[mergeKey])
+}
+
 // CreateTwoWayMergePatch is a helper to construct a two-way merge patch from objects (instead of bytes)
 func CreateTwoWayMergePatch(orig, new, dataStruct interface{}) ([]byte, bool, error) {
 	origBytes, err := json.Marshal(orig)
@@ -2,16 +2,36 @@ package diff
 
 import (
 	"context"
+	"regexp"
+	"time"
 
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/util/managedfields"
+	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2/textlogger"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+	"k8s.io/kubectl/pkg/util/openapi"
 )
 
 type Option func(*options)
 
+// DefaultIgnoredPodTemplateAnnotations holds the pod template annotations that are ignored by default. The annotations
+// are set by imperative commands such as `kubectl rollout restart` and would otherwise cause permanent differences.
+var DefaultIgnoredPodTemplateAnnotations = []string{
+	"kubectl.kubernetes.io/restartedAt",
+}
+
+// DefaultStripMetadataFields holds the metadata fields that are removed from the compared resources by default. The
+// fields are set by the server and are never meant to be compared.
+var DefaultStripMetadataFields = []string{
+	"generation",
+	"resourceVersion",
+	"uid",
+	"selfLink",
+	"creationTimestamp",
+}
+
 // Holds diffing settings
 type options struct {
 	// If set to true then differences caused by aggregated roles in RBAC resources are ignored.
@@ -20,10 +40,51 @@ type options struct {
 	log                   logr.Logger
 	structuredMergeDiff   bool
 	gvkParser             *managedfields.GvkParser
+	openAPISchema         openapi.Resources
 	manager               string
 	serverSideDiff        bool
 	serverSideDryRunner   ServerSideDryRunner
 	ignoreMutationWebhook bool
+	// Differences between RFC3339 timestamps located at timestampFields are ignored if they are within timestampTolerance.
+	timestampTolerance time.Duration
+	timestampFields    [][]string
+	// If set to true then differences between container images that only differ in the digest are ignored.
+	ignoreImageDigests bool
+	// If set to true then only labels and annotations are compared.
+	metadataOnly bool
+	// If set to true then differences in metadata.finalizers are ignored.
+	ignoreFinalizers bool
+	// If set to true then fields defaulted by the server are added to the predicted live state.
+	serverDefaultedPredictedLive bool
+	// If set to true then differences in spec.replicas are ignored.
+	ignoreReplicas bool
+	// Differences in spec.replicas of the workloads scaled by horizontal pod autoscalers are ignored.
+	autoscaledWorkloads []autoscaledWorkload
+	// If set to true then fields with incompatible types in config and live state fail the diff.
+	strictNormalization bool
+	// Annotations of pod templates that are never compared.
+	ignoredPodTemplateAnnotations []string
+	// If set to true then the textual two-way comparison of config and live state is added to the result.
+	twoWayDeltas bool
+	// Fields whose values are masked in the diff result.
+	sensitivePaths []SensitivePath
+	// Matches of the pattern are removed from resource names and references to ConfigMaps and Secrets.
+	generatedNamePattern *regexp.Regexp
+	// Fields whose boolean and string values are canonicalized before comparison.
+	boolOrStringPaths []BoolOrStringPath
+	// Fields whose duration strings are canonicalized before comparison.
+	durationPaths []DurationPath
+	// Lists whose elements are matched by a merge key when computing the list changes of the result.
+	keyedListPaths []KeyedListPath
+	// If not empty then only the fields owned by these managers or by no manager are compared.
+	userManagers []string
+	// If set to true then the affinity, tolerations and topology spread constraints of pod specs are canonicalized.
+	normalizeScheduling bool
+	// Fields of metadata that are removed from the compared resources.
+	stripMetadataFields []string
+	// Keys of labels and annotations that are removed from the compared resources.
+	ignoredLabelKeys      []string
+	ignoredAnnotationKeys []string
 }
 
 func applyOptions(opts []Option) options {
@@ -32,6 +93,9 @@ func applyOptions(opts []Option) options {
 		ignoreMutationWebhook: true,
 		normalizer:            GetNoopNormalizer(),
 		log:                   textlogger.NewLogger(textlogger.NewConfig()),
+
+		ignoredPodTemplateAnnotations: DefaultIgnoredPodTemplateAnnotations,
+		stripMetadataFields:           DefaultStripMetadataFields,
 	}
 	for _, opt := range opts {
 		opt(&o)
@@ -93,12 +157,24 @@ func WithStructuredMergeDiff(smd bool) Option {
 	}
 }
 
+// WithGVKParser sets the parser used to resolve the schema of the diffed resources in structured merge and server-side
+// diffs. The parser does not need to be loaded from a live cluster: a pre-loaded schema, e.g. one created with
+// kube.NewGVKParserFromOpenAPIV2 from a bundled OpenAPI document, provides the same merge keys and defaulting offline.
 func WithGVKParser(parser *managedfields.GvkParser) Option {
 	return func(o *options) {
 		o.gvkParser = parser
 	}
 }
 
+// WithOpenAPISchema sets the OpenAPI schema of the diffed resources, e.g. the schema loaded by the cluster cache. The
+// duration strings of all the fields the schema declares with the duration format are canonicalized before
+// comparison, the same way as the fields set using WithDurationPaths.
+func WithOpenAPISchema(schema openapi.Resources) Option {
+	return func(o *options) {
+		o.openAPISchema = schema
+	}
+}
+
 func WithManager(manager string) Option {
 	return func(o *options) {
 		o.manager = manager
@@ -122,3 +198,208 @@ func WithServerSideDryRunner(ssadr ServerSideDryRunner) Option {
 		o.serverSideDryRunner = ssadr
 	}
 }
+
+// WithServerDryRun compares the live state with the object returned by a server-side apply of the config in dry-run
+// mode, using the API server of the given REST config. Unlike the offline prediction, the returned object includes the
+// changes of mutating admission webhooks and the defaults set by the API server. The offline diff is used if config is
+// nil.
+func WithServerDryRun(config *rest.Config) Option {
+	if config == nil {
+		return func(o *options) {}
+	}
+	// the runner is shared by all diffs using the option, so the clients and the discovered REST mappings are reused
+	runner := &restConfigDryRunner{config: config}
+	return withServerDryRunner(runner)
+}
+
+func withServerDryRunner(runner ServerSideDryRunner) Option {
+	return func(o *options) {
+		o.serverSideDiff = true
+		o.ignoreMutationWebhook = false
+		o.serverSideDryRunner = runner
+	}
+}
+
+// WithUserManagers compares only the fields that are owned by one of the given field managers according to the
+// managed fields of the live state, or by no manager at all. The fields owned exclusively by other managers, e.g. the
+// controllers that co-manage the resource, are ignored in both the config and the live state. Requires a GVK parser,
+// see WithGVKParser.
+func WithUserManagers(managers ...string) Option {
+	return func(o *options) {
+		o.userManagers = managers
+	}
+}
+
+// WithTimestampTolerance ignores differences between RFC3339 timestamps at the given field paths
+// (e.g. []string{"spec", "lastRotated"}) if the values are no more than tolerance apart.
+func WithTimestampTolerance(tolerance time.Duration, fields ...[]string) Option {
+	return func(o *options) {
+		o.timestampTolerance = tolerance
+		o.timestampFields = fields
+	}
+}
+
+// WithIgnoreImageDigests ignores differences between container images that reference the same tag and only differ in
+// the digest, e.g. "nginx:1.2" in the config and "nginx:1.2@sha256:..." in the live state. This prevents differences
+// caused by digest resolution of mutable tags. Images with different tags or repositories are still compared.
+func WithIgnoreImageDigests(ignoreImageDigests bool) Option {
+	return func(o *options) {
+		o.ignoreImageDigests = ignoreImageDigests
+	}
+}
+
+// WithMetadataOnly restricts the comparison to the labels and annotations of the resources. Annotations managed by
+// the server or by kubectl are not compared.
+func WithMetadataOnly(metadataOnly bool) Option {
+	return func(o *options) {
+		o.metadataOnly = metadataOnly
+	}
+}
+
+// WithServerDefaultedPredictedLive enriches DiffResult.PredictedLive with the fields defaulted by the server. The
+// config is applied using the server-side dry runner (see WithServerSideDryRunner) and the fields missing from the
+// predicted live state are copied from the returned object. The predicted live state is left unchanged if the dry
+// run is not available. The Modified flag of the result is not affected.
+func WithServerDefaultedPredictedLive(enabled bool) Option {
+	return func(o *options) {
+		o.serverDefaultedPredictedLive = enabled
+	}
+}
+
+// WithIgnoreFinalizers drops metadata.finalizers from the compared resources. Useful for resources such as
+// PersistentVolumeClaims whose finalizers are managed by controllers.
+func WithIgnoreFinalizers(ignoreFinalizers bool) Option {
+	return func(o *options) {
+		o.ignoreFinalizers = ignoreFinalizers
+	}
+}
+
+// WithIgnoreReplicas drops spec.replicas from the compared resources. Useful if the replicas of the workloads are
+// managed by an autoscaler that is not part of the compared resources.
+func WithIgnoreReplicas(ignoreReplicas bool) Option {
+	return func(o *options) {
+		o.ignoreReplicas = ignoreReplicas
+	}
+}
+
+// WithHorizontalPodAutoscalers drops spec.replicas from the compared workloads that are the scale target of one of
+// the given HorizontalPodAutoscalers. DiffArray adds the autoscalers found in the compared resources automatically.
+func WithHorizontalPodAutoscalers(hpas ...*unstructured.Unstructured) Option {
+	return func(o *options) {
+		for _, hpa := range hpas {
+			if workload, ok := getAutoscaledWorkload(hpa); ok {
+				o.autoscaledWorkloads = append(o.autoscaledWorkloads, workload)
+			}
+		}
+	}
+}
+
+// WithStrictNormalization fails the diff with an error if a field has incompatible types in the normalized config and
+// live state, e.g. a map in the config and a string in the live state. Such fields are always reported in
+// DiffResult.FieldDeltas. A number and a string are only incompatible if the schema set using WithGVKParser declares
+// the field as either numeric or string, so quantities and int-or-string fields such as maxSurge accept both.
+func WithStrictNormalization(strictNormalization bool) Option {
+	return func(o *options) {
+		o.strictNormalization = strictNormalization
+	}
+}
+
+// WithIgnoredPodTemplateAnnotations replaces the set of annotations that are ignored in the pod templates of workload
+// resources (spec.template.metadata.annotations), which defaults to DefaultIgnoredPodTemplateAnnotations. Calling it
+// without annotations compares all pod template annotations.
+func WithIgnoredPodTemplateAnnotations(annotations ...string) Option {
+	return func(o *options) {
+		o.ignoredPodTemplateAnnotations = annotations
+	}
+}
+
+// WithTwoWayDeltas adds the textual two-way comparison of the config and the live state to DiffResult.TwoWay. This
+// separates what is textually different, e.g. to render it to the user, from whether syncing would change anything,
+// which is still decided by the three-way diff and reported in DiffResult.Modified.
+func WithTwoWayDeltas(twoWayDeltas bool) Option {
+	return func(o *options) {
+		o.twoWayDeltas = twoWayDeltas
+	}
+}
+
+// WithSensitivePaths masks the values of the given fields in DiffResult.NormalizedLive and DiffResult.PredictedLive
+// (and in DiffResult.TwoWay) like HideSecretData does for the data of secrets. This prevents leaking sensitive values
+// embedded in resources other than secrets, e.g. tokens in ConfigMaps. The unmasked values are still compared, so
+// DiffResult.Modified is not affected.
+func WithSensitivePaths(paths ...SensitivePath) Option {
+	return func(o *options) {
+		o.sensitivePaths = paths
+	}
+}
+
+// WithGeneratedNamePattern removes the matches of the given pattern from the names of the compared resources and from
+// the references to ConfigMaps and Secrets in their pod specs, e.g. regexp.MustCompile(`-[a-z0-9]{10}$`) for the hash
+// suffixes of the ConfigMaps and Secrets created by kustomize generators. This prevents differences caused by
+// regenerated names if the content of the generated resources is compared separately.
+func WithGeneratedNamePattern(pattern *regexp.Regexp) Option {
+	return func(o *options) {
+		o.generatedNamePattern = pattern
+	}
+}
+
+// WithBoolOrStringPaths canonicalizes the values of the given fields that accept both booleans and strings, so that
+// e.g. enabled: "true" and enabled: true are considered equal. The values are converted to strings if the schema of
+// the resource, see WithGVKParser, declares the field as string, and to booleans otherwise.
+func WithBoolOrStringPaths(paths ...BoolOrStringPath) Option {
+	return func(o *options) {
+		o.boolOrStringPaths = paths
+	}
+}
+
+// WithDurationPaths canonicalizes the duration strings of the given fields, so that e.g. timeout: 0m30s and
+// timeout: 30s are considered equal. The fields declared with the duration format are normalized automatically if the
+// schema is set using WithOpenAPISchema; use DurationPathsFromCRD if only the CRD is available.
+func WithDurationPaths(paths ...DurationPath) Option {
+	return func(o *options) {
+		o.durationPaths = append(o.durationPaths, paths...)
+	}
+}
+
+// WithKeyedListPaths matches the elements of the given lists by their merge key when computing
+// DiffResult.ListChanges, so that e.g. reordered containers are reported as moved rather than as modified. The lists
+// declared as keyed by a single field in the schema of the resource, see WithGVKParser, are matched automatically.
+func WithKeyedListPaths(paths ...KeyedListPath) Option {
+	return func(o *options) {
+		o.keyedListPaths = append(o.keyedListPaths, paths...)
+	}
+}
+
+// WithSchedulingNormalization canonicalizes spec.affinity, spec.tolerations and spec.topologySpreadConstraints of pods
+// and pod templates before comparison. Terms and requirements whose order has no meaning are sorted and the default
+// operator and effect of tolerations are removed, so that equivalent representations, e.g. reordered by the API
+// server, are not reported as differences.
+func WithSchedulingNormalization(normalizeScheduling bool) Option {
+	return func(o *options) {
+		o.normalizeScheduling = normalizeScheduling
+	}
+}
+
+// WithIgnoredLabels removes the labels with the given keys from both the config and the live state before comparison,
+// e.g. the common labels the sync engine adds to every applied resource.
+func WithIgnoredLabels(keys ...string) Option {
+	return func(o *options) {
+		o.ignoredLabelKeys = append(o.ignoredLabelKeys, keys...)
+	}
+}
+
+// WithIgnoredAnnotations removes the annotations with the given keys from both the config and the live state before
+// comparison, e.g. the common annotations the sync engine adds to every applied resource.
+func WithIgnoredAnnotations(keys ...string) Option {
+	return func(o *options) {
+		o.ignoredAnnotationKeys = append(o.ignoredAnnotationKeys, keys...)
+	}
+}
+
+// WithStripMetadataFields replaces the set of metadata fields that are removed from both the config and the live state
+// before comparison, which defaults to DefaultStripMetadataFields. Calling it without fields compares all metadata
+// fields.
+func WithStripMetadataFields(fields ...string) Option {
+	return func(o *options) {
+		o.stripMetadataFields = fields
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/argoproj/gitops-engine/pkg/diff/mocks"
 	"github.com/argoproj/gitops-engine/pkg/diff/testdata"
@@ -21,13 +23,19 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/managedfields"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	testcore "k8s.io/client-go/testing"
 	"k8s.io/klog/v2/textlogger"
 	openapiproto "k8s.io/kube-openapi/pkg/util/proto"
+	"k8s.io/kubectl/pkg/util/openapi"
 	"sigs.k8s.io/yaml"
 )
 
@@ -273,6 +281,37 @@ func TestDiffArrayModification(t *testing.T) {
 	assert.True(t, diffResList.Modified)
 }
 
+func TestDiffArrayResults(t *testing.T) {
+	unchanged := newDeployment()
+	unchanged.Name = "unchanged"
+	changed := newDeployment()
+	changed.Name = "changed"
+	modified := changed.DeepCopy()
+	ten := int32(10)
+	modified.Spec.Replicas = &ten
+	added := mustToUnstructured(newDeployment())
+	added.SetName("added")
+
+	config := []*unstructured.Unstructured{mustToUnstructured(unchanged), mustToUnstructured(modified), added}
+	live := []*unstructured.Unstructured{mustToUnstructured(unchanged), mustToUnstructured(changed), nil}
+	results, err := DiffArray(config, live, diffOptionsForTest()...)
+	require.NoError(t, err)
+	assert.True(t, results.Modified)
+	require.Len(t, results.Results, 3)
+
+	for i, name := range []string{"unchanged", "changed", "added"} {
+		res := results.Results[i]
+		assert.Equal(t, "apps", res.Group)
+		assert.Equal(t, "Deployment", res.Kind)
+		assert.Equal(t, name, res.Name)
+		assert.Same(t, res, results.Get("apps", "Deployment", res.Namespace, name))
+	}
+	assert.False(t, results.Results[0].Modified)
+	assert.True(t, results.Results[1].Modified)
+	assert.True(t, results.Results[2].Modified)
+	assert.Nil(t, results.Get("apps", "Deployment", "", "unknown"))
+}
+
 // TestThreeWayDiff will perform a diff when there is a kubectl.kubernetes.io/last-applied-configuration
 // present in the live object.
 func TestThreeWayDiff(t *testing.T) {
@@ -691,6 +730,124 @@ func TestInvalidSecretStringData(t *testing.T) {
 	assert.True(t, dr.Modified)
 }
 
+func TestDiffTypeMismatch(t *testing.T) {
+	config := StrToUnstructured(`
+apiVersion: example.com/v1
+kind: Foo
+metadata:
+  name: my-foo
+spec:
+  settings:
+    level: high
+  items:
+  - name: a
+`)
+	live := StrToUnstructured(`
+apiVersion: example.com/v1
+kind: Foo
+metadata:
+  name: my-foo
+spec:
+  settings: high
+  items:
+  - name: true
+`)
+
+	t.Run("Reported", func(t *testing.T) {
+		dr := diff(t, config, live, diffOptionsForTest()...)
+		assert.True(t, dr.Modified)
+		assert.Equal(t, []FieldDelta{
+			{Type: FieldDeltaTypeMismatch, Path: "spec.items[0].name", ConfigType: "string", LiveType: "bool"},
+			{Type: FieldDeltaTypeMismatch, Path: "spec.settings", ConfigType: "map", LiveType: "string"},
+		}, dr.FieldDeltas)
+	})
+
+	t.Run("StrictNormalization", func(t *testing.T) {
+		_, err := Diff(config, live, append(diffOptionsForTest(), WithStrictNormalization(true))...)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "TypeMismatch: spec.items[0].name is string in config but bool in live state")
+	})
+
+	t.Run("NoMismatch", func(t *testing.T) {
+		dr, err := Diff(config, config.DeepCopy(), append(diffOptionsForTest(), WithStrictNormalization(true))...)
+		require.NoError(t, err)
+		assert.Empty(t, dr.FieldDeltas)
+	})
+}
+
+func TestDiffTypeMismatchNumberOrString(t *testing.T) {
+	config := StrToUnstructured(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-deployment
+spec:
+  strategy:
+    rollingUpdate:
+      maxSurge: 1
+      maxUnavailable: 0
+  template:
+    spec:
+      containers:
+      - name: main
+        image: nginx
+        resources:
+          requests:
+            cpu: 1
+            memory: 512Mi
+`)
+	live := StrToUnstructured(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-deployment
+spec:
+  strategy:
+    rollingUpdate:
+      maxSurge: "25%"
+      maxUnavailable: "25%"
+  template:
+    spec:
+      containers:
+      - name: main
+        image: nginx
+        resources:
+          requests:
+            cpu: "1"
+            memory: 512Mi
+`)
+
+	for name, opts := range map[string][]Option{
+		"WithoutSchema": {WithStrictNormalization(true)},
+		"WithSchema":    {WithStrictNormalization(true), WithGVKParser(buildGVKParser(t))},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dr, err := Diff(config, live, append(diffOptionsForTest(), opts...)...)
+			require.NoError(t, err)
+			assert.Empty(t, dr.FieldDeltas)
+			assert.True(t, dr.Modified)
+			paths, err := ChangedPaths(dr)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"spec.strategy.rollingUpdate.maxSurge", "spec.strategy.rollingUpdate.maxUnavailable"}, paths)
+		})
+	}
+
+	t.Run("DeclaredBySchema", func(t *testing.T) {
+		config := config.DeepCopy()
+		require.NoError(t, unstructured.SetNestedField(config.Object, "3", "spec", "replicas"))
+		live := live.DeepCopy()
+		require.NoError(t, unstructured.SetNestedField(live.Object, int64(3), "spec", "replicas"))
+
+		dr, err := Diff(config, live, append(diffOptionsForTest(), WithStrictNormalization(true))...)
+		require.NoError(t, err)
+		assert.Empty(t, dr.FieldDeltas)
+
+		_, err = Diff(config, live, append(diffOptionsForTest(), WithStrictNormalization(true), WithGVKParser(buildGVKParser(t)))...)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "TypeMismatch: spec.replicas is string in config but number in live state")
+	})
+}
+
 func TestNullSecretData(t *testing.T) {
 	configUn := unmarshalFile("testdata/wordpress-config.json")
 	liveUn := unmarshalFile("testdata/wordpress-live.json")
@@ -754,6 +911,888 @@ func TestUnsortedEndpoints(t *testing.T) {
 	}
 }
 
+func TestDiffStrategicMergeLists(t *testing.T) {
+	liveDep := newDeployment()
+	liveDep.Spec.Template.Spec.Containers = append(liveDep.Spec.Template.Spec.Containers, v1.Container{
+		Name:  "sidecar",
+		Image: "busybox",
+		Env:   []v1.EnvVar{{Name: "B", Value: "b"}, {Name: "A", Value: "a"}},
+	})
+	liveDep.Spec.Template.Spec.Volumes = []v1.Volume{{Name: "data"}, {Name: "config"}}
+	liveUn := mustToUnstructured(liveDep)
+
+	t.Run("Reordered", func(t *testing.T) {
+		configDep := liveDep.DeepCopy()
+		containers := configDep.Spec.Template.Spec.Containers
+		containers[0], containers[1] = containers[1], containers[0]
+		containers[0].Env[0], containers[0].Env[1] = containers[0].Env[1], containers[0].Env[0]
+		volumes := configDep.Spec.Template.Spec.Volumes
+		volumes[0], volumes[1] = volumes[1], volumes[0]
+
+		dr := diff(t, mustToUnstructured(configDep), liveUn, diffOptionsForTest()...)
+		assert.False(t, dr.Modified)
+	})
+
+	t.Run("ReorderedWithAddedEnvVar", func(t *testing.T) {
+		configDep := liveDep.DeepCopy()
+		containers := configDep.Spec.Template.Spec.Containers
+		containers[0], containers[1] = containers[1], containers[0]
+		containers[0].Env = append(containers[0].Env, v1.EnvVar{Name: "C", Value: "c"})
+
+		dr := diff(t, mustToUnstructured(configDep), liveUn, diffOptionsForTest()...)
+		assert.True(t, dr.Modified)
+		predicted := YamlToDeploy(t, dr.PredictedLive)
+		normalizedLive := YamlToDeploy(t, dr.NormalizedLive)
+		require.Len(t, predicted.Spec.Template.Spec.Containers, 2)
+		for i, container := range predicted.Spec.Template.Spec.Containers {
+			assert.Equal(t, normalizedLive.Spec.Template.Spec.Containers[i].Name, container.Name)
+		}
+		var sidecar v1.Container
+		for _, container := range predicted.Spec.Template.Spec.Containers {
+			if container.Name == "sidecar" {
+				sidecar = container
+			}
+		}
+		assert.Equal(t, []v1.EnvVar{{Name: "A", Value: "a"}, {Name: "B", Value: "b"}, {Name: "C", Value: "c"}}, sidecar.Env)
+	})
+}
+
+func TestDiffResultMergePatch(t *testing.T) {
+	liveDep := newDeployment()
+	liveDep.Labels = map[string]string{"removed": "true", "kept": "true"}
+	configDep := liveDep.DeepCopy()
+	delete(configDep.Labels, "removed")
+	three := int32(3)
+	configDep.Spec.Replicas = &three
+	lastApplied, err := json.Marshal(liveDep)
+	require.NoError(t, err)
+	liveDep.Annotations = map[string]string{AnnotationLastAppliedConfig: string(lastApplied)}
+
+	dr := diff(t, mustToUnstructured(configDep), mustToUnstructured(liveDep), diffOptionsForTest()...)
+	require.True(t, dr.Modified)
+	patch, err := dr.MergePatch()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"metadata":{"labels":{"removed":null}},"spec":{"replicas":3}}`, string(patch))
+
+	t.Run("MissingLive", func(t *testing.T) {
+		dr := diff(t, mustToUnstructured(configDep), nil, diffOptionsForTest()...)
+		patch, err := dr.MergePatch()
+		require.NoError(t, err)
+		assert.Equal(t, dr.PredictedLive, patch)
+	})
+}
+
+func TestDiffTwoWayDeltas(t *testing.T) {
+	configDep := newDeployment()
+	lastApplied, err := json.Marshal(configDep)
+	require.NoError(t, err)
+	liveDep := configDep.DeepCopy()
+	liveDep.Annotations = map[string]string{AnnotationLastAppliedConfig: string(lastApplied)}
+	// defaulted by the server, so it is not part of the config
+	ten := int32(10)
+	liveDep.Spec.RevisionHistoryLimit = &ten
+	config := mustToUnstructured(configDep)
+	live := mustToUnstructured(liveDep)
+
+	dr := diff(t, config, live, append(diffOptionsForTest(), WithTwoWayDeltas(true))...)
+	assert.False(t, dr.Modified)
+	require.NotNil(t, dr.TwoWay)
+	assert.True(t, dr.TwoWay.Modified)
+	deltas, err := FormatDiff(dr.TwoWay)
+	require.NoError(t, err)
+	assert.Equal(t, "- spec.revisionHistoryLimit: 10\n", deltas)
+
+	t.Run("Disabled", func(t *testing.T) {
+		dr := diff(t, config, live, diffOptionsForTest()...)
+		assert.Nil(t, dr.TwoWay)
+	})
+}
+
+func TestDiffBestMatch(t *testing.T) {
+	newConfigMap := func(name string, data string) *unstructured.Unstructured {
+		return StrToUnstructured(fmt.Sprintf(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %s
+  generateName: settings-
+  namespace: default
+data: %s
+`, name, data))
+	}
+	config := newConfigMap("", `{"a": "1", "b": "2", "c": "3"}`)
+	unstructured.RemoveNestedField(config.Object, "metadata", "name")
+	worse := newConfigMap("settings-x7k2p", `{"a": "1", "b": "20", "c": "30"}`)
+	better := newConfigMap("settings-q9z4m", `{"a": "1", "b": "2", "c": "30"}`)
+	otherKind := better.DeepCopy()
+	otherKind.SetKind("Secret")
+
+	live, dr, err := DiffBestMatch(config, []*unstructured.Unstructured{otherKind, worse, better}, diffOptionsForTest()...)
+	require.NoError(t, err)
+	require.NotNil(t, live)
+	assert.Equal(t, "settings-q9z4m", live.GetName())
+	assert.True(t, dr.Modified)
+	deltas, err := FormatDiff(dr)
+	require.NoError(t, err)
+	assert.Equal(t, "~ data.c: \"30\" -> \"3\"\n", deltas)
+
+	t.Run("NoCandidates", func(t *testing.T) {
+		live, dr, err := DiffBestMatch(config, []*unstructured.Unstructured{otherKind}, diffOptionsForTest()...)
+		require.NoError(t, err)
+		assert.Nil(t, live)
+		assert.Nil(t, dr)
+	})
+}
+
+func TestThreeAndTwoWayDiff(t *testing.T) {
+	appliedDep := newDeployment()
+	appliedDep.Labels = map[string]string{"team": "frontend"}
+	lastApplied, err := json.Marshal(appliedDep)
+	require.NoError(t, err)
+	liveDep := appliedDep.DeepCopy()
+	liveDep.Annotations = map[string]string{AnnotationLastAppliedConfig: string(lastApplied)}
+	config := mustToUnstructured(newDeployment())
+	live := mustToUnstructured(liveDep)
+	liveWithoutLastApplied := live.DeepCopy()
+	liveWithoutLastApplied.SetAnnotations(nil)
+
+	threeWay, twoWay, err := ThreeAndTwoWayDiff(config, live, diffOptionsForTest()...)
+	require.NoError(t, err)
+	// the label removed from the config is only detected using the last applied configuration
+	assert.True(t, threeWay.Modified)
+	assert.False(t, twoWay.Modified)
+
+	expectedThreeWay := diff(t, config, live, diffOptionsForTest()...)
+	assert.Equal(t, expectedThreeWay, threeWay)
+	expectedTwoWay := diff(t, config, liveWithoutLastApplied, diffOptionsForTest()...)
+	assert.Equal(t, expectedTwoWay, twoWay)
+	// the objects passed in are not modified
+	assert.Equal(t, mustToUnstructured(liveDep), live)
+
+	t.Run("SharedPartsAreComputedOnce", func(t *testing.T) {
+		serverLive := config.DeepCopy()
+		require.NoError(t, unstructured.SetNestedField(serverLive.Object, "ClusterFirst", "spec", "template", "spec", "dnsPolicy"))
+		serverLiveBytes, err := json.Marshal(serverLive)
+		require.NoError(t, err)
+		dryRunner := mocks.NewServerSideDryRunner(t)
+		dryRunner.On("Run", mock.Anything, mock.AnythingOfType("*unstructured.Unstructured"), "").Return(string(serverLiveBytes), nil).Once()
+
+		threeWay, twoWay, err := ThreeAndTwoWayDiff(config, live, append(diffOptionsForTest(),
+			WithServerSideDryRunner(dryRunner), WithServerDefaultedPredictedLive(true), WithTwoWayDeltas(true))...)
+		require.NoError(t, err)
+		dryRunner.AssertNumberOfCalls(t, "Run", 1)
+		for _, dr := range []*DiffResult{threeWay, twoWay} {
+			dnsPolicy, _, _ := unstructured.NestedString(bytesToUnstructured(t, dr.PredictedLive).Object, "spec", "template", "spec", "dnsPolicy")
+			assert.Equal(t, "ClusterFirst", dnsPolicy)
+			require.NotNil(t, dr.TwoWay)
+		}
+		assert.Same(t, threeWay.TwoWay, twoWay.TwoWay)
+	})
+}
+
+func TestDiffSensitivePaths(t *testing.T) {
+	newConfigMap := func(token string) *unstructured.Unstructured {
+		return StrToUnstructured(fmt.Sprintf(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-config
+  namespace: default
+data:
+  token: %s
+  url: https://example.com
+`, token))
+	}
+	sensitive := WithSensitivePaths(SensitivePath{GVK: schema.GroupVersionKind{Kind: "ConfigMap"}, Path: "/data/token"})
+
+	t.Run("DifferentValues", func(t *testing.T) {
+		dr := diff(t, newConfigMap("new-secret"), newConfigMap("old-secret"), append(diffOptionsForTest(), sensitive)...)
+		assert.True(t, dr.Modified)
+		assert.NotContains(t, string(dr.NormalizedLive), "old-secret")
+		assert.NotContains(t, string(dr.PredictedLive), "new-secret")
+		deltas, err := FormatDiff(dr)
+		require.NoError(t, err)
+		assert.Equal(t, `~ data.token: "++++++++" -> "++++++++++++"`+"\n", deltas)
+	})
+
+	t.Run("SameValues", func(t *testing.T) {
+		dr := diff(t, newConfigMap("secret"), newConfigMap("secret"), append(diffOptionsForTest(), sensitive)...)
+		assert.False(t, dr.Modified)
+		assert.NotContains(t, string(dr.NormalizedLive), "secret")
+		assert.Contains(t, string(dr.PredictedLive), "https://example.com")
+	})
+
+	t.Run("OtherKind", func(t *testing.T) {
+		opts := append(diffOptionsForTest(), WithSensitivePaths(SensitivePath{GVK: schema.GroupVersionKind{Kind: "Secret"}, Path: "/data/token"}))
+		dr := diff(t, newConfigMap("new-secret"), newConfigMap("old-secret"), opts...)
+		assert.Contains(t, string(dr.NormalizedLive), "old-secret")
+	})
+}
+
+func TestDiffGeneratedNamePattern(t *testing.T) {
+	newConfigMap := func(name string) *unstructured.Unstructured {
+		return StrToUnstructured(fmt.Sprintf(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %s
+  namespace: test
+data:
+  key: value
+`, name))
+	}
+	newDeploymentWithConfig := func(configMapName string) *unstructured.Unstructured {
+		dep := newDeployment()
+		dep.Spec.Template.Spec.Containers[0].EnvFrom = []v1.EnvFromSource{{
+			ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: configMapName}},
+		}}
+		dep.Spec.Template.Spec.Volumes = []v1.Volume{{
+			Name: "config",
+			VolumeSource: v1.VolumeSource{
+				ConfigMap: &v1.ConfigMapVolumeSource{LocalObjectReference: v1.LocalObjectReference{Name: configMapName}},
+			},
+		}}
+		return mustToUnstructured(dep)
+	}
+	pattern := WithGeneratedNamePattern(regexp.MustCompile(`-[a-z0-9]{10}$`))
+
+	t.Run("ConfigMap", func(t *testing.T) {
+		dr := diff(t, newConfigMap("app-config-k7f8g9h2m4"), newConfigMap("app-config-b5t6c8d9f2"), append(diffOptionsForTest(), pattern)...)
+		assert.False(t, dr.Modified)
+	})
+
+	t.Run("ReferencingDeployment", func(t *testing.T) {
+		config := newDeploymentWithConfig("app-config-k7f8g9h2m4")
+		live := newDeploymentWithConfig("app-config-b5t6c8d9f2")
+		dr := diff(t, config, live, append(diffOptionsForTest(), pattern)...)
+		assert.False(t, dr.Modified)
+
+		dr = diff(t, config, live, diffOptionsForTest()...)
+		assert.True(t, dr.Modified)
+	})
+
+	t.Run("DifferentBaseName", func(t *testing.T) {
+		config := newDeploymentWithConfig("app-config-k7f8g9h2m4")
+		live := newDeploymentWithConfig("other-config-b5t6c8d9f2")
+		dr := diff(t, config, live, append(diffOptionsForTest(), pattern)...)
+		assert.True(t, dr.Modified)
+	})
+}
+
+func TestDiffIgnoreRestartedAtAnnotation(t *testing.T) {
+	configDep := newDeployment()
+	configDep.Spec.Template.Annotations = map[string]string{"kubectl.kubernetes.io/restartedAt": "2024-01-01T03:04:05Z"}
+	liveDep := configDep.DeepCopy()
+	liveDep.Spec.Template.Annotations = map[string]string{"kubectl.kubernetes.io/restartedAt": "2024-01-02T03:04:05Z"}
+	config := mustToUnstructured(configDep)
+	live := mustToUnstructured(liveDep)
+
+	t.Run("IgnoredByDefault", func(t *testing.T) {
+		dr := diff(t, config, live, diffOptionsForTest()...)
+		assert.False(t, dr.Modified)
+	})
+
+	t.Run("OptOut", func(t *testing.T) {
+		dr := diff(t, config, live, append(diffOptionsForTest(), WithIgnoredPodTemplateAnnotations())...)
+		assert.True(t, dr.Modified)
+	})
+
+	t.Run("OtherAnnotationsAreCompared", func(t *testing.T) {
+		otherConfigDep := configDep.DeepCopy()
+		otherConfigDep.Spec.Template.Annotations["example.com/checksum"] = "abc"
+		otherLiveDep := liveDep.DeepCopy()
+		otherLiveDep.Spec.Template.Annotations["example.com/checksum"] = "def"
+		dr := diff(t, mustToUnstructured(otherConfigDep), mustToUnstructured(otherLiveDep), diffOptionsForTest()...)
+		assert.True(t, dr.Modified)
+	})
+}
+
+func TestDiffIgnoreAutoscaledReplicas(t *testing.T) {
+	configDep := newDeployment()
+	liveDep := configDep.DeepCopy()
+	five := int32(5)
+	liveDep.Spec.Replicas = &five
+	config := mustToUnstructured(configDep)
+	live := mustToUnstructured(liveDep)
+	hpa := StrToUnstructured(`
+apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: demo
+  namespace: test
+spec:
+  scaleTargetRef:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: demo
+  minReplicas: 2
+  maxReplicas: 10
+`)
+
+	t.Run("NotAutoscaled", func(t *testing.T) {
+		dr := diff(t, config, live, diffOptionsForTest()...)
+		assert.True(t, dr.Modified)
+	})
+
+	t.Run("AutoscalerInDiffedResources", func(t *testing.T) {
+		drs, err := DiffArray([]*unstructured.Unstructured{config, hpa}, []*unstructured.Unstructured{live, hpa}, diffOptionsForTest()...)
+		require.NoError(t, err)
+		assert.False(t, drs.Modified)
+	})
+
+	t.Run("AutoscalerOfOtherWorkload", func(t *testing.T) {
+		other := hpa.DeepCopy()
+		require.NoError(t, unstructured.SetNestedField(other.Object, "other", "spec", "scaleTargetRef", "name"))
+		drs, err := DiffArray([]*unstructured.Unstructured{config, other}, []*unstructured.Unstructured{live, other}, diffOptionsForTest()...)
+		require.NoError(t, err)
+		assert.True(t, drs.Modified)
+	})
+
+	t.Run("ExplicitAutoscaler", func(t *testing.T) {
+		dr := diff(t, config, live, append(diffOptionsForTest(), WithHorizontalPodAutoscalers(hpa))...)
+		assert.False(t, dr.Modified)
+	})
+
+	t.Run("IgnoreReplicas", func(t *testing.T) {
+		dr := diff(t, config, live, append(diffOptionsForTest(), WithIgnoreReplicas(true))...)
+		assert.False(t, dr.Modified)
+	})
+
+	t.Run("IgnoreReplicasAnnotation", func(t *testing.T) {
+		annotatedConfigDep := configDep.DeepCopy()
+		annotatedConfigDep.Annotations = map[string]string{AnnotationIgnoreReplicas: "true"}
+		annotatedLiveDep := liveDep.DeepCopy()
+		annotatedLiveDep.Annotations = map[string]string{AnnotationIgnoreReplicas: "true"}
+		dr := diff(t, mustToUnstructured(annotatedConfigDep), mustToUnstructured(annotatedLiveDep), diffOptionsForTest()...)
+		assert.False(t, dr.Modified)
+
+		// the live state is not annotated yet, so only the annotation differs
+		dr = diff(t, mustToUnstructured(annotatedConfigDep), live, diffOptionsForTest()...)
+		assert.True(t, dr.Modified)
+		assert.NotContains(t, dr.Explain(), "spec.replicas:")
+	})
+}
+
+func TestDiffStripMetadataFields(t *testing.T) {
+	// the config of some custom resources mistakenly includes metadata set by the server
+	config := StrToUnstructured(`
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: my-widget
+  namespace: default
+  generation: 1
+  resourceVersion: "100"
+spec:
+  size: 3
+`)
+	live := StrToUnstructured(`
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: my-widget
+  namespace: default
+  generation: 4
+  resourceVersion: "2345"
+  uid: 0f4a5b5e-4d1c-4b8e-9b4e-3c2f1d0e9a8b
+  creationTimestamp: "2024-01-01T00:00:00Z"
+spec:
+  size: 3
+`)
+
+	dr := diff(t, config, live, diffOptionsForTest()...)
+	assert.False(t, dr.Modified)
+
+	dr = diff(t, config, live, append(diffOptionsForTest(), WithStripMetadataFields("resourceVersion", "uid", "creationTimestamp"))...)
+	assert.True(t, dr.Modified)
+	assert.Contains(t, dr.Explain(), "metadata.generation")
+}
+
+func TestDiffIgnoresManagedFields(t *testing.T) {
+	// the config of the custom resource was exported from the cluster before the migration to server-side apply
+	config := StrToUnstructured(`
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: my-widget
+  namespace: default
+  managedFields:
+  - apiVersion: example.com/v1
+    fieldsType: FieldsV1
+    fieldsV1:
+      f:spec:
+        f:size: {}
+    manager: kubectl-client-side-apply
+    operation: Update
+spec:
+  size: 3
+`)
+	live := StrToUnstructured(`
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: my-widget
+  namespace: default
+  managedFields:
+  - apiVersion: example.com/v1
+    fieldsType: FieldsV1
+    fieldsV1:
+      f:spec:
+        f:size: {}
+    manager: argocd-controller
+    operation: Apply
+spec:
+  size: 3
+`)
+	assertNoManagedFields := func(t *testing.T, dr *DiffResult) {
+		t.Helper()
+		for _, data := range [][]byte{dr.NormalizedLive, dr.PredictedLive} {
+			var obj map[string]interface{}
+			require.NoError(t, json.Unmarshal(data, &obj))
+			_, found, _ := unstructured.NestedFieldNoCopy(obj, "metadata", "managedFields")
+			assert.False(t, found)
+		}
+	}
+
+	t.Run("TwoWay", func(t *testing.T) {
+		dr := diff(t, config, live, append(diffOptionsForTest(), WithTwoWayDeltas(true))...)
+		assert.False(t, dr.Modified)
+		assertNoManagedFields(t, dr)
+		require.NotNil(t, dr.TwoWay)
+		assert.False(t, dr.TwoWay.Modified)
+		assertNoManagedFields(t, dr.TwoWay)
+	})
+
+	t.Run("ThreeWay", func(t *testing.T) {
+		lastApplied, err := json.Marshal(config)
+		require.NoError(t, err)
+		live := live.DeepCopy()
+		live.SetAnnotations(map[string]string{AnnotationLastAppliedConfig: string(lastApplied)})
+		dr := diff(t, config, live, diffOptionsForTest()...)
+		assert.False(t, dr.Modified)
+		assertNoManagedFields(t, dr)
+	})
+
+	t.Run("Modified", func(t *testing.T) {
+		config := config.DeepCopy()
+		require.NoError(t, unstructured.SetNestedField(config.Object, int64(5), "spec", "size"))
+		dr := diff(t, config, live, diffOptionsForTest()...)
+		assert.True(t, dr.Modified)
+		assertNoManagedFields(t, dr)
+		deltas, err := FormatDiff(dr)
+		require.NoError(t, err)
+		assert.Equal(t, "~ spec.size: 3 -> 5\n", deltas)
+	})
+}
+
+func TestRemoveManagedFields(t *testing.T) {
+	withManagedFields := []byte(`{"metadata":{"name":"my-widget","managedFields":[{"manager":"kubectl"}]},"spec":{"size":3}}`)
+
+	t.Run("EqualWithoutManagedFields", func(t *testing.T) {
+		dr, err := removeManagedFields(&DiffResult{
+			Modified:       true,
+			NormalizedLive: withManagedFields,
+			PredictedLive:  []byte(`{"spec":{"size":3},"metadata":{"name":"my-widget"}}`),
+		})
+		require.NoError(t, err)
+		assert.False(t, dr.Modified)
+		assert.Equal(t, string(dr.NormalizedLive), string(dr.PredictedLive))
+	})
+
+	t.Run("NotModifiedIsKept", func(t *testing.T) {
+		dr, err := removeManagedFields(&DiffResult{
+			Modified:       false,
+			NormalizedLive: withManagedFields,
+			PredictedLive:  []byte(`{"metadata":{"name":"my-widget"},"spec":{"size":5}}`),
+		})
+		require.NoError(t, err)
+		assert.False(t, dr.Modified)
+	})
+
+	t.Run("ModifiedIsKept", func(t *testing.T) {
+		dr, err := removeManagedFields(&DiffResult{
+			Modified:       true,
+			NormalizedLive: withManagedFields,
+			PredictedLive:  []byte(`{"metadata":{"name":"my-widget"},"spec":{"size":5}}`),
+		})
+		require.NoError(t, err)
+		assert.True(t, dr.Modified)
+	})
+}
+
+func TestDiffSchedulingNormalization(t *testing.T) {
+	config := StrToUnstructured(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+  namespace: default
+spec:
+  template:
+    spec:
+      affinity:
+        nodeAffinity:
+          requiredDuringSchedulingIgnoredDuringExecution:
+            nodeSelectorTerms:
+            - matchExpressions:
+              - key: zone
+                operator: In
+                values: [us-east-1b, us-east-1a]
+              - key: arch
+                operator: In
+                values: [amd64]
+            - matchExpressions:
+              - key: pool
+                operator: Exists
+        podAntiAffinity:
+          preferredDuringSchedulingIgnoredDuringExecution:
+          - weight: 100
+            podAffinityTerm:
+              topologyKey: kubernetes.io/hostname
+              labelSelector:
+                matchExpressions:
+                - key: app
+                  operator: In
+                  values: [web, api]
+      tolerations:
+      - key: dedicated
+        operator: Equal
+        value: gpu
+        effect: NoSchedule
+      - key: node.kubernetes.io/not-ready
+        operator: Exists
+        effect: NoExecute
+        tolerationSeconds: 300
+      topologySpreadConstraints:
+      - maxSkew: 1
+        topologyKey: zone
+        whenUnsatisfiable: DoNotSchedule
+      - maxSkew: 1
+        topologyKey: kubernetes.io/hostname
+        whenUnsatisfiable: ScheduleAnyway
+      containers:
+      - name: app
+        image: nginx
+`)
+	live := StrToUnstructured(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+  namespace: default
+spec:
+  template:
+    spec:
+      affinity:
+        nodeAffinity:
+          requiredDuringSchedulingIgnoredDuringExecution:
+            nodeSelectorTerms:
+            - matchExpressions:
+              - key: pool
+                operator: Exists
+            - matchExpressions:
+              - key: arch
+                operator: In
+                values: [amd64]
+              - key: zone
+                operator: In
+                values: [us-east-1a, us-east-1b]
+        podAntiAffinity:
+          preferredDuringSchedulingIgnoredDuringExecution:
+          - weight: 100
+            podAffinityTerm:
+              topologyKey: kubernetes.io/hostname
+              labelSelector:
+                matchExpressions:
+                - key: app
+                  operator: In
+                  values: [api, web]
+      tolerations:
+      - key: node.kubernetes.io/not-ready
+        operator: Exists
+        effect: NoExecute
+        tolerationSeconds: 300
+      - key: dedicated
+        value: gpu
+        effect: NoSchedule
+      topologySpreadConstraints:
+      - maxSkew: 1
+        topologyKey: kubernetes.io/hostname
+        whenUnsatisfiable: ScheduleAnyway
+      - maxSkew: 1
+        topologyKey: zone
+        whenUnsatisfiable: DoNotSchedule
+      containers:
+      - name: app
+        image: nginx
+`)
+	opts := append(diffOptionsForTest(), WithSchedulingNormalization(true))
+
+	t.Run("EquivalentRepresentations", func(t *testing.T) {
+		dr := diff(t, config, live, opts...)
+		assert.False(t, dr.Modified)
+
+		dr = diff(t, config, live, diffOptionsForTest()...)
+		assert.True(t, dr.Modified)
+	})
+
+	t.Run("ChangedToleration", func(t *testing.T) {
+		changed := config.DeepCopy()
+		tolerations, _, err := unstructured.NestedSlice(changed.Object, "spec", "template", "spec", "tolerations")
+		require.NoError(t, err)
+		tolerations[0].(map[string]interface{})["value"] = "tpu"
+		require.NoError(t, unstructured.SetNestedSlice(changed.Object, tolerations, "spec", "template", "spec", "tolerations"))
+
+		dr := diff(t, changed, live, opts...)
+		assert.True(t, dr.Modified)
+	})
+}
+
+func TestDiffUserManagers(t *testing.T) {
+	live := StrToUnstructured(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-config
+  namespace: default
+  managedFields:
+  - manager: our-controller
+    operation: Apply
+    apiVersion: v1
+    fieldsType: FieldsV1
+    fieldsV1:
+      f:data:
+        f:owned: {}
+  - manager: other-controller
+    operation: Update
+    apiVersion: v1
+    fieldsType: FieldsV1
+    fieldsV1:
+      f:data:
+        f:injected: {}
+data:
+  owned: value
+  injected: live-value
+`)
+	newConfig := func(owned string) *unstructured.Unstructured {
+		return StrToUnstructured(fmt.Sprintf(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-config
+  namespace: default
+data:
+  owned: %s
+  injected: config-value
+`, owned))
+	}
+	opts := append(diffOptionsForTest(), WithGVKParser(buildGVKParser(t)), WithUserManagers("our-controller"))
+
+	t.Run("OtherManagerFieldIgnored", func(t *testing.T) {
+		dr := diff(t, newConfig("value"), live, opts...)
+		assert.False(t, dr.Modified)
+
+		dr = diff(t, newConfig("value"), live, diffOptionsForTest()...)
+		assert.True(t, dr.Modified)
+	})
+
+	t.Run("UserManagerFieldCompared", func(t *testing.T) {
+		dr := diff(t, newConfig("changed"), live, opts...)
+		assert.True(t, dr.Modified)
+		assert.Contains(t, dr.Explain(), "data.owned: changed")
+		assert.NotContains(t, dr.Explain(), "data.injected")
+	})
+
+	t.Run("MissingGVKParser", func(t *testing.T) {
+		_, err := Diff(newConfig("value"), live, append(diffOptionsForTest(), WithUserManagers("our-controller"))...)
+		assert.Error(t, err)
+	})
+}
+
+func TestDiffTimestampTolerance(t *testing.T) {
+	newObj := func(lastRotated string) *unstructured.Unstructured {
+		return StrToUnstructured(fmt.Sprintf(`
+apiVersion: example.com/v1
+kind: Certificate
+metadata:
+  name: my-cert
+  namespace: default
+spec:
+  lastRotated: "%s"
+`, lastRotated))
+	}
+	configUn := newObj("2024-01-01T00:00:00Z")
+	liveUn := newObj("2024-01-01T00:00:01Z")
+	fields := []string{"spec", "lastRotated"}
+
+	t.Run("WithinTolerance", func(t *testing.T) {
+		opts := append(diffOptionsForTest(), WithTimestampTolerance(5*time.Second, fields))
+		dr := diff(t, configUn, liveUn, opts...)
+		assert.False(t, dr.Modified)
+	})
+
+	t.Run("OutsideTolerance", func(t *testing.T) {
+		opts := append(diffOptionsForTest(), WithTimestampTolerance(500*time.Millisecond, fields))
+		dr := diff(t, configUn, liveUn, opts...)
+		assert.True(t, dr.Modified)
+	})
+
+	t.Run("NoTolerance", func(t *testing.T) {
+		dr := diff(t, configUn, liveUn, diffOptionsForTest()...)
+		assert.True(t, dr.Modified)
+	})
+}
+
+func TestDiffIgnoreImageDigests(t *testing.T) {
+	configDep := newDeployment()
+	configDep.Spec.Template.Spec.Containers[0].Image = "nginx:1.2"
+	liveDep := configDep.DeepCopy()
+	liveDep.Spec.Template.Spec.Containers[0].Image = "nginx:1.2@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	config := mustToUnstructured(configDep)
+	live := mustToUnstructured(liveDep)
+
+	t.Run("DigestIgnored", func(t *testing.T) {
+		dr := diff(t, config, live, append(diffOptionsForTest(), WithIgnoreImageDigests(true))...)
+		assert.False(t, dr.Modified)
+	})
+
+	t.Run("DigestCompared", func(t *testing.T) {
+		dr := diff(t, config, live, diffOptionsForTest()...)
+		assert.True(t, dr.Modified)
+	})
+
+	t.Run("TagDifferent", func(t *testing.T) {
+		otherDep := liveDep.DeepCopy()
+		otherDep.Spec.Template.Spec.Containers[0].Image = "nginx:1.3@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+		dr := diff(t, config, mustToUnstructured(otherDep), append(diffOptionsForTest(), WithIgnoreImageDigests(true))...)
+		assert.True(t, dr.Modified)
+	})
+}
+
+func TestDiffMetadataOnly(t *testing.T) {
+	configDep := newDeployment()
+	configDep.Labels = map[string]string{"team": "a"}
+	configDep.Annotations = map[string]string{"owner": "a"}
+	liveDep := configDep.DeepCopy()
+	three := int32(3)
+	liveDep.Spec.Replicas = &three
+	liveDep.Spec.Template.Spec.Containers[0].Image = "gcr.io/kuar-demo/kuard-amd64:2"
+	liveDep.Annotations["deployment.kubernetes.io/revision"] = "2"
+
+	t.Run("SpecDiffers", func(t *testing.T) {
+		dr := diff(t, mustToUnstructured(configDep), mustToUnstructured(liveDep), append(diffOptionsForTest(), WithMetadataOnly(true))...)
+		assert.False(t, dr.Modified)
+
+		dr = diff(t, mustToUnstructured(configDep), mustToUnstructured(liveDep), diffOptionsForTest()...)
+		assert.True(t, dr.Modified)
+	})
+
+	t.Run("LabelDiffers", func(t *testing.T) {
+		driftedDep := liveDep.DeepCopy()
+		driftedDep.Labels["team"] = "b"
+		dr := diff(t, mustToUnstructured(configDep), mustToUnstructured(driftedDep), append(diffOptionsForTest(), WithMetadataOnly(true))...)
+		assert.True(t, dr.Modified)
+	})
+
+	t.Run("AnnotationDiffers", func(t *testing.T) {
+		driftedDep := liveDep.DeepCopy()
+		driftedDep.Annotations["owner"] = "b"
+		dr := diff(t, mustToUnstructured(configDep), mustToUnstructured(driftedDep), append(diffOptionsForTest(), WithMetadataOnly(true))...)
+		assert.True(t, dr.Modified)
+	})
+}
+
+func TestDiffIgnoresDeployID(t *testing.T) {
+	configUn := mustToUnstructured(newDeployment())
+	liveUn := configUn.DeepCopy()
+	liveUn.SetAnnotations(map[string]string{"gitops-engine.io/deploy-id": "deploy-1"})
+
+	dr := diff(t, configUn, liveUn, diffOptionsForTest()...)
+	assert.False(t, dr.Modified)
+
+	configUn.SetAnnotations(map[string]string{"gitops-engine.io/deploy-id": "deploy-2"})
+	dr = diff(t, configUn, liveUn, diffOptionsForTest()...)
+	assert.False(t, dr.Modified)
+}
+
+func TestDiffIgnoresManifestHash(t *testing.T) {
+	configUn := mustToUnstructured(newDeployment())
+	applied := configUn.DeepCopy()
+	applied.SetAnnotations(map[string]string{"gitops-engine.io/manifest-hash": "abc"})
+	lastApplied, err := json.Marshal(applied.Object)
+	require.NoError(t, err)
+	liveUn := applied.DeepCopy()
+	liveUn.SetAnnotations(map[string]string{
+		"gitops-engine.io/manifest-hash": "abc",
+		AnnotationLastAppliedConfig:      string(lastApplied),
+	})
+
+	dr := diff(t, configUn, liveUn, diffOptionsForTest()...)
+	assert.False(t, dr.Modified)
+}
+
+func TestDiffIgnoreFinalizers(t *testing.T) {
+	configUn := mustToUnstructured(newDeployment())
+	configUn.SetFinalizers([]string{"example.com/cleanup"})
+	liveUn := mustToUnstructured(newDeployment())
+	liveUn.SetFinalizers([]string{"foregroundDeletion"})
+
+	dr := diff(t, configUn, liveUn, diffOptionsForTest()...)
+	assert.True(t, dr.Modified)
+
+	dr = diff(t, configUn, liveUn, append(diffOptionsForTest(), WithIgnoreFinalizers(true))...)
+	assert.False(t, dr.Modified)
+}
+
+func TestDiffServerDefaultedPredictedLive(t *testing.T) {
+	config := StrToUnstructured(`
+apiVersion: v1
+kind: Service
+metadata:
+  name: my-svc
+  namespace: default
+spec:
+  ports:
+  - port: 80
+  selector:
+    app: my-app
+`)
+	serverLive := config.DeepCopy()
+	require.NoError(t, unstructured.SetNestedField(serverLive.Object, "None", "spec", "sessionAffinity"))
+	require.NoError(t, unstructured.SetNestedField(serverLive.Object, "ClusterIP", "spec", "type"))
+	require.NoError(t, unstructured.SetNestedField(serverLive.Object, "mutated", "spec", "selector", "app"))
+	serverLiveBytes, err := json.Marshal(serverLive)
+	require.NoError(t, err)
+
+	t.Run("DefaultsAreAdded", func(t *testing.T) {
+		dryRunner := mocks.NewServerSideDryRunner(t)
+		dryRunner.On("Run", mock.Anything, mock.AnythingOfType("*unstructured.Unstructured"), "").Return(string(serverLiveBytes), nil)
+
+		dr := diff(t, config, nil, append(diffOptionsForTest(), WithServerSideDryRunner(dryRunner), WithServerDefaultedPredictedLive(true))...)
+
+		assert.True(t, dr.Modified)
+		predictedLive := bytesToUnstructured(t, dr.PredictedLive)
+		sessionAffinity, _, _ := unstructured.NestedString(predictedLive.Object, "spec", "sessionAffinity")
+		assert.Equal(t, "None", sessionAffinity)
+		serviceType, _, _ := unstructured.NestedString(predictedLive.Object, "spec", "type")
+		assert.Equal(t, "ClusterIP", serviceType)
+		selector, _, _ := unstructured.NestedStringMap(predictedLive.Object, "spec", "selector")
+		// values of the predicted live state are never overwritten
+		assert.Equal(t, map[string]string{"app": "my-app"}, selector)
+	})
+
+	t.Run("FallbackOnDryRunError", func(t *testing.T) {
+		dryRunner := mocks.NewServerSideDryRunner(t)
+		dryRunner.On("Run", mock.Anything, mock.AnythingOfType("*unstructured.Unstructured"), "").Return("", fmt.Errorf("dry run not supported"))
+
+		dr := diff(t, config, nil, append(diffOptionsForTest(), WithServerSideDryRunner(dryRunner), WithServerDefaultedPredictedLive(true))...)
+
+		predictedLive := bytesToUnstructured(t, dr.PredictedLive)
+		_, found, _ := unstructured.NestedString(predictedLive.Object, "spec", "sessionAffinity")
+		assert.False(t, found)
+	})
+}
+
 func buildGVKParser(t *testing.T) *managedfields.GvkParser {
 	document := &openapi_v2.Document{}
 	err := proto.Unmarshal(testdata.OpenAPIV2Doc, document)
@@ -1253,6 +2292,45 @@ func TestHideSecretDataLastAppliedConfig(t *testing.T) {
 
 }
 
+func TestSecretTypeChange(t *testing.T) {
+	newSecret := func(secretType corev1.SecretType, data map[string]string) *unstructured.Unstructured {
+		secret := createSecret(data)
+		secret.SetAPIVersion("v1")
+		secret.SetName("registry")
+		secret.SetNamespace("default")
+		require.NoError(t, unstructured.SetNestedField(secret.Object, string(secretType), "type"))
+		return secret
+	}
+	data := map[string]string{corev1.DockerConfigJsonKey: `{"auths":{}}`}
+	// the data is masked, so the live and target data cannot be distinguished
+	config, live, err := HideSecretData(newSecret(corev1.SecretTypeDockerConfigJson, data), newSecret(corev1.SecretTypeOpaque, data), nil)
+	require.NoError(t, err)
+	opts := append(diffOptionsForTest(),
+		WithNormalizer(NewIgnoreDifferencesNormalizer([]IgnoreDifference{{Kind: "Secret", JSONPointers: []string{"/type"}}})),
+		WithSensitivePaths(SensitivePath{GVK: schema.GroupVersionKind{Kind: "Secret"}, Path: "/type"}))
+
+	dr := diff(t, config, live, opts...)
+	assert.True(t, dr.Modified)
+	assert.Equal(t, []FieldDelta{{
+		Type:       FieldDeltaSecretTypeChange,
+		Path:       "type",
+		ConfigType: string(corev1.SecretTypeDockerConfigJson),
+		LiveType:   string(corev1.SecretTypeOpaque),
+	}}, dr.FieldDeltas)
+	deltas, err := FormatDiff(dr)
+	require.NoError(t, err)
+	assert.Equal(t, `~ type: "Opaque" -> "kubernetes.io/dockerconfigjson"`+"\n", deltas)
+	assert.Contains(t, dr.Explain(), "SecretTypeChange: Secret type changes from Opaque to kubernetes.io/dockerconfigjson, which requires replacing the Secret (e.g. Replace=true)")
+
+	t.Run("SameType", func(t *testing.T) {
+		config, live, err := HideSecretData(newSecret(corev1.SecretTypeOpaque, data), newSecret(corev1.SecretTypeOpaque, data), nil)
+		require.NoError(t, err)
+		dr := diff(t, config, live, opts...)
+		assert.False(t, dr.Modified)
+		assert.Empty(t, dr.FieldDeltas)
+	})
+}
+
 func TestRemarshal(t *testing.T) {
 	manifest := []byte(`
 apiVersion: v1
@@ -1403,3 +2481,267 @@ func StrToUnstructured(yamlStr string) *unstructured.Unstructured {
 	}
 	return &unstructured.Unstructured{Object: obj}
 }
+
+func TestDiffResultExplain(t *testing.T) {
+	configDep := newDeployment()
+	configDep.Labels = map[string]string{"team": "a"}
+	liveDep := configDep.DeepCopy()
+	liveDep.Labels = nil
+	liveDep.Spec.Template.Spec.Containers[0].Image = "nginx:1.2"
+	configDep.Spec.Template.Spec.Containers[0].Image = "nginx:1.3"
+	config := mustToUnstructured(configDep)
+	live := mustToUnstructured(liveDep)
+
+	t.Run("Modified", func(t *testing.T) {
+		dr := diff(t, config, live, append(diffOptionsForTest(), WithIgnoreFinalizers(true))...)
+		explanation := dr.Explain()
+		assert.Contains(t, explanation, "metadata.labels.team: added")
+		assert.Contains(t, explanation, "spec.template.spec.containers[0].image: changed")
+		assert.Contains(t, explanation, "metadata.finalizers are ignored")
+		assert.NotContains(t, explanation, "spec.replicas")
+	})
+
+	t.Run("InSync", func(t *testing.T) {
+		dr := diff(t, config, config, diffOptionsForTest()...)
+		assert.Contains(t, dr.Explain(), "Resource is in sync")
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		dr := diff(t, config, nil, diffOptionsForTest()...)
+		assert.Contains(t, dr.Explain(), "does not exist in the live state")
+	})
+}
+
+func TestDiffDurationPaths(t *testing.T) {
+	config := StrToUnstructured(`
+apiVersion: example.com/v1
+kind: Pipeline
+metadata:
+  name: my-pipeline
+spec:
+  timeout: 0m30s
+  steps:
+  - name: build
+    timeout: 1h0m0s
+`)
+	live := StrToUnstructured(`
+apiVersion: example.com/v1
+kind: Pipeline
+metadata:
+  name: my-pipeline
+spec:
+  timeout: 30s
+  steps:
+  - name: build
+    timeout: 60m
+`)
+	gvk := schema.GroupVersionKind{Group: "example.com", Kind: "Pipeline"}
+
+	t.Run("Canonicalized", func(t *testing.T) {
+		dr := diff(t, config, live, append(diffOptionsForTest(), WithDurationPaths(
+			DurationPath{GVK: gvk, Path: "/spec/timeout"},
+			DurationPath{GVK: gvk, Path: "/spec/steps/*/timeout"},
+		))...)
+		assert.False(t, dr.Modified)
+	})
+
+	t.Run("NotConfigured", func(t *testing.T) {
+		dr := diff(t, config, live, append(diffOptionsForTest(), WithDurationPaths(DurationPath{GVK: gvk, Path: "/spec/timeout"}))...)
+		assert.True(t, dr.Modified)
+	})
+
+	t.Run("DifferentValue", func(t *testing.T) {
+		other := live.DeepCopy()
+		require.NoError(t, unstructured.SetNestedField(other.Object, "31s", "spec", "timeout"))
+		dr := diff(t, config, other, append(diffOptionsForTest(), WithDurationPaths(DurationPath{GVK: gvk, Path: "/spec/timeout"}))...)
+		assert.True(t, dr.Modified)
+	})
+
+	t.Run("FromCRD", func(t *testing.T) {
+		crd := StrToUnstructured(`
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: pipelines.example.com
+spec:
+  group: example.com
+  names:
+    kind: Pipeline
+  versions:
+  - name: v1
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            properties:
+              timeout:
+                type: string
+                format: duration
+              steps:
+                type: array
+                items:
+                  type: object
+                  properties:
+                    name:
+                      type: string
+                    timeout:
+                      type: string
+                      format: duration
+`)
+		paths := DurationPathsFromCRD(crd)
+		assert.ElementsMatch(t, []DurationPath{
+			{GVK: schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Pipeline"}, Path: "/spec/timeout"},
+			{GVK: schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Pipeline"}, Path: "/spec/steps/*/timeout"},
+		}, paths)
+		dr := diff(t, config, live, append(diffOptionsForTest(), WithDurationPaths(paths...))...)
+		assert.False(t, dr.Modified)
+	})
+
+	t.Run("FromOpenAPISchema", func(t *testing.T) {
+		document, err := openapi_v2.ParseDocument([]byte(`
+swagger: "2.0"
+info:
+  title: example
+  version: v1
+paths: {}
+definitions:
+  com.example.v1.Pipeline:
+    type: object
+    x-kubernetes-group-version-kind:
+    - group: example.com
+      version: v1
+      kind: Pipeline
+    properties:
+      apiVersion:
+        type: string
+      kind:
+        type: string
+      spec:
+        type: object
+        properties:
+          timeout:
+            type: string
+            format: duration
+          steps:
+            type: array
+            items:
+              $ref: '#/definitions/com.example.v1.Step'
+  com.example.v1.Step:
+    type: object
+    properties:
+      name:
+        type: string
+      timeout:
+        type: string
+        format: duration
+`))
+		require.NoError(t, err)
+		resources, err := openapi.NewOpenAPIData(document)
+		require.NoError(t, err)
+
+		// 1h0m0s and 60m are equal once the durations declared by the schema are canonicalized
+		res, err := Diff(config, live, append(diffOptionsForTest(), WithOpenAPISchema(resources))...)
+		require.NoError(t, err)
+		assert.False(t, res.Modified)
+
+		other := live.DeepCopy()
+		require.NoError(t, unstructured.SetNestedField(other.Object, "31s", "spec", "timeout"))
+		res, err = Diff(config, other, append(diffOptionsForTest(), WithOpenAPISchema(resources))...)
+		require.NoError(t, err)
+		assert.True(t, res.Modified)
+	})
+}
+
+func TestDiffBoolOrStringPaths(t *testing.T) {
+	config := StrToUnstructured(`
+apiVersion: example.com/v1
+kind: Feature
+metadata:
+  name: my-feature
+spec:
+  enabled: "true"
+`)
+	live := StrToUnstructured(`
+apiVersion: example.com/v1
+kind: Feature
+metadata:
+  name: my-feature
+spec:
+  enabled: true
+`)
+	path := BoolOrStringPath{GVK: schema.GroupVersionKind{Group: "example.com", Kind: "Feature"}, Path: "/spec/enabled"}
+
+	t.Run("Canonicalized", func(t *testing.T) {
+		dr := diff(t, config, live, append(diffOptionsForTest(), WithBoolOrStringPaths(path))...)
+		assert.False(t, dr.Modified)
+	})
+
+	t.Run("NotConfigured", func(t *testing.T) {
+		dr := diff(t, config, live, diffOptionsForTest()...)
+		assert.True(t, dr.Modified)
+	})
+
+	t.Run("DifferentValue", func(t *testing.T) {
+		other := live.DeepCopy()
+		require.NoError(t, unstructured.SetNestedField(other.Object, false, "spec", "enabled"))
+		dr := diff(t, config, other, append(diffOptionsForTest(), WithBoolOrStringPaths(path))...)
+		assert.True(t, dr.Modified)
+	})
+
+	t.Run("SchemaDeclaresString", func(t *testing.T) {
+		cm := StrToUnstructured(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-config
+data:
+  enabled: true
+`)
+		Normalize(cm, WithGVKParser(buildGVKParser(t)), WithBoolOrStringPaths(BoolOrStringPath{GVK: schema.GroupVersionKind{Kind: "ConfigMap"}, Path: "/data/enabled"}))
+		val, _, _ := unstructured.NestedFieldNoCopy(cm.Object, "data", "enabled")
+		assert.Equal(t, "true", val)
+	})
+}
+
+func TestDiffServerDryRun(t *testing.T) {
+	config := StrToUnstructured(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-config
+  namespace: default
+data:
+  key: value
+`)
+	live := config.DeepCopy()
+
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	// simulates a mutating admission webhook that adds a label
+	client.PrependReactor("patch", "configmaps", func(action testcore.Action) (bool, runtime.Object, error) {
+		patch := action.(testcore.PatchAction)
+		assert.Equal(t, types.ApplyPatchType, patch.GetPatchType())
+		obj := &unstructured.Unstructured{}
+		if err := json.Unmarshal(patch.GetPatch(), &obj.Object); err != nil {
+			return true, nil, err
+		}
+		obj.SetLabels(map[string]string{"injected-by-webhook": "true"})
+		return true, obj, nil
+	})
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Version: "v1"}})
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	runner := &restConfigDryRunner{dynamicClient: client, mapper: mapper}
+
+	t.Run("MutationDetected", func(t *testing.T) {
+		dr := diff(t, config, live, append(diffOptionsForTest(), withServerDryRunner(runner))...)
+		assert.True(t, dr.Modified)
+		predictedLive := bytesToUnstructured(t, dr.PredictedLive)
+		assert.Equal(t, "true", predictedLive.GetLabels()["injected-by-webhook"])
+	})
+
+	t.Run("OfflineFallback", func(t *testing.T) {
+		dr := diff(t, config, live, append(diffOptionsForTest(), WithServerDryRun(nil))...)
+		assert.False(t, dr.Modified)
+	})
+}
@@ -0,0 +1,176 @@
+package diff
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/kube-openapi/pkg/util/proto"
+	"k8s.io/kubectl/pkg/util/openapi"
+)
+
+// durationPathWildcard is the token of a DurationPath that matches all elements of a list or all values of a map
+const durationPathWildcard = "*"
+
+// DurationPath identifies a field of the resources of a kind that holds a duration string, e.g. "30s"
+type DurationPath struct {
+	// GVK is the kind of the resources the path applies to. The path applies to all versions of the kind if the
+	// version is empty.
+	GVK schema.GroupVersionKind
+	// Path is a JSON pointer (RFC 6901) to the field, e.g. /spec/timeout. A * token matches all elements of a list or
+	// all values of a map, e.g. /spec/steps/*/timeout.
+	Path string
+}
+
+func (p DurationPath) matches(gvk schema.GroupVersionKind) bool {
+	return p.GVK.Group == gvk.Group && p.GVK.Kind == gvk.Kind && (p.GVK.Version == "" || p.GVK.Version == gvk.Version)
+}
+
+// DurationPathsFromCRD returns the paths of the fields the schema of the given CustomResourceDefinition declares with
+// the duration format, for each version of the CRD
+func DurationPathsFromCRD(crd *unstructured.Unstructured) []DurationPath {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	var paths []DurationPath
+	for _, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(version, "name")
+		openAPISchema, _, _ := unstructured.NestedMap(version, "schema", "openAPIV3Schema")
+		gvk := schema.GroupVersionKind{Group: group, Version: name, Kind: kind}
+		for _, path := range collectDurationPaths(openAPISchema, "") {
+			paths = append(paths, DurationPath{GVK: gvk, Path: path})
+		}
+	}
+	return paths
+}
+
+var (
+	schemaDurationPathsLock sync.Mutex
+	// schemaDurationPaths caches the duration paths of the kinds of the most recently used OpenAPI schema, which is
+	// replaced whenever the cluster cache reloads the schema
+	schemaDurationPathsSchema openapi.Resources
+	schemaDurationPaths       = map[schema.GroupVersionKind][]DurationPath{}
+)
+
+// durationPathsFromSchema returns the paths of the fields the OpenAPI schema of the given kind declares with the
+// duration format
+func durationPathsFromSchema(resources openapi.Resources, gvk schema.GroupVersionKind) []DurationPath {
+	schemaDurationPathsLock.Lock()
+	defer schemaDurationPathsLock.Unlock()
+	if schemaDurationPathsSchema != resources {
+		schemaDurationPathsSchema = resources
+		schemaDurationPaths = map[schema.GroupVersionKind][]DurationPath{}
+	}
+	if paths, ok := schemaDurationPaths[gvk]; ok {
+		return paths
+	}
+	var paths []DurationPath
+	if s := resources.LookupResource(gvk); s != nil {
+		for _, path := range collectSchemaDurationPaths(s, "", map[string]bool{}) {
+			paths = append(paths, DurationPath{GVK: gvk, Path: path})
+		}
+	}
+	schemaDurationPaths[gvk] = paths
+	return paths
+}
+
+func collectSchemaDurationPaths(s proto.Schema, path string, visited map[string]bool) []string {
+	var paths []string
+	switch v := s.(type) {
+	case *proto.Primitive:
+		if v.Format == "duration" {
+			paths = append(paths, path)
+		}
+	case *proto.Kind:
+		for name, field := range v.Fields {
+			token := strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+			paths = append(paths, collectSchemaDurationPaths(field, path+"/"+token, visited)...)
+		}
+	case *proto.Array:
+		paths = append(paths, collectSchemaDurationPaths(v.SubType, path+"/"+durationPathWildcard, visited)...)
+	case *proto.Map:
+		paths = append(paths, collectSchemaDurationPaths(v.SubType, path+"/"+durationPathWildcard, visited)...)
+	case *proto.Ref:
+		// schemas such as JSONSchemaProps are recursive
+		if visited[v.Reference()] {
+			return nil
+		}
+		visited[v.Reference()] = true
+		paths = append(paths, collectSchemaDurationPaths(v.SubSchema(), path, visited)...)
+		delete(visited, v.Reference())
+	}
+	return paths
+}
+
+func collectDurationPaths(s map[string]interface{}, path string) []string {
+	if format, _, _ := unstructured.NestedString(s, "format"); format == "duration" {
+		return []string{path}
+	}
+	var paths []string
+	if properties, ok := s["properties"].(map[string]interface{}); ok {
+		for name, prop := range properties {
+			if propSchema, ok := prop.(map[string]interface{}); ok {
+				token := strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+				paths = append(paths, collectDurationPaths(propSchema, path+"/"+token)...)
+			}
+		}
+	}
+	for _, key := range []string{"items", "additionalProperties"} {
+		if child, ok := s[key].(map[string]interface{}); ok {
+			paths = append(paths, collectDurationPaths(child, path+"/"+durationPathWildcard)...)
+		}
+	}
+	return paths
+}
+
+// normalizeDurations canonicalizes the duration strings of the given fields, so that equivalent durations, e.g. "30s"
+// and "0m30s", are equal. Values that are not valid durations are left unchanged.
+func normalizeDurations(un *unstructured.Unstructured, paths []DurationPath) {
+	gvk := un.GroupVersionKind()
+	for _, p := range paths {
+		if !p.matches(gvk) {
+			continue
+		}
+		tokens, err := parseJSONPointer(p.Path)
+		if err != nil || len(tokens) == 0 {
+			continue
+		}
+		normalizeDurationAt(un.Object, tokens)
+	}
+}
+
+func normalizeDurationAt(value interface{}, tokens []string) {
+	token, rest := tokens[0], tokens[1:]
+	update := func(val interface{}, set func(interface{})) {
+		if len(rest) > 0 {
+			normalizeDurationAt(val, rest)
+		} else if s, ok := val.(string); ok {
+			if d, err := time.ParseDuration(s); err == nil {
+				set(d.String())
+			}
+		}
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			if token == durationPathWildcard || token == key {
+				key := key
+				update(val, func(canonical interface{}) { v[key] = canonical })
+			}
+		}
+	case []interface{}:
+		for i, val := range v {
+			if token == durationPathWildcard || token == strconv.Itoa(i) {
+				i := i
+				update(val, func(canonical interface{}) { v[i] = canonical })
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Explain returns a human-readable explanation of why the resource is considered modified. It lists the outermost
+// paths at which the live and the predicted live state differ, along with the kind of each change, the fields with
+// incompatible types and the normalizations and ignore rules that were applied. The explanation is meant for
+// troubleshooting by end users and its format might change, use FormatDiff or MergePatch for machine consumption.
+func (r *DiffResult) Explain() string {
+	var out strings.Builder
+	changes, err := fieldChanges(r, formatOptions{})
+	switch {
+	case err != nil:
+		fmt.Fprintf(&out, "Resource differences could not be determined: %v\n", err)
+	case !r.Modified:
+		out.WriteString("Resource is in sync: the live state matches the desired state.\n")
+	case isJSONNull(r.NormalizedLive):
+		out.WriteString("Resource is modified because it does not exist in the live state.\n")
+	case isJSONNull(r.PredictedLive):
+		out.WriteString("Resource is modified because it exists in the live state but not in the desired state.\n")
+	case len(changes) == 0:
+		out.WriteString("Resource is modified, but the normalized live and desired state do not differ in any field.\n")
+	default:
+		out.WriteString("Resource is modified because the following fields differ between the live and the desired state:\n")
+		for _, c := range changes {
+			switch c.op {
+			case fieldAdded:
+				fmt.Fprintf(&out, "  - %s: added (not present in the live state)\n", formatPath(c.path))
+			case fieldRemoved:
+				fmt.Fprintf(&out, "  - %s: removed (not present in the desired state)\n", formatPath(c.path))
+			default:
+				fmt.Fprintf(&out, "  - %s: changed\n", formatPath(c.path))
+			}
+		}
+	}
+	if len(r.FieldDeltas) > 0 {
+		out.WriteString("Fields with incompatible types in the desired and the live state:\n")
+		for _, d := range r.FieldDeltas {
+			fmt.Fprintf(&out, "  - %s\n", d)
+		}
+	}
+	if len(r.Normalizations) > 0 {
+		out.WriteString("Normalizations and ignore rules applied before comparing:\n")
+		for _, n := range r.Normalizations {
+			fmt.Fprintf(&out, "  - %s\n", n)
+		}
+	}
+	return out.String()
+}
+
+// normalizations returns descriptions of the normalizations and ignore rules enabled by the options
+func (o options) normalizations() []string {
+	var result []string
+	if _, ok := o.normalizer.(*noopNormalizer); o.normalizer != nil && !ok {
+		result = append(result, "custom normalizer (e.g. ignoreDifferences rules)")
+	}
+	if o.ignoreAggregatedRoles {
+		result = append(result, "aggregated rules of cluster roles are ignored")
+	}
+	if len(o.ignoredLabelKeys) > 0 {
+		result = append(result, fmt.Sprintf("labels ignored: %s", strings.Join(o.ignoredLabelKeys, ", ")))
+	}
+	if len(o.ignoredAnnotationKeys) > 0 {
+		result = append(result, fmt.Sprintf("annotations ignored: %s", strings.Join(o.ignoredAnnotationKeys, ", ")))
+	}
+	if len(o.stripMetadataFields) > 0 {
+		result = append(result, fmt.Sprintf("metadata fields removed: %s", strings.Join(o.stripMetadataFields, ", ")))
+	}
+	if len(o.ignoredPodTemplateAnnotations) > 0 {
+		result = append(result, fmt.Sprintf("pod template annotations ignored: %s", strings.Join(o.ignoredPodTemplateAnnotations, ", ")))
+	}
+	if o.ignoreFinalizers {
+		result = append(result, "metadata.finalizers are ignored")
+	}
+	if o.ignoreReplicas {
+		result = append(result, "spec.replicas are ignored")
+	} else if len(o.autoscaledWorkloads) > 0 {
+		result = append(result, "spec.replicas of autoscaled workloads are ignored")
+	}
+	if o.timestampTolerance > 0 {
+		result = append(result, fmt.Sprintf("timestamps differing by less than %s are ignored", o.timestampTolerance))
+	}
+	if o.ignoreImageDigests {
+		result = append(result, "container image digests are ignored")
+	}
+	if len(o.userManagers) > 0 {
+		result = append(result, fmt.Sprintf("only fields owned by %s are compared", strings.Join(o.userManagers, ", ")))
+	}
+	if o.generatedNamePattern != nil {
+		result = append(result, fmt.Sprintf("generated name suffixes matching %q are removed", o.generatedNamePattern.String()))
+	}
+	if len(o.boolOrStringPaths) > 0 {
+		result = append(result, "boolean and string values of configured fields are canonicalized")
+	}
+	if len(o.durationPaths) > 0 {
+		result = append(result, "duration strings of configured fields are canonicalized")
+	}
+	if o.normalizeScheduling {
+		result = append(result, "affinity, tolerations and topology spread constraints are canonicalized")
+	}
+	if o.metadataOnly {
+		result = append(result, "only labels and annotations are compared")
+	}
+	if len(o.sensitivePaths) > 0 {
+		result = append(result, "sensitive fields are masked")
+	}
+	return result
+}
@@ -0,0 +1,123 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	smdschema "sigs.k8s.io/structured-merge-diff/v4/schema"
+)
+
+// FieldDeltaType is the kind of a field level difference reported in DiffResult.FieldDeltas
+type FieldDeltaType string
+
+const (
+	// FieldDeltaTypeMismatch is reported if a field has incompatible types in the config and the live state, e.g. a
+	// map in the config and a string in the live state
+	FieldDeltaTypeMismatch FieldDeltaType = "TypeMismatch"
+	// FieldDeltaSecretTypeChange is reported if the type of a Secret differs in the config and the live state. The
+	// type of a Secret is immutable, so the Secret must be replaced, e.g. using the Replace=true sync option.
+	FieldDeltaSecretTypeChange FieldDeltaType = "SecretTypeChange"
+)
+
+// FieldDelta is a field level difference between the config and the live state
+type FieldDelta struct {
+	Type FieldDeltaType
+	// Path of the field, e.g. spec.template.spec.containers[0].env
+	Path string
+	// ConfigType is the type of the field in the config: map, list, string, number or bool. For a SecretTypeChange,
+	// the type of the Secret in the config.
+	ConfigType string
+	// LiveType is the type of the field in the live state: map, list, string, number or bool. For a
+	// SecretTypeChange, the type of the Secret in the live state.
+	LiveType string
+}
+
+func (d FieldDelta) String() string {
+	if d.Type == FieldDeltaSecretTypeChange {
+		return fmt.Sprintf("%s: Secret type changes from %s to %s, which requires replacing the Secret (e.g. Replace=true)", d.Type, d.LiveType, d.ConfigType)
+	}
+	return fmt.Sprintf("%s: %s is %s in config but %s in live state", d.Type, formatPath(d.Path), d.ConfigType, d.LiveType)
+}
+
+// secretTypeChange returns the delta reporting the change of the type of the given Secret, if the config sets a type
+// that differs from the type of the live Secret
+func secretTypeChange(config, live *unstructured.Unstructured) (FieldDelta, bool) {
+	if !isSecret(config) {
+		return FieldDelta{}, false
+	}
+	configType, _, _ := unstructured.NestedString(config.Object, "type")
+	liveType, _, _ := unstructured.NestedString(live.Object, "type")
+	if configType == "" || configType == liveType {
+		return FieldDelta{}, false
+	}
+	return FieldDelta{Type: FieldDeltaSecretTypeChange, Path: "type", ConfigType: configType, LiveType: liveType}, true
+}
+
+func isSecret(un *unstructured.Unstructured) bool {
+	gvk := un.GroupVersionKind()
+	return gvk.Group == "" && gvk.Kind == "Secret"
+}
+
+// typeMismatches returns the paths which are present in both the config and the live state with incompatible types.
+// Null values are compatible with any type. Numbers and strings are compatible unless the schema of the field, which
+// is looked up using the given function, declares either a numeric or a string type, since fields such as quantities
+// and int-or-string fields, e.g. cpu: 1 and cpu: "1", accept both.
+func typeMismatches(config, live interface{}, path string, tokens []string, scalar func(tokens []string) smdschema.Scalar) []FieldDelta {
+	if config == nil || live == nil {
+		return nil
+	}
+	configType := valueTypeName(config)
+	liveType := valueTypeName(live)
+	if configType != liveType {
+		if isNumberAndString(configType, liveType) {
+			if declared := scalar(tokens); declared != smdschema.Numeric && declared != smdschema.String {
+				return nil
+			}
+		}
+		return []FieldDelta{{Type: FieldDeltaTypeMismatch, Path: path, ConfigType: configType, LiveType: liveType}}
+	}
+	var deltas []FieldDelta
+	switch configVal := config.(type) {
+	case map[string]interface{}:
+		liveMap := live.(map[string]interface{})
+		keys := make([]string, 0, len(configVal))
+		for k := range configVal {
+			if _, ok := liveMap[k]; ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			deltas = append(deltas, typeMismatches(configVal[k], liveMap[k], joinPath(path, k), append(tokens[:len(tokens):len(tokens)], k), scalar)...)
+		}
+	case []interface{}:
+		liveList := live.([]interface{})
+		for i := 0; i < len(configVal) && i < len(liveList); i++ {
+			deltas = append(deltas, typeMismatches(configVal[i], liveList[i], fmt.Sprintf("%s[%d]", path, i), append(tokens[:len(tokens):len(tokens)], strconv.Itoa(i)), scalar)...)
+		}
+	}
+	return deltas
+}
+
+func isNumberAndString(configType, liveType string) bool {
+	return configType == "number" && liveType == "string" || configType == "string" && liveType == "number"
+}
+
+func valueTypeName(val interface{}) string {
+	switch val.(type) {
+	case map[string]interface{}:
+		return "map"
+	case []interface{}:
+		return "list"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case int, int32, int64, float32, float64:
+		return "number"
+	default:
+		return fmt.Sprintf("%T", val)
+	}
+}
@@ -0,0 +1,226 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// truncatedMarker is appended to formatted diffs that exceed the maximum output size
+const truncatedMarker = "... (truncated)\n"
+
+type formatOptions struct {
+	explicitNulls  bool
+	maxOutputBytes int
+}
+
+// FormatOption configures the output of FormatDiff
+type FormatOption func(*formatOptions)
+
+// WithExplicitNulls renders fields explicitly set to null as <null> instead of treating them as absent fields. This
+// allows distinguishing a field that is removed from a field that is set to null, e.g. "- spec: {...}" versus
+// "~ spec: <null> -> {...}".
+func WithExplicitNulls(explicitNulls bool) FormatOption {
+	return func(o *formatOptions) {
+		o.explicitNulls = explicitNulls
+	}
+}
+
+// WithMaxOutputBytes limits the size of the formatted diff. Changes that do not fit into the limit are omitted and
+// replaced with a "... (truncated)" marker. The limit is disabled if maxOutputBytes is zero or negative.
+func WithMaxOutputBytes(maxOutputBytes int) FormatOption {
+	return func(o *formatOptions) {
+		o.maxOutputBytes = maxOutputBytes
+	}
+}
+
+// FormatDiff renders the field level changes between the normalized live and the predicted live state of the given
+// diff result, one change per line. Added fields are prefixed with "+", removed fields with "-" and changed fields
+// with "~". Fields are rendered using their path and compact JSON values. Formatting never affects dr.Modified.
+func FormatDiff(dr *DiffResult, opts ...FormatOption) (string, error) {
+	o := formatOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	lines, err := formatDiffLines(dr, o)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	for _, line := range lines {
+		if o.maxOutputBytes > 0 && out.Len()+len(line)+1 > o.maxOutputBytes {
+			out.WriteString(truncatedMarker)
+			break
+		}
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+	return out.String(), nil
+}
+
+// ChangeCount returns the number of changes rendered by FormatDiff for the given diff result. The count always covers
+// the whole object, regardless of the maximum output size.
+func ChangeCount(dr *DiffResult, opts ...FormatOption) (int, error) {
+	o := formatOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	lines, err := formatDiffLines(dr, o)
+	if err != nil {
+		return 0, err
+	}
+	return len(lines), nil
+}
+
+// ChangedPaths returns the outermost paths at which the normalized live and the predicted live state of the given diff
+// result differ, e.g. "spec.replicas"
+func ChangedPaths(dr *DiffResult, opts ...FormatOption) ([]string, error) {
+	o := formatOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	changes, err := fieldChanges(dr, o)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(changes))
+	for _, c := range changes {
+		paths = append(paths, formatPath(c.path))
+	}
+	return paths, nil
+}
+
+func formatDiffLines(dr *DiffResult, o formatOptions) ([]string, error) {
+	changes, err := fieldChanges(dr, o)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, 0, len(changes))
+	for _, c := range changes {
+		switch c.op {
+		case fieldAdded:
+			lines = append(lines, fmt.Sprintf("+ %s: %s", formatPath(c.path), formatValue(c.predicted)))
+		case fieldRemoved:
+			lines = append(lines, fmt.Sprintf("- %s: %s", formatPath(c.path), formatValue(c.live)))
+		default:
+			lines = append(lines, fmt.Sprintf("~ %s: %s -> %s", formatPath(c.path), formatValue(c.live), formatValue(c.predicted)))
+		}
+	}
+	return lines, nil
+}
+
+// fieldChanges returns the differences between the normalized live and the predicted live state of the diff result
+func fieldChanges(dr *DiffResult, o formatOptions) ([]fieldChange, error) {
+	var live, predicted interface{}
+	if err := json.Unmarshal(dr.NormalizedLive, &live); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal live state: %w", err)
+	}
+	if err := json.Unmarshal(dr.PredictedLive, &predicted); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal predicted live state: %w", err)
+	}
+	var changes []fieldChange
+	collectFieldChanges(&changes, "", live, live != nil || o.explicitNulls, predicted, predicted != nil || o.explicitNulls, o)
+	return changes, nil
+}
+
+type fieldChangeOp string
+
+const (
+	fieldAdded   fieldChangeOp = "added"
+	fieldRemoved fieldChangeOp = "removed"
+	fieldChanged fieldChangeOp = "changed"
+)
+
+// fieldChange is a difference between the live and the predicted live state at the outermost path where they differ
+type fieldChange struct {
+	op        fieldChangeOp
+	path      string
+	live      interface{}
+	predicted interface{}
+}
+
+func collectFieldChanges(changes *[]fieldChange, path string, live interface{}, liveFound bool, predicted interface{}, predictedFound bool, o formatOptions) {
+	if !o.explicitNulls {
+		liveFound = liveFound && live != nil
+		predictedFound = predictedFound && predicted != nil
+	}
+	switch {
+	case !liveFound && !predictedFound:
+		return
+	case !liveFound:
+		*changes = append(*changes, fieldChange{op: fieldAdded, path: path, predicted: predicted})
+		return
+	case !predictedFound:
+		*changes = append(*changes, fieldChange{op: fieldRemoved, path: path, live: live})
+		return
+	}
+
+	liveMap, liveIsMap := live.(map[string]interface{})
+	predictedMap, predictedIsMap := predicted.(map[string]interface{})
+	if liveIsMap && predictedIsMap {
+		keys := make(map[string]bool)
+		for k := range liveMap {
+			keys[k] = true
+		}
+		for k := range predictedMap {
+			keys[k] = true
+		}
+		sortedKeys := make([]string, 0, len(keys))
+		for k := range keys {
+			sortedKeys = append(sortedKeys, k)
+		}
+		sort.Strings(sortedKeys)
+		for _, k := range sortedKeys {
+			liveVal, liveOk := liveMap[k]
+			predictedVal, predictedOk := predictedMap[k]
+			collectFieldChanges(changes, joinPath(path, k), liveVal, liveOk, predictedVal, predictedOk, o)
+		}
+		return
+	}
+
+	liveList, liveIsList := live.([]interface{})
+	predictedList, predictedIsList := predicted.([]interface{})
+	if liveIsList && predictedIsList {
+		for i := 0; i < len(liveList) || i < len(predictedList); i++ {
+			var liveVal, predictedVal interface{}
+			if i < len(liveList) {
+				liveVal = liveList[i]
+			}
+			if i < len(predictedList) {
+				predictedVal = predictedList[i]
+			}
+			collectFieldChanges(changes, fmt.Sprintf("%s[%d]", path, i), liveVal, i < len(liveList), predictedVal, i < len(predictedList), o)
+		}
+		return
+	}
+
+	if formatValue(live) != formatValue(predicted) {
+		*changes = append(*changes, fieldChange{op: fieldChanged, path: path, live: live, predicted: predicted})
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func formatPath(path string) string {
+	if path == "" {
+		return "."
+	}
+	return path
+}
+
+func formatValue(val interface{}) string {
+	if val == nil {
+		return "<null>"
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Sprintf("%v", val)
+	}
+	return string(data)
+}
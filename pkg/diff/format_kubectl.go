@@ -0,0 +1,129 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// kubectlDiffLiveDir and kubectlDiffMergedDir are the directories of the live and merged files in the headers of
+	// FormatKubectlDiff, following the naming of the temporary directories created by kubectl diff
+	kubectlDiffLiveDir   = "/tmp/LIVE-0"
+	kubectlDiffMergedDir = "/tmp/MERGED-0"
+	// kubectlDiffTimeFormat is the format of the file timestamps in the headers of a unified diff produced by diff -u
+	kubectlDiffTimeFormat = "2006-01-02 15:04:05.000000000 -0700"
+)
+
+// FormatKubectlDiff renders the given diff results in the format of kubectl diff, so tools parsing the output of
+// kubectl diff can process them. Each modified resource is rendered as a unified diff between the YAML of the
+// normalized live and the predicted live state, preceded by the "diff -u -N" command line and the file headers. The
+// file names identify the resource as <group>.<version>.<kind>.<namespace>.<name>, the group being omitted for the
+// core group, exactly like kubectl diff does. Unmodified resources are omitted.
+func FormatKubectlDiff(results []*DiffResult) (string, error) {
+	return formatKubectlDiff(results, time.Now())
+}
+
+func formatKubectlDiff(results []*DiffResult, now time.Time) (string, error) {
+	timestamp := now.Format(kubectlDiffTimeFormat)
+	var out strings.Builder
+	for _, dr := range results {
+		if dr == nil || !dr.Modified {
+			continue
+		}
+		live, err := kubectlDiffYAML(dr.NormalizedLive)
+		if err != nil {
+			return "", fmt.Errorf("failed to convert live state: %w", err)
+		}
+		predicted, err := kubectlDiffYAML(dr.PredictedLive)
+		if err != nil {
+			return "", fmt.Errorf("failed to convert predicted live state: %w", err)
+		}
+		name, err := kubectlDiffFileName(dr)
+		if err != nil {
+			return "", err
+		}
+		liveFile, mergedFile := path.Join(kubectlDiffLiveDir, name), path.Join(kubectlDiffMergedDir, name)
+		body, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        splitLines(live),
+			B:        splitLines(predicted),
+			FromFile: liveFile,
+			FromDate: timestamp,
+			ToFile:   mergedFile,
+			ToDate:   timestamp,
+			Context:  3,
+		})
+		if err != nil {
+			return "", err
+		}
+		if body == "" {
+			continue
+		}
+		out.WriteString(fmt.Sprintf("diff -u -N %s %s\n", liveFile, mergedFile))
+		out.WriteString(body)
+	}
+	return out.String(), nil
+}
+
+// kubectlDiffYAML converts the given JSON object to YAML. A null object is rendered as an empty file, like the missing
+// file of a created or deleted resource in kubectl diff.
+func kubectlDiffYAML(data []byte) (string, error) {
+	if isJSONNull(data) {
+		return "", nil
+	}
+	out, err := yaml.JSONToYAML(data)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// kubectlDiffFileName returns the name kubectl diff uses for the files of the resource of the given diff result
+func kubectlDiffFileName(dr *DiffResult) (string, error) {
+	data := dr.PredictedLive
+	if isJSONNull(data) {
+		data = dr.NormalizedLive
+	}
+	var obj struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		Metadata   struct {
+			Namespace string `json:"namespace"`
+			Name      string `json:"name"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return "", fmt.Errorf("failed to unmarshal resource: %w", err)
+	}
+	gv, err := schema.ParseGroupVersion(obj.APIVersion)
+	if err != nil {
+		return "", err
+	}
+	kind, namespace, name := dr.Kind, dr.Namespace, dr.Name
+	if kind == "" {
+		kind, namespace, name = obj.Kind, obj.Metadata.Namespace, obj.Metadata.Name
+	}
+	name = fmt.Sprintf("%s.%s.%s.%s", gv.Version, kind, namespace, name)
+	if gv.Group != "" {
+		name = gv.Group + "." + name
+	}
+	return name, nil
+}
+
+// splitLines splits the given text into lines, keeping the line endings
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
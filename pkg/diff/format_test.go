@@ -0,0 +1,151 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatDiff(t *testing.T) {
+	newResult := func(live, predicted string) *DiffResult {
+		return &DiffResult{Modified: true, NormalizedLive: []byte(live), PredictedLive: []byte(predicted)}
+	}
+
+	t.Run("Changes", func(t *testing.T) {
+		dr := newResult(
+			`{"metadata":{"name":"foo","labels":{"a":"1"}},"spec":{"replicas":1,"ports":[80,443]}}`,
+			`{"metadata":{"name":"foo","annotations":{"b":"2"}},"spec":{"replicas":2,"ports":[80]}}`,
+		)
+		out, err := FormatDiff(dr)
+		require.NoError(t, err)
+		assert.Equal(t, `+ metadata.annotations: {"b":"2"}
+- metadata.labels: {"a":"1"}
+- spec.ports[1]: 443
+~ spec.replicas: 1 -> 2
+`, out)
+	})
+
+	t.Run("NullVsMissing", func(t *testing.T) {
+		nullSpec := newResult(`{"metadata":{"name":"foo"},"spec":null}`, `{"metadata":{"name":"foo"},"spec":{"replicas":1}}`)
+		removedSpec := newResult(`{"metadata":{"name":"foo"},"spec":{"replicas":1}}`, `{"metadata":{"name":"foo"}}`)
+		droppedNull := newResult(`{"metadata":{"name":"foo"},"spec":null}`, `{"metadata":{"name":"foo"}}`)
+
+		out, err := FormatDiff(nullSpec)
+		require.NoError(t, err)
+		assert.Equal(t, "+ spec: {\"replicas\":1}\n", out)
+		out, err = FormatDiff(droppedNull)
+		require.NoError(t, err)
+		assert.Empty(t, out)
+
+		out, err = FormatDiff(nullSpec, WithExplicitNulls(true))
+		require.NoError(t, err)
+		assert.Equal(t, "~ spec: <null> -> {\"replicas\":1}\n", out)
+		out, err = FormatDiff(droppedNull, WithExplicitNulls(true))
+		require.NoError(t, err)
+		assert.Equal(t, "- spec: <null>\n", out)
+		out, err = FormatDiff(removedSpec, WithExplicitNulls(true))
+		require.NoError(t, err)
+		assert.Equal(t, "- spec: {\"replicas\":1}\n", out)
+
+		// formatting never changes the diff result itself
+		assert.True(t, nullSpec.Modified)
+	})
+}
+
+func TestFormatDiffMaxOutputBytes(t *testing.T) {
+	live := map[string]interface{}{}
+	predicted := map[string]interface{}{}
+	for i := 0; i < 100; i++ {
+		live[fmt.Sprintf("key-%03d", i)] = "old"
+		predicted[fmt.Sprintf("key-%03d", i)] = "new"
+	}
+	liveBytes, err := json.Marshal(map[string]interface{}{"data": live})
+	require.NoError(t, err)
+	predictedBytes, err := json.Marshal(map[string]interface{}{"data": predicted})
+	require.NoError(t, err)
+	dr := &DiffResult{Modified: true, NormalizedLive: liveBytes, PredictedLive: predictedBytes}
+
+	full, err := FormatDiff(dr)
+	require.NoError(t, err)
+	line := "~ data.key-000: \"old\" -> \"new\"\n"
+	assert.Len(t, full, 100*len(line))
+
+	out, err := FormatDiff(dr, WithMaxOutputBytes(3*len(line)+1))
+	require.NoError(t, err)
+	assert.Equal(t, line+strings.Replace(line, "000", "001", 1)+strings.Replace(line, "000", "002", 1)+"... (truncated)\n", out)
+
+	out, err = FormatDiff(dr, WithMaxOutputBytes(len(full)))
+	require.NoError(t, err)
+	assert.Equal(t, full, out)
+
+	// the change count and the diff result cover the whole object
+	count, err := ChangeCount(dr, WithMaxOutputBytes(3*len(line)+1))
+	require.NoError(t, err)
+	assert.Equal(t, 100, count)
+	assert.True(t, dr.Modified)
+
+	paths, err := ChangedPaths(dr)
+	require.NoError(t, err)
+	assert.Len(t, paths, 100)
+	assert.Equal(t, "data.key-000", paths[0])
+}
+
+func TestFormatKubectlDiff(t *testing.T) {
+	live := `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"nginx","namespace":"default"},` +
+		`"spec":{"replicas":1,"selector":{"matchLabels":{"app":"nginx"}},"template":{"metadata":{"labels":{"app":"nginx"}},` +
+		`"spec":{"containers":[{"image":"nginx:1.25","name":"nginx"}]}}}}`
+	predicted := strings.Replace(strings.Replace(live, `"replicas":1`, `"replicas":3`, 1), "nginx:1.25", "nginx:1.26", 1)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+
+	t.Run("ModifiedDeployment", func(t *testing.T) {
+		out, err := formatKubectlDiff([]*DiffResult{
+			{Group: "apps", Kind: "Deployment", Namespace: "default", Name: "nginx", Modified: true, NormalizedLive: []byte(live), PredictedLive: []byte(predicted)},
+			{Group: "apps", Kind: "Deployment", Namespace: "default", Name: "unchanged", NormalizedLive: []byte(live), PredictedLive: []byte(live)},
+		}, now)
+		require.NoError(t, err)
+		assert.Equal(t, `diff -u -N /tmp/LIVE-0/apps.v1.Deployment.default.nginx /tmp/MERGED-0/apps.v1.Deployment.default.nginx
+--- /tmp/LIVE-0/apps.v1.Deployment.default.nginx	2024-01-02 03:04:05.000000006 +0000
++++ /tmp/MERGED-0/apps.v1.Deployment.default.nginx	2024-01-02 03:04:05.000000006 +0000
+@@ -4,7 +4,7 @@
+   name: nginx
+   namespace: default
+ spec:
+-  replicas: 1
++  replicas: 3
+   selector:
+     matchLabels:
+       app: nginx
+@@ -14,5 +14,5 @@
+         app: nginx
+     spec:
+       containers:
+-      - image: nginx:1.25
++      - image: nginx:1.26
+         name: nginx
+`, out)
+	})
+
+	t.Run("CreatedCoreResource", func(t *testing.T) {
+		out, err := formatKubectlDiff([]*DiffResult{{
+			Modified:       true,
+			NormalizedLive: []byte("null"),
+			PredictedLive:  []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"cm","namespace":"default"}}`),
+		}}, now)
+		require.NoError(t, err)
+		assert.Equal(t, `diff -u -N /tmp/LIVE-0/v1.ConfigMap.default.cm /tmp/MERGED-0/v1.ConfigMap.default.cm
+--- /tmp/LIVE-0/v1.ConfigMap.default.cm	2024-01-02 03:04:05.000000006 +0000
++++ /tmp/MERGED-0/v1.ConfigMap.default.cm	2024-01-02 03:04:05.000000006 +0000
+@@ -0,0 +1,5 @@
++apiVersion: v1
++kind: ConfigMap
++metadata:
++  name: cm
++  namespace: default
+`, out)
+	})
+}
@@ -0,0 +1,257 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// IgnoreDifference holds a rule that defines which fields of matching resources should be ignored during diffing.
+// Empty Group, Name and Namespace match any value. If Namespaces is not empty then the rule only applies to
+// resources in one of the listed namespaces.
+type IgnoreDifference struct {
+	Group                 string   `json:"group,omitempty"`
+	Kind                  string   `json:"kind"`
+	Name                  string   `json:"name,omitempty"`
+	Namespace             string   `json:"namespace,omitempty"`
+	Namespaces            []string `json:"namespaces,omitempty"`
+	JSONPointers          []string `json:"jsonPointers,omitempty"`
+	JQPathExpressions     []string `json:"jqPathExpressions,omitempty"`
+	ManagedFieldsManagers []string `json:"managedFieldsManagers,omitempty"`
+}
+
+type ignoreDifferenceKey struct {
+	group      string
+	kind       string
+	name       string
+	namespace  string
+	namespaces string
+}
+
+func (id IgnoreDifference) key() ignoreDifferenceKey {
+	namespaces := append([]string(nil), id.Namespaces...)
+	sort.Strings(namespaces)
+	return ignoreDifferenceKey{group: id.Group, kind: id.Kind, name: id.Name, namespace: id.Namespace, namespaces: strings.Join(namespaces, ",")}
+}
+
+// Matches returns true if the rule applies to the given resource
+func (id IgnoreDifference) Matches(un *unstructured.Unstructured) bool {
+	if un == nil {
+		return false
+	}
+	gvk := un.GroupVersionKind()
+	if (id.Group != "" && id.Group != gvk.Group) || id.Kind != gvk.Kind {
+		return false
+	}
+	if id.Name != "" && id.Name != un.GetName() {
+		return false
+	}
+	if id.Namespace != "" && id.Namespace != un.GetNamespace() {
+		return false
+	}
+	if len(id.Namespaces) > 0 {
+		for _, ns := range id.Namespaces {
+			if ns == un.GetNamespace() {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// MergeIgnoreDifferences combines the given rule sets into a single list. Rules that target the same
+// group, kind, name and namespaces are merged into one rule containing the union of their JSON pointers,
+// jq path expressions and managed fields managers. The order of the first occurrence of each rule and
+// each path is preserved, so rule sets passed first take precedence in the resulting order.
+func MergeIgnoreDifferences(ruleSets ...[]IgnoreDifference) []IgnoreDifference {
+	var result []IgnoreDifference
+	indexByKey := make(map[ignoreDifferenceKey]int)
+	for _, rules := range ruleSets {
+		for _, rule := range rules {
+			key := rule.key()
+			i, ok := indexByKey[key]
+			if !ok {
+				i = len(result)
+				indexByKey[key] = i
+				result = append(result, IgnoreDifference{Group: rule.Group, Kind: rule.Kind, Name: rule.Name, Namespace: rule.Namespace, Namespaces: append([]string(nil), rule.Namespaces...)})
+			}
+			merged := &result[i]
+			merged.JSONPointers = appendUnique(merged.JSONPointers, rule.JSONPointers...)
+			merged.JQPathExpressions = appendUnique(merged.JQPathExpressions, rule.JQPathExpressions...)
+			merged.ManagedFieldsManagers = appendUnique(merged.ManagedFieldsManagers, rule.ManagedFieldsManagers...)
+		}
+	}
+	return result
+}
+
+func appendUnique(items []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, item := range items {
+			if item == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			items = append(items, v)
+		}
+	}
+	return items
+}
+
+// ApplyIgnoreDifferences returns a copy of the given object without the fields ignored by the matching rules, i.e. the
+// fields located at their JSON pointers and jq path expressions. The result is the same as normalizing the object using
+// NewIgnoreDifferencesNormalizer, so it can e.g. be hashed consistently with the diff. Only the subset of jq that
+// addresses fields is supported: field access, indexing, iteration, pipes and select with an equality comparison.
+// The managed fields managers of the rules are not applied since they require the schema of the resource.
+func ApplyIgnoreDifferences(obj *unstructured.Unstructured, rules []IgnoreDifference) (*unstructured.Unstructured, error) {
+	if obj == nil {
+		return nil, nil
+	}
+	result := obj.DeepCopy()
+	if err := removeIgnoredFields(result, rules); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// NewIgnoreDifferencesNormalizer returns a normalizer that removes the fields ignored by the given rules, see
+// ApplyIgnoreDifferences
+func NewIgnoreDifferencesNormalizer(rules []IgnoreDifference) Normalizer {
+	return &ignoreDifferencesNormalizer{rules: rules}
+}
+
+type ignoreDifferencesNormalizer struct {
+	rules []IgnoreDifference
+}
+
+func (n *ignoreDifferencesNormalizer) Normalize(un *unstructured.Unstructured) error {
+	return removeIgnoredFields(un, n.rules)
+}
+
+// removeIgnoredFields removes the fields ignored by the rules matching the given object. All the paths are resolved
+// before any field is removed, so removed list items do not shift the items addressed by other paths.
+func removeIgnoredFields(un *unstructured.Unstructured, rules []IgnoreDifference) error {
+	var paths [][]interface{}
+	for _, rule := range rules {
+		if !rule.Matches(un) {
+			continue
+		}
+		for _, pointer := range rule.JSONPointers {
+			tokens, err := parseJSONPointer(pointer)
+			if err != nil {
+				return err
+			}
+			if path, ok := resolveJSONPointer(un.Object, tokens); ok && len(path) > 0 {
+				paths = append(paths, path)
+			}
+		}
+		for _, expr := range rule.JQPathExpressions {
+			jq, err := parseJQPath(expr)
+			if err != nil {
+				return fmt.Errorf("invalid jq path expression %q: %w", expr, err)
+			}
+			for _, path := range jq.paths(un.Object, nil) {
+				if len(path) > 0 {
+					paths = append(paths, path)
+				}
+			}
+		}
+	}
+	// list items are removed starting from the last item so the indexes of the remaining paths stay valid
+	sort.SliceStable(paths, func(i, j int) bool {
+		for k := 0; k < len(paths[i]) && k < len(paths[j]); k++ {
+			a, aIsIndex := paths[i][k].(int)
+			b, bIsIndex := paths[j][k].(int)
+			switch {
+			case aIsIndex && bIsIndex:
+				if a != b {
+					return a > b
+				}
+			case aIsIndex != bIsIndex:
+				return bIsIndex
+			case paths[i][k].(string) != paths[j][k].(string):
+				return paths[i][k].(string) < paths[j][k].(string)
+			}
+		}
+		return len(paths[i]) > len(paths[j])
+	})
+	for _, path := range paths {
+		removePath(un.Object, path)
+	}
+	return nil
+}
+
+// resolveJSONPointer converts the tokens of a JSON pointer to a path whose list indexes are integers
+func resolveJSONPointer(doc interface{}, tokens []string) ([]interface{}, bool) {
+	path := make([]interface{}, 0, len(tokens))
+	for i := range tokens {
+		parent, ok := lookupJSONPointer(doc, tokens[:i])
+		if !ok {
+			return nil, false
+		}
+		if _, isList := parent.([]interface{}); isList {
+			index, err := strconv.Atoi(tokens[i])
+			if err != nil {
+				return nil, false
+			}
+			path = append(path, index)
+		} else {
+			path = append(path, tokens[i])
+		}
+	}
+	if _, ok := lookupJSONPointer(doc, tokens); !ok {
+		return nil, false
+	}
+	return path, true
+}
+
+// removePath removes the value located at the given path, which is made of map keys and list indexes
+func removePath(doc map[string]interface{}, path []interface{}) {
+	var parent interface{} = doc
+	for _, key := range path[:len(path)-1] {
+		switch val := parent.(type) {
+		case map[string]interface{}:
+			parent = val[key.(string)]
+		case []interface{}:
+			parent = val[key.(int)]
+		default:
+			return
+		}
+	}
+	switch val := parent.(type) {
+	case map[string]interface{}:
+		if key, ok := path[len(path)-1].(string); ok {
+			delete(val, key)
+		}
+	case []interface{}:
+		if i, ok := path[len(path)-1].(int); ok && i >= 0 && i < len(val) {
+			items := append(append([]interface{}{}, val[:i]...), val[i+1:]...)
+			setPath(doc, path[:len(path)-1], items)
+		}
+	}
+}
+
+// setPath sets the value located at the given non-empty path, which is made of map keys and list indexes
+func setPath(doc map[string]interface{}, path []interface{}, value interface{}) {
+	var parent interface{} = doc
+	for _, key := range path[:len(path)-1] {
+		switch val := parent.(type) {
+		case map[string]interface{}:
+			parent = val[key.(string)]
+		case []interface{}:
+			parent = val[key.(int)]
+		}
+	}
+	switch val := parent.(type) {
+	case map[string]interface{}:
+		val[path[len(path)-1].(string)] = value
+	case []interface{}:
+		val[path[len(path)-1].(int)] = value
+	}
+}
@@ -0,0 +1,217 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestMergeIgnoreDifferences(t *testing.T) {
+	appRules := []IgnoreDifference{{
+		Group:        "apps",
+		Kind:         "Deployment",
+		JSONPointers: []string{"/spec/replicas"},
+	}, {
+		Kind:         "Service",
+		Name:         "my-svc",
+		JSONPointers: []string{"/spec/clusterIP"},
+	}}
+	globalRules := []IgnoreDifference{{
+		Group:                 "apps",
+		Kind:                  "Deployment",
+		JSONPointers:          []string{"/spec/replicas", "/spec/template/metadata/annotations"},
+		JQPathExpressions:     []string{".spec.template.spec.containers[].image"},
+		ManagedFieldsManagers: []string{"kube-controller-manager"},
+	}, {
+		Group:        "admissionregistration.k8s.io",
+		Kind:         "MutatingWebhookConfiguration",
+		JSONPointers: []string{"/webhooks/0/clientConfig/caBundle"},
+	}}
+
+	t.Run("OverlappingAndDisjoint", func(t *testing.T) {
+		merged := MergeIgnoreDifferences(appRules, globalRules)
+		assert.Equal(t, []IgnoreDifference{{
+			Group:                 "apps",
+			Kind:                  "Deployment",
+			JSONPointers:          []string{"/spec/replicas", "/spec/template/metadata/annotations"},
+			JQPathExpressions:     []string{".spec.template.spec.containers[].image"},
+			ManagedFieldsManagers: []string{"kube-controller-manager"},
+		}, {
+			Kind:         "Service",
+			Name:         "my-svc",
+			JSONPointers: []string{"/spec/clusterIP"},
+		}, {
+			Group:        "admissionregistration.k8s.io",
+			Kind:         "MutatingWebhookConfiguration",
+			JSONPointers: []string{"/webhooks/0/clientConfig/caBundle"},
+		}}, merged)
+	})
+
+	t.Run("IdenticalEntries", func(t *testing.T) {
+		merged := MergeIgnoreDifferences(appRules, appRules)
+		assert.Equal(t, appRules, merged)
+	})
+
+	t.Run("DifferentNamesAreNotMerged", func(t *testing.T) {
+		merged := MergeIgnoreDifferences(
+			[]IgnoreDifference{{Kind: "Service", Name: "a", JSONPointers: []string{"/spec"}}},
+			[]IgnoreDifference{{Kind: "Service", Name: "b", JSONPointers: []string{"/spec"}}},
+		)
+		assert.Len(t, merged, 2)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		assert.Empty(t, MergeIgnoreDifferences())
+	})
+}
+
+func TestIgnoreDifferenceMatches(t *testing.T) {
+	newDeployment := func(namespace string) *unstructured.Unstructured {
+		un := &unstructured.Unstructured{}
+		un.SetAPIVersion("apps/v1")
+		un.SetKind("Deployment")
+		un.SetName("my-app")
+		un.SetNamespace(namespace)
+		return un
+	}
+	tenantRule := IgnoreDifference{
+		Group:        "apps",
+		Kind:         "Deployment",
+		Namespaces:   []string{"tenant-a", "tenant-b"},
+		JSONPointers: []string{"/spec/replicas"},
+	}
+
+	assert.True(t, tenantRule.Matches(newDeployment("tenant-a")))
+	assert.True(t, tenantRule.Matches(newDeployment("tenant-b")))
+	assert.False(t, tenantRule.Matches(newDeployment("kube-system")))
+
+	globalRule := IgnoreDifference{Group: "apps", Kind: "Deployment"}
+	assert.True(t, globalRule.Matches(newDeployment("kube-system")))
+
+	assert.False(t, IgnoreDifference{Kind: "Service"}.Matches(newDeployment("tenant-a")))
+	assert.False(t, IgnoreDifference{Group: "apps", Kind: "Deployment", Name: "other"}.Matches(newDeployment("tenant-a")))
+	assert.False(t, tenantRule.Matches(nil))
+
+	t.Run("RulesWithDifferentNamespacesAreNotMerged", func(t *testing.T) {
+		systemRule := IgnoreDifference{Group: "apps", Kind: "Deployment", Namespaces: []string{"kube-system"}, JSONPointers: []string{"/spec/template"}}
+		assert.Len(t, MergeIgnoreDifferences([]IgnoreDifference{tenantRule}, []IgnoreDifference{systemRule}), 2)
+		reordered := IgnoreDifference{Group: "apps", Kind: "Deployment", Namespaces: []string{"tenant-b", "tenant-a"}, JSONPointers: []string{"/spec/template"}}
+		merged := MergeIgnoreDifferences([]IgnoreDifference{tenantRule}, []IgnoreDifference{reordered})
+		require.Len(t, merged, 1)
+		assert.Equal(t, []string{"/spec/replicas", "/spec/template"}, merged[0].JSONPointers)
+	})
+}
+
+func TestApplyIgnoreDifferences(t *testing.T) {
+	config := StrToUnstructured(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+  namespace: default
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: app
+        image: my-app:1.0
+      - name: sidecar
+        image: sidecar:1.0
+`)
+	live := StrToUnstructured(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+  namespace: default
+  annotations:
+    example.com/injected: "true"
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+      - name: app
+        image: my-app:1.0
+      - name: istio-proxy
+        image: proxy:1.20
+      - name: sidecar
+        image: sidecar:1.2
+`)
+	rules := []IgnoreDifference{{
+		Group:        "apps",
+		Kind:         "Deployment",
+		JSONPointers: []string{"/spec/replicas", "/metadata/annotations/example.com~1injected"},
+		JQPathExpressions: []string{
+			`.spec.template.spec.containers[] | select(.name == "sidecar") | .image`,
+			`.spec.template.spec.containers[] | select(.name == "istio-proxy")`,
+		},
+	}, {
+		Kind:         "Service",
+		JSONPointers: []string{"/spec/template"},
+	}}
+
+	normalizedLive, err := ApplyIgnoreDifferences(live, rules)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"template": map[string]interface{}{
+			"spec": map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "app", "image": "my-app:1.0"},
+					map[string]interface{}{"name": "sidecar"},
+				},
+			},
+		},
+	}, normalizedLive.Object["spec"])
+	assert.Empty(t, normalizedLive.GetAnnotations())
+	// the given object is not modified
+	_, found, err := unstructured.NestedFieldNoCopy(live.Object, "spec", "replicas")
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	normalizedConfig, err := ApplyIgnoreDifferences(config, rules)
+	require.NoError(t, err)
+	assert.Equal(t, normalizedConfig.Object["spec"], normalizedLive.Object["spec"])
+
+	// the same fields are ignored by the diff
+	dr := diff(t, config, live, append(diffOptionsForTest(), WithNormalizer(NewIgnoreDifferencesNormalizer(rules)))...)
+	assert.False(t, dr.Modified)
+	dr = diff(t, config, live, diffOptionsForTest()...)
+	assert.True(t, dr.Modified)
+
+	_, err = ApplyIgnoreDifferences(live, []IgnoreDifference{{Kind: "Deployment", JQPathExpressions: []string{`.spec | keys`}}})
+	assert.Error(t, err)
+}
+
+func TestParseJQPath(t *testing.T) {
+	obj := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"name": "a", "port": int64(80)},
+				map[string]interface{}{"name": "b", "port": int64(443)},
+			},
+			"my.field": "value",
+		},
+	}
+	for expr, expected := range map[string][][]interface{}{
+		`.spec.items[0].name`:                          {{"spec", "items", 0, "name"}},
+		`.spec.items[-1]`:                              {{"spec", "items", 1}},
+		`.spec.items[] | .name`:                        {{"spec", "items", 0, "name"}, {"spec", "items", 1, "name"}},
+		`.spec.items[] | select(.port == 443) | .name`: {{"spec", "items", 1, "name"}},
+		`.spec.items[] | select(.name != "a")`:         {{"spec", "items", 1}},
+		`.spec["my.field"]`:                            {{"spec", "my.field"}},
+		`.spec."my.field"`:                             {{"spec", "my.field"}},
+		`.spec.missing`:                                nil,
+	} {
+		path, err := parseJQPath(expr)
+		require.NoError(t, err, expr)
+		assert.Equal(t, expected, path.paths(obj, nil), expr)
+	}
+	for _, expr := range []string{`spec`, `.spec | length`, `.spec[abc]`, `select(.a > 1)`, `.spec["a`} {
+		_, err := parseJQPath(expr)
+		assert.Error(t, err, expr)
+	}
+}
@@ -0,0 +1,282 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jqPath is a compiled jq path expression, e.g. `.spec.containers[] | select(.name == "app") | .image`. Only the
+// subset of jq that addresses fields is supported: field access (.a, ."a", .["a"]), indexing (.[0]), iteration (.[]),
+// pipes and select with an equality (==) or inequality (!=) comparison of a path and a literal.
+type jqPath []jqStep
+
+type jqStep struct {
+	field    *string
+	index    *int
+	iterate  bool
+	selector *jqSelector
+}
+
+type jqSelector struct {
+	path  jqPath
+	equal bool
+	value interface{}
+}
+
+// parseJQPath compiles the given jq path expression
+func parseJQPath(expr string) (jqPath, error) {
+	terms, err := splitJQ(expr, "|")
+	if err != nil {
+		return nil, err
+	}
+	var path jqPath
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		if strings.HasPrefix(term, "select(") && strings.HasSuffix(term, ")") {
+			selector, err := parseJQSelector(term[len("select(") : len(term)-1])
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, jqStep{selector: selector})
+			continue
+		}
+		steps, err := parseJQPathTerm(term)
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, steps...)
+	}
+	return path, nil
+}
+
+// splitJQ splits the expression at the given separator, ignoring separators within string literals and parentheses
+func splitJQ(expr string, sep string) ([]string, error) {
+	var parts []string
+	depth, start, inString := 0, 0, false
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case depth == 0 && strings.HasPrefix(expr[i:], sep):
+			parts = append(parts, expr[start:i])
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	if inString || depth != 0 {
+		return nil, fmt.Errorf("unbalanced quotes or parentheses in %q", expr)
+	}
+	return append(parts, expr[start:]), nil
+}
+
+func parseJQSelector(expr string) (*jqSelector, error) {
+	for _, op := range []string{"==", "!="} {
+		parts, err := splitJQ(expr, op)
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) != 2 {
+			continue
+		}
+		path, err := parseJQPathTerm(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, err
+		}
+		for _, step := range path {
+			if step.iterate || step.selector != nil {
+				return nil, fmt.Errorf("unsupported path in select: %q", parts[0])
+			}
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(parts[1])), &value); err != nil {
+			return nil, fmt.Errorf("unsupported literal in select: %q", parts[1])
+		}
+		return &jqSelector{path: path, equal: op == "==", value: value}, nil
+	}
+	return nil, fmt.Errorf("unsupported select expression: %q", expr)
+}
+
+// parseJQPathTerm compiles a term made of field accesses, indexes and iterations, e.g. .spec.containers[0]
+func parseJQPathTerm(term string) (jqPath, error) {
+	if !strings.HasPrefix(term, ".") {
+		return nil, fmt.Errorf("unsupported expression: %q", term)
+	}
+	var path jqPath
+	for i := 0; i < len(term); {
+		switch term[i] {
+		case '.':
+			i++
+			if i < len(term) && term[i] == '"' {
+				end := closingQuote(term, i)
+				if end < 0 {
+					return nil, fmt.Errorf("unterminated string in %q", term)
+				}
+				field, err := strconv.Unquote(term[i : end+1])
+				if err != nil {
+					return nil, err
+				}
+				path = append(path, jqStep{field: &field})
+				i = end + 1
+				continue
+			}
+			start := i
+			for i < len(term) && (term[i] == '_' || term[i] >= 'a' && term[i] <= 'z' || term[i] >= 'A' && term[i] <= 'Z' || term[i] >= '0' && term[i] <= '9') {
+				i++
+			}
+			if i > start {
+				field := term[start:i]
+				path = append(path, jqStep{field: &field})
+			} else if i < len(term) && term[i] != '[' {
+				return nil, fmt.Errorf("unsupported expression: %q", term)
+			}
+		case '[':
+			end := strings.IndexByte(term[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated brackets in %q", term)
+			}
+			content := strings.TrimSpace(term[i+1 : i+end])
+			switch {
+			case content == "":
+				path = append(path, jqStep{iterate: true})
+			case strings.HasPrefix(content, `"`):
+				field, err := strconv.Unquote(content)
+				if err != nil {
+					return nil, err
+				}
+				path = append(path, jqStep{field: &field})
+			default:
+				index, err := strconv.Atoi(content)
+				if err != nil {
+					return nil, fmt.Errorf("unsupported index %q in %q", content, term)
+				}
+				path = append(path, jqStep{index: &index})
+			}
+			i += end + 1
+		default:
+			return nil, fmt.Errorf("unsupported expression: %q", term)
+		}
+	}
+	return path, nil
+}
+
+// closingQuote returns the index of the quote that terminates the string literal starting at the given index
+func closingQuote(s string, start int) int {
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// paths returns the paths of the existing values matched by the expression. The elements of a path are either map
+// keys (string) or list indexes (int).
+func (p jqPath) paths(value interface{}, prefix []interface{}) [][]interface{} {
+	if len(p) == 0 {
+		return [][]interface{}{prefix}
+	}
+	step, rest := p[0], p[1:]
+	child := func(key interface{}, val interface{}) [][]interface{} {
+		return rest.paths(val, append(append([]interface{}{}, prefix...), key))
+	}
+	switch {
+	case step.field != nil:
+		if m, ok := value.(map[string]interface{}); ok {
+			if val, ok := m[*step.field]; ok {
+				return child(*step.field, val)
+			}
+		}
+	case step.index != nil:
+		if items, ok := value.([]interface{}); ok {
+			i := *step.index
+			if i < 0 {
+				i += len(items)
+			}
+			if i >= 0 && i < len(items) {
+				return child(i, items[i])
+			}
+		}
+	case step.iterate:
+		var result [][]interface{}
+		switch val := value.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(val))
+			for k := range val {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				result = append(result, child(k, val[k])...)
+			}
+		case []interface{}:
+			for i, item := range val {
+				result = append(result, child(i, item)...)
+			}
+		}
+		return result
+	case step.selector != nil:
+		if step.selector.matches(value) {
+			return rest.paths(value, prefix)
+		}
+	}
+	return nil
+}
+
+func (s *jqSelector) matches(value interface{}) bool {
+	current := value
+	for _, step := range s.path {
+		switch {
+		case step.field != nil:
+			m, _ := current.(map[string]interface{})
+			current = m[*step.field]
+		case step.index != nil:
+			items, _ := current.([]interface{})
+			i := *step.index
+			if i < 0 {
+				i += len(items)
+			}
+			if i >= 0 && i < len(items) {
+				current = items[i]
+			} else {
+				current = nil
+			}
+		}
+	}
+	return jqEqual(current, s.value) == s.equal
+}
+
+// jqEqual compares the given values, treating numbers of different types as equal if their values are equal
+func jqEqual(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
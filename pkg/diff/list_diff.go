@@ -0,0 +1,276 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/managedfields"
+	smdschema "sigs.k8s.io/structured-merge-diff/v4/schema"
+)
+
+// ListElementChangeType describes how an element of a merge-keyed list changed
+type ListElementChangeType string
+
+const (
+	// ListElementAdded means the element exists only in the config
+	ListElementAdded ListElementChangeType = "Added"
+	// ListElementRemoved means the element exists only in the live state
+	ListElementRemoved ListElementChangeType = "Removed"
+	// ListElementModified means the element exists on both sides but its content differs
+	ListElementModified ListElementChangeType = "Modified"
+	// ListElementMoved means the element exists on both sides with the same content but at a different index. Elements
+	// whose content differs are reported as modified, see ListElementChange.Moved.
+	ListElementMoved ListElementChangeType = "Moved"
+	// ListElementUnchanged means the element exists on both sides with the same content at the same index
+	ListElementUnchanged ListElementChangeType = "Unchanged"
+)
+
+// ListElementChange holds the change of a single element of a merge-keyed list
+type ListElementChange struct {
+	Type ListElementChangeType
+	// Key is the value of the merge key of the element
+	Key string
+	// ConfigIndex is the index of the element in the config list or -1 if the element was removed
+	ConfigIndex int
+	// LiveIndex is the index of the element in the live list or -1 if the element was added
+	LiveIndex int
+	// Moved is true if the element exists on both sides at different indices, regardless of whether it was modified
+	Moved bool
+}
+
+// KeyedListPath identifies a list of the resources of a kind whose elements are identified by a merge key
+type KeyedListPath struct {
+	// GVK is the kind of the resources the path applies to. The path applies to all versions of the kind if the
+	// version is empty.
+	GVK schema.GroupVersionKind
+	// Path is a JSON pointer (RFC 6901) to the list, e.g. /spec/containers. A * token matches all elements of a list or
+	// all values of a map, e.g. /spec/containers/*/env.
+	Path string
+	// MergeKey is the field that identifies the elements of the list, e.g. name
+	MergeKey string
+}
+
+func (p KeyedListPath) matches(gvk schema.GroupVersionKind) bool {
+	return p.GVK.Group == gvk.Group && p.GVK.Kind == gvk.Kind && (p.GVK.Version == "" || p.GVK.Version == gvk.Version)
+}
+
+// KeyedListChanges holds the changes of the elements of a merge-keyed list of the compared resources
+type KeyedListChanges struct {
+	// Path is the path of the list in the predicted live state, e.g. spec.containers[0].env
+	Path    string
+	Changes []ListElementChange
+}
+
+// DiffKeyedList compares two lists whose elements are identified by the given merge key (e.g. "name" for
+// containers or env variables). Unlike a positional comparison, an element that exists on both sides at different
+// indices is reported as moved rather than as removed and added. Changes are returned in config order followed
+// by the removed elements in live order. Elements without the merge key are compared by index. If the merge key is
+// not unique in either list, the elements cannot be matched by key, so all the elements are compared by index.
+func DiffKeyedList(config, live []interface{}, mergeKey string) []ListElementChange {
+	liveIndexByKey, liveUnique := listElementIndices(live, mergeKey)
+	if _, configUnique := listElementIndices(config, mergeKey); !configUnique || !liveUnique {
+		return diffPositionalList(config, live, mergeKey)
+	}
+
+	var changes []ListElementChange
+	matched := make(map[int]bool)
+	for i, item := range config {
+		key := listElementKey(item, mergeKey, i)
+		liveIndex, ok := liveIndexByKey[key]
+		if !ok {
+			changes = append(changes, ListElementChange{Type: ListElementAdded, Key: key, ConfigIndex: i, LiveIndex: -1})
+			continue
+		}
+		matched[liveIndex] = true
+		change := ListElementChange{Key: key, ConfigIndex: i, LiveIndex: liveIndex, Moved: i != liveIndex}
+		switch {
+		case !reflect.DeepEqual(item, live[liveIndex]):
+			change.Type = ListElementModified
+		case change.Moved:
+			change.Type = ListElementMoved
+		default:
+			change.Type = ListElementUnchanged
+		}
+		changes = append(changes, change)
+	}
+	for i, item := range live {
+		if !matched[i] {
+			changes = append(changes, ListElementChange{Type: ListElementRemoved, Key: listElementKey(item, mergeKey, i), ConfigIndex: -1, LiveIndex: i})
+		}
+	}
+	return changes
+}
+
+// DiffKeyedListField compares the merge-keyed lists located at the given field path of the config and live resources.
+func DiffKeyedListField(config, live *unstructured.Unstructured, mergeKey string, fields ...string) ([]ListElementChange, error) {
+	var configList, liveList []interface{}
+	var err error
+	if config != nil {
+		if configList, _, err = unstructured.NestedSlice(config.Object, fields...); err != nil {
+			return nil, fmt.Errorf("error reading config list: %w", err)
+		}
+	}
+	if live != nil {
+		if liveList, _, err = unstructured.NestedSlice(live.Object, fields...); err != nil {
+			return nil, fmt.Errorf("error reading live list: %w", err)
+		}
+	}
+	return DiffKeyedList(configList, liveList, mergeKey), nil
+}
+
+// listElementIndices returns the index of each element of the given list by its merge key value and whether the
+// values are unique
+func listElementIndices(list []interface{}, mergeKey string) (map[string]int, bool) {
+	indices := make(map[string]int, len(list))
+	for i, item := range list {
+		key := listElementKey(item, mergeKey, i)
+		if _, ok := indices[key]; ok {
+			return nil, false
+		}
+		indices[key] = i
+	}
+	return indices, true
+}
+
+// diffPositionalList compares the elements of the given lists by index
+func diffPositionalList(config, live []interface{}, mergeKey string) []ListElementChange {
+	var changes []ListElementChange
+	for i := 0; i < len(config) || i < len(live); i++ {
+		switch {
+		case i >= len(live):
+			changes = append(changes, ListElementChange{Type: ListElementAdded, Key: listElementKey(config[i], mergeKey, i), ConfigIndex: i, LiveIndex: -1})
+		case i >= len(config):
+			changes = append(changes, ListElementChange{Type: ListElementRemoved, Key: listElementKey(live[i], mergeKey, i), ConfigIndex: -1, LiveIndex: i})
+		case reflect.DeepEqual(config[i], live[i]):
+			changes = append(changes, ListElementChange{Type: ListElementUnchanged, Key: listElementKey(config[i], mergeKey, i), ConfigIndex: i, LiveIndex: i})
+		default:
+			changes = append(changes, ListElementChange{Type: ListElementModified, Key: listElementKey(config[i], mergeKey, i), ConfigIndex: i, LiveIndex: i})
+		}
+	}
+	return changes
+}
+
+// keyedListChanges returns the changes of the merge-keyed lists of the predicted live state compared to the
+// normalized live state of the given modified diff result. The merge keys are taken from the given paths and, if the
+// parser is set, from the schema of the kind.
+func keyedListChanges(dr *DiffResult, gvk schema.GroupVersionKind, paths []KeyedListPath, gvkParser *managedfields.GvkParser) ([]KeyedListChanges, error) {
+	if !dr.Modified || isJSONNull(dr.NormalizedLive) || isJSONNull(dr.PredictedLive) {
+		return nil, nil
+	}
+	var live, predicted interface{}
+	if err := json.Unmarshal(dr.NormalizedLive, &live); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal live state: %w", err)
+	}
+	if err := json.Unmarshal(dr.PredictedLive, &predicted); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal predicted live state: %w", err)
+	}
+	type keyedList struct {
+		tokens   []string
+		mergeKey string
+	}
+	var keyedLists []keyedList
+	for _, p := range paths {
+		if !p.matches(gvk) {
+			continue
+		}
+		tokens, err := parseJSONPointer(p.Path)
+		if err != nil {
+			return nil, err
+		}
+		keyedLists = append(keyedLists, keyedList{tokens: tokens, mergeKey: p.MergeKey})
+	}
+	mergeKey := func(tokens []string) string {
+		for _, l := range keyedLists {
+			if matchTokens(l.tokens, tokens) {
+				return l.mergeKey
+			}
+		}
+		return schemaListKey(gvkParser, gvk, tokens)
+	}
+	var changes []KeyedListChanges
+	collectKeyedListChanges(predicted, live, "", nil, mergeKey, &changes)
+	return changes, nil
+}
+
+func collectKeyedListChanges(config, live interface{}, path string, tokens []string, mergeKey func(tokens []string) string, changes *[]KeyedListChanges) {
+	switch configVal := config.(type) {
+	case map[string]interface{}:
+		liveMap, ok := live.(map[string]interface{})
+		if !ok {
+			return
+		}
+		keys := make([]string, 0, len(configVal))
+		for k := range configVal {
+			if _, ok := liveMap[k]; ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			collectKeyedListChanges(configVal[k], liveMap[k], joinPath(path, k), append(tokens[:len(tokens):len(tokens)], k), mergeKey, changes)
+		}
+	case []interface{}:
+		liveList, ok := live.([]interface{})
+		if !ok {
+			return
+		}
+		key := mergeKey(tokens)
+		if key == "" {
+			for i := 0; i < len(configVal) && i < len(liveList); i++ {
+				collectKeyedListChanges(configVal[i], liveList[i], fmt.Sprintf("%s[%d]", path, i), append(tokens[:len(tokens):len(tokens)], strconv.Itoa(i)), mergeKey, changes)
+			}
+			return
+		}
+		listChanges := DiffKeyedList(configVal, liveList, key)
+		for _, c := range listChanges {
+			if c.Type != ListElementUnchanged {
+				*changes = append(*changes, KeyedListChanges{Path: path, Changes: listChanges})
+				break
+			}
+		}
+		// nested lists, e.g. the env variables of a container, are compared within the matched elements
+		for _, c := range listChanges {
+			if c.Type == ListElementModified {
+				collectKeyedListChanges(configVal[c.ConfigIndex], liveList[c.LiveIndex], fmt.Sprintf("%s[%d]", path, c.ConfigIndex), append(tokens[:len(tokens):len(tokens)], strconv.Itoa(c.ConfigIndex)), mergeKey, changes)
+			}
+		}
+	}
+}
+
+// matchTokens returns true if the given tokens match the given pattern, whose * tokens match any token
+func matchTokens(pattern, tokens []string) bool {
+	if len(pattern) != len(tokens) {
+		return false
+	}
+	for i := range pattern {
+		if pattern[i] != durationPathWildcard && pattern[i] != tokens[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// schemaListKey returns the merge key of the list at the given path according to the schema of the kind, or an empty
+// string if the schema or the list is unknown or the list is not identified by a single key
+func schemaListKey(gvkParser *managedfields.GvkParser, gvk schema.GroupVersionKind, tokens []string) string {
+	atom, ok := schemaAtom(gvkParser, gvk, tokens)
+	if !ok || atom.List == nil || atom.List.ElementRelationship != smdschema.Associative || len(atom.List.Keys) != 1 {
+		return ""
+	}
+	return atom.List.Keys[0]
+}
+
+// listElementKey returns the merge key value of the given list element or its index if the key is missing
+func listElementKey(item interface{}, mergeKey string, index int) string {
+	if m, ok := item.(map[string]interface{}); ok {
+		if v, ok := m[mergeKey]; ok && v != nil {
+			return fmt.Sprintf("%v", v)
+		}
+	}
+	return fmt.Sprintf("[%d]", index)
+}
@@ -0,0 +1,213 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestDiffKeyedList(t *testing.T) {
+	live := []interface{}{
+		map[string]interface{}{"name": "A", "value": "1"},
+		map[string]interface{}{"name": "B", "value": "2"},
+		map[string]interface{}{"name": "C", "value": "3"},
+		map[string]interface{}{"name": "D", "value": "4"},
+	}
+
+	t.Run("Reordered", func(t *testing.T) {
+		config := []interface{}{live[2], live[0], live[1], live[3]}
+		changes := DiffKeyedList(config, live, "name")
+		assert.Equal(t, []ListElementChange{
+			{Type: ListElementMoved, Key: "C", ConfigIndex: 0, LiveIndex: 2, Moved: true},
+			{Type: ListElementMoved, Key: "A", ConfigIndex: 1, LiveIndex: 0, Moved: true},
+			{Type: ListElementMoved, Key: "B", ConfigIndex: 2, LiveIndex: 1, Moved: true},
+			{Type: ListElementUnchanged, Key: "D", ConfigIndex: 3, LiveIndex: 3},
+		}, changes)
+	})
+
+	t.Run("ReorderedWithChanges", func(t *testing.T) {
+		config := []interface{}{
+			map[string]interface{}{"name": "B", "value": "2"},
+			map[string]interface{}{"name": "A", "value": "changed"},
+			map[string]interface{}{"name": "E", "value": "5"},
+			map[string]interface{}{"name": "D", "value": "4"},
+		}
+		changes := DiffKeyedList(config, live, "name")
+		assert.Equal(t, []ListElementChange{
+			{Type: ListElementMoved, Key: "B", ConfigIndex: 0, LiveIndex: 1, Moved: true},
+			{Type: ListElementModified, Key: "A", ConfigIndex: 1, LiveIndex: 0, Moved: true},
+			{Type: ListElementAdded, Key: "E", ConfigIndex: 2, LiveIndex: -1},
+			{Type: ListElementUnchanged, Key: "D", ConfigIndex: 3, LiveIndex: 3},
+			{Type: ListElementRemoved, Key: "C", ConfigIndex: -1, LiveIndex: 2},
+		}, changes)
+	})
+
+	t.Run("DuplicateMergeKey", func(t *testing.T) {
+		config := []interface{}{
+			map[string]interface{}{"name": "A", "value": "1"},
+			map[string]interface{}{"name": "A", "value": "2"},
+			map[string]interface{}{"name": "C", "value": "3"},
+		}
+		changes := DiffKeyedList(config, live, "name")
+		assert.Equal(t, []ListElementChange{
+			{Type: ListElementUnchanged, Key: "A", ConfigIndex: 0, LiveIndex: 0},
+			{Type: ListElementModified, Key: "A", ConfigIndex: 1, LiveIndex: 1},
+			{Type: ListElementUnchanged, Key: "C", ConfigIndex: 2, LiveIndex: 2},
+			{Type: ListElementRemoved, Key: "D", ConfigIndex: -1, LiveIndex: 3},
+		}, changes)
+	})
+
+	t.Run("MissingMergeKey", func(t *testing.T) {
+		changes := DiffKeyedList([]interface{}{"a", "b"}, []interface{}{"b", "a"}, "name")
+		assert.Equal(t, []ListElementChange{
+			{Type: ListElementModified, Key: "[0]", ConfigIndex: 0, LiveIndex: 0},
+			{Type: ListElementModified, Key: "[1]", ConfigIndex: 1, LiveIndex: 1},
+		}, changes)
+	})
+}
+
+func TestDiffKeyedListField(t *testing.T) {
+	newPod := func(containers ...interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Pod",
+			"metadata":   map[string]interface{}{"name": "my-pod"},
+			"spec":       map[string]interface{}{"containers": containers},
+		}}
+	}
+	app := map[string]interface{}{"name": "app", "image": "nginx:1.2"}
+	sidecar := map[string]interface{}{"name": "sidecar", "image": "envoy"}
+	config := newPod(sidecar, app)
+	live := newPod(app, sidecar)
+
+	changes, err := DiffKeyedListField(config, live, "name", "spec", "containers")
+	require.NoError(t, err)
+	assert.Equal(t, []ListElementChange{
+		{Type: ListElementMoved, Key: "sidecar", ConfigIndex: 0, LiveIndex: 1, Moved: true},
+		{Type: ListElementMoved, Key: "app", ConfigIndex: 1, LiveIndex: 0, Moved: true},
+	}, changes)
+}
+
+func TestDiffListChanges(t *testing.T) {
+	t.Run("KeyedListPaths", func(t *testing.T) {
+		live := StrToUnstructured(`
+apiVersion: example.com/v1
+kind: Workload
+metadata:
+  name: my-workload
+spec:
+  containers:
+  - name: app
+    image: nginx:1.2
+    env:
+    - name: A
+      value: "1"
+    - name: B
+      value: "2"
+  - name: sidecar
+    image: envoy
+`)
+		config := StrToUnstructured(`
+apiVersion: example.com/v1
+kind: Workload
+metadata:
+  name: my-workload
+spec:
+  containers:
+  - name: sidecar
+    image: envoy
+  - name: app
+    image: nginx:1.3
+    env:
+    - name: B
+      value: "2"
+    - name: A
+      value: "1"
+`)
+		gvk := schema.GroupVersionKind{Group: "example.com", Kind: "Workload"}
+		opts := append(diffOptionsForTest(), WithKeyedListPaths(
+			KeyedListPath{GVK: gvk, Path: "/spec/containers", MergeKey: "name"},
+			KeyedListPath{GVK: gvk, Path: "/spec/containers/*/env", MergeKey: "name"},
+		))
+
+		dr := diff(t, config, live, opts...)
+		assert.True(t, dr.Modified)
+		assert.Equal(t, []KeyedListChanges{{
+			Path: "spec.containers",
+			Changes: []ListElementChange{
+				{Type: ListElementMoved, Key: "sidecar", ConfigIndex: 0, LiveIndex: 1, Moved: true},
+				{Type: ListElementModified, Key: "app", ConfigIndex: 1, LiveIndex: 0, Moved: true},
+			},
+		}, {
+			Path: "spec.containers[1].env",
+			Changes: []ListElementChange{
+				{Type: ListElementMoved, Key: "B", ConfigIndex: 0, LiveIndex: 1, Moved: true},
+				{Type: ListElementMoved, Key: "A", ConfigIndex: 1, LiveIndex: 0, Moved: true},
+			},
+		}}, dr.ListChanges)
+
+		dr = diff(t, config, live, diffOptionsForTest()...)
+		assert.True(t, dr.Modified)
+		assert.Empty(t, dr.ListChanges)
+	})
+
+	t.Run("Schema", func(t *testing.T) {
+		live := StrToUnstructured(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-deployment
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: nginx:1.2
+        env:
+        - name: A
+          value: "1"
+      - name: sidecar
+        image: envoy
+`)
+		config := StrToUnstructured(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-deployment
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: nginx:1.2
+        env:
+        - name: A
+          value: "1"
+        - name: B
+          value: "2"
+`)
+		dr := diff(t, config, live, append(diffOptionsForTest(), WithGVKParser(buildGVKParser(t)))...)
+		assert.True(t, dr.Modified)
+		assert.Equal(t, []KeyedListChanges{{
+			Path: "spec.template.spec.containers",
+			Changes: []ListElementChange{
+				{Type: ListElementModified, Key: "app", ConfigIndex: 0, LiveIndex: 0},
+				// not removed, since the sidecar was not applied from the config
+				{Type: ListElementUnchanged, Key: "sidecar", ConfigIndex: 1, LiveIndex: 1},
+			},
+		}, {
+			Path: "spec.template.spec.containers[0].env",
+			Changes: []ListElementChange{
+				{Type: ListElementUnchanged, Key: "A", ConfigIndex: 0, LiveIndex: 0},
+				{Type: ListElementAdded, Key: "B", ConfigIndex: 1, LiveIndex: -1},
+			},
+		}}, dr.ListChanges)
+
+		dr = diff(t, live, live, append(diffOptionsForTest(), WithGVKParser(buildGVKParser(t)))...)
+		assert.False(t, dr.Modified)
+		assert.Empty(t, dr.ListChanges)
+	})
+}
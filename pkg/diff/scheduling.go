@@ -0,0 +1,185 @@
+package diff
+
+import (
+	"encoding/json"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// normalizeScheduling canonicalizes the affinity, tolerations and topology spread constraints of the pod spec or pod
+// template of the resource, so that equivalent representations are equal. The lists whose order has no meaning are
+// sorted, the values of set-based selector requirements are sorted and the default operator and effect of tolerations
+// are removed.
+func normalizeScheduling(un *unstructured.Unstructured) {
+	podSpecPaths := [][]string{{"spec"}}
+	for _, path := range podTemplatePaths {
+		podSpecPaths = append(podSpecPaths, append(append([]string{}, path...), "spec"))
+	}
+	for _, podSpecPath := range podSpecPaths {
+		podSpec, ok, err := unstructured.NestedMap(un.Object, podSpecPath...)
+		if !ok || err != nil {
+			continue
+		}
+		changed := false
+		if affinity, ok := podSpec["affinity"].(map[string]interface{}); ok {
+			normalizeAffinity(affinity)
+			changed = true
+		}
+		if tolerations, ok := podSpec["tolerations"].([]interface{}); ok {
+			for _, t := range tolerations {
+				if toleration, ok := t.(map[string]interface{}); ok {
+					normalizeToleration(toleration)
+				}
+			}
+			sortByJSON(tolerations)
+			changed = true
+		}
+		if constraints, ok := podSpec["topologySpreadConstraints"].([]interface{}); ok {
+			for _, c := range constraints {
+				if constraint, ok := c.(map[string]interface{}); ok {
+					normalizeLabelSelector(constraint["labelSelector"])
+					sortStrings(constraint["matchLabelKeys"])
+				}
+			}
+			sortByJSON(constraints)
+			changed = true
+		}
+		if changed {
+			_ = unstructured.SetNestedMap(un.Object, podSpec, podSpecPath...)
+		}
+	}
+}
+
+// normalizeAffinity canonicalizes the node affinity, pod affinity and pod anti-affinity of a pod spec
+func normalizeAffinity(affinity map[string]interface{}) {
+	if nodeAffinity, ok := affinity["nodeAffinity"].(map[string]interface{}); ok {
+		if required, ok := nodeAffinity["requiredDuringSchedulingIgnoredDuringExecution"].(map[string]interface{}); ok {
+			if terms, ok := required["nodeSelectorTerms"].([]interface{}); ok {
+				for _, term := range terms {
+					normalizeNodeSelectorTerm(term)
+				}
+				sortByJSON(terms)
+			}
+		}
+		if preferred, ok := nodeAffinity["preferredDuringSchedulingIgnoredDuringExecution"].([]interface{}); ok {
+			for _, p := range preferred {
+				if term, ok := p.(map[string]interface{}); ok {
+					normalizeNodeSelectorTerm(term["preference"])
+				}
+			}
+			sortByJSON(preferred)
+		}
+	}
+	for _, field := range []string{"podAffinity", "podAntiAffinity"} {
+		podAffinity, ok := affinity[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if required, ok := podAffinity["requiredDuringSchedulingIgnoredDuringExecution"].([]interface{}); ok {
+			for _, term := range required {
+				normalizePodAffinityTerm(term)
+			}
+			sortByJSON(required)
+		}
+		if preferred, ok := podAffinity["preferredDuringSchedulingIgnoredDuringExecution"].([]interface{}); ok {
+			for _, p := range preferred {
+				if term, ok := p.(map[string]interface{}); ok {
+					normalizePodAffinityTerm(term["podAffinityTerm"])
+				}
+			}
+			sortByJSON(preferred)
+		}
+	}
+}
+
+// normalizeNodeSelectorTerm sorts the requirements of a node selector term, which are ANDed
+func normalizeNodeSelectorTerm(term interface{}) {
+	if t, ok := term.(map[string]interface{}); ok {
+		normalizeSelectorRequirements(t["matchExpressions"])
+		normalizeSelectorRequirements(t["matchFields"])
+	}
+}
+
+func normalizePodAffinityTerm(term interface{}) {
+	t, ok := term.(map[string]interface{})
+	if !ok {
+		return
+	}
+	normalizeLabelSelector(t["labelSelector"])
+	normalizeLabelSelector(t["namespaceSelector"])
+	sortStrings(t["namespaces"])
+	sortStrings(t["matchLabelKeys"])
+	sortStrings(t["mismatchLabelKeys"])
+}
+
+func normalizeLabelSelector(selector interface{}) {
+	if s, ok := selector.(map[string]interface{}); ok {
+		normalizeSelectorRequirements(s["matchExpressions"])
+	}
+}
+
+// normalizeSelectorRequirements sorts the given selector requirements and the values of each requirement
+func normalizeSelectorRequirements(requirements interface{}) {
+	items, ok := requirements.([]interface{})
+	if !ok {
+		return
+	}
+	for _, item := range items {
+		if requirement, ok := item.(map[string]interface{}); ok {
+			sortStrings(requirement["values"])
+		}
+	}
+	sortByJSON(items)
+}
+
+// normalizeToleration removes the fields of a toleration that are equal to their defaults: the Equal operator, the
+// empty effect that matches all effects and the empty value
+func normalizeToleration(toleration map[string]interface{}) {
+	if toleration["operator"] == "Equal" {
+		delete(toleration, "operator")
+	}
+	for _, field := range []string{"effect", "value", "key"} {
+		if toleration[field] == "" {
+			delete(toleration, field)
+		}
+	}
+}
+
+// sortStrings sorts the given list if it only contains strings
+func sortStrings(list interface{}) {
+	items, ok := list.([]interface{})
+	if !ok {
+		return
+	}
+	for _, item := range items {
+		if _, ok := item.(string); !ok {
+			return
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].(string) < items[j].(string)
+	})
+}
+
+// sortByJSON sorts the items of the given list by their JSON representation
+func sortByJSON(items []interface{}) {
+	keys := make([]string, len(items))
+	for i, item := range items {
+		data, _ := json.Marshal(item)
+		keys[i] = string(data)
+	}
+	sort.Sort(byKeys{items: items, keys: keys})
+}
+
+type byKeys struct {
+	items []interface{}
+	keys  []string
+}
+
+func (b byKeys) Len() int           { return len(b.items) }
+func (b byKeys) Less(i, j int) bool { return b.keys[i] < b.keys[j] }
+func (b byKeys) Swap(i, j int) {
+	b.items[i], b.items[j] = b.items[j], b.items[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
@@ -0,0 +1,132 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SensitivePath identifies a field of the resources of a kind whose value is masked in the diff result
+type SensitivePath struct {
+	// GVK is the kind of the resources the path applies to. The path applies to all versions of the kind if the
+	// version is empty.
+	GVK schema.GroupVersionKind
+	// Path is a JSON pointer (RFC 6901) to the masked field, e.g. /data/token
+	Path string
+}
+
+func (p SensitivePath) matches(gvk schema.GroupVersionKind) bool {
+	return p.GVK.Group == gvk.Group && p.GVK.Kind == gvk.Kind && (p.GVK.Version == "" || p.GVK.Version == gvk.Version)
+}
+
+// maskSensitivePaths replaces the values of the sensitive fields in the normalized live and predicted live state of
+// the given diff result with plus(+). Same as for secret data, different values get replacements of different length.
+func maskSensitivePaths(dr *DiffResult, gvk schema.GroupVersionKind, paths []SensitivePath) error {
+	var live, predicted interface{}
+	if err := json.Unmarshal(dr.NormalizedLive, &live); err != nil {
+		return fmt.Errorf("failed to unmarshal live state: %w", err)
+	}
+	if err := json.Unmarshal(dr.PredictedLive, &predicted); err != nil {
+		return fmt.Errorf("failed to unmarshal predicted live state: %w", err)
+	}
+	masked := false
+	for _, p := range paths {
+		if !p.matches(gvk) {
+			continue
+		}
+		tokens, err := parseJSONPointer(p.Path)
+		if err != nil {
+			return err
+		}
+		if len(tokens) == 0 || (gvk.Group == "" && gvk.Kind == "Secret" && tokens[0] == "type") {
+			// the type of a Secret is never masked, since changing it requires replacing the Secret
+			continue
+		}
+		liveVal, liveFound := lookupJSONPointer(live, tokens)
+		predictedVal, predictedFound := lookupJSONPointer(predicted, tokens)
+		predictedReplacement := replacement
+		if liveFound && predictedFound && !reflect.DeepEqual(liveVal, predictedVal) {
+			predictedReplacement = replacement + "++++"
+		}
+		if liveFound {
+			setJSONPointer(live, tokens, replacement)
+			masked = true
+		}
+		if predictedFound {
+			setJSONPointer(predicted, tokens, predictedReplacement)
+			masked = true
+		}
+	}
+	if !masked {
+		return nil
+	}
+	liveData, err := json.Marshal(live)
+	if err != nil {
+		return err
+	}
+	predictedData, err := json.Marshal(predicted)
+	if err != nil {
+		return err
+	}
+	dr.NormalizedLive = liveData
+	dr.PredictedLive = predictedData
+	return nil
+}
+
+// parseJSONPointer returns the unescaped reference tokens of the given JSON pointer
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q: must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(tokens[i], "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func lookupJSONPointer(doc interface{}, tokens []string) (interface{}, bool) {
+	current := doc
+	for _, token := range tokens {
+		switch val := current.(type) {
+		case map[string]interface{}:
+			next, ok := val[token]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(val) {
+				return nil, false
+			}
+			current = val[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func setJSONPointer(doc interface{}, tokens []string, value interface{}) {
+	parent, ok := lookupJSONPointer(doc, tokens[:len(tokens)-1])
+	if !ok {
+		return
+	}
+	last := tokens[len(tokens)-1]
+	switch val := parent.(type) {
+	case map[string]interface{}:
+		val[last] = value
+	case []interface{}:
+		if i, err := strconv.Atoi(last); err == nil && i >= 0 && i < len(val) {
+			val[i] = value
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// defaultDryRunManager is the field manager of the server-side dry-run applies if no manager is configured
+const defaultDryRunManager = "gitops-engine-diff"
+
+// restConfigDryRunner runs server-side applies in dry-run mode using the API server of the given REST config. The
+// clients are created on first use.
+type restConfigDryRunner struct {
+	config *rest.Config
+
+	init          sync.Once
+	initErr       error
+	dynamicClient dynamic.Interface
+	mapper        meta.RESTMapper
+}
+
+func (r *restConfigDryRunner) clients() (dynamic.Interface, meta.RESTMapper, error) {
+	r.init.Do(func() {
+		if r.dynamicClient != nil && r.mapper != nil {
+			return
+		}
+		dynamicClient, err := dynamic.NewForConfig(r.config)
+		if err != nil {
+			r.initErr = fmt.Errorf("failed to create dynamic client: %w", err)
+			return
+		}
+		disco, err := discovery.NewDiscoveryClientForConfig(r.config)
+		if err != nil {
+			r.initErr = fmt.Errorf("failed to create discovery client: %w", err)
+			return
+		}
+		r.dynamicClient = dynamicClient
+		r.mapper = restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(disco))
+	})
+	return r.dynamicClient, r.mapper, r.initErr
+}
+
+// Run applies the given object using a server-side apply in dry-run mode and returns the object returned by the API
+// server as JSON. The returned object includes the changes of mutating admission webhooks.
+func (r *restConfigDryRunner) Run(ctx context.Context, obj *unstructured.Unstructured, manager string) (string, error) {
+	dynamicClient, mapper, err := r.clients()
+	if err != nil {
+		return "", err
+	}
+	gvk := obj.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return "", fmt.Errorf("failed to get REST mapping of %s: %w", gvk, err)
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	var resource dynamic.ResourceInterface = dynamicClient.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		resource = dynamicClient.Resource(mapping.Resource).Namespace(obj.GetNamespace())
+	}
+	if manager == "" {
+		manager = defaultDryRunManager
+	}
+	force := true
+	res, err := resource.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+		DryRun:       []string{metav1.DryRunAll},
+		FieldManager: manager,
+		Force:        &force,
+	})
+	if err != nil {
+		return "", err
+	}
+	out, err := json.Marshal(res)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
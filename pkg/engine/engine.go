@@ -83,7 +83,7 @@ func (e *gitOpsEngine) Sync(ctx context.Context,
 	if err != nil {
 		return nil, err
 	}
-	opts = append(opts, sync.WithSkipHooks(!diffRes.Modified))
+	opts = append(opts, sync.WithSkipHooks(!diffRes.Modified), sync.WithContext(ctx))
 	syncCtx, cleanup, err := sync.NewSyncContext(revision, result, e.config, e.config, e.kubectl, namespace, e.cache.GetOpenAPISchema(), opts...)
 	if err != nil {
 		return nil, err
@@ -26,6 +26,14 @@ const (
 	HealthStatusMissing HealthStatusCode = "Missing"
 )
 
+// Messages of workloads that are Suspended because they are paused. The messages are the same regardless of how the
+// kind represents the paused state, so they can be used as a consistent signal.
+const (
+	messageDeploymentPaused          = "deployment paused"
+	messageRolloutPausedForPromotion = "rollout paused for promotion"
+	messageCronJobSuspended          = "cronjob suspended"
+)
+
 // Implements custom health assessment that overrides built-in assessment
 type HealthOverride interface {
 	GetResourceHealth(obj *unstructured.Unstructured) (*HealthStatus, error)
@@ -62,6 +70,42 @@ func IsWorse(current, new HealthStatusCode) bool {
 	return newIndex > currentIndex
 }
 
+// DefaultAggregateHealthPrecedence holds the precedence of health codes used by AggregateHealth. Codes with higher
+// precedence win over codes with lower precedence and codes with equal precedence are interchangeable.
+var DefaultAggregateHealthPrecedence = map[HealthStatusCode]int{
+	HealthStatusHealthy:     0,
+	HealthStatusSuspended:   1,
+	HealthStatusMissing:     1,
+	HealthStatusUnknown:     2,
+	HealthStatusProgressing: 3,
+	HealthStatusDegraded:    4,
+}
+
+// AggregateHealth combines the health of a set of resources into a single health status using
+// DefaultAggregateHealthPrecedence
+func AggregateHealth(statuses []*HealthStatus) *HealthStatus {
+	return AggregateHealthWithPrecedence(statuses, DefaultAggregateHealthPrecedence)
+}
+
+// AggregateHealthWithPrecedence combines the health of a set of resources into a single health status. The result has
+// the code with the highest precedence and the message of the first status with that code. Nil statuses, e.g. of
+// resources without health assessment, are ignored and an empty set of statuses is Healthy.
+func AggregateHealthWithPrecedence(statuses []*HealthStatus, precedence map[HealthStatusCode]int) *HealthStatus {
+	var worst *HealthStatus
+	for _, status := range statuses {
+		if status == nil {
+			continue
+		}
+		if worst == nil || precedence[status.Status] > precedence[worst.Status] {
+			worst = status
+		}
+	}
+	if worst == nil {
+		return &HealthStatus{Status: HealthStatusHealthy}
+	}
+	return &HealthStatus{Status: worst.Status, Message: worst.Message}
+}
+
 // GetResourceHealth returns the health of a k8s resource
 func GetResourceHealth(obj *unstructured.Unstructured, healthOverride HealthOverride) (health *HealthStatus, err error) {
 	if obj.GetDeletionTimestamp() != nil {
@@ -97,7 +141,8 @@ func GetResourceHealth(obj *unstructured.Unstructured, healthOverride HealthOver
 
 }
 
-// GetHealthCheckFunc returns built-in health check function or nil if health check is not supported
+// GetHealthCheckFunc returns built-in health check function, the health check registered using RegisterStatusHealth
+// or nil if health check is not supported
 func GetHealthCheckFunc(gvk schema.GroupVersionKind) func(obj *unstructured.Unstructured) (*HealthStatus, error) {
 	switch gvk.Group {
 	case "apps":
@@ -120,6 +165,28 @@ func GetHealthCheckFunc(gvk schema.GroupVersionKind) func(obj *unstructured.Unst
 		switch gvk.Kind {
 		case "Workflow":
 			return getArgoWorkflowHealth
+		case "Rollout":
+			return getArgoRolloutHealth
+		}
+	case "snapshot.storage.k8s.io":
+		switch gvk.Kind {
+		case "VolumeSnapshot":
+			return getVolumeSnapshotHealth
+		}
+	case "velero.io":
+		switch gvk.Kind {
+		case "Backup", "Restore":
+			return getVeleroHealth
+		}
+	case "tekton.dev":
+		switch gvk.Kind {
+		case "PipelineRun", "TaskRun":
+			return getTektonRunHealth
+		}
+	case "machineconfiguration.openshift.io":
+		switch gvk.Kind {
+		case "MachineConfigPool":
+			return getMachineConfigPoolHealth
 		}
 	case "apiregistration.k8s.io":
 		switch gvk.Kind {
@@ -130,6 +197,8 @@ func GetHealthCheckFunc(gvk schema.GroupVersionKind) func(obj *unstructured.Unst
 		switch gvk.Kind {
 		case kube.IngressKind:
 			return getIngressHealth
+		case kube.NetworkPolicyKind:
+			return getNetworkPolicyHealth
 		}
 	case "":
 		switch gvk.Kind {
@@ -139,11 +208,19 @@ func GetHealthCheckFunc(gvk schema.GroupVersionKind) func(obj *unstructured.Unst
 			return getPVCHealth
 		case kube.PodKind:
 			return getPodHealth
+		case kube.NodeKind:
+			return getNodeHealth
+		case kube.ResourceQuotaKind:
+			return getResourceQuotaHealth
+		case kube.LimitRangeKind:
+			return getLimitRangeHealth
 		}
 	case "batch":
 		switch gvk.Kind {
 		case kube.JobKind:
 			return getJobHealth
+		case "CronJob":
+			return getCronJobHealth
 		}
 	case "autoscaling":
 		switch gvk.Kind {
@@ -151,5 +228,5 @@ func GetHealthCheckFunc(gvk schema.GroupVersionKind) func(obj *unstructured.Unst
 			return getHPAHealth
 		}
 	}
-	return nil
+	return getRegisteredHealthCheck(gvk)
 }
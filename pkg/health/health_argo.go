@@ -1,6 +1,8 @@
 package health
 
 import (
+	"strconv"
+
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -42,3 +44,70 @@ func getArgoWorkflowHealth(obj *unstructured.Unstructured) (*HealthStatus, error
 	}
 	return &HealthStatus{Status: HealthStatusUnknown, Message: wf.Status.Message}, nil
 }
+
+// Rollout phases
+// See: https://github.com/argoproj/argo-rollouts/blob/master/pkg/apis/rollouts/v1alpha1/types.go
+const (
+	rolloutPhaseHealthy     = "Healthy"
+	rolloutPhaseDegraded    = "Degraded"
+	rolloutPhaseProgressing = "Progressing"
+	rolloutPhasePaused      = "Paused"
+)
+
+// An agnostic rollout object only considers whether the rollout is paused, Status.Phase and Status.Message.
+type argoRollout struct {
+	Spec struct {
+		Paused bool
+	}
+	Status struct {
+		Phase           string
+		Message         string
+		PauseConditions []struct {
+			Reason string
+		}
+	}
+}
+
+func getArgoRolloutHealth(obj *unstructured.Unstructured) (*HealthStatus, error) {
+	var rollout argoRollout
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &rollout)
+	if err != nil {
+		return nil, err
+	}
+	if observedGeneration, ok := getRolloutObservedGeneration(obj); ok {
+		if health := getObservedGenerationHealth(obj.GetGeneration(), observedGeneration); health != nil {
+			return health, nil
+		}
+	}
+	if rollout.Spec.Paused || len(rollout.Status.PauseConditions) > 0 || rollout.Status.Phase == rolloutPhasePaused {
+		return &HealthStatus{Status: HealthStatusSuspended, Message: messageRolloutPausedForPromotion}, nil
+	}
+	switch rollout.Status.Phase {
+	case rolloutPhaseHealthy:
+		return &HealthStatus{Status: HealthStatusHealthy, Message: rollout.Status.Message}, nil
+	case rolloutPhaseDegraded:
+		return &HealthStatus{Status: HealthStatusDegraded, Message: rollout.Status.Message}, nil
+	case "", rolloutPhaseProgressing:
+		return &HealthStatus{Status: HealthStatusProgressing, Message: rollout.Status.Message}, nil
+	}
+	return &HealthStatus{Status: HealthStatusUnknown, Message: rollout.Status.Message}, nil
+}
+
+// getRolloutObservedGeneration returns the generation observed by the rollout controller. Older versions of Argo Rollouts
+// report it as a string rather than an integer.
+func getRolloutObservedGeneration(obj *unstructured.Unstructured) (int64, bool) {
+	value, found, err := unstructured.NestedFieldNoCopy(obj.Object, "status", "observedGeneration")
+	if !found || err != nil {
+		return 0, false
+	}
+	switch v := value.(type) {
+	case string:
+		generation, err := strconv.ParseInt(v, 10, 64)
+		return generation, err == nil
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
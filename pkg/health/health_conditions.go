@@ -0,0 +1,81 @@
+package health
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// statusCondition is an agnostic representation of the conditions reported in status.conditions by most resources.
+type statusCondition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
+// getStatusConditions returns the conditions in status.conditions of the given object. Malformed conditions are ignored.
+func getStatusConditions(obj *unstructured.Unstructured) []statusCondition {
+	items, found, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if !found || err != nil {
+		return nil
+	}
+	var conditions []statusCondition
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var condition statusCondition
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, &condition); err != nil {
+			continue
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions
+}
+
+// falseConditionsMessage returns a message listing the reasons and messages of all the conditions with status False,
+// the most recently transitioned first, or empty string if there are none.
+func falseConditionsMessage(conditions []statusCondition) string {
+	return conditionsMessage(conditions, func(condition statusCondition) bool {
+		return condition.Status == "False"
+	})
+}
+
+// conditionsMessage returns a message listing the reasons and messages of the conditions matching the given predicate,
+// the most recently transitioned first, or empty string if there are none. It is used by checkers whose failing
+// conditions are not reported with status False, e.g. Degraded conditions with status True.
+func conditionsMessage(conditions []statusCondition, failing func(condition statusCondition) bool) string {
+	var failed []statusCondition
+	for _, condition := range conditions {
+		if failing(condition) {
+			failed = append(failed, condition)
+		}
+	}
+	// conditions without a valid transition time are listed last
+	transitionTime := func(c statusCondition) time.Time {
+		t, _ := time.Parse(time.RFC3339, c.LastTransitionTime)
+		return t
+	}
+	sort.SliceStable(failed, func(i, j int) bool {
+		return transitionTime(failed[i]).After(transitionTime(failed[j]))
+	})
+	messages := make([]string, 0, len(failed))
+	for _, condition := range failed {
+		message := condition.Type
+		if condition.Reason != "" {
+			message = fmt.Sprintf("%s: %s", message, condition.Reason)
+		}
+		if condition.Message != "" {
+			message = fmt.Sprintf("%s: %s", message, condition.Message)
+		}
+		messages = append(messages, message)
+	}
+	return strings.Join(messages, "; ")
+}
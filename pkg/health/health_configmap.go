@@ -0,0 +1,77 @@
+package health
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+
+	"github.com/argoproj/gitops-engine/pkg/utils/kube"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// GetImmutableConfigHealthWithTarget returns the health of a ConfigMap or Secret taking into account its target state.
+// Immutable ConfigMaps and Secrets (immutable: true) cannot be updated in place, so the resource is Degraded if the
+// target differs from the live resource. Otherwise, and if the live resource is mutable, the resource is Healthy.
+func GetImmutableConfigHealthWithTarget(live, target *unstructured.Unstructured) (*HealthStatus, error) {
+	gvk := live.GroupVersionKind()
+	if gvk.Group != "" || (gvk.Kind != kube.ConfigMapKind && gvk.Kind != kube.SecretKind) {
+		return nil, fmt.Errorf("unsupported immutable config GVK: %s", gvk)
+	}
+	if immutable, _, _ := unstructured.NestedBool(live.Object, "immutable"); !immutable || target == nil {
+		return &HealthStatus{Status: HealthStatusHealthy}, nil
+	}
+	liveData, err := getConfigData(live)
+	if err != nil {
+		return nil, err
+	}
+	targetData, err := getConfigData(target)
+	if err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(liveData, targetData) {
+		return &HealthStatus{
+			Status:  HealthStatusDegraded,
+			Message: fmt.Sprintf("%s %s is immutable and differs from the target: it must be deleted and recreated to be updated", gvk.Kind, live.GetName()),
+		}, nil
+	}
+	return &HealthStatus{Status: HealthStatusHealthy}, nil
+}
+
+// getConfigData returns the fields of a ConfigMap or Secret that cannot be updated if it is immutable. The stringData
+// of a Secret is merged into its data, the same way it is persisted by the API server.
+func getConfigData(obj *unstructured.Unstructured) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	immutable, _, _ := unstructured.NestedBool(obj.Object, "immutable")
+	result["immutable"] = immutable
+	for _, field := range []string{"data", "binaryData"} {
+		data, _, err := unstructured.NestedStringMap(obj.Object, field)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s of %s %s: %w", field, obj.GetKind(), obj.GetName(), err)
+		}
+		if len(data) > 0 {
+			result[field] = data
+		}
+	}
+	if obj.GetKind() == kube.SecretKind {
+		stringData, _, err := unstructured.NestedStringMap(obj.Object, "stringData")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stringData of Secret %s: %w", obj.GetName(), err)
+		}
+		if len(stringData) > 0 {
+			data, _ := result["data"].(map[string]string)
+			if data == nil {
+				data = map[string]string{}
+			}
+			for k, v := range stringData {
+				data[k] = base64.StdEncoding.EncodeToString([]byte(v))
+			}
+			result["data"] = data
+		}
+		secretType, _, _ := unstructured.NestedString(obj.Object, "type")
+		if secretType == "" {
+			secretType = "Opaque"
+		}
+		result["type"] = secretType
+	}
+	return result, nil
+}
@@ -0,0 +1,24 @@
+package health
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func getCronJobHealth(obj *unstructured.Unstructured) (*HealthStatus, error) {
+	// spec.suspend is the same in all versions of CronJob
+	suspend, _, err := unstructured.NestedBool(obj.Object, "spec", "suspend")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spec.suspend of CronJob: %v", err)
+	}
+	if suspend {
+		return &HealthStatus{
+			Status:  HealthStatusSuspended,
+			Message: messageCronJobSuspended,
+		}, nil
+	}
+	return &HealthStatus{
+		Status: HealthStatusHealthy,
+	}, nil
+}
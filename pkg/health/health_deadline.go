@@ -0,0 +1,79 @@
+package health
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ProgressingDeadlines configures how long a resource may stay Progressing before it is reported Degraded.
+// Deadlines in Kinds take precedence over Default. A zero duration means no deadline.
+type ProgressingDeadlines struct {
+	Default time.Duration
+	Kinds   map[schema.GroupKind]time.Duration
+}
+
+func (d ProgressingDeadlines) deadlineFor(gk schema.GroupKind) time.Duration {
+	if deadline, ok := d.Kinds[gk]; ok {
+		return deadline
+	}
+	return d.Default
+}
+
+// GetResourceHealthWithProgressingDeadline returns the health of a resource like GetResourceHealth, but reports
+// a Progressing resource as Degraded once it has been Progressing for longer than its deadline. Since health checks
+// are stateless, the caller may provide the time the resource started progressing. If progressingSince is zero, the
+// time of the resource's status conditions is used instead. If neither is known, no deadline applies.
+func GetResourceHealthWithProgressingDeadline(obj *unstructured.Unstructured, healthOverride HealthOverride, deadlines ProgressingDeadlines, progressingSince time.Time) (*HealthStatus, error) {
+	health, err := GetResourceHealth(obj, healthOverride)
+	if err != nil || health == nil || health.Status != HealthStatusProgressing {
+		return health, err
+	}
+	deadline := deadlines.deadlineFor(obj.GroupVersionKind().GroupKind())
+	if deadline <= 0 {
+		return health, nil
+	}
+	if progressingSince.IsZero() {
+		progressingSince = getProgressingSince(obj)
+	}
+	if progressingSince.IsZero() || time.Since(progressingSince) <= deadline {
+		return health, nil
+	}
+	message := fmt.Sprintf("Progressing for longer than the deadline of %v", deadline)
+	if health.Message != "" {
+		message = fmt.Sprintf("%s: %s", message, health.Message)
+	}
+	return &HealthStatus{Status: HealthStatusDegraded, Message: message}, nil
+}
+
+// getProgressingSince returns the time of the Progressing condition, or the latest time of any condition if the
+// resource has no Progressing condition. The time of a condition is its lastUpdateTime if present, since e.g. the
+// Progressing condition of a Deployment keeps its lastTransitionTime across rollouts because its status stays True,
+// and its lastTransitionTime otherwise.
+func getProgressingSince(obj *unstructured.Unstructured) time.Time {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	var latest time.Time
+	for _, item := range conditions {
+		condition, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		conditionTime, _ := condition["lastUpdateTime"].(string)
+		if conditionTime == "" {
+			conditionTime, _ = condition["lastTransitionTime"].(string)
+		}
+		parsed, err := time.Parse(time.RFC3339, conditionTime)
+		if err != nil {
+			continue
+		}
+		if condition["type"] == "Progressing" {
+			return parsed
+		}
+		if parsed.After(latest) {
+			latest = parsed
+		}
+	}
+	return latest
+}
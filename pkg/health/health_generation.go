@@ -0,0 +1,19 @@
+package health
+
+import "fmt"
+
+// messageGenerationNotObserved is the message of workloads whose controller has not observed the latest update yet
+const messageGenerationNotObserved = "Waiting for controller to observe update"
+
+// getObservedGenerationHealth returns Progressing health if the controller of a workload has not observed the given
+// generation yet or nil otherwise. Any other status reported by a stale workload refers to the previous generation and
+// must not be trusted.
+func getObservedGenerationHealth(generation, observedGeneration int64) *HealthStatus {
+	if generation <= observedGeneration {
+		return nil
+	}
+	return &HealthStatus{
+		Status:  HealthStatusProgressing,
+		Message: fmt.Sprintf("%s: observed generation %d is less than generation %d", messageGenerationNotObserved, observedGeneration, generation),
+	}
+}
@@ -3,6 +3,8 @@ package health
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	autoscalingv2 "k8s.io/api/autoscaling/v2"
@@ -22,10 +24,11 @@ var (
 )
 
 type hpaCondition struct {
-	Type    string
-	Reason  string
-	Message string
-	Status  string
+	Type               string
+	Reason             string
+	Message            string
+	Status             string
+	LastTransitionTime string
 }
 
 func getHPAHealth(obj *unstructured.Unstructured) (*HealthStatus, error) {
@@ -71,14 +74,15 @@ func getAutoScalingV2HPAHealth(hpa *autoscalingv2.HorizontalPodAutoscaler) (*Hea
 	conditions := make([]hpaCondition, 0, len(statusConditions))
 	for _, statusCondition := range statusConditions {
 		conditions = append(conditions, hpaCondition{
-			Type:    string(statusCondition.Type),
-			Reason:  statusCondition.Reason,
-			Message: statusCondition.Message,
-			Status:  string(statusCondition.Status),
+			Type:               string(statusCondition.Type),
+			Reason:             statusCondition.Reason,
+			Message:            statusCondition.Message,
+			Status:             string(statusCondition.Status),
+			LastTransitionTime: statusCondition.LastTransitionTime.Format(time.RFC3339),
 		})
 	}
 
-	return checkConditions(conditions, progressingStatus)
+	return checkConditions(conditions, hpa.Status.CurrentReplicas, hpa.Status.DesiredReplicas, progressingStatus)
 }
 
 func getAutoScalingV2beta2HPAHealth(hpa *autoscalingv2beta2.HorizontalPodAutoscaler) (*HealthStatus, error) {
@@ -86,14 +90,15 @@ func getAutoScalingV2beta2HPAHealth(hpa *autoscalingv2beta2.HorizontalPodAutosca
 	conditions := make([]hpaCondition, 0, len(statusConditions))
 	for _, statusCondition := range statusConditions {
 		conditions = append(conditions, hpaCondition{
-			Type:    string(statusCondition.Type),
-			Reason:  statusCondition.Reason,
-			Message: statusCondition.Message,
-			Status:  string(statusCondition.Status),
+			Type:               string(statusCondition.Type),
+			Reason:             statusCondition.Reason,
+			Message:            statusCondition.Message,
+			Status:             string(statusCondition.Status),
+			LastTransitionTime: statusCondition.LastTransitionTime.Format(time.RFC3339),
 		})
 	}
 
-	return checkConditions(conditions, progressingStatus)
+	return checkConditions(conditions, hpa.Status.CurrentReplicas, hpa.Status.DesiredReplicas, progressingStatus)
 }
 
 func getAutoScalingV2beta1HPAHealth(hpa *autoscalingv2beta1.HorizontalPodAutoscaler) (*HealthStatus, error) {
@@ -101,14 +106,15 @@ func getAutoScalingV2beta1HPAHealth(hpa *autoscalingv2beta1.HorizontalPodAutosca
 	conditions := make([]hpaCondition, 0, len(statusConditions))
 	for _, statusCondition := range statusConditions {
 		conditions = append(conditions, hpaCondition{
-			Type:    string(statusCondition.Type),
-			Reason:  statusCondition.Reason,
-			Message: statusCondition.Message,
-			Status:  string(statusCondition.Status),
+			Type:               string(statusCondition.Type),
+			Reason:             statusCondition.Reason,
+			Message:            statusCondition.Message,
+			Status:             string(statusCondition.Status),
+			LastTransitionTime: statusCondition.LastTransitionTime.Format(time.RFC3339),
 		})
 	}
 
-	return checkConditions(conditions, progressingStatus)
+	return checkConditions(conditions, hpa.Status.CurrentReplicas, hpa.Status.DesiredReplicas, progressingStatus)
 }
 
 func getAutoScalingV1HPAHealth(hpa *autoscalingv1.HorizontalPodAutoscaler) (*HealthStatus, error) {
@@ -128,25 +134,57 @@ func getAutoScalingV1HPAHealth(hpa *autoscalingv1.HorizontalPodAutoscaler) (*Hea
 		return progressingStatus, nil
 	}
 
-	return checkConditions(conditions, progressingStatus)
+	return checkConditions(conditions, hpa.Status.CurrentReplicas, hpa.Status.DesiredReplicas, progressingStatus)
 }
 
-func checkConditions(conditions []hpaCondition, progressingStatus *HealthStatus) (*HealthStatus, error) {
+// checkConditions returns Degraded if any condition reports that the HPA is unable to scale, e.g. because the metrics
+// cannot be fetched, Healthy if the HPA is able to scale or its scaling is limited, and progressingStatus otherwise.
+func checkConditions(conditions []hpaCondition, currentReplicas, desiredReplicas int32, progressingStatus *HealthStatus) (*HealthStatus, error) {
+	replicasMessage := func(message string) string {
+		return fmt.Sprintf("%s (current replicas: %d, desired replicas: %d)", message, currentReplicas, desiredReplicas)
+	}
+	var degraded []statusCondition
 	for _, condition := range conditions {
 		if isDegraded(&condition) {
-			return &HealthStatus{
-				Status:  HealthStatusDegraded,
-				Message: condition.Message,
-			}, nil
+			degraded = append(degraded, statusCondition{
+				Type:               condition.Type,
+				Status:             condition.Status,
+				Reason:             condition.Reason,
+				Message:            condition.Message,
+				LastTransitionTime: condition.LastTransitionTime,
+			})
 		}
+	}
+	if len(degraded) > 0 {
+		// e.g. both AbleToScale and ScalingActive fail if the target cannot be found
+		return &HealthStatus{
+			Status: HealthStatusDegraded,
+			Message: replicasMessage(conditionsMessage(degraded, func(statusCondition) bool {
+				return true
+			})),
+		}, nil
+	}
 
-		if isHealthy(&condition) {
-			return &HealthStatus{
-				Status:  HealthStatusHealthy,
-				Message: condition.Message,
-			}, nil
+	var healthyCondition *hpaCondition
+	for i := range conditions {
+		if !isHealthy(&conditions[i]) {
+			continue
+		}
+		// the note about limited scaling takes precedence over the generic AbleToScale message
+		if healthyCondition == nil || conditions[i].Type == "ScalingLimited" {
+			healthyCondition = &conditions[i]
 		}
 	}
+	if healthyCondition != nil {
+		message := healthyCondition.Message
+		if healthyCondition.Type == "ScalingLimited" {
+			message = "scaling is limited: " + message
+		}
+		return &HealthStatus{
+			Status:  HealthStatusHealthy,
+			Message: replicasMessage(message),
+		}, nil
+	}
 
 	return progressingStatus, nil
 }
@@ -163,6 +201,10 @@ func isDegraded(condition *hpaCondition) bool {
 			return true
 		}
 	}
+	// e.g. FailedGetExternalMetric, FailedGetPodsMetric or InvalidMetricSourceType
+	if (condition.Type == "AbleToScale" || condition.Type == "ScalingActive") && condition.Status == "False" {
+		return strings.HasPrefix(condition.Reason, "Failed") || strings.HasPrefix(condition.Reason, "Invalid")
+	}
 	return false
 }
 
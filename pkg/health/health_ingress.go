@@ -1,6 +1,11 @@
 package health
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/argoproj/gitops-engine/pkg/utils/kube"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
@@ -11,6 +16,74 @@ func getIngressHealth(obj *unstructured.Unstructured) (*HealthStatus, error) {
 		health.Status = HealthStatusHealthy
 	} else {
 		health.Status = HealthStatusProgressing
+		health.Message = "Waiting for Ingress to be assigned an address"
 	}
 	return &health, nil
 }
+
+// GetIngressHealthWithTree returns the health of an Ingress taking into account the given resource tree.
+// The Ingress is Degraded if any of the referenced backend services is missing from the tree, otherwise
+// the result is the same as for the built-in Ingress health check.
+func GetIngressHealthWithTree(obj *unstructured.Unstructured, tree []*unstructured.Unstructured) (*HealthStatus, error) {
+	services := make(map[string]bool)
+	for _, res := range tree {
+		if res != nil && res.GroupVersionKind().Group == "" && res.GetKind() == kube.ServiceKind {
+			services[res.GetNamespace()+"/"+res.GetName()] = true
+		}
+	}
+	var missing []string
+	for _, name := range getIngressBackendServices(obj) {
+		if !services[obj.GetNamespace()+"/"+name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return &HealthStatus{
+			Status:  HealthStatusDegraded,
+			Message: fmt.Sprintf("Backend service(s) not found: %s", strings.Join(missing, ", ")),
+		}, nil
+	}
+	return getIngressHealth(obj)
+}
+
+// getIngressBackendServices returns the sorted names of the services referenced by the Ingress. Both the
+// networking.k8s.io/v1 (backend.service.name) and the older beta (backend.serviceName) formats are supported.
+func getIngressBackendServices(obj *unstructured.Unstructured) []string {
+	names := make(map[string]bool)
+	addBackend := func(backend map[string]interface{}) {
+		if name, ok, _ := unstructured.NestedString(backend, "service", "name"); ok && name != "" {
+			names[name] = true
+		}
+		if name, ok, _ := unstructured.NestedString(backend, "serviceName"); ok && name != "" {
+			names[name] = true
+		}
+	}
+	for _, field := range []string{"defaultBackend", "backend"} {
+		if backend, ok, _ := unstructured.NestedMap(obj.Object, "spec", field); ok {
+			addBackend(backend)
+		}
+	}
+	rules, _, _ := unstructured.NestedSlice(obj.Object, "spec", "rules")
+	for _, rule := range rules {
+		ruleMap, ok := rule.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		paths, _, _ := unstructured.NestedSlice(ruleMap, "http", "paths")
+		for _, path := range paths {
+			pathMap, ok := path.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if backend, ok, _ := unstructured.NestedMap(pathMap, "backend"); ok {
+				addBackend(backend)
+			}
+		}
+	}
+	result := make([]string, 0, len(names))
+	for name := range names {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
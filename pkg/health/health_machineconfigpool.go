@@ -0,0 +1,57 @@
+package health
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// An agnostic OpenShift MachineConfigPool object only considers the status conditions.
+// See: https://github.com/openshift/api/blob/master/machineconfiguration/v1/types.go
+type machineConfigPool struct {
+	Status struct {
+		Conditions []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions,omitempty"`
+	} `json:"status,omitempty"`
+}
+
+func getMachineConfigPoolHealth(obj *unstructured.Unstructured) (*HealthStatus, error) {
+	var pool machineConfigPool
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &pool)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert unstructured MachineConfigPool to typed: %v", err)
+	}
+	conditions := make(map[string]bool)
+	degraded := false
+	for _, condition := range pool.Status.Conditions {
+		if condition.Status == "True" {
+			conditions[condition.Type] = true
+			degraded = degraded || isMachineConfigPoolDegradedCondition(condition.Type)
+		}
+	}
+	if degraded {
+		// all the degraded conditions are listed as e.g. Degraded itself only summarizes NodeDegraded
+		message := conditionsMessage(getStatusConditions(obj), func(condition statusCondition) bool {
+			return condition.Status == "True" && isMachineConfigPoolDegradedCondition(condition.Type)
+		})
+		return &HealthStatus{Status: HealthStatusDegraded, Message: message}, nil
+	}
+	if conditions["Updating"] {
+		return &HealthStatus{Status: HealthStatusProgressing, Message: "Machine config pool is updating"}, nil
+	}
+	if conditions["Updated"] {
+		return &HealthStatus{Status: HealthStatusHealthy, Message: "All nodes are updated"}, nil
+	}
+	return &HealthStatus{Status: HealthStatusProgressing, Message: "Waiting for machine config pool to be updated"}, nil
+}
+
+func isMachineConfigPoolDegradedCondition(conditionType string) bool {
+	switch conditionType {
+	case "Degraded", "NodeDegraded", "RenderDegraded":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package health
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/argoproj/gitops-engine/pkg/utils/kube"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func getNetworkPolicyHealth(obj *unstructured.Unstructured) (*HealthStatus, error) {
+	gvk := obj.GroupVersionKind()
+	switch gvk {
+	case networkingv1.SchemeGroupVersion.WithKind(kube.NetworkPolicyKind):
+		var policy networkingv1.NetworkPolicy
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &policy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert unstructured NetworkPolicy to typed: %v", err)
+		}
+		return getNetworkingv1NetworkPolicyHealth(&policy)
+	default:
+		return nil, fmt.Errorf("unsupported NetworkPolicy GVK: %s", gvk)
+	}
+}
+
+// getNetworkingv1NetworkPolicyHealth reports network policies as Degraded only if their rules are obviously invalid.
+// Policies that are valid but might be surprising, e.g. with an empty pod selector, are Healthy.
+func getNetworkingv1NetworkPolicyHealth(policy *networkingv1.NetworkPolicy) (*HealthStatus, error) {
+	var problems []string
+
+	hasPolicyType := map[networkingv1.PolicyType]bool{}
+	for _, policyType := range policy.Spec.PolicyTypes {
+		if policyType != networkingv1.PolicyTypeIngress && policyType != networkingv1.PolicyTypeEgress {
+			problems = append(problems, fmt.Sprintf("unknown policy type %q", policyType))
+		}
+		hasPolicyType[policyType] = true
+	}
+	// rules of a type that is not listed in explicitly specified policy types are ignored
+	if len(policy.Spec.PolicyTypes) > 0 {
+		if len(policy.Spec.Ingress) > 0 && !hasPolicyType[networkingv1.PolicyTypeIngress] {
+			problems = append(problems, "ingress rules are defined but policyTypes does not include Ingress")
+		}
+		if len(policy.Spec.Egress) > 0 && !hasPolicyType[networkingv1.PolicyTypeEgress] {
+			problems = append(problems, "egress rules are defined but policyTypes does not include Egress")
+		}
+	}
+
+	for i, rule := range policy.Spec.Ingress {
+		problems = append(problems, networkPolicyPortProblems(fmt.Sprintf("ingress[%d]", i), rule.Ports)...)
+		problems = append(problems, networkPolicyPeerProblems(fmt.Sprintf("ingress[%d]", i), rule.From)...)
+	}
+	for i, rule := range policy.Spec.Egress {
+		problems = append(problems, networkPolicyPortProblems(fmt.Sprintf("egress[%d]", i), rule.Ports)...)
+		problems = append(problems, networkPolicyPeerProblems(fmt.Sprintf("egress[%d]", i), rule.To)...)
+	}
+
+	if len(problems) > 0 {
+		return &HealthStatus{
+			Status:  HealthStatusDegraded,
+			Message: fmt.Sprintf("NetworkPolicy has invalid rules: %s", strings.Join(problems, "; ")),
+		}, nil
+	}
+	return &HealthStatus{
+		Status: HealthStatusHealthy,
+	}, nil
+}
+
+func networkPolicyPortProblems(rule string, ports []networkingv1.NetworkPolicyPort) []string {
+	var problems []string
+	for _, port := range ports {
+		// named ports cannot be verified without the selected pods
+		if port.Port == nil || port.Port.Type != intstr.Int {
+			if port.EndPort != nil {
+				problems = append(problems, fmt.Sprintf("%s: endPort %d requires a numeric port", rule, *port.EndPort))
+			}
+			continue
+		}
+		value := port.Port.IntVal
+		if value < 1 || value > 65535 {
+			problems = append(problems, fmt.Sprintf("%s: port %d is out of range", rule, value))
+			continue
+		}
+		if port.EndPort != nil && (*port.EndPort < value || *port.EndPort > 65535) {
+			problems = append(problems, fmt.Sprintf("%s: endPort %d is invalid for port %d", rule, *port.EndPort, value))
+		}
+	}
+	return problems
+}
+
+func networkPolicyPeerProblems(rule string, peers []networkingv1.NetworkPolicyPeer) []string {
+	var problems []string
+	for _, peer := range peers {
+		if peer.IPBlock == nil {
+			continue
+		}
+		_, cidr, err := net.ParseCIDR(peer.IPBlock.CIDR)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid CIDR %q", rule, peer.IPBlock.CIDR))
+			continue
+		}
+		for _, except := range peer.IPBlock.Except {
+			exceptIP, _, err := net.ParseCIDR(except)
+			if err != nil || !cidr.Contains(exceptIP) {
+				problems = append(problems, fmt.Sprintf("%s: except %q is not within CIDR %q", rule, except, peer.IPBlock.CIDR))
+			}
+		}
+	}
+	return problems
+}
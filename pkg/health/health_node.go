@@ -0,0 +1,48 @@
+package health
+
+import (
+	"fmt"
+
+	"github.com/argoproj/gitops-engine/pkg/utils/kube"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func getNodeHealth(obj *unstructured.Unstructured) (*HealthStatus, error) {
+	gvk := obj.GroupVersionKind()
+	switch gvk {
+	case corev1.SchemeGroupVersion.WithKind(kube.NodeKind):
+		var node corev1.Node
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &node)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert unstructured Node to typed: %v", err)
+		}
+		return getCorev1NodeHealth(&node)
+	default:
+		return nil, fmt.Errorf("unsupported Node GVK: %s", gvk)
+	}
+}
+
+func getCorev1NodeHealth(node *corev1.Node) (*HealthStatus, error) {
+	var ready *corev1.NodeCondition
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == corev1.NodeReady {
+			ready = &node.Status.Conditions[i]
+			break
+		}
+	}
+	if ready == nil {
+		return &HealthStatus{Status: HealthStatusProgressing, Message: "Waiting for node to report readiness"}, nil
+	}
+	switch ready.Status {
+	case corev1.ConditionFalse:
+		return &HealthStatus{Status: HealthStatusDegraded, Message: fmt.Sprintf("Node is not ready: %s: %s", ready.Reason, ready.Message)}, nil
+	case corev1.ConditionUnknown:
+		return &HealthStatus{Status: HealthStatusUnknown, Message: fmt.Sprintf("Node readiness is unknown: %s: %s", ready.Reason, ready.Message)}, nil
+	}
+	if node.Spec.Unschedulable {
+		return &HealthStatus{Status: HealthStatusSuspended, Message: "Node is cordoned"}, nil
+	}
+	return &HealthStatus{Status: HealthStatusHealthy, Message: ready.Message}, nil
+}
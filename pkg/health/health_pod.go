@@ -33,7 +33,9 @@ func getCorev1PodHealth(pod *corev1.Pod) (*HealthStatus, error) {
 	// then we will prematurely fail the PreSync/PostSync hook. Meanwhile, when that error condition is resolved
 	// (e.g. the image is available), the resource hook pod will unexpectedly be executed even though the sync has
 	// completed.
-	if pod.Spec.RestartPolicy == corev1.RestartPolicyAlways {
+	// Pods that have terminated are assessed solely on their phase.
+	terminated := pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+	if pod.Spec.RestartPolicy == corev1.RestartPolicyAlways && !terminated {
 		var status HealthStatusCode
 		var messages []string
 
@@ -63,6 +65,9 @@ func getCorev1PodHealth(pod *corev1.Pod) (*HealthStatus, error) {
 				return ctr.State.Terminated.Reason
 			}
 			if ctr.State.Terminated.ExitCode != 0 {
+				if ctr.State.Terminated.Reason != "" {
+					return fmt.Sprintf("container %q failed with exit code %d (reason: %s)", ctr.Name, ctr.State.Terminated.ExitCode, ctr.State.Terminated.Reason)
+				}
 				return fmt.Sprintf("container %q failed with exit code %d", ctr.Name, ctr.State.Terminated.ExitCode)
 			}
 		}
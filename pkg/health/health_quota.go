@@ -0,0 +1,88 @@
+package health
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/argoproj/gitops-engine/pkg/utils/kube"
+)
+
+func getResourceQuotaHealth(obj *unstructured.Unstructured) (*HealthStatus, error) {
+	gvk := obj.GroupVersionKind()
+	switch gvk {
+	case corev1.SchemeGroupVersion.WithKind(kube.ResourceQuotaKind):
+		var quota corev1.ResourceQuota
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &quota)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert unstructured ResourceQuota to typed: %v", err)
+		}
+		return getCorev1ResourceQuotaHealth(&quota)
+	default:
+		return nil, fmt.Errorf("unsupported ResourceQuota GVK: %s", gvk)
+	}
+}
+
+// getCorev1ResourceQuotaHealth reports the quota as degraded if the usage of any resource reached its hard limit. Hard
+// limits of zero forbid the resource on purpose and are never considered exhausted.
+func getCorev1ResourceQuotaHealth(quota *corev1.ResourceQuota) (*HealthStatus, error) {
+	var exhausted []string
+	for name, hard := range quota.Status.Hard {
+		used, ok := quota.Status.Used[name]
+		if !ok || hard.IsZero() || used.Cmp(hard) < 0 {
+			continue
+		}
+		exhausted = append(exhausted, fmt.Sprintf("%s (used %s, hard %s)", name, used.String(), hard.String()))
+	}
+	if len(exhausted) > 0 {
+		sort.Strings(exhausted)
+		return &HealthStatus{
+			Status:  HealthStatusDegraded,
+			Message: fmt.Sprintf("Quota exhausted: %s", strings.Join(exhausted, ", ")),
+		}, nil
+	}
+	return &HealthStatus{Status: HealthStatusHealthy}, nil
+}
+
+func getLimitRangeHealth(obj *unstructured.Unstructured) (*HealthStatus, error) {
+	gvk := obj.GroupVersionKind()
+	switch gvk {
+	case corev1.SchemeGroupVersion.WithKind(kube.LimitRangeKind):
+		var limitRange corev1.LimitRange
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &limitRange)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert unstructured LimitRange to typed: %v", err)
+		}
+		return getCorev1LimitRangeHealth(&limitRange)
+	default:
+		return nil, fmt.Errorf("unsupported LimitRange GVK: %s", gvk)
+	}
+}
+
+// getCorev1LimitRangeHealth reports the limit range as healthy unless its limits contradict each other, e.g. a minimum
+// that is greater than the maximum. Limit ranges have no status.
+func getCorev1LimitRangeHealth(limitRange *corev1.LimitRange) (*HealthStatus, error) {
+	for _, item := range limitRange.Spec.Limits {
+		for name, minimum := range item.Min {
+			if maximum, ok := item.Max[name]; ok && minimum.Cmp(maximum) > 0 {
+				return &HealthStatus{
+					Status:  HealthStatusDegraded,
+					Message: fmt.Sprintf("%s limit of %s: min %s is greater than max %s", item.Type, name, minimum.String(), maximum.String()),
+				}, nil
+			}
+		}
+		for name, request := range item.DefaultRequest {
+			if limit, ok := item.Default[name]; ok && request.Cmp(limit) > 0 {
+				return &HealthStatus{
+					Status:  HealthStatusDegraded,
+					Message: fmt.Sprintf("%s limit of %s: default request %s is greater than default %s", item.Type, name, request.String(), limit.String()),
+				}, nil
+			}
+		}
+	}
+	return &HealthStatus{Status: HealthStatusHealthy}, nil
+}
@@ -26,7 +26,10 @@ func getStatefulSetHealth(obj *unstructured.Unstructured) (*HealthStatus, error)
 
 func getAppsv1StatefulSetHealth(sts *appsv1.StatefulSet) (*HealthStatus, error) {
 	// Borrowed at kubernetes/kubectl/rollout_status.go https://github.com/kubernetes/kubernetes/blob/5232ad4a00ec93942d0b2c6359ee6cd1201b46bc/pkg/kubectl/rollout_status.go#L131
-	if sts.Status.ObservedGeneration == 0 || sts.Generation > sts.Status.ObservedGeneration {
+	if health := getObservedGenerationHealth(sts.Generation, sts.Status.ObservedGeneration); health != nil {
+		return health, nil
+	}
+	if sts.Status.ObservedGeneration == 0 {
 		return &HealthStatus{
 			Status:  HealthStatusProgressing,
 			Message: "Waiting for statefulset spec update to be observed...",
@@ -53,6 +56,8 @@ func getAppsv1StatefulSetHealth(sts *appsv1.StatefulSet) (*HealthStatus, error)
 			Message: fmt.Sprintf("partitioned roll out complete: %d new pods have been updated...", sts.Status.UpdatedReplicas),
 		}, nil
 	}
+	// pods of an OnDelete statefulset are only updated once they are deleted manually, so the health is solely based on
+	// the observed generation and the ready replicas rather than on the updated replicas
 	if sts.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
 		return &HealthStatus{
 			Status:  HealthStatusHealthy,
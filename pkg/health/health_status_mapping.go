@@ -0,0 +1,137 @@
+package health
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+type statusHealthMapping struct {
+	statusPath  string
+	messagePath string
+	mapping     map[string]HealthStatusCode
+}
+
+var (
+	statusHealthMappingsLock sync.RWMutex
+	statusHealthMappings     = map[schema.GroupVersionKind]*statusHealthMapping{}
+)
+
+// RegisterStatusHealth registers a health check for resources of the given GVK that have no built-in health check.
+// The check reads the status string located at statusPath (a JSONPath expression such as "{.status.phase}" or
+// ".status.phase") and maps it to a health status using the given mapping. The optional messagePath points to a
+// string used as the health message. If there is no message, the message of Degraded resources lists all the status
+// conditions that are False. Resources that report status.observedGeneration are Progressing until the observed
+// generation catches up with metadata.generation. Resources without a status value are Progressing and resources with
+// a value missing from the mapping are Unknown.
+func RegisterStatusHealth(gvk schema.GroupVersionKind, statusPath string, mapping map[string]HealthStatusCode, messagePath string) error {
+	if _, err := parseJSONPath(statusPath); err != nil {
+		return fmt.Errorf("invalid status path %q: %w", statusPath, err)
+	}
+	if messagePath != "" {
+		if _, err := parseJSONPath(messagePath); err != nil {
+			return fmt.Errorf("invalid message path %q: %w", messagePath, err)
+		}
+	}
+	m := &statusHealthMapping{statusPath: statusPath, messagePath: messagePath, mapping: make(map[string]HealthStatusCode, len(mapping))}
+	for k, v := range mapping {
+		m.mapping[k] = v
+	}
+	statusHealthMappingsLock.Lock()
+	defer statusHealthMappingsLock.Unlock()
+	statusHealthMappings[gvk] = m
+	return nil
+}
+
+// UnregisterStatusHealth removes the health check registered for the given GVK
+func UnregisterStatusHealth(gvk schema.GroupVersionKind) {
+	statusHealthMappingsLock.Lock()
+	defer statusHealthMappingsLock.Unlock()
+	delete(statusHealthMappings, gvk)
+}
+
+func getRegisteredHealthCheck(gvk schema.GroupVersionKind) func(obj *unstructured.Unstructured) (*HealthStatus, error) {
+	statusHealthMappingsLock.RLock()
+	defer statusHealthMappingsLock.RUnlock()
+	if m, ok := statusHealthMappings[gvk]; ok {
+		return m.getHealth
+	}
+	return nil
+}
+
+func (m *statusHealthMapping) getHealth(obj *unstructured.Unstructured) (*HealthStatus, error) {
+	if observedGeneration, found, err := unstructured.NestedInt64(obj.Object, "status", "observedGeneration"); found && err == nil {
+		if health := getObservedGenerationHealth(obj.GetGeneration(), observedGeneration); health != nil {
+			return health, nil
+		}
+	}
+	status, err := findJSONPathString(m.statusPath, obj)
+	if err != nil {
+		return nil, err
+	}
+	health := &HealthStatus{}
+	if m.messagePath != "" {
+		if health.Message, err = findJSONPathString(m.messagePath, obj); err != nil {
+			return nil, err
+		}
+	}
+	if status == "" {
+		health.Status = HealthStatusProgressing
+		if health.Message == "" {
+			health.Message = "Waiting for status to be reported"
+		}
+		return health, nil
+	}
+	code, ok := m.mapping[status]
+	if !ok {
+		health.Status = HealthStatusUnknown
+		if health.Message == "" {
+			health.Message = fmt.Sprintf("Unknown status: %s", status)
+		}
+		return health, nil
+	}
+	health.Status = code
+	if code == HealthStatusDegraded && health.Message == "" {
+		health.Message = falseConditionsMessage(getStatusConditions(obj))
+	}
+	return health, nil
+}
+
+func parseJSONPath(path string) (*jsonpath.JSONPath, error) {
+	if !strings.HasPrefix(path, "{") {
+		path = "{" + path + "}"
+	}
+	jp := jsonpath.New("health").AllowMissingKeys(true)
+	if err := jp.Parse(path); err != nil {
+		return nil, err
+	}
+	return jp, nil
+}
+
+// findJSONPathString returns the first value matched by the given path or empty string if there is no match.
+// The path is parsed on every call because a parsed JSONPath is not safe for concurrent use.
+func findJSONPathString(path string, obj *unstructured.Unstructured) (string, error) {
+	jp, err := parseJSONPath(path)
+	if err != nil {
+		return "", err
+	}
+	results, err := jp.FindResults(obj.Object)
+	if err != nil {
+		return "", err
+	}
+	for _, result := range results {
+		for _, v := range result {
+			if v.CanInterface() {
+				if v.Interface() == nil {
+					continue
+				}
+				return fmt.Sprintf("%v", v.Interface()), nil
+			}
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,55 @@
+package health
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// An agnostic Tekton PipelineRun/TaskRun object only considers the Succeeded condition and the start and completion times.
+// See: https://github.com/tektoncd/pipeline/blob/main/docs/pipelineruns.md#monitoring-execution-status
+type tektonRun struct {
+	Status struct {
+		Conditions []struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Reason  string `json:"reason,omitempty"`
+			Message string `json:"message,omitempty"`
+		} `json:"conditions,omitempty"`
+		StartTime      string `json:"startTime,omitempty"`
+		CompletionTime string `json:"completionTime,omitempty"`
+	} `json:"status,omitempty"`
+}
+
+func getTektonRunHealth(obj *unstructured.Unstructured) (*HealthStatus, error) {
+	var run tektonRun
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &run)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert unstructured %s to typed: %v", obj.GetKind(), err)
+	}
+	for _, condition := range run.Status.Conditions {
+		if condition.Type != "Succeeded" {
+			continue
+		}
+		switch condition.Status {
+		case "True":
+			message := fmt.Sprintf("%s succeeded", obj.GetKind())
+			if run.Status.CompletionTime != "" {
+				message = fmt.Sprintf("%s at %s", message, run.Status.CompletionTime)
+			}
+			return &HealthStatus{Status: HealthStatusHealthy, Message: message}, nil
+		case "False":
+			// custom tasks might report additional failed conditions, so all of them are listed
+			message := fmt.Sprintf("%s failed: %s", obj.GetKind(), falseConditionsMessage(getStatusConditions(obj)))
+			return &HealthStatus{Status: HealthStatusDegraded, Message: message}, nil
+		default:
+			message := fmt.Sprintf("%s is %s", obj.GetKind(), condition.Reason)
+			if run.Status.StartTime != "" {
+				message = fmt.Sprintf("%s since %s", message, run.Status.StartTime)
+			}
+			return &HealthStatus{Status: HealthStatusProgressing, Message: message}, nil
+		}
+	}
+	return &HealthStatus{Status: HealthStatusProgressing, Message: fmt.Sprintf("Waiting for %s to start", obj.GetKind())}, nil
+}
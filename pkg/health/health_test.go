@@ -7,10 +7,12 @@ package health
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/yaml"
 )
 
@@ -31,6 +33,15 @@ func getHealthStatus(yamlPath string, t *testing.T) *HealthStatus {
 	return health
 }
 
+func loadObject(t *testing.T, yamlPath string) *unstructured.Unstructured {
+	yamlBytes, err := os.ReadFile(yamlPath)
+	require.NoError(t, err)
+	var obj unstructured.Unstructured
+	err = yaml.Unmarshal(yamlBytes, &obj)
+	require.NoError(t, err)
+	return &obj
+}
+
 func TestDeploymentHealth(t *testing.T) {
 	assertAppHealth(t, "../utils/kube/testdata/nginx.yaml", HealthStatusHealthy)
 	assertAppHealth(t, "./testdata/deployment-progressing.yaml", HealthStatusProgressing)
@@ -38,22 +49,110 @@ func TestDeploymentHealth(t *testing.T) {
 	assertAppHealth(t, "./testdata/deployment-degraded.yaml", HealthStatusDegraded)
 }
 
+func TestNetworkPolicyHealth(t *testing.T) {
+	assertAppHealth(t, "./testdata/networkpolicy-valid.yaml", HealthStatusHealthy)
+
+	health := getHealthStatus("./testdata/networkpolicy-invalid-port.yaml", t)
+	assert.Equal(t, HealthStatusDegraded, health.Status)
+	assert.Equal(t, "NetworkPolicy has invalid rules: ingress[0]: port -80 is out of range", health.Message)
+
+	health = getHealthStatus("./testdata/networkpolicy-conflicting-types.yaml", t)
+	assert.Equal(t, HealthStatusDegraded, health.Status)
+	assert.Equal(t, "NetworkPolicy has invalid rules: egress rules are defined but policyTypes does not include Egress", health.Message)
+}
+
+func TestPausedWorkloadHealth(t *testing.T) {
+	for _, tc := range []struct {
+		yamlPath string
+		message  string
+	}{
+		{"./testdata/deployment-suspended.yaml", "deployment paused"},
+		{"./testdata/rollout-paused.yaml", "rollout paused for promotion"},
+		{"./testdata/cronjob-suspended.yaml", "cronjob suspended"},
+	} {
+		health := getHealthStatus(tc.yamlPath, t)
+		assert.Equal(t, HealthStatusSuspended, health.Status, tc.yamlPath)
+		assert.Equal(t, tc.message, health.Message, tc.yamlPath)
+	}
+}
+
 func TestStatefulSetHealth(t *testing.T) {
 	assertAppHealth(t, "./testdata/statefulset.yaml", HealthStatusHealthy)
 }
 
 func TestStatefulSetOnDeleteHealth(t *testing.T) {
 	assertAppHealth(t, "./testdata/statefulset-ondelete.yaml", HealthStatusHealthy)
+	// pods are only updated once they are deleted, so pods at an old revision do not affect health
+	assertAppHealth(t, "./testdata/statefulset-ondelete-old-revision.yaml", HealthStatusHealthy)
 }
 
 func TestDaemonSetOnDeleteHealth(t *testing.T) {
 	assertAppHealth(t, "./testdata/daemonset-ondelete.yaml", HealthStatusHealthy)
 }
+func TestStaleGenerationHealth(t *testing.T) {
+	for _, yamlPath := range []string{
+		"./testdata/deployment-stale-generation.yaml",
+		"./testdata/statefulset-stale-generation.yaml",
+		"./testdata/daemonset-stale-generation.yaml",
+		"./testdata/replicaset-stale-generation.yaml",
+		"./testdata/rollout-stale-generation.yaml",
+	} {
+		health := getHealthStatus(yamlPath, t)
+		assert.Equal(t, HealthStatusProgressing, health.Status, yamlPath)
+		assert.Contains(t, health.Message, messageGenerationNotObserved, yamlPath)
+	}
+
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "ReplicaPool"}
+	require.NoError(t, RegisterStatusHealth(gvk, ".status.phase", map[string]HealthStatusCode{"Ready": HealthStatusHealthy}, ""))
+	defer UnregisterStatusHealth(gvk)
+	newPool := func(status map[string]interface{}) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{"status": status}}
+		obj.SetGroupVersionKind(gvk)
+		obj.SetName("my-pool")
+		obj.SetGeneration(2)
+		return obj
+	}
+
+	health, err := GetResourceHealth(newPool(map[string]interface{}{"phase": "Ready", "observedGeneration": int64(1)}), nil)
+	require.NoError(t, err)
+	assert.Equal(t, &HealthStatus{Status: HealthStatusProgressing, Message: "Waiting for controller to observe update: observed generation 1 is less than generation 2"}, health)
+
+	health, err = GetResourceHealth(newPool(map[string]interface{}{"phase": "Ready", "observedGeneration": int64(2)}), nil)
+	require.NoError(t, err)
+	assert.Equal(t, HealthStatusHealthy, health.Status)
+
+	// resources that do not report the observed generation are not checked
+	health, err = GetResourceHealth(newPool(map[string]interface{}{"phase": "Ready"}), nil)
+	require.NoError(t, err)
+	assert.Equal(t, HealthStatusHealthy, health.Status)
+}
+
 func TestPVCHealth(t *testing.T) {
 	assertAppHealth(t, "./testdata/pvc-bound.yaml", HealthStatusHealthy)
 	assertAppHealth(t, "./testdata/pvc-pending.yaml", HealthStatusProgressing)
 }
 
+func TestResourceQuotaHealth(t *testing.T) {
+	assertAppHealth(t, "./testdata/resourcequota-healthy.yaml", HealthStatusHealthy)
+
+	health := getHealthStatus("./testdata/resourcequota-exhausted.yaml", t)
+	assert.Equal(t, HealthStatusDegraded, health.Status)
+	assert.Equal(t, "Quota exhausted: requests.cpu (used 4, hard 4)", health.Message)
+}
+
+func TestLimitRangeHealth(t *testing.T) {
+	assertAppHealth(t, "./testdata/limitrange.yaml", HealthStatusHealthy)
+
+	obj := loadObject(t, "./testdata/limitrange.yaml")
+	limits, _, _ := unstructured.NestedSlice(obj.Object, "spec", "limits")
+	require.NoError(t, unstructured.SetNestedField(limits[0].(map[string]interface{}), "50m", "max", "cpu"))
+	require.NoError(t, unstructured.SetNestedSlice(obj.Object, limits, "spec", "limits"))
+	health, err := GetResourceHealth(obj, nil)
+	require.NoError(t, err)
+	assert.Equal(t, HealthStatusDegraded, health.Status)
+	assert.Equal(t, "Container limit of cpu: min 100m is greater than max 50m", health.Message)
+}
+
 func TestServiceHealth(t *testing.T) {
 	assertAppHealth(t, "./testdata/svc-clusterip.yaml", HealthStatusHealthy)
 	assertAppHealth(t, "./testdata/svc-loadbalancer.yaml", HealthStatusHealthy)
@@ -65,6 +164,91 @@ func TestIngressHealth(t *testing.T) {
 	assertAppHealth(t, "./testdata/ingress.yaml", HealthStatusHealthy)
 	assertAppHealth(t, "./testdata/ingress-unassigned.yaml", HealthStatusProgressing)
 	assertAppHealth(t, "./testdata/ingress-nonemptylist.yaml", HealthStatusHealthy)
+	assertAppHealth(t, "./testdata/ingress-v1-unassigned.yaml", HealthStatusProgressing)
+	assertAppHealth(t, "./testdata/ingress-v1beta1.yaml", HealthStatusHealthy)
+}
+
+func TestIngressHealthWithTree(t *testing.T) {
+	svc := loadObject(t, "./testdata/svc-argocd-server.yaml")
+	metricsSvc := loadObject(t, "./testdata/svc-clusterip.yaml")
+
+	t.Run("BackendServiceMissing", func(t *testing.T) {
+		health, err := GetIngressHealthWithTree(loadObject(t, "./testdata/ingress-v1-unassigned.yaml"), []*unstructured.Unstructured{svc})
+		require.NoError(t, err)
+		assert.Equal(t, HealthStatusDegraded, health.Status)
+		assert.Contains(t, health.Message, "argocd-metrics")
+	})
+
+	t.Run("AwaitingAddress", func(t *testing.T) {
+		health, err := GetIngressHealthWithTree(loadObject(t, "./testdata/ingress-v1-unassigned.yaml"), []*unstructured.Unstructured{svc, metricsSvc})
+		require.NoError(t, err)
+		assert.Equal(t, HealthStatusProgressing, health.Status)
+	})
+
+	t.Run("BetaBackendServiceMissing", func(t *testing.T) {
+		health, err := GetIngressHealthWithTree(loadObject(t, "./testdata/ingress-v1beta1.yaml"), nil)
+		require.NoError(t, err)
+		assert.Equal(t, HealthStatusDegraded, health.Status)
+	})
+
+	t.Run("BetaHealthy", func(t *testing.T) {
+		health, err := GetIngressHealthWithTree(loadObject(t, "./testdata/ingress-v1beta1.yaml"), []*unstructured.Unstructured{svc})
+		require.NoError(t, err)
+		assert.Equal(t, HealthStatusHealthy, health.Status)
+	})
+}
+
+func TestImmutableConfigHealthWithTarget(t *testing.T) {
+	live := loadObject(t, "./testdata/configmap-immutable.yaml")
+
+	t.Run("PendingChange", func(t *testing.T) {
+		health, err := GetImmutableConfigHealthWithTarget(live, loadObject(t, "./testdata/configmap-immutable-pending-change.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, HealthStatusDegraded, health.Status)
+		assert.Equal(t, "ConfigMap app-settings is immutable and differs from the target: it must be deleted and recreated to be updated", health.Message)
+	})
+
+	t.Run("InSync", func(t *testing.T) {
+		target := live.DeepCopy()
+		target.SetResourceVersion("")
+		health, err := GetImmutableConfigHealthWithTarget(live, target)
+		require.NoError(t, err)
+		assert.Equal(t, HealthStatusHealthy, health.Status)
+	})
+
+	t.Run("Mutable", func(t *testing.T) {
+		mutable := live.DeepCopy()
+		unstructured.RemoveNestedField(mutable.Object, "immutable")
+		health, err := GetImmutableConfigHealthWithTarget(mutable, loadObject(t, "./testdata/configmap-immutable-pending-change.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, HealthStatusHealthy, health.Status)
+	})
+
+	t.Run("SecretStringData", func(t *testing.T) {
+		secret := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": "credentials"},
+			"immutable":  true,
+			"type":       "Opaque",
+			"data":       map[string]interface{}{"password": "c2VjcmV0"},
+		}}
+		target := secret.DeepCopy()
+		unstructured.RemoveNestedField(target.Object, "data")
+		unstructured.RemoveNestedField(target.Object, "type")
+		require.NoError(t, unstructured.SetNestedStringMap(target.Object, map[string]string{"password": "secret"}, "stringData"))
+		health, err := GetImmutableConfigHealthWithTarget(secret, target)
+		require.NoError(t, err)
+		assert.Equal(t, HealthStatusHealthy, health.Status)
+
+		require.NoError(t, unstructured.SetNestedStringMap(target.Object, map[string]string{"password": "changed"}, "stringData"))
+		health, err = GetImmutableConfigHealthWithTarget(secret, target)
+		require.NoError(t, err)
+		assert.Equal(t, HealthStatusDegraded, health.Status)
+	})
+
+	_, err := GetImmutableConfigHealthWithTarget(loadObject(t, "./testdata/svc-clusterip.yaml"), nil)
+	assert.Error(t, err)
 }
 
 func TestCRD(t *testing.T) {
@@ -87,9 +271,23 @@ func TestHPA(t *testing.T) {
 	assertAppHealth(t, "./testdata/hpa-v2beta1-healthy.yaml", HealthStatusHealthy)
 	assertAppHealth(t, "./testdata/hpa-v1-degraded.yaml", HealthStatusDegraded)
 	assertAppHealth(t, "./testdata/hpa-v1-healthy.yaml", HealthStatusHealthy)
+	assertAppHealth(t, "./testdata/hpa-v1-degraded-failed-metric.yaml", HealthStatusDegraded)
 	assertAppHealth(t, "./testdata/hpa-v1-healthy-toofew.yaml", HealthStatusHealthy)
 	assertAppHealth(t, "./testdata/hpa-v1-progressing.yaml", HealthStatusProgressing)
 	assertAppHealth(t, "./testdata/hpa-v1-progressing-with-no-annotations.yaml", HealthStatusProgressing)
+
+	health := getHealthStatus("./testdata/hpa-v2-degraded-external-metric.yaml", t)
+	assert.Equal(t, HealthStatusDegraded, health.Status)
+	assert.Equal(t, "ScalingActive: FailedGetExternalMetric: the HPA was unable to compute the replica count: unable to get external metric default/queue_depth/nil: no metrics returned from external metrics API (current replicas: 2, desired replicas: 2)", health.Message)
+
+	// all the conditions reporting that the HPA is unable to scale are listed
+	health = getHealthStatus("./testdata/hpa-v1-degraded.yaml", t)
+	assert.Equal(t, HealthStatusDegraded, health.Status)
+	assert.Equal(t, "AbleToScale: FailedGetScale: the HPA controller was unable to get the target's current scale; ScalingActive: FailedGetResourceMetric: the HPA was unable to compute the replica count: unable to get metrics for resource cpu: unable to fetch metrics from resource metrics API: the server is currently unable to handle the request (get pods.metrics.k8s.io) (current replicas: 1, desired replicas: 0)", health.Message)
+
+	health = getHealthStatus("./testdata/hpa-v2-healthy-limited.yaml", t)
+	assert.Equal(t, HealthStatusHealthy, health.Status)
+	assert.Equal(t, "scaling is limited: the desired replica count is more than the maximum replica count (current replicas: 2, desired replicas: 2)", health.Message)
 }
 
 func TestPod(t *testing.T) {
@@ -104,6 +302,12 @@ func TestPod(t *testing.T) {
 	assertAppHealth(t, "./testdata/pod-failed.yaml", HealthStatusDegraded)
 	assertAppHealth(t, "./testdata/pod-succeeded.yaml", HealthStatusHealthy)
 	assertAppHealth(t, "./testdata/pod-deletion.yaml", HealthStatusProgressing)
+	assertAppHealth(t, "./testdata/pod-running-partially-ready.yaml", HealthStatusProgressing)
+	assertAppHealth(t, "./testdata/pod-job-succeeded.yaml", HealthStatusHealthy)
+
+	health := getHealthStatus("./testdata/pod-job-failed.yaml", t)
+	assert.Equal(t, HealthStatusDegraded, health.Status)
+	assert.Equal(t, `container "migrate" failed with exit code 137 (reason: Error)`, health.Message)
 }
 
 func TestApplication(t *testing.T) {
@@ -111,6 +315,158 @@ func TestApplication(t *testing.T) {
 	assert.Nil(t, getHealthStatus("./testdata/application-degraded.yaml", t))
 }
 
+func TestVolumeSnapshotHealth(t *testing.T) {
+	assertAppHealth(t, "./testdata/volumesnapshot-ready.yaml", HealthStatusHealthy)
+	assertAppHealth(t, "./testdata/volumesnapshot-pending.yaml", HealthStatusProgressing)
+
+	health := getHealthStatus("./testdata/volumesnapshot-failed.yaml", t)
+	assert.Equal(t, HealthStatusDegraded, health.Status)
+	assert.Equal(t, "Snapshot failed: Failed to check and update snapshot content: failed to take snapshot of the volume pvc-8d6f7c1a: rpc error: code = Internal desc = volume not found", health.Message)
+}
+
+func TestVelero(t *testing.T) {
+	assertAppHealth(t, "./testdata/velero-backup-completed.yaml", HealthStatusHealthy)
+	assertAppHealth(t, "./testdata/velero-backup-inprogress.yaml", HealthStatusProgressing)
+	assertAppHealth(t, "./testdata/velero-restore-failedvalidation.yaml", HealthStatusDegraded)
+
+	health := getHealthStatus("./testdata/velero-backup-failed.yaml", t)
+	assert.Equal(t, HealthStatusDegraded, health.Status)
+	assert.Equal(t, "Backup is in phase Failed: error getting backup storage location: BackupStorageLocation.velero.io \"default\" not found", health.Message)
+}
+
+func TestRegisterStatusHealth(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Certificate"}
+	require.NoError(t, RegisterStatusHealth(gvk, ".status.phase", map[string]HealthStatusCode{
+		"Issued":  HealthStatusHealthy,
+		"Pending": HealthStatusProgressing,
+	}, "{.status.message}"))
+	defer UnregisterStatusHealth(gvk)
+
+	newCert := func(status map[string]interface{}) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{"status": status}}
+		obj.SetGroupVersionKind(gvk)
+		obj.SetName("my-cert")
+		return obj
+	}
+
+	health, err := GetResourceHealth(newCert(map[string]interface{}{"phase": "Issued", "message": "certificate is valid"}), nil)
+	require.NoError(t, err)
+	assert.Equal(t, &HealthStatus{Status: HealthStatusHealthy, Message: "certificate is valid"}, health)
+
+	health, err = GetResourceHealth(newCert(map[string]interface{}{"phase": "Pending"}), nil)
+	require.NoError(t, err)
+	assert.Equal(t, HealthStatusProgressing, health.Status)
+
+	health, err = GetResourceHealth(newCert(map[string]interface{}{"phase": "Revoked"}), nil)
+	require.NoError(t, err)
+	assert.Equal(t, &HealthStatus{Status: HealthStatusUnknown, Message: "Unknown status: Revoked"}, health)
+
+	health, err = GetResourceHealth(newCert(nil), nil)
+	require.NoError(t, err)
+	assert.Equal(t, HealthStatusProgressing, health.Status)
+
+	// other versions of the kind are not affected
+	assert.Nil(t, GetHealthCheckFunc(schema.GroupVersionKind{Group: "example.com", Version: "v2", Kind: "Certificate"}))
+
+	assert.Error(t, RegisterStatusHealth(gvk, "{.status[", nil, ""))
+}
+
+func TestFalseConditionsMessage(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Database"}
+	require.NoError(t, RegisterStatusHealth(gvk, ".status.phase", map[string]HealthStatusCode{"Failed": HealthStatusDegraded}, ""))
+	defer UnregisterStatusHealth(gvk)
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"status": map[string]interface{}{
+		"phase": "Failed",
+		"conditions": []interface{}{
+			map[string]interface{}{"type": "Ready", "status": "False", "reason": "DependenciesNotReady", "lastTransitionTime": "2024-01-01T10:00:00Z"},
+			map[string]interface{}{"type": "Synced", "status": "True", "reason": "ReconcileSuccess", "lastTransitionTime": "2024-01-01T12:00:00Z"},
+			map[string]interface{}{"type": "StorageReady", "status": "False", "reason": "ProvisioningFailed", "message": "quota exceeded", "lastTransitionTime": "2024-01-01T11:00:00Z"},
+			map[string]interface{}{"type": "BackupReady", "status": "False"},
+		},
+	}}}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetName("my-db")
+
+	health, err := GetResourceHealth(obj, nil)
+	require.NoError(t, err)
+	assert.Equal(t, HealthStatusDegraded, health.Status)
+	assert.Equal(t, "StorageReady: ProvisioningFailed: quota exceeded; Ready: DependenciesNotReady; BackupReady", health.Message)
+
+	assert.Empty(t, falseConditionsMessage(nil))
+}
+
+func TestNode(t *testing.T) {
+	assertAppHealth(t, "./testdata/node-ready.yaml", HealthStatusHealthy)
+	assertAppHealth(t, "./testdata/node-cordoned.yaml", HealthStatusSuspended)
+
+	health := getHealthStatus("./testdata/node-notready.yaml", t)
+	assert.Equal(t, HealthStatusDegraded, health.Status)
+	assert.Equal(t, "Node is not ready: KubeletNotReady: container runtime network not ready: NetworkReady=false reason:NetworkPluginNotReady message:Network plugin returns error: cni plugin not initialized", health.Message)
+}
+
+func TestMachineConfigPool(t *testing.T) {
+	health := getHealthStatus("./testdata/machineconfigpool-degraded.yaml", t)
+	assert.Equal(t, HealthStatusDegraded, health.Status)
+	assert.Equal(t, `NodeDegraded: 1 nodes are reporting degraded status on sync: Node worker-2 is reporting: "failed to drain node: worker-2 after 1 hour"; Degraded`, health.Message)
+}
+
+func TestTektonRun(t *testing.T) {
+	health := getHealthStatus("./testdata/tekton-pipelinerun-failed.yaml", t)
+	assert.Equal(t, HealthStatusDegraded, health.Status)
+	assert.Equal(t, "PipelineRun failed: Succeeded: Failed: Tasks Completed: 2 (Failed: 1, Cancelled 0), Skipped: 1", health.Message)
+
+	run := &unstructured.Unstructured{Object: map[string]interface{}{"status": map[string]interface{}{
+		"conditions": []interface{}{
+			map[string]interface{}{"type": "Succeeded", "status": "False", "reason": "Failed", "lastTransitionTime": "2024-05-14T09:15:41Z"},
+			map[string]interface{}{"type": "ResultsVerified", "status": "False", "reason": "VerificationFailed", "message": "signature mismatch", "lastTransitionTime": "2024-05-14T09:15:42Z"},
+		},
+	}}}
+	run.SetGroupVersionKind(schema.GroupVersionKind{Group: "tekton.dev", Version: "v1", Kind: "TaskRun"})
+	health, err := GetResourceHealth(run, nil)
+	require.NoError(t, err)
+	assert.Equal(t, HealthStatusDegraded, health.Status)
+	assert.Equal(t, "TaskRun failed: ResultsVerified: VerificationFailed: signature mismatch; Succeeded: Failed", health.Message)
+
+	health = getHealthStatus("./testdata/tekton-taskrun-running.yaml", t)
+	assert.Equal(t, HealthStatusProgressing, health.Status)
+	assert.Equal(t, "TaskRun is Running since 2024-05-14T09:12:04Z", health.Message)
+
+	health = getHealthStatus("./testdata/tekton-taskrun-succeeded.yaml", t)
+	assert.Equal(t, HealthStatusHealthy, health.Status)
+	assert.Equal(t, "TaskRun succeeded at 2024-05-14T09:14:22Z", health.Message)
+}
+
+func TestAggregateHealth(t *testing.T) {
+	healthy := &HealthStatus{Status: HealthStatusHealthy}
+	suspended := &HealthStatus{Status: HealthStatusSuspended, Message: "suspended"}
+	missing := &HealthStatus{Status: HealthStatusMissing, Message: "missing"}
+	progressing := &HealthStatus{Status: HealthStatusProgressing, Message: "progressing"}
+	degraded := &HealthStatus{Status: HealthStatusDegraded, Message: "degraded"}
+
+	assert.Equal(t, &HealthStatus{Status: HealthStatusHealthy}, AggregateHealth(nil))
+	assert.Equal(t, &HealthStatus{Status: HealthStatusHealthy}, AggregateHealth([]*HealthStatus{healthy, nil}))
+	assert.Equal(t, degraded, AggregateHealth([]*HealthStatus{healthy, progressing, degraded, suspended}))
+	assert.Equal(t, progressing, AggregateHealth([]*HealthStatus{suspended, progressing, healthy, missing}))
+	assert.Equal(t, suspended, AggregateHealth([]*HealthStatus{healthy, suspended, missing}))
+	assert.Equal(t, missing, AggregateHealth([]*HealthStatus{missing, healthy, suspended}))
+
+	// the message of the first status with the worst code is used
+	assert.Equal(t, "first", AggregateHealth([]*HealthStatus{
+		{Status: HealthStatusDegraded, Message: "first"},
+		{Status: HealthStatusDegraded, Message: "second"},
+	}).Message)
+
+	// the precedence is configurable
+	precedence := map[HealthStatusCode]int{
+		HealthStatusHealthy:     0,
+		HealthStatusProgressing: 1,
+		HealthStatusDegraded:    2,
+		HealthStatusMissing:     3,
+	}
+	assert.Equal(t, missing, AggregateHealthWithPrecedence([]*HealthStatus{degraded, missing, progressing}, precedence))
+}
+
 func TestAPIService(t *testing.T) {
 	assertAppHealth(t, "./testdata/apiservice-v1-true.yaml", HealthStatusHealthy)
 	assertAppHealth(t, "./testdata/apiservice-v1-false.yaml", HealthStatusProgressing)
@@ -167,3 +523,59 @@ func TestGetArgoWorkflowHealth(t *testing.T) {
 	assert.Equal(t, "", health.Message)
 
 }
+
+func TestArgoWorkflowHealth(t *testing.T) {
+	health := getHealthStatus("./testdata/workflow-failed.yaml", t)
+	require.NotNil(t, health)
+	assert.Equal(t, HealthStatusDegraded, health.Status)
+	assert.Equal(t, "child 'hello-world-x7k2p' failed", health.Message)
+
+	// applications share the group of workflows but are not checked as workflows
+	assert.Nil(t, GetHealthCheckFunc(schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Application"}))
+}
+
+func TestProgressingDeadline(t *testing.T) {
+	obj := loadObject(t, "./testdata/deployment-progressing.yaml")
+	deploymentKind := schema.GroupKind{Group: "apps", Kind: "Deployment"}
+
+	// exceeded deadline taken from the provided start time
+	health, err := GetResourceHealthWithProgressingDeadline(obj, nil, ProgressingDeadlines{Default: time.Minute}, time.Now().Add(-2*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, HealthStatusDegraded, health.Status)
+	assert.Contains(t, health.Message, "Progressing for longer than the deadline of 1m0s")
+
+	// exceeded deadline taken from the Progressing condition
+	health, err = GetResourceHealthWithProgressingDeadline(obj, nil, ProgressingDeadlines{Kinds: map[schema.GroupKind]time.Duration{deploymentKind: time.Hour}}, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, HealthStatusDegraded, health.Status)
+
+	// within the deadline
+	health, err = GetResourceHealthWithProgressingDeadline(obj, nil, ProgressingDeadlines{Default: time.Hour}, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, HealthStatusProgressing, health.Status)
+
+	// a new rollout updates the Progressing condition but keeps its lastTransitionTime
+	rollout := obj.DeepCopy()
+	conditions, _, err := unstructured.NestedSlice(rollout.Object, "status", "conditions")
+	require.NoError(t, err)
+	for _, item := range conditions {
+		condition := item.(map[string]interface{})
+		if condition["type"] == "Progressing" {
+			condition["lastUpdateTime"] = time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+		}
+	}
+	require.NoError(t, unstructured.SetNestedSlice(rollout.Object, conditions, "status", "conditions"))
+	health, err = GetResourceHealthWithProgressingDeadline(rollout, nil, ProgressingDeadlines{Default: time.Hour}, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, HealthStatusProgressing, health.Status)
+
+	// no deadline for the kind
+	health, err = GetResourceHealthWithProgressingDeadline(obj, nil, ProgressingDeadlines{Default: time.Minute, Kinds: map[schema.GroupKind]time.Duration{deploymentKind: 0}}, time.Now().Add(-2*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, HealthStatusProgressing, health.Status)
+
+	// healthy resources are not affected
+	health, err = GetResourceHealthWithProgressingDeadline(loadObject(t, "../utils/kube/testdata/nginx.yaml"), nil, ProgressingDeadlines{Default: time.Minute}, time.Now().Add(-2*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, HealthStatusHealthy, health.Status)
+}
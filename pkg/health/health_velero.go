@@ -0,0 +1,56 @@
+package health
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type veleroPhase string
+
+// Backup and Restore phases
+// See: https://github.com/vmware-tanzu/velero/blob/main/pkg/apis/velero/v1/backup_types.go
+const (
+	veleroPhaseNew                        veleroPhase = "New"
+	veleroPhaseFailedValidation           veleroPhase = "FailedValidation"
+	veleroPhaseInProgress                 veleroPhase = "InProgress"
+	veleroPhaseWaitingForPluginOperations veleroPhase = "WaitingForPluginOperations"
+	veleroPhaseFinalizing                 veleroPhase = "Finalizing"
+	veleroPhaseCompleted                  veleroPhase = "Completed"
+	veleroPhasePartiallyFailed            veleroPhase = "PartiallyFailed"
+	veleroPhaseFailed                     veleroPhase = "Failed"
+)
+
+// An agnostic Velero Backup/Restore object only considers the phase, failure reason and validation errors.
+type veleroOperation struct {
+	Status struct {
+		Phase            veleroPhase `json:"phase,omitempty"`
+		FailureReason    string      `json:"failureReason,omitempty"`
+		ValidationErrors []string    `json:"validationErrors,omitempty"`
+	} `json:"status,omitempty"`
+}
+
+func getVeleroHealth(obj *unstructured.Unstructured) (*HealthStatus, error) {
+	var op veleroOperation
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &op)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert unstructured %s to typed: %v", obj.GetKind(), err)
+	}
+	switch op.Status.Phase {
+	case "", veleroPhaseNew, veleroPhaseInProgress, veleroPhaseWaitingForPluginOperations, veleroPhaseFinalizing:
+		return &HealthStatus{Status: HealthStatusProgressing, Message: fmt.Sprintf("%s is in phase %s", obj.GetKind(), op.Status.Phase)}, nil
+	case veleroPhaseCompleted:
+		return &HealthStatus{Status: HealthStatusHealthy, Message: fmt.Sprintf("%s completed", obj.GetKind())}, nil
+	case veleroPhaseFailedValidation:
+		return &HealthStatus{Status: HealthStatusDegraded, Message: fmt.Sprintf("%s failed validation: %s", obj.GetKind(), strings.Join(op.Status.ValidationErrors, "; "))}, nil
+	case veleroPhaseFailed, veleroPhasePartiallyFailed:
+		message := fmt.Sprintf("%s is in phase %s", obj.GetKind(), op.Status.Phase)
+		if op.Status.FailureReason != "" {
+			message = fmt.Sprintf("%s: %s", message, op.Status.FailureReason)
+		}
+		return &HealthStatus{Status: HealthStatusDegraded, Message: message}, nil
+	}
+	return &HealthStatus{Status: HealthStatusUnknown, Message: fmt.Sprintf("%s is in unknown phase %s", obj.GetKind(), op.Status.Phase)}, nil
+}
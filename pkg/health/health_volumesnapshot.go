@@ -0,0 +1,41 @@
+package health
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// An agnostic VolumeSnapshot object only considers whether the snapshot is ready to use and its error.
+// See: https://github.com/kubernetes-csi/external-snapshotter/blob/master/client/apis/volumesnapshot/v1/types.go
+type volumeSnapshot struct {
+	Status *struct {
+		ReadyToUse *bool `json:"readyToUse,omitempty"`
+		Error      *struct {
+			Message *string `json:"message,omitempty"`
+		} `json:"error,omitempty"`
+	} `json:"status,omitempty"`
+}
+
+func getVolumeSnapshotHealth(obj *unstructured.Unstructured) (*HealthStatus, error) {
+	var snapshot volumeSnapshot
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert unstructured VolumeSnapshot to typed: %v", err)
+	}
+	if snapshot.Status == nil {
+		return &HealthStatus{Status: HealthStatusProgressing, Message: "Waiting for the snapshot to be created"}, nil
+	}
+	if snapshot.Status.ReadyToUse != nil && *snapshot.Status.ReadyToUse {
+		return &HealthStatus{Status: HealthStatusHealthy, Message: "Snapshot is ready to use"}, nil
+	}
+	if snapshot.Status.Error != nil {
+		message := "Snapshot failed"
+		if snapshot.Status.Error.Message != nil && *snapshot.Status.Error.Message != "" {
+			message = fmt.Sprintf("%s: %s", message, *snapshot.Status.Error.Message)
+		}
+		return &HealthStatus{Status: HealthStatusDegraded, Message: message}, nil
+	}
+	return &HealthStatus{Status: HealthStatusProgressing, Message: "Waiting for the snapshot to be ready to use"}, nil
+}
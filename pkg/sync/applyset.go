@@ -0,0 +1,264 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/argoproj/gitops-engine/pkg/sync/common"
+	"github.com/argoproj/gitops-engine/pkg/utils/kube"
+)
+
+// applySetTooling is the value of the tooling annotation of the ApplySets managed by the engine
+const applySetTooling = "gitops-engine/v1"
+
+// ApplySetID returns the ID of the ApplySet with the given parent: the unpadded URL-safe base64 encoding of the SHA-256
+// hash of <name>.<namespace>.<kind>.<group> of the parent, prefixed by "applyset-" and suffixed by "-v1"
+func ApplySetID(parent *unstructured.Unstructured) string {
+	gvk := parent.GroupVersionKind()
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s.%s.%s.%s", parent.GetName(), parent.GetNamespace(), gvk.Kind, gvk.Group)))
+	return fmt.Sprintf("applyset-%s-v1", base64.RawURLEncoding.EncodeToString(hash[:]))
+}
+
+// WithApplySet tracks the managed resources using the ApplySet with the given parent object, usually a Secret or a
+// ConfigMap. The target resources are labeled as members of the ApplySet and the parent is created or updated before
+// any resource is applied. The live members of the ApplySet that are not target resources are pruned even if they
+// are missing from the reconciliation result.
+func WithApplySet(parent *unstructured.Unstructured) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.applySetParent = parent
+	}
+}
+
+// labelApplySetMembers labels the target resources as members of the ApplySet. The targets are copied before they are
+// labeled so the objects passed by the caller are not modified.
+func (sc *syncContext) labelApplySetMembers() {
+	id := ApplySetID(sc.applySetParent)
+	for key, res := range sc.resources {
+		if res.Target == nil || sc.isHook(res.Target) || key == kube.GetResourceKey(sc.applySetParent) {
+			continue
+		}
+		if res.Target.GetLabels()[common.LabelApplySetPartOf] == id {
+			continue
+		}
+		res.Target = res.Target.DeepCopy()
+		labels := res.Target.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[common.LabelApplySetPartOf] = id
+		res.Target.SetLabels(labels)
+		sc.resources[key] = res
+	}
+}
+
+// prepareApplySet adds the live members of the ApplySet to the resources and updates the parent so it lists the group
+// kinds and namespaces of both the live and the target members
+func (sc *syncContext) prepareApplySet() error {
+	parentIf, err := sc.getApplySetParentIf()
+	if err != nil {
+		return err
+	}
+	live, err := sc.getApplySetParent(parentIf)
+	if err != nil {
+		return err
+	}
+	groupKinds, namespaces := sc.getApplySetTargetMembership()
+	if live != nil {
+		for _, gk := range splitApplySetAnnotation(live, common.AnnotationApplySetGroupKinds) {
+			groupKinds[schema.ParseGroupKind(gk)] = true
+		}
+		for _, ns := range splitApplySetAnnotation(live, common.AnnotationApplySetAdditionalNamespaces) {
+			namespaces[ns] = true
+		}
+	}
+
+	id := ApplySetID(sc.applySetParent)
+	for gk := range groupKinds {
+		members, err := sc.listApplySetMembers(id, gk, namespaces)
+		if err != nil {
+			return fmt.Errorf("failed to list members of kind %s: %w", gk, err)
+		}
+		for _, member := range members {
+			key := kube.GetResourceKey(member)
+			res := sc.resources[key]
+			if res.Live == nil {
+				res.Live = member
+				sc.resources[key] = res
+			}
+		}
+	}
+	if sc.dryRun {
+		return nil
+	}
+	return sc.updateApplySetParent(parentIf, live, groupKinds, namespaces)
+}
+
+// completeApplySet removes the group kinds and namespaces that have no target members from the parent once the
+// members that are no longer targets have been pruned. A parent that still lists them is valid, so errors are only
+// logged.
+func (sc *syncContext) completeApplySet() {
+	if sc.applySetParent == nil || sc.dryRun {
+		return
+	}
+	parentIf, err := sc.getApplySetParentIf()
+	if err == nil {
+		var live *unstructured.Unstructured
+		if live, err = sc.getApplySetParent(parentIf); err == nil {
+			groupKinds, namespaces := sc.getApplySetTargetMembership()
+			err = sc.updateApplySetParent(parentIf, live, groupKinds, namespaces)
+		}
+	}
+	if err != nil {
+		sc.log.Error(err, "Failed to remove the group kinds and namespaces without members from the ApplySet parent")
+	}
+}
+
+// getApplySetTargetMembership returns the group kinds and namespaces of the target members of the ApplySet
+func (sc *syncContext) getApplySetTargetMembership() (map[schema.GroupKind]bool, map[string]bool) {
+	groupKinds := map[schema.GroupKind]bool{}
+	namespaces := map[string]bool{sc.applySetParent.GetNamespace(): true}
+	for key, res := range sc.resources {
+		if res.Target == nil || sc.isHook(res.Target) || key == kube.GetResourceKey(sc.applySetParent) {
+			continue
+		}
+		groupKinds[key.GroupKind()] = true
+		namespaces[res.Target.GetNamespace()] = true
+	}
+	return groupKinds, namespaces
+}
+
+func (sc *syncContext) getApplySetParentIf() (dynamic.ResourceInterface, error) {
+	gvk := sc.applySetParent.GroupVersionKind()
+	apiResource, err := kube.ServerResourceForGroupVersionKind(sc.disco, gvk, "get")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the resource of the ApplySet parent: %w", err)
+	}
+	res := kube.ToGroupVersionResource(gvk.GroupVersion().String(), apiResource)
+	return kube.ToResourceInterface(sc.dynamicIf, apiResource, res, sc.applySetParent.GetNamespace()), nil
+}
+
+// getApplySetParent returns the live parent of the ApplySet or nil if it does not exist yet
+func (sc *syncContext) getApplySetParent(parentIf dynamic.ResourceInterface) (*unstructured.Unstructured, error) {
+	live, err := parentIf.Get(sc.getContext(), sc.applySetParent.GetName(), metav1.GetOptions{})
+	if apierr.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the ApplySet parent: %w", err)
+	}
+	if id := live.GetLabels()[common.LabelApplySetID]; id != "" && id != ApplySetID(sc.applySetParent) {
+		return nil, fmt.Errorf("%s/%s is the parent of another ApplySet: %s", live.GetKind(), live.GetName(), id)
+	}
+	return live, nil
+}
+
+// listApplySetMembers returns the live resources of the given group kind that are members of the ApplySet. Namespaced
+// resources are only listed in the given namespaces.
+func (sc *syncContext) listApplySetMembers(id string, gk schema.GroupKind, namespaces map[string]bool) ([]*unstructured.Unstructured, error) {
+	gvk, err := kube.PreferredVersion(sc.disco, gk.WithVersion(""))
+	if apierr.IsNotFound(err) {
+		// the type of the members is no longer served, e.g. because the CRD was deleted
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	apiResource, err := kube.ServerResourceForGroupVersionKind(sc.disco, gvk, "list")
+	if err != nil {
+		return nil, err
+	}
+	res := kube.ToGroupVersionResource(gvk.GroupVersion().String(), apiResource)
+	listNamespaces := []string{""}
+	if apiResource.Namespaced {
+		listNamespaces = nil
+		for ns := range namespaces {
+			if ns != "" {
+				listNamespaces = append(listNamespaces, ns)
+			}
+		}
+	}
+	var members []*unstructured.Unstructured
+	for _, ns := range listNamespaces {
+		list, err := kube.ToResourceInterface(sc.dynamicIf, apiResource, res, ns).List(sc.getContext(), metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("%s=%s", common.LabelApplySetPartOf, id),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			members = append(members, &list.Items[i])
+		}
+	}
+	return members, nil
+}
+
+// updateApplySetParent creates or updates the parent so it identifies the ApplySet and lists the given group kinds and
+// namespaces of the members
+func (sc *syncContext) updateApplySetParent(parentIf dynamic.ResourceInterface, live *unstructured.Unstructured, groupKinds map[schema.GroupKind]bool, namespaces map[string]bool) error {
+	var obj *unstructured.Unstructured
+	if live != nil {
+		obj = live.DeepCopy()
+	} else {
+		obj = sc.applySetParent.DeepCopy()
+	}
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[common.LabelApplySetID] = ApplySetID(sc.applySetParent)
+	obj.SetLabels(labels)
+
+	var gks, additionalNamespaces []string
+	for gk := range groupKinds {
+		gks = append(gks, gk.String())
+	}
+	for ns := range namespaces {
+		if ns != "" && ns != sc.applySetParent.GetNamespace() {
+			additionalNamespaces = append(additionalNamespaces, ns)
+		}
+	}
+	sort.Strings(gks)
+	sort.Strings(additionalNamespaces)
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[common.AnnotationApplySetTooling] = applySetTooling
+	annotations[common.AnnotationApplySetGroupKinds] = strings.Join(gks, ",")
+	if len(additionalNamespaces) > 0 {
+		annotations[common.AnnotationApplySetAdditionalNamespaces] = strings.Join(additionalNamespaces, ",")
+	} else {
+		delete(annotations, common.AnnotationApplySetAdditionalNamespaces)
+	}
+	obj.SetAnnotations(annotations)
+
+	var err error
+	if live == nil {
+		_, err = parentIf.Create(sc.getContext(), obj, metav1.CreateOptions{})
+	} else {
+		_, err = parentIf.Update(sc.getContext(), obj, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update the ApplySet parent: %w", err)
+	}
+	return nil
+}
+
+func splitApplySetAnnotation(obj *unstructured.Unstructured, annotation string) []string {
+	var items []string
+	for _, item := range strings.Split(obj.GetAnnotations()[annotation], ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -17,6 +17,28 @@ const (
 	// AnnotationKeyHookDeletePolicy is the policy of deleting a hook
 	AnnotationKeyHookDeletePolicy = "argocd.argoproj.io/hook-delete-policy"
 	AnnotationDeletionApproved    = "argocd.argoproj.io/deletion-approved"
+	// AnnotationHookRunPolicy is the policy that decides whether a hook runs on every sync or only on changes
+	AnnotationHookRunPolicy = "gitops-engine.io/hook-run-policy"
+	// AnnotationDeployID contains the identifier of the sync operation that applied the resource
+	AnnotationDeployID = "gitops-engine.io/deploy-id"
+	// AnnotationManifestHash contains the hash of the target manifest that was most recently applied to the resource
+	AnnotationManifestHash = "gitops-engine.io/manifest-hash"
+	// AnnotationHealthTimeout overrides the sync wave timeout for the resource, e.g. "5m"
+	AnnotationHealthTimeout = "gitops-engine.io/health-timeout"
+	// AnnotationPendingPrune contains the time a resource was marked for pruning by a sync with soft prune enabled
+	AnnotationPendingPrune = "gitops-engine.io/pending-prune"
+
+	// LabelApplySetID identifies the parent object of an ApplySet
+	LabelApplySetID = "applyset.kubernetes.io/id"
+	// LabelApplySetPartOf contains the ID of the ApplySet a resource is a member of
+	LabelApplySetPartOf = "applyset.kubernetes.io/part-of"
+	// AnnotationApplySetTooling contains the name and version of the tool that manages an ApplySet
+	AnnotationApplySetTooling = "applyset.kubernetes.io/tooling"
+	// AnnotationApplySetGroupKinds is a comma-separated list of the group kinds of the members of an ApplySet
+	AnnotationApplySetGroupKinds = "applyset.kubernetes.io/contains-group-kinds"
+	// AnnotationApplySetAdditionalNamespaces is a comma-separated list of the namespaces of the members of an ApplySet
+	// other than the namespace of the parent
+	AnnotationApplySetAdditionalNamespaces = "applyset.kubernetes.io/additional-namespaces"
 
 	// Sync option that disables dry run in resource is missing in the cluster
 	SyncOptionSkipDryRunOnMissingResource = "SkipDryRunOnMissingResource=true"
@@ -97,6 +119,9 @@ const (
 	ResultCodeSyncFailed   ResultCode = "SyncFailed"
 	ResultCodePruned       ResultCode = "Pruned"
 	ResultCodePruneSkipped ResultCode = "PruneSkipped"
+	// ResultCodeHookSkipped is reported for hooks that are not run by the operation because of their run policy, e.g.
+	// hooks with the OnChange run policy if no resources are changed
+	ResultCodeHookSkipped ResultCode = "HookSkipped"
 )
 
 type HookType string
@@ -134,6 +159,22 @@ func NewHookDeletePolicy(p string) (HookDeletePolicy, bool) {
 			p == string(HookDeletePolicyBeforeHookCreation)
 }
 
+type HookRunPolicy string
+
+const (
+	// HookRunPolicyAlways runs the hook on every sync
+	HookRunPolicyAlways HookRunPolicy = "Always"
+	// HookRunPolicyOnChange runs the hook only if at least one non-hook resource is changed by the sync. Hooks that
+	// are not run are reported as succeeded with the ResultCodeHookSkipped status.
+	HookRunPolicyOnChange HookRunPolicy = "OnChange"
+)
+
+func NewHookRunPolicy(p string) (HookRunPolicy, bool) {
+	return HookRunPolicy(p),
+		p == string(HookRunPolicyAlways) ||
+			p == string(HookRunPolicyOnChange)
+}
+
 type ResourceSyncResult struct {
 	// holds associated resource key
 	ResourceKey kube.ResourceKey
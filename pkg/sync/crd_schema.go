@@ -0,0 +1,226 @@
+package sync
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/argoproj/gitops-engine/pkg/sync/common"
+	"github.com/argoproj/gitops-engine/pkg/utils/kube"
+)
+
+// maxReportedSchemaViolations is the maximum number of invalidated custom resources listed in a message
+const maxReportedSchemaViolations = 10
+
+// CustomResourceLister returns the existing custom resources of the given group kind, e.g. from the cluster cache
+type CustomResourceLister func(gk schema.GroupKind) ([]*unstructured.Unstructured, error)
+
+// WithCRDSchemaChangeCheck verifies before anything is applied that the updated CRDs of the target resources do not
+// invalidate the existing custom resources of the CRDs, e.g. because a field changed its type, a required field was
+// added or a version stored by existing resources is no longer served. The existing custom resources are returned
+// by the given lister or, if it is nil, taken from the live resources of the reconciliation result. Invalidated
+// custom resources are logged as a warning. If block is true then the sync operation fails instead and the results
+// of the CRDs list the invalidated custom resources.
+func WithCRDSchemaChangeCheck(block bool, lister CustomResourceLister) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.crdSchemaCheck = true
+		ctx.crdSchemaCheckBlock = block
+		ctx.customResourceLister = lister
+	}
+}
+
+// checkCRDSchemaChanges verifies the schema changes of the CRDs of the given tasks and returns false if the operation
+// must be stopped
+func (sc *syncContext) checkCRDSchemaChanges(tasks syncTasks) bool {
+	ok := true
+	for _, task := range tasks {
+		if task.targetObj == nil || task.liveObj == nil || !kube.IsCRD(task.targetObj) {
+			continue
+		}
+		violations, err := sc.getCRDSchemaViolations(task.targetObj, task.liveObj)
+		if err != nil {
+			sc.setResourceResult(task, common.ResultCodeSyncFailed, common.OperationError, fmt.Sprintf("failed to verify the schema change: %v", err))
+			ok = false
+			continue
+		}
+		if len(violations) == 0 {
+			continue
+		}
+		if len(violations) > maxReportedSchemaViolations {
+			violations = append(violations[:maxReportedSchemaViolations], fmt.Sprintf("and %d more", len(violations)-maxReportedSchemaViolations))
+		}
+		message := fmt.Sprintf("schema change invalidates existing custom resources: %s", strings.Join(violations, "; "))
+		if !sc.crdSchemaCheckBlock {
+			sc.log.WithValues("crd", task.name()).Info("Warning: " + message)
+			continue
+		}
+		sc.setResourceResult(task, common.ResultCodeSyncFailed, common.OperationFailed, message)
+		ok = false
+	}
+	return ok
+}
+
+// getCRDSchemaViolations returns the existing custom resources of the given CRD that are invalid according to the
+// target schema, along with the reason, or nil if the versions of the CRD are unchanged
+func (sc *syncContext) getCRDSchemaViolations(target, live *unstructured.Unstructured) ([]string, error) {
+	targetVersions, _, _ := unstructured.NestedSlice(target.Object, "spec", "versions")
+	liveVersions, _, _ := unstructured.NestedSlice(live.Object, "spec", "versions")
+	if reflect.DeepEqual(targetVersions, liveVersions) {
+		return nil, nil
+	}
+	group, _, _ := unstructured.NestedString(target.Object, "spec", "group")
+	kind, _, _ := unstructured.NestedString(target.Object, "spec", "names", "kind")
+	gk := schema.GroupKind{Group: group, Kind: kind}
+
+	schemas := map[string]map[string]interface{}{}
+	for _, v := range targetVersions {
+		version, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if served, _, _ := unstructured.NestedBool(version, "served"); !served {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(version, "name")
+		openAPISchema, _, _ := unstructured.NestedMap(version, "schema", "openAPIV3Schema")
+		schemas[name] = openAPISchema
+	}
+
+	var resources []*unstructured.Unstructured
+	if sc.customResourceLister != nil {
+		var err error
+		if resources, err = sc.customResourceLister(gk); err != nil {
+			return nil, err
+		}
+	} else {
+		for key, res := range sc.resources {
+			if key.GroupKind() == gk && res.Live != nil {
+				resources = append(resources, res.Live)
+			}
+		}
+	}
+
+	var violations []string
+	for _, res := range resources {
+		name := res.GetName()
+		if res.GetNamespace() != "" {
+			name = res.GetNamespace() + "/" + name
+		}
+		version := res.GroupVersionKind().Version
+		openAPISchema, ok := schemas[version]
+		if !ok {
+			violations = append(violations, fmt.Sprintf("%s: version %s is no longer served", name, version))
+			continue
+		}
+		obj := map[string]interface{}{}
+		for k, v := range res.Object {
+			if k != "apiVersion" && k != "kind" && k != "metadata" {
+				obj[k] = v
+			}
+		}
+		errs := validateStructuralSchema(obj, openAPISchema, "")
+		sort.Strings(errs)
+		for _, err := range errs {
+			violations = append(violations, fmt.Sprintf("%s: %s", name, err))
+		}
+	}
+	sort.Strings(violations)
+	return violations, nil
+}
+
+// validateStructuralSchema returns the reasons why the given value is invalid according to the given structural
+// schema. Only the constraints that commonly change incompatibly are verified: types, required fields and enums.
+// Unknown fields are not reported since they are pruned by the API server.
+func validateStructuralSchema(value interface{}, s map[string]interface{}, path string) []string {
+	if len(s) == 0 || value == nil {
+		return nil
+	}
+	field := strings.TrimPrefix(path, ".")
+	if field == "" {
+		field = "<root>"
+	}
+	if intOrString, _, _ := unstructured.NestedBool(s, "x-kubernetes-int-or-string"); intOrString {
+		switch value.(type) {
+		case string, int64, int, float64:
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: Invalid type, expected integer or string", field)}
+	}
+	if typ, _, _ := unstructured.NestedString(s, "type"); typ != "" && !hasSchemaType(value, typ) {
+		return []string{fmt.Sprintf("%s: Invalid type, expected %s", field, typ)}
+	}
+	if enum, ok := s["enum"].([]interface{}); ok && len(enum) > 0 {
+		found := false
+		for _, allowed := range enum {
+			if reflect.DeepEqual(allowed, value) || fmt.Sprint(allowed) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return []string{fmt.Sprintf("%s: Unsupported value %v", field, value)}
+		}
+	}
+
+	var errs []string
+	switch val := value.(type) {
+	case map[string]interface{}:
+		required, _, _ := unstructured.NestedStringSlice(s, "required")
+		for _, name := range required {
+			if _, ok := val[name]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: Required value", strings.TrimPrefix(path+"."+name, ".")))
+			}
+		}
+		properties, _, _ := unstructured.NestedMap(s, "properties")
+		additional, _, _ := unstructured.NestedMap(s, "additionalProperties")
+		for name, item := range val {
+			if propSchema, ok := properties[name].(map[string]interface{}); ok {
+				errs = append(errs, validateStructuralSchema(item, propSchema, path+"."+name)...)
+			} else if additional != nil {
+				errs = append(errs, validateStructuralSchema(item, additional, path+"."+name)...)
+			}
+		}
+	case []interface{}:
+		items, _, _ := unstructured.NestedMap(s, "items")
+		for i, item := range val {
+			errs = append(errs, validateStructuralSchema(item, items, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return errs
+}
+
+func hasSchemaType(value interface{}, typ string) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "integer":
+		switch v := value.(type) {
+		case int64, int:
+			return true
+		case float64:
+			return v == float64(int64(v))
+		}
+		return false
+	case "number":
+		switch value.(type) {
+		case int64, int, float64:
+			return true
+		}
+		return false
+	}
+	return true
+}
@@ -30,6 +30,11 @@ Hooks are regular Kubernetes resources that have `argocd.argoproj.io/hook` annot
     annotations:
       argocd.argoproj.io/hook: PreSync
 
+Any resource, including custom resources, can be used as a hook. The hook completion is determined using the health
+assessment of the live hook resource (see the health package and WithHealthOverride): the hook is running while the
+resource is Progressing or Suspended, succeeded once it is Healthy, and failed if it is Degraded. Resources without
+a health check are considered successful as soon as they are created.
+
 The annotation value indicates the sync operation phase:
 
  - PreSync - executes prior to the apply of the manifests.
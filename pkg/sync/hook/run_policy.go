@@ -0,0 +1,15 @@
+package hook
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/argoproj/gitops-engine/pkg/sync/common"
+)
+
+// RunPolicy returns the run policy of the given hook, defaulting to Always if the annotation is missing or invalid
+func RunPolicy(obj *unstructured.Unstructured) common.HookRunPolicy {
+	if p, ok := common.NewHookRunPolicy(obj.GetAnnotations()[common.AnnotationHookRunPolicy]); ok {
+		return p
+	}
+	return common.HookRunPolicyAlways
+}
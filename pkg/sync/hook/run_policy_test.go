@@ -0,0 +1,17 @@
+package hook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/argoproj/gitops-engine/pkg/sync/common"
+	. "github.com/argoproj/gitops-engine/pkg/utils/testing"
+)
+
+func TestRunPolicy(t *testing.T) {
+	assert.Equal(t, common.HookRunPolicyAlways, RunPolicy(NewPod()))
+	assert.Equal(t, common.HookRunPolicyAlways, RunPolicy(Annotate(NewPod(), "gitops-engine.io/hook-run-policy", "garbage")))
+	assert.Equal(t, common.HookRunPolicyAlways, RunPolicy(Annotate(NewPod(), "gitops-engine.io/hook-run-policy", "Always")))
+	assert.Equal(t, common.HookRunPolicyOnChange, RunPolicy(Annotate(NewPod(), "gitops-engine.io/hook-run-policy", "OnChange")))
+}
@@ -2,6 +2,8 @@ package sync
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"sort"
@@ -10,16 +12,21 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	v1 "k8s.io/api/core/v1"
 	v1extensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	apierr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	authorizationv1client "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2/textlogger"
@@ -56,6 +63,32 @@ type SyncContext interface {
 	Sync()
 	// Returns current sync operation state and information about resources synchronized so far.
 	GetState() (common.OperationPhase, string, []common.ResourceSyncResult)
+	// Resume restores the state of a previously interrupted sync operation from the state store (see WithStateStore).
+	// The next Sync call re-evaluates the tasks that were running using the live state and continues from the first
+	// incomplete wave instead of starting the operation from the beginning.
+	Resume(operationID string) error
+	// Plan computes the tasks of the sync operation, including their waves, operations and diffs, without executing
+	// them. The returned plan can be serialized, e.g. to be approved externally.
+	Plan() (*SyncPlan, error)
+	// ExecutePlan starts the sync operation using the given plan. The operation is aborted if the tasks or the resource
+	// versions of the live resources differ from the plan. Subsequent steps are executed by calling Sync.
+	ExecutePlan(plan *SyncPlan) error
+}
+
+// SyncState holds the state of a sync operation that is persisted by a StateStore
+type SyncState struct {
+	Phase     common.OperationPhase
+	Message   string
+	StartedAt metav1.Time
+	Results   []common.ResourceSyncResult
+}
+
+// StateStore persists the state of sync operations, so that an operation interrupted by a process restart can be resumed
+type StateStore interface {
+	// Save stores the state of the given operation
+	Save(operationID string, state SyncState) error
+	// Load returns the stored state of the given operation or nil if no state is stored
+	Load(operationID string) (*SyncState, error)
 }
 
 // SyncOpt is a callback that update sync operation settings
@@ -116,6 +149,33 @@ func WithPrune(prune bool) SyncOpt {
 	}
 }
 
+// WithOptimisticLock specifies that resources are updated using the resource version of the live resource observed
+// when the sync tasks were computed, so an update fails with a conflict if the resource has been modified concurrently.
+// Conflicting updates are retried with the resource version of the current live resource, see WithConflictRetry.
+func WithOptimisticLock(optimisticLock bool) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.optimisticLock = optimisticLock
+	}
+}
+
+// WithConflictRetry sets the backoff of the retries of updates that fail with a conflict if optimistic locking is
+// enabled. Defaults to retry.DefaultRetry.
+func WithConflictRetry(backoff wait.Backoff) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.conflictRetry = backoff
+	}
+}
+
+// WithSoftPrune specifies that resources are marked for pruning instead of being deleted. The resources that would be
+// pruned are annotated with gitops-engine.io/pending-prune and the time they were marked, so they can be reviewed
+// before they are deleted by a subsequent sync without soft prune. Marked resources are excluded from subsequent syncs
+// with soft prune, i.e. they are neither reported in the results nor considered as changed resources.
+func WithSoftPrune(softPrune bool) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.softPrune = softPrune
+	}
+}
+
 // WithPruneConfirmed specifies if prune is confirmed for resources that require confirmation
 func WithPruneConfirmed(confirmed bool) SyncOpt {
 	return func(ctx *syncContext) {
@@ -147,11 +207,13 @@ func WithPruneLast(enabled bool) SyncOpt {
 	}
 }
 
-// WithResourceModificationChecker sets resource modification result
-func WithResourceModificationChecker(enabled bool, diffResults *diff.DiffResultList) SyncOpt {
+// WithResourceModificationChecker sets resource modification result. If enabled, only the modified resources are
+// applied. The results are used to determine whether the sync changes any resources, e.g. to skip hooks with the
+// OnChange run policy, even if the checker is not enabled, so the resources do not have to be compared again.
+func WithResourceModificationChecker(enabled bool, diffResults *diff.DiffArrayResults) SyncOpt {
 	return func(ctx *syncContext) {
 		ctx.applyOutOfSyncOnly = enabled
-		if enabled {
+		if diffResults != nil {
 			ctx.modificationResult = groupDiffResults(diffResults)
 		} else {
 			ctx.modificationResult = nil
@@ -176,6 +238,15 @@ func WithLogr(log logr.Logger) SyncOpt {
 	}
 }
 
+// WithContext sets the context of the sync operation. The context is passed to every request the operation sends to
+// the cluster, and waits of the operation, e.g. the retries of applying custom resources whose type is not served yet,
+// stop once the context is done. Defaults to context.Background().
+func WithContext(operationContext context.Context) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.operationContext = operationContext
+	}
+}
+
 // WithSyncWaveHook sets a callback that is invoked after application of every wave
 func WithSyncWaveHook(syncWaveHook common.SyncWaveHook) SyncOpt {
 	return func(ctx *syncContext) {
@@ -201,6 +272,258 @@ func WithServerSideApplyManager(manager string) SyncOpt {
 	}
 }
 
+// WithServerSideApplyManagerPerWave sets the field manager used to apply the resources of the given sync waves.
+// Resources of waves without a configured manager are applied using the manager set by WithServerSideApplyManager.
+func WithServerSideApplyManagerPerWave(managers map[int]string) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.serverSideApplyManagersPerWave = managers
+	}
+}
+
+// WithPreflightRBAC enables the permission check of all resources before the sync operation starts. Every verb required
+// by a task (e.g. create and patch for server-side apply, delete for forced syncs and hook delete policies) is verified
+// using SelfSubjectAccessReview in the namespace of the task and the operation fails without modifying any resources
+// if any permission is missing.
+func WithPreflightRBAC(enabled bool) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.preflightRBAC = enabled
+	}
+}
+
+// WithDeployID sets the identifier of the sync operation. If not empty, every applied resource is annotated
+// with the identifier so live resources can be correlated with the operation that produced them.
+func WithDeployID(deployID string) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.deployID = deployID
+	}
+}
+
+// WithCommonLabels adds the given labels to every applied resource, e.g. the team or cost center owning the resources.
+// Labels set by the target resource are kept unless overwrite is true. The labels are ignored when the sync engine
+// compares target and live resources.
+func WithCommonLabels(labels map[string]string, overwrite bool) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.commonLabels = labels
+		ctx.overwriteCommonLabels = overwrite
+	}
+}
+
+// WithCommonAnnotations adds the given annotations to every applied resource. Annotations set by the target resource
+// are kept unless overwrite is true. The annotations are ignored when the sync engine compares target and live
+// resources.
+func WithCommonAnnotations(annotations map[string]string, overwrite bool) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.commonAnnotations = annotations
+		ctx.overwriteCommonAnnotations = overwrite
+	}
+}
+
+// WithSkipUnchangedManifests enables skipping resources whose target manifest hash matches the hash stored in the
+// live resource annotation, provided that the live resource is healthy. Resources without a health check (e.g.
+// ConfigMaps) are considered healthy and are skipped as well. Skipped resources are not diffed. The hash annotation
+// is updated on every apply, see WithManifestHashIgnoreDifferences.
+func WithSkipUnchangedManifests(enabled bool) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.skipUnchangedManifests = enabled
+	}
+}
+
+// WithManifestHashIgnoreDifferences sets the ignore difference rules that are applied to the target manifests before
+// their hash is computed, see WithSkipUnchangedManifests. Changes of ignored fields then do not change the hash, so
+// they are skipped the same way they are ignored when diffing.
+func WithManifestHashIgnoreDifferences(rules ...diff.IgnoreDifference) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.manifestHashIgnoreDifferences = rules
+	}
+}
+
+// WithPruneFinalizerCheck enables reporting of resources whose deletion would be blocked when prunes are dry-run.
+// Finalizers of the pruned resources are always reported; if dryRunDelete is true then a server-side dry-run
+// deletion is performed as well and its failure is reported.
+func WithPruneFinalizerCheck(dryRunDelete bool) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.pruneFinalizerCheck = true
+		ctx.pruneDryRunDelete = dryRunDelete
+	}
+}
+
+// WithMaxConcurrentDeletes limits the number of prune and hook delete operations that are in flight at the same time,
+// independently of the apply operations. This protects the API server and dependent controllers from mass deletions.
+// The number of deletes is unbounded if maxConcurrentDeletes is zero or negative.
+func WithMaxConcurrentDeletes(maxConcurrentDeletes int) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.maxConcurrentDeletes = maxConcurrentDeletes
+	}
+}
+
+// WithPruneAllowedKinds restricts pruning to resources of the given kinds. Resources of other kinds that are missing
+// from the target state are not pruned and reported as skipped. All kinds are allowed if no kinds are given.
+func WithPruneAllowedKinds(kinds ...schema.GroupKind) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.pruneAllowedKinds = kinds
+	}
+}
+
+// WithPruneDeniedKinds prevents pruning of resources of the given kinds, e.g. PersistentVolumeClaims or
+// CustomResourceDefinitions. Resources of these kinds that are missing from the target state are reported as skipped.
+// Denied kinds take precedence over allowed kinds.
+func WithPruneDeniedKinds(kinds ...schema.GroupKind) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.pruneDeniedKinds = kinds
+	}
+}
+
+// WithPruneGracePeriod prevents pruning of resources that were created less than the given grace period ago, e.g.
+// resources created by a manifest that was just applied but has not propagated to the target state yet. Such resources
+// are reported as skipped and pruned by a later sync once they are older than the grace period. The grace period is
+// disabled if it is zero or negative.
+func WithPruneGracePeriod(gracePeriod time.Duration) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.pruneGracePeriod = gracePeriod
+	}
+}
+
+// WithInPlaceAPIGroupChange enables updating resources in place when the API group of a resource changes, e.g. when
+// an Ingress moves from extensions/v1beta1 to networking.k8s.io/v1. A target resource without live state is treated
+// as the same logical resource as a live resource without target state if both have the same kind, namespace and name.
+// Instead of creating the target resource and pruning the live resource, the target resource is applied over the
+// live resource.
+func WithInPlaceAPIGroupChange(enabled bool) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.inPlaceAPIGroupChange = enabled
+	}
+}
+
+// WithExpectedLive enables an optimistic concurrency check of the live resources. Before a resource listed in the
+// given map is applied, its current resource version is fetched from the cluster and compared with the expected one.
+// An empty expected resource version means that the resource is expected not to exist. The apply fails and the
+// conflict is reported if the versions differ, e.g. because the resource was changed concurrently.
+func WithExpectedLive(expectedLive map[kube.ResourceKey]string) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.expectedLive = expectedLive
+	}
+}
+
+// ResourceGenerator generates additional target resources based on the live state of the managed resources
+type ResourceGenerator func(live map[kube.ResourceKey]*unstructured.Unstructured) ([]*unstructured.Unstructured, error)
+
+// WithResourceGenerator sets a generator of additional target resources. The generated resources are merged into
+// the target resources before the sync tasks are planned and replace targets with the same key. The sync operation
+// fails if the generator returns an error.
+func WithResourceGenerator(generator ResourceGenerator) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.resourceGenerator = generator
+	}
+}
+
+// WithStateStore sets the store used to persist the state of the sync operation with the given ID after every sync step.
+// The stored state allows resuming the operation using SyncContext.Resume.
+func WithStateStore(store StateStore, operationID string) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.stateStore = store
+		ctx.operationID = operationID
+	}
+}
+
+// WithWaveTimeout sets the maximum duration of a single sync wave, including the apply of the wave resources and
+// waiting for them to become healthy. The sync operation fails if a wave takes longer than the timeout. The timer of
+// a wave starts when the wave is started or first observed by this sync context. Zero means no timeout. Resources can
+// override the timeout using the gitops-engine.io/health-timeout annotation.
+func WithWaveTimeout(timeout time.Duration) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.waveTimeout = timeout
+	}
+}
+
+// WithWaveAtomic makes every sync wave all-or-nothing: if a resource of a wave fails to apply, the resources of the
+// same wave that were applied successfully are reverted to their state before the sync and the operation fails.
+// Created resources are deleted and updated resources are replaced by their previous live state. Only the applies of
+// the wave are reverted: pruned resources and hooks are not restored, failures detected after the wave was applied
+// (e.g. degraded health) do not trigger a revert, and side effects of the applied resources, e.g. pods started by an
+// updated Deployment or the data of a deleted PersistentVolumeClaim, cannot be undone.
+func WithWaveAtomic(enabled bool) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.waveAtomic = enabled
+	}
+}
+
+// WithNamespaceActiveTimeout makes every sync wave wait until the namespaces its resources are applied into are active,
+// e.g. until a previous namespace of the same name that is still terminating is deleted and the namespace is created
+// again. Namespaces, including the auto-created namespace, are not applied while the live namespace is terminating
+// either. The sync operation fails if the namespaces are not active after the given timeout. Zero disables the wait.
+func WithNamespaceActiveTimeout(timeout time.Duration) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.namespaceActiveTimeout = timeout
+	}
+}
+
+// WithServerDryRunOperations makes the preview of the sync report the operation the API server would perform to apply
+// each resource: created, configured or unchanged. The operation is determined by a server-side dry run of the apply
+// and reported in the tasks of Plan and, if the sync runs in dry-run mode, in the messages of the resource results.
+// Unlike the client-side dry run, an existing resource whose target state does not change anything is reported as
+// unchanged.
+func WithServerDryRunOperations(enabled bool) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.serverDryRunOperations = enabled
+	}
+}
+
+// HookClassifier determines whether the given object is a hook, the phase it runs in and its delete policies
+type HookClassifier func(obj *unstructured.Unstructured) (phase common.SyncPhase, isHook bool, deletePolicy []common.HookDeletePolicy)
+
+// WithHookClassifier sets a classifier that replaces the built-in parsing of the hook annotations. Objects classified
+// as hooks are run as hooks even if they were passed as target resources, and hooks that are not classified as hooks
+// are synced as regular resources.
+func WithHookClassifier(classifier HookClassifier) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.hookClassifier = classifier
+	}
+}
+
+// ReadinessProbe determines whether the given live object is ready, in addition to its health. The message explains why
+// the object is not ready.
+type ReadinessProbe func(obj *unstructured.Unstructured) (ready bool, message string, err error)
+
+// WithReadinessProbe sets a probe that is consulted for the live resources of the given GVK once they are healthy or
+// have no health check, e.g. to query an endpoint provisioned by the resource. A resource is complete only when it is
+// healthy and the probe passes, so later sync waves wait for the probe. Errors of the probe are reported in the
+// resource message and the probe is retried.
+func WithReadinessProbe(gvk schema.GroupVersionKind, probe ReadinessProbe) SyncOpt {
+	return func(ctx *syncContext) {
+		if ctx.readinessProbes == nil {
+			ctx.readinessProbes = map[schema.GroupVersionKind]ReadinessProbe{}
+		}
+		ctx.readinessProbes[gvk] = probe
+	}
+}
+
+// PauseGate is invoked once a sync operation reaches the phase configured using WithPauseGate. The operation is resumed
+// once the returned channel is closed.
+type PauseGate func() <-chan struct{}
+
+// WithPauseGate pauses the sync operation before the first wave of the given phase, or of a later phase if the given
+// phase has no tasks, is applied until the channel returned by the gate is closed. E.g. the operation is paused after
+// PreSync and before the main apply if the phase is Sync. Sync does not block while the operation is paused: the
+// operation stays Running and every Sync call checks whether it can be resumed. A paused operation is cancelled using
+// Terminate.
+func WithPauseGate(phase common.SyncPhase, gate PauseGate) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.pausePhase = phase
+		ctx.pauseGate = gate
+	}
+}
+
+// WithHookNamespace sets the namespace hooks without an explicit namespace are created in instead of the namespace of
+// the application, e.g. a sandbox namespace for migration jobs. The hooks are deleted from that namespace according to
+// their delete policy. The permissions to manage the hooks are verified in that namespace. If autoCreate is true then
+// the namespace is created in the PreSync phase if it does not exist.
+func WithHookNamespace(namespace string, autoCreate bool) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.hookNamespace = namespace
+		ctx.autoCreateHookNamespace = autoCreate
+	}
+}
+
 // NewSyncContext creates new instance of a SyncContext
 func NewSyncContext(
 	revision string,
@@ -224,6 +547,10 @@ func NewSyncContext(
 	if err != nil {
 		return nil, nil, err
 	}
+	kubeclientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, nil, err
+	}
 	resourceOps, cleanup, err := kubectl.ManageResources(rawConfig, openAPISchema)
 	if err != nil {
 		return nil, nil, err
@@ -237,6 +564,7 @@ func NewSyncContext(
 		dynamicIf:           dynamicIf,
 		disco:               disco,
 		extensionsclientset: extensionsclientset,
+		accessReviews:       kubeclientset.AuthorizationV1().SelfSubjectAccessReviews(),
 		kubectl:             kubectl,
 		resourceOps:         resourceOps,
 		namespace:           namespace,
@@ -273,10 +601,15 @@ func groupResources(reconciliationResult ReconciliationResult) map[kubeutil.Reso
 	return resources
 }
 
-// generates a map of resource and its modification result based on diffResultList
-func groupDiffResults(diffResultList *diff.DiffResultList) map[kubeutil.ResourceKey]bool {
+// generates a map of resource and its modification result based on diffResults
+func groupDiffResults(diffResults *diff.DiffArrayResults) map[kubeutil.ResourceKey]bool {
 	modifiedResources := make(map[kube.ResourceKey]bool)
-	for _, res := range diffResultList.Diffs {
+	for _, res := range diffResults.Results {
+		if res.Kind != "" {
+			modifiedResources[kube.NewResourceKey(res.Group, res.Kind, res.Namespace, res.Name)] = res.Modified
+			continue
+		}
+		// results that were not computed by diff.Diff might not be identified
 		var obj unstructured.Unstructured
 		var err error
 		if string(res.NormalizedLive) != "null" {
@@ -294,6 +627,9 @@ func groupDiffResults(diffResultList *diff.DiffResultList) map[kubeutil.Resource
 
 const (
 	crdReadinessTimeout = time.Duration(3) * time.Second
+	// the duration and interval of the retries of applying custom resources whose type is not served yet
+	crdServedTimeout       = time.Duration(10) * time.Second
+	crdServedRetryInterval = time.Duration(200) * time.Millisecond
 )
 
 // getOperationPhase returns a hook status from an _live_ unstructured object
@@ -331,14 +667,27 @@ type syncContext struct {
 	dynamicIf           dynamic.Interface
 	disco               discovery.DiscoveryInterface
 	extensionsclientset *clientset.Clientset
+	accessReviews       authorizationv1client.SelfSubjectAccessReviewInterface
 	kubectl             kube.Kubectl
 	resourceOps         kube.ResourceOperations
 	namespace           string
+	// namespace of the hooks without an explicit namespace and whether it is created if it does not exist
+	hookNamespace           string
+	autoCreateHookNamespace bool
 
 	dryRun                 bool
 	force                  bool
 	validate               bool
 	skipHooks              bool
+	hookClassifier         HookClassifier
+	readinessProbes        map[schema.GroupVersionKind]ReadinessProbe
+	syncLoopDetector       *SyncLoopDetector
+	crdSchemaCheck         bool
+	crdSchemaCheckBlock    bool
+	customResourceLister   CustomResourceLister
+	applySetParent         *unstructured.Unstructured
+	pausePhase             common.SyncPhase
+	pauseGate              PauseGate
 	resourcesFilter        func(key kube.ResourceKey, target *unstructured.Unstructured, live *unstructured.Unstructured) bool
 	prune                  bool
 	replace                bool
@@ -347,6 +696,32 @@ type syncContext struct {
 	pruneLast              bool
 	prunePropagationPolicy *metav1.DeletionPropagation
 	pruneConfirmed         bool
+	softPrune              bool
+	optimisticLock         bool
+	conflictRetry          wait.Backoff
+	pruneFinalizerCheck    bool
+	pruneDryRunDelete      bool
+	maxConcurrentDeletes   int
+	pruneAllowedKinds      []schema.GroupKind
+	pruneDeniedKinds       []schema.GroupKind
+	pruneGracePeriod       time.Duration
+	preflightRBAC          bool
+	deployID               string
+	skipUnchangedManifests bool
+	inPlaceAPIGroupChange  bool
+	expectedLive           map[kube.ResourceKey]string
+	resourceGenerator      ResourceGenerator
+	waveTimeout            time.Duration
+	waveAtomic             bool
+	stateStore             StateStore
+	operationID            string
+
+	// the labels and annotations added to every applied resource and whether they replace the ones set by the target
+	// resources
+	commonLabels               map[string]string
+	commonAnnotations          map[string]string
+	overwriteCommonLabels      bool
+	overwriteCommonAnnotations bool
 
 	syncRes   map[string]common.ResourceSyncResult
 	startedAt time.Time
@@ -354,6 +729,21 @@ type syncContext struct {
 	phase     common.OperationPhase
 	message   string
 
+	// the phase and wave that is currently running and the time it was started; used to enforce waveTimeout
+	currentWave          *syncWave
+	currentWaveStartedAt time.Time
+
+	// the maximum duration to wait for the namespaces of a wave to become active and when the wait started
+	namespaceActiveTimeout time.Duration
+	namespaceWaitStartedAt time.Time
+
+	// whether the operations the API server would perform are determined by a server-side dry run in previews
+	serverDryRunOperations bool
+
+	// the channel returned by pauseGate and whether it was closed, i.e. the operation was resumed
+	pauseSignal  <-chan struct{}
+	pauseResumed bool
+
 	log logr.Logger
 	// lock to protect concurrent updates of the result list
 	lock sync.Mutex
@@ -364,9 +754,63 @@ type syncContext struct {
 
 	syncWaveHook common.SyncWaveHook
 
+	// field managers used to apply the resources of specific sync waves
+	serverSideApplyManagersPerWave map[int]string
+
 	applyOutOfSyncOnly bool
 	// stores whether the resource is modified or not
 	modificationResult map[kube.ResourceKey]bool
+
+	// ignore difference rules applied to the target manifests before hashing them
+	manifestHashIgnoreDifferences []diff.IgnoreDifference
+	// the context of the sync operation, see WithContext
+	operationContext context.Context
+	// diffFunc overrides diff.Diff, e.g. in tests
+	diffFunc func(config, live *unstructured.Unstructured, opts ...diff.Option) (*diff.DiffResult, error)
+	// the changes checked by the sync loop detector, which are recorded once they are applied
+	syncLoopPatches map[kube.ResourceKey]string
+}
+
+// generateResources executes the resource generator and merges the generated resources into the target resources
+func (sc *syncContext) generateResources() error {
+	live := make(map[kube.ResourceKey]*unstructured.Unstructured)
+	for k, res := range sc.resources {
+		if res.Live != nil {
+			live[k] = res.Live
+		}
+	}
+	generated, err := sc.resourceGenerator(live)
+	if err != nil {
+		return err
+	}
+	for _, obj := range generated {
+		if obj == nil {
+			continue
+		}
+		key := kube.GetResourceKey(obj)
+		res := sc.resources[key]
+		res.Target = obj
+		sc.resources[key] = res
+	}
+	return nil
+}
+
+// paused returns true if the operation must not apply the tasks of the given phase because it waits for the signal of
+// the pause gate. The gate is invoked the first time the operation reaches the configured phase.
+func (sc *syncContext) paused(phase common.SyncPhase) bool {
+	if sc.pauseGate == nil || sc.pauseResumed || syncPhaseOrder[phase] < syncPhaseOrder[sc.pausePhase] {
+		return false
+	}
+	if sc.pauseSignal == nil {
+		sc.pauseSignal = sc.pauseGate()
+	}
+	select {
+	case <-sc.pauseSignal:
+		sc.pauseResumed = true
+		return false
+	default:
+		return true
+	}
 }
 
 func (sc *syncContext) setRunningPhase(tasks []*syncTask, isPendingDeletion bool) {
@@ -393,6 +837,24 @@ func (sc *syncContext) setRunningPhase(tasks []*syncTask, isPendingDeletion bool
 // sync has performs the actual apply or hook based sync
 func (sc *syncContext) Sync() {
 	sc.log.WithValues("skipHooks", sc.skipHooks, "started", sc.started()).Info("Syncing")
+	if sc.stateStore != nil && sc.operationID != "" {
+		defer sc.saveState()
+	}
+	if sc.resourceGenerator != nil {
+		if err := sc.generateResources(); err != nil {
+			sc.setOperationPhase(common.OperationError, fmt.Sprintf("failed to generate resources: %v", err))
+			return
+		}
+	}
+	if sc.applySetParent != nil {
+		sc.labelApplySetMembers()
+		if !sc.started() {
+			if err := sc.prepareApplySet(); err != nil {
+				sc.setOperationPhase(common.OperationError, fmt.Sprintf("failed to prepare ApplySet: %v", err))
+				return
+			}
+		}
+	}
 	tasks, ok := sc.getSyncTasks()
 	if !ok {
 		sc.setOperationPhase(common.OperationFailed, "one or more synchronization tasks are not valid")
@@ -413,12 +875,44 @@ func (sc *syncContext) Sync() {
 		if sc.applyOutOfSyncOnly {
 			dryRunTasks = sc.filterOutOfSyncTasks(tasks)
 		}
+		if sc.skipUnchangedManifests {
+			dryRunTasks = sc.filterUnchangedManifestTasks(dryRunTasks)
+		}
+
+		if sc.syncLoopDetector != nil && !sc.dryRun && !sc.detectSyncLoops(dryRunTasks) {
+			sc.setOperationPhase(common.OperationFailed, "sync loop detected: one or more resources are changed in the same way by every sync")
+			return
+		}
+
+		if sc.crdSchemaCheck && !sc.checkCRDSchemaChanges(dryRunTasks) {
+			sc.setOperationPhase(common.OperationFailed, "one or more CRD schema changes invalidate existing custom resources")
+			return
+		}
+
+		if sc.preflightRBAC {
+			missing, err := sc.getMissingPermissions(dryRunTasks)
+			if err != nil {
+				sc.setOperationPhase(common.OperationError, fmt.Sprintf("failed to verify permissions: %v", err))
+				return
+			}
+			if len(missing) > 0 {
+				sc.setOperationPhase(common.OperationFailed, fmt.Sprintf("missing permissions: %s", strings.Join(missing, "; ")))
+				return
+			}
+		}
 
 		sc.log.WithValues("tasks", dryRunTasks).Info("Tasks (dry-run)")
 		if sc.runTasks(dryRunTasks, true) == failed {
 			sc.setOperationPhase(common.OperationFailed, "one or more objects failed to apply (dry run)")
 			return
 		}
+
+		// hooks with the OnChange run policy are skipped for the whole operation if no resources are changed
+		if !sc.resourcesChanged(dryRunTasks) {
+			for _, task := range tasks.Filter(func(t *syncTask) bool { return t.pending() && t.runOnChange() }) {
+				sc.setResourceResult(task, common.ResultCodeHookSkipped, common.OperationSucceeded, "skipped (no resources changed)")
+			}
+		}
 	}
 
 	// update status of any tasks that are running, note that this must exclude pruning tasks
@@ -441,11 +935,19 @@ func (sc *syncContext) Sync() {
 				sc.log.WithValues("task", task, "healthStatus", healthStatus).V(1).Info("attempting to update health of running task")
 				if healthStatus == nil {
 					// some objects (e.g. secret) do not have health, and they automatically success
-					sc.setResourceResult(task, task.syncStatus, common.OperationSucceeded, task.message)
+					if ready, message := sc.probeReadiness(task); !ready {
+						sc.setResourceResult(task, task.syncStatus, common.OperationRunning, message)
+					} else {
+						sc.setResourceResult(task, task.syncStatus, common.OperationSucceeded, task.message)
+					}
 				} else {
 					switch healthStatus.Status {
 					case health.HealthStatusHealthy:
-						sc.setResourceResult(task, task.syncStatus, common.OperationSucceeded, healthStatus.Message)
+						if ready, message := sc.probeReadiness(task); !ready {
+							sc.setResourceResult(task, task.syncStatus, common.OperationRunning, message)
+						} else {
+							sc.setResourceResult(task, task.syncStatus, common.OperationSucceeded, healthStatus.Message)
+						}
 					case health.HealthStatusDegraded:
 						sc.setResourceResult(task, task.syncStatus, common.OperationFailed, healthStatus.Message)
 					}
@@ -460,6 +962,10 @@ func (sc *syncContext) Sync() {
 	multiStep := tasks.multiStep()
 	runningTasks := tasks.Filter(func(t *syncTask) bool { return (multiStep || t.isHook()) && t.running() })
 	if runningTasks.Len() > 0 {
+		if timedOut := sc.timedOutTasks(runningTasks); timedOut.Len() > 0 {
+			sc.failTimedOutWave(tasks, runningTasks, timedOut)
+			return
+		}
 		sc.setRunningPhase(runningTasks, false)
 		return
 	}
@@ -504,12 +1010,16 @@ func (sc *syncContext) Sync() {
 	if sc.applyOutOfSyncOnly {
 		tasks = sc.filterOutOfSyncTasks(tasks)
 	}
+	if sc.skipUnchangedManifests {
+		tasks = sc.filterUnchangedManifestTasks(tasks)
+	}
 
 	// If no sync tasks were generated (e.g., in case all application manifests have been removed),
 	// the sync operation is successful.
 	if len(tasks) == 0 {
 		// delete all completed hooks which have appropriate delete policy
 		sc.deleteHooks(hooksPendingDeletionSuccessful)
+		sc.completeApplySet()
 		sc.setOperationPhase(common.OperationSucceeded, "successfully synced (no more tasks)")
 		return
 	}
@@ -519,6 +1029,11 @@ func (sc *syncContext) Sync() {
 	wave := tasks.wave()
 	finalWave := phase == tasks.lastPhase() && wave == tasks.lastWave()
 
+	if sc.paused(phase) {
+		sc.setOperationPhase(common.OperationRunning, fmt.Sprintf("paused before %s phase: waiting for the signal to resume", phase))
+		return
+	}
+
 	// if it is the last phase/wave and the only remaining tasks are non-hooks, the we are successful
 	// EVEN if those objects subsequently degraded
 	// This handles the common case where neither hooks or waves are used and a sync equates to simply an (asynchronous) kubectl apply of manifests, which succeeds immediately.
@@ -527,9 +1042,31 @@ func (sc *syncContext) Sync() {
 	sc.log.WithValues("phase", phase, "wave", wave, "tasks", tasks, "syncFailTasks", syncFailTasks).V(1).Info("Filtering tasks in correct phase and wave")
 	tasks = tasks.Filter(func(t *syncTask) bool { return t.phase == phase && t.wave() == wave })
 
+	if sc.namespaceActiveTimeout > 0 && !sc.dryRun {
+		inactive, err := sc.getInactiveNamespaces(tasks)
+		if err != nil {
+			sc.setOperationPhase(common.OperationError, fmt.Sprintf("failed to get namespaces: %v", err))
+			return
+		}
+		if len(inactive) > 0 {
+			if sc.namespaceWaitStartedAt.IsZero() {
+				sc.namespaceWaitStartedAt = time.Now()
+			}
+			if time.Since(sc.namespaceWaitStartedAt) > sc.namespaceActiveTimeout {
+				sc.deleteHooks(hooksPendingDeletionFailed)
+				sc.setOperationFailed(syncFailTasks, nil, fmt.Sprintf("namespaces did not become active within %v: %s", sc.namespaceActiveTimeout, strings.Join(inactive, ", ")))
+				return
+			}
+			sc.setOperationPhase(common.OperationRunning, fmt.Sprintf("waiting for namespaces to become active: %s", strings.Join(inactive, ", ")))
+			return
+		}
+		sc.namespaceWaitStartedAt = time.Time{}
+	}
+
 	sc.setOperationPhase(common.OperationRunning, "one or more tasks are running")
 
 	sc.log.WithValues("tasks", tasks).V(1).Info("Wet-run")
+	sc.startWave(phase, wave)
 	runState := sc.runTasks(tasks, false)
 
 	if sc.syncWaveHook != nil && runState != failed {
@@ -546,11 +1083,17 @@ func (sc *syncContext) Sync() {
 	case failed:
 		syncFailedTasks, _ := tasks.Split(func(t *syncTask) bool { return t.syncStatus == common.ResultCodeSyncFailed })
 		sc.deleteHooks(hooksPendingDeletionFailed)
+		if sc.waveAtomic && !sc.dryRun {
+			sc.revertWave(tasks)
+			sc.setOperationFailed(syncFailTasks, syncFailedTasks, fmt.Sprintf("one or more objects failed to apply, reverted the applied objects of sync wave %d of phase %s", wave, phase))
+			return
+		}
 		sc.setOperationFailed(syncFailTasks, syncFailedTasks, "one or more objects failed to apply")
 	case successful:
 		if remainingTasks.Len() == 0 {
 			// delete all completed hooks which have appropriate delete policy
 			sc.deleteHooks(hooksPendingDeletionSuccessful)
+			sc.completeApplySet()
 			sc.setOperationPhase(common.OperationSucceeded, "successfully synced (all tasks run)")
 		} else {
 			sc.setRunningPhase(remainingTasks, false)
@@ -562,6 +1105,142 @@ func (sc *syncContext) Sync() {
 	}
 }
 
+// requiredVerbs returns the API verbs needed to perform the given task
+func (sc *syncContext) requiredVerbs(t *syncTask) []string {
+	if t.isPrune() {
+		if sc.softPrune {
+			return []string{"patch"}
+		}
+		return []string{"delete"}
+	}
+	var verbs []string
+	shouldReplace := sc.replace || resourceutil.HasAnnotationOption(t.targetObj, common.AnnotationSyncOptions, common.SyncOptionReplace)
+	force := sc.force || resourceutil.HasAnnotationOption(t.targetObj, common.AnnotationSyncOptions, common.SyncOptionForce)
+	switch {
+	case shouldReplace && t.liveObj == nil:
+		verbs = append(verbs, "create")
+	case shouldReplace:
+		verbs = append(verbs, "update")
+		if force && !kube.IsCRD(t.targetObj) && t.targetObj.GetKind() != kubeutil.NamespaceKind {
+			// forced replace deletes and re-creates the resource
+			verbs = append(verbs, "delete", "create")
+		}
+	case sc.shouldUseServerSideApply(t.targetObj):
+		// server-side apply creates missing resources using a patch request
+		verbs = append(verbs, "patch")
+		if t.liveObj == nil {
+			verbs = append(verbs, "create")
+		}
+	case t.liveObj == nil:
+		verbs = append(verbs, "create")
+	default:
+		verbs = append(verbs, "patch")
+	}
+	if force && !shouldReplace {
+		// forced apply deletes and re-creates the resource if it cannot be patched
+		verbs = append(verbs, "delete", "create")
+	}
+	if t.isHook() && (t.hasHookDeletePolicy(common.HookDeletePolicyBeforeHookCreation) ||
+		t.hasHookDeletePolicy(common.HookDeletePolicyHookSucceeded) ||
+		t.hasHookDeletePolicy(common.HookDeletePolicyHookFailed)) {
+		verbs = append(verbs, "delete")
+	}
+	return verbs
+}
+
+// getMissingPermissions uses SelfSubjectAccessReview to verify that all the given tasks can be performed and returns
+// the list of the missing permissions
+func (sc *syncContext) getMissingPermissions(tasks syncTasks) ([]string, error) {
+	if sc.accessReviews == nil {
+		return nil, fmt.Errorf("self subject access review client is not configured")
+	}
+	var missing []string
+	checked := make(map[authorizationv1.ResourceAttributes]bool)
+	for _, task := range tasks {
+		if task.skipDryRun {
+			// the resource API is not known yet, e.g. the CRD is created during the sync
+			continue
+		}
+		if task.isPrune() && !sc.willPrune(task.liveObj) {
+			continue
+		}
+		serverRes, err := kube.ServerResourceForGroupVersionKind(sc.disco, task.groupVersionKind(), "get")
+		if err != nil {
+			return nil, err
+		}
+		for _, verb := range sc.requiredVerbs(task) {
+			attributes := authorizationv1.ResourceAttributes{
+				Verb:     verb,
+				Group:    task.group(),
+				Version:  task.version(),
+				Resource: serverRes.Name,
+			}
+			if serverRes.Namespaced {
+				attributes.Namespace = task.namespace()
+			}
+			if _, ok := checked[attributes]; ok {
+				continue
+			}
+			review, err := sc.accessReviews.Create(sc.getContext(), &authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &attributes},
+			}, metav1.CreateOptions{})
+			if err != nil {
+				return nil, err
+			}
+			checked[attributes] = review.Status.Allowed
+			if !review.Status.Allowed {
+				resource := attributes.Resource
+				if attributes.Group != "" {
+					resource = fmt.Sprintf("%s.%s", attributes.Resource, attributes.Group)
+				}
+				message := fmt.Sprintf("cannot %s %s", attributes.Verb, resource)
+				if attributes.Namespace != "" {
+					message = fmt.Sprintf("%s in namespace %s", message, attributes.Namespace)
+				}
+				missing = append(missing, message)
+			}
+		}
+	}
+	return missing, nil
+}
+
+// mergeAPIGroupChanges merges the create task of a resource whose API group changed with the prune task of the live
+// resource stored using the previous API group, so that the resource is updated in place
+func (sc *syncContext) mergeAPIGroupChanges(tasks syncTasks) syncTasks {
+	taskKey := func(t *syncTask, obj *unstructured.Unstructured) string {
+		namespace := obj.GetNamespace()
+		if namespace == "" {
+			namespace = sc.namespace
+		}
+		return fmt.Sprintf("%s/%s/%s/%s", t.phase, obj.GetKind(), namespace, obj.GetName())
+	}
+	pruneTasks := make(map[string]*syncTask)
+	for _, t := range tasks {
+		if t.targetObj == nil && t.liveObj != nil {
+			pruneTasks[taskKey(t, t.liveObj)] = t
+		}
+	}
+	merged := make(map[*syncTask]bool)
+	for _, t := range tasks {
+		if t.targetObj == nil || t.liveObj != nil {
+			continue
+		}
+		key := taskKey(t, t.targetObj)
+		pruneTask, ok := pruneTasks[key]
+		if !ok || pruneTask.liveObj.GroupVersionKind().Group == t.targetObj.GroupVersionKind().Group {
+			continue
+		}
+		sc.log.WithValues("kind", t.kind(), "name", t.name(), "from", pruneTask.liveObj.GetAPIVersion(), "to", t.targetObj.GetAPIVersion()).
+			Info("Updating resource in place after API group change")
+		t.liveObj = pruneTask.liveObj
+		merged[pruneTask] = true
+		delete(pruneTasks, key)
+	}
+	return tasks.Filter(func(t *syncTask) bool {
+		return !merged[t]
+	})
+}
+
 // filter out out-of-sync tasks
 func (sc *syncContext) filterOutOfSyncTasks(tasks syncTasks) syncTasks {
 	return tasks.Filter(func(t *syncTask) bool {
@@ -577,6 +1256,123 @@ func (sc *syncContext) filterOutOfSyncTasks(tasks syncTasks) syncTasks {
 	})
 }
 
+// filter out tasks of healthy resources whose target manifest has not changed since it was last applied
+func (sc *syncContext) filterUnchangedManifestTasks(tasks syncTasks) syncTasks {
+	return tasks.Filter(func(t *syncTask) bool {
+		if t.isHook() || t.targetObj == nil || t.liveObj == nil {
+			return true
+		}
+		hash := t.targetObj.GetAnnotations()[common.AnnotationManifestHash]
+		if hash == "" || t.liveObj.GetAnnotations()[common.AnnotationManifestHash] != hash {
+			return true
+		}
+		healthStatus, err := health.GetResourceHealth(t.liveObj, sc.healthOverride)
+		if err != nil {
+			return true
+		}
+		// resources without a health check are healthy as soon as they exist
+		if healthStatus != nil && healthStatus.Status != health.HealthStatusHealthy {
+			return true
+		}
+		sc.log.WithValues("resource key", t.resourceKey()).V(1).Info("Skipping as resource manifest was not changed")
+		return false
+	})
+}
+
+// resourcesChanged returns true if at least one non-hook resource is created, updated or pruned by the given tasks.
+// The resources are only compared if their modification result is not known, see WithResourceModificationChecker.
+func (sc *syncContext) resourcesChanged(tasks syncTasks) bool {
+	return tasks.Any(func(t *syncTask) bool {
+		if t.isHook() {
+			return false
+		}
+		if t.targetObj == nil {
+			return sc.willPrune(t.liveObj)
+		}
+		if t.liveObj == nil {
+			return true
+		}
+		if modified, ok := sc.modificationResult[t.resourceKey()]; ok {
+			return modified
+		}
+		res, err := sc.diffResource(t.targetObj, t.liveObj, sc.commonMetadataDiffOptions()...)
+		return err != nil || res.Modified
+	})
+}
+
+// diffResource compares the given target and live resources
+func (sc *syncContext) diffResource(config, live *unstructured.Unstructured, opts ...diff.Option) (*diff.DiffResult, error) {
+	if sc.diffFunc != nil {
+		return sc.diffFunc(config, live, opts...)
+	}
+	return diff.Diff(config, live, opts...)
+}
+
+// commonMetadataDiffOptions returns the diff options that ignore the common labels and annotations
+func (sc *syncContext) commonMetadataDiffOptions() []diff.Option {
+	var opts []diff.Option
+	for key := range sc.commonLabels {
+		opts = append(opts, diff.WithIgnoredLabels(key))
+	}
+	for key := range sc.commonAnnotations {
+		opts = append(opts, diff.WithIgnoredAnnotations(key))
+	}
+	return opts
+}
+
+// mergeCommonMetadata returns the given labels or annotations of a resource merged with the common ones
+func mergeCommonMetadata(values map[string]string, common map[string]string, overwrite bool) map[string]string {
+	if len(common) == 0 {
+		return values
+	}
+	merged := make(map[string]string, len(values)+len(common))
+	for k, v := range common {
+		merged[k] = v
+	}
+	for k, v := range values {
+		if _, ok := common[k]; !ok || !overwrite {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// probeReadiness returns whether the live object of the given task passes the readiness probe registered for its GVK
+// and the reason if it does not
+func (sc *syncContext) probeReadiness(task *syncTask) (bool, string) {
+	probe, ok := sc.readinessProbes[task.liveObj.GroupVersionKind()]
+	if !ok {
+		return true, ""
+	}
+	ready, message, err := probe(task.liveObj)
+	if err != nil {
+		return false, fmt.Sprintf("readiness probe failed: %v", err)
+	}
+	if !ready && message == "" {
+		message = "waiting for readiness probe"
+	}
+	return ready, message
+}
+
+// manifestHash returns the hash of the given manifest after it is normalized the same way it is normalized when diffing
+// and the fields ignored by the given rules are removed. Annotations added by the sync engine, e.g. a manifest hash
+// annotation that is already present in the manifest derived from the live resource, are ignored, so that the hash
+// is stable.
+func manifestHash(obj *unstructured.Unstructured, rules []diff.IgnoreDifference) (string, error) {
+	obj = obj.DeepCopy()
+	diff.Normalize(obj)
+	obj, err := diff.ApplyIgnoreDifferences(obj, rules)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
 func (sc *syncContext) deleteHooks(hooksPendingDeletion syncTasks) {
 	for _, task := range hooksPendingDeletion {
 		err := sc.deleteResource(task)
@@ -597,6 +1393,32 @@ func (sc *syncContext) GetState() (common.OperationPhase, string, []common.Resou
 	return sc.phase, sc.message, resourceRes
 }
 
+// Resume restores the state of the given sync operation from the state store
+func (sc *syncContext) Resume(operationID string) error {
+	if sc.stateStore == nil {
+		return fmt.Errorf("state store is not configured")
+	}
+	state, err := sc.stateStore.Load(operationID)
+	if err != nil {
+		return fmt.Errorf("failed to load state of operation %s: %w", operationID, err)
+	}
+	if state == nil {
+		return fmt.Errorf("no state stored for operation %s", operationID)
+	}
+	WithInitialState(state.Phase, state.Message, state.Results, state.StartedAt)(sc)
+	sc.operationID = operationID
+	sc.log.WithValues("operationID", operationID, "phase", state.Phase).Info("Resuming sync operation")
+	return nil
+}
+
+func (sc *syncContext) saveState() {
+	phase, message, results := sc.GetState()
+	state := SyncState{Phase: phase, Message: message, StartedAt: metav1.NewTime(sc.startedAt), Results: results}
+	if err := sc.stateStore.Save(sc.operationID, state); err != nil {
+		sc.log.Error(err, fmt.Sprintf("failed to save state of operation %s", sc.operationID))
+	}
+}
+
 func (sc *syncContext) setOperationFailed(syncFailTasks, syncFailedTasks syncTasks, message string) {
 	errorMessageFactory := func(tasks []*syncTask, message string) string {
 		messages := syncFailedTasks.Map(func(task *syncTask) string {
@@ -627,6 +1449,109 @@ func (sc *syncContext) setOperationFailed(syncFailTasks, syncFailedTasks syncTas
 	}
 }
 
+type syncWave struct {
+	phase common.SyncPhase
+	wave  int
+}
+
+// startWave records the start time of the given wave unless the wave is already running
+func (sc *syncContext) startWave(phase common.SyncPhase, wave int) {
+	current := syncWave{phase: phase, wave: wave}
+	if sc.currentWave != nil && *sc.currentWave == current {
+		return
+	}
+	sc.currentWave = &current
+	sc.currentWaveStartedAt = time.Now()
+}
+
+// taskTimeout returns the health timeout of the given task, which defaults to the wave timeout
+func (sc *syncContext) taskTimeout(task *syncTask) (time.Duration, bool) {
+	if timeout, ok := task.healthTimeout(); ok {
+		return timeout, true
+	}
+	return sc.waveTimeout, false
+}
+
+// timedOutTasks returns the running tasks of the current wave that have been running for longer than their timeout
+func (sc *syncContext) timedOutTasks(runningTasks syncTasks) syncTasks {
+	hasTimeout := false
+	for _, task := range runningTasks {
+		if timeout, _ := sc.taskTimeout(task); timeout > 0 {
+			hasTimeout = true
+			break
+		}
+	}
+	if !hasTimeout {
+		return nil
+	}
+	sc.startWave(runningTasks.phase(), runningTasks.wave())
+	elapsed := time.Since(sc.currentWaveStartedAt)
+	return runningTasks.Filter(func(t *syncTask) bool {
+		timeout, _ := sc.taskTimeout(t)
+		return timeout > 0 && elapsed > timeout
+	})
+}
+
+// failTimedOutWave marks the timed out tasks of the wave as failed and fails the operation
+func (sc *syncContext) failTimedOutWave(tasks, runningTasks, timedOut syncTasks) {
+	var incomplete []string
+	overridden := false
+	for _, task := range timedOut {
+		name := fmt.Sprintf("%s/%s/%s", task.group(), task.kind(), task.name())
+		if timeout, ok := sc.taskTimeout(task); ok {
+			overridden = true
+			sc.setResourceResult(task, task.syncStatus, common.OperationFailed, fmt.Sprintf("did not become healthy within its health timeout of %v", timeout))
+			incomplete = append(incomplete, fmt.Sprintf("%s (health timeout %v)", name, timeout))
+		} else {
+			sc.setResourceResult(task, task.syncStatus, common.OperationFailed, fmt.Sprintf("did not complete within the sync wave timeout of %v", sc.waveTimeout))
+			incomplete = append(incomplete, name)
+		}
+	}
+	syncFailTasks, _ := tasks.Split(func(t *syncTask) bool { return t.phase == common.SyncPhaseSyncFail })
+	if overridden {
+		sc.setOperationFailed(syncFailTasks, nil, fmt.Sprintf("sync wave %d of phase %s failed, resources exceeded their timeout: %s",
+			runningTasks.wave(), runningTasks.phase(), strings.Join(incomplete, ", ")))
+		return
+	}
+	sc.setOperationFailed(syncFailTasks, nil, fmt.Sprintf("sync wave %d of phase %s exceeded timeout of %v, incomplete resources: %s",
+		runningTasks.wave(), runningTasks.phase(), sc.waveTimeout, strings.Join(incomplete, ", ")))
+}
+
+// revertWave reverts the resources of the given wave that were applied successfully to their state before the sync
+func (sc *syncContext) revertWave(tasks syncTasks) {
+	reverted := tasks.Filter(func(t *syncTask) bool {
+		return !t.isHook() && !t.isPrune() && t.targetObj != nil && t.syncStatus == common.ResultCodeSynced
+	})
+	ss := newStateSync(successful)
+	for _, task := range reverted {
+		t := task
+		ss.Go(func(state runState) runState {
+			logCtx := sc.log.WithValues("task", t)
+			logCtx.Info("Reverting apply of failed sync wave")
+			var err error
+			if t.liveObj == nil {
+				err = sc.kubectl.DeleteResource(sc.getContext(), sc.config, t.targetObj.GroupVersionKind(), t.targetObj.GetName(), t.targetObj.GetNamespace(), sc.getDeleteOptions())
+				if isNotFoundErr(err) {
+					err = nil
+				}
+			} else {
+				previous := t.liveObj.DeepCopy()
+				previous.SetResourceVersion("")
+				previous.SetManagedFields(nil)
+				_, err = sc.resourceOps.UpdateResource(sc.getContext(), previous, cmdutil.DryRunNone)
+			}
+			if err != nil {
+				logCtx.Error(err, "Failed to revert apply")
+				sc.setResourceResult(t, common.ResultCodeSyncFailed, common.OperationError, fmt.Sprintf("failed to revert after another resource of the wave failed: %v", err))
+				return failed
+			}
+			sc.setResourceResult(t, common.ResultCodeSyncFailed, common.OperationFailed, "reverted after another resource of the wave failed")
+			return state
+		})
+	}
+	ss.Wait()
+}
+
 func (sc *syncContext) started() bool {
 	return len(sc.syncRes) > 0
 }
@@ -635,9 +1560,31 @@ func (sc *syncContext) containsResource(resource reconciledResource) bool {
 	return sc.resourcesFilter == nil || sc.resourcesFilter(resource.key(), resource.Target, resource.Live)
 }
 
+// isHook returns true if the given object is a hook according to the configured hook classifier or the hook annotations
+func (sc *syncContext) isHook(obj *unstructured.Unstructured) bool {
+	if sc.hookClassifier != nil {
+		_, isHook, _ := sc.hookClassifier(obj)
+		return isHook
+	}
+	return hook.IsHook(obj)
+}
+
+// syncPhases returns the phases the given object is synced in according to the configured hook classifier or the
+// hook annotations
+func (sc *syncContext) syncPhases(obj *unstructured.Unstructured) []common.SyncPhase {
+	if sc.hookClassifier == nil {
+		return syncPhases(obj)
+	}
+	if phase, isHook, _ := sc.hookClassifier(obj); isHook {
+		return []common.SyncPhase{phase}
+	}
+	return []common.SyncPhase{common.SyncPhaseSync}
+}
+
 // generates the list of sync tasks we will be performing during this sync.
 func (sc *syncContext) getSyncTasks() (_ syncTasks, successful bool) {
 	resourceTasks := syncTasks{}
+	var hooks []*unstructured.Unstructured
 	successful = true
 
 	for k, resource := range sc.resources {
@@ -646,25 +1593,47 @@ func (sc *syncContext) getSyncTasks() (_ syncTasks, successful bool) {
 			continue
 		}
 
+		if sc.softPrune && resource.Target == nil && isPendingPrune(resource.Live) {
+			sc.log.WithValues("group", k.Group, "kind", k.Kind, "name", k.Name).V(1).Info("Skipping resource pending prune")
+			continue
+		}
+
 		obj := obj(resource.Target, resource.Live)
 
 		// this creates garbage tasks
-		if hook.IsHook(obj) {
+		if sc.isHook(obj) {
+			// objects classified as hooks by a custom classifier are not split from the target resources by Reconcile
+			if sc.hookClassifier != nil && resource.Target != nil {
+				hooks = append(hooks, resource.Target)
+			}
 			sc.log.WithValues("group", obj.GroupVersionKind().Group, "kind", obj.GetKind(), "namespace", obj.GetNamespace(), "name", obj.GetName()).V(1).Info("Skipping hook")
 			continue
 		}
 
-		for _, phase := range syncPhases(obj) {
-			resourceTasks = append(resourceTasks, &syncTask{phase: phase, targetObj: resource.Target, liveObj: resource.Live})
+		for _, phase := range sc.syncPhases(obj) {
+			resourceTasks = append(resourceTasks, &syncTask{phase: phase, targetObj: resource.Target, liveObj: resource.Live, hookClassifier: sc.hookClassifier})
 		}
 	}
 
+	for _, obj := range sc.hooks {
+		// hooks that are not classified as hooks by a custom classifier are synced as regular resources
+		if sc.isHook(obj) {
+			hooks = append(hooks, obj)
+		} else {
+			resourceTasks = append(resourceTasks, &syncTask{phase: common.SyncPhaseSync, targetObj: obj, hookClassifier: sc.hookClassifier})
+		}
+	}
+
+	if sc.inPlaceAPIGroupChange {
+		resourceTasks = sc.mergeAPIGroupChanges(resourceTasks)
+	}
+
 	sc.log.WithValues("resourceTasks", resourceTasks).V(1).Info("Tasks from managed resources")
 
 	hookTasks := syncTasks{}
 	if !sc.skipHooks {
-		for _, obj := range sc.hooks {
-			for _, phase := range syncPhases(obj) {
+		for _, obj := range hooks {
+			for _, phase := range sc.syncPhases(obj) {
 				// Hook resources names are deterministic, whether they are defined by the user (metadata.name),
 				// or formulated at the time of the operation (metadata.generateName). If user specifies
 				// metadata.generateName, then we will generate a formulated metadata.name before submission.
@@ -681,7 +1650,7 @@ func (sc *syncContext) getSyncTasks() (_ syncTasks, successful bool) {
 					targetObj.SetName(fmt.Sprintf("%s%s", generateName, postfix))
 				}
 
-				hookTasks = append(hookTasks, &syncTask{phase: phase, targetObj: targetObj})
+				hookTasks = append(hookTasks, &syncTask{phase: phase, targetObj: targetObj, hookClassifier: sc.hookClassifier})
 			}
 		}
 	}
@@ -703,13 +1672,52 @@ func (sc *syncContext) getSyncTasks() (_ syncTasks, successful bool) {
 			// possibility of the resource from unintentionally becoming created in the
 			// namespace during the `kubectl apply`
 			task.targetObj = task.targetObj.DeepCopy()
-			task.targetObj.SetNamespace(sc.namespace)
+			if sc.hookNamespace != "" && task.isHook() {
+				task.targetObj.SetNamespace(sc.hookNamespace)
+			} else {
+				task.targetObj.SetNamespace(sc.namespace)
+			}
+		}
+
+		if sc.skipUnchangedManifests && !task.isHook() {
+			hash, err := manifestHash(task.targetObj, sc.manifestHashIgnoreDifferences)
+			if err != nil {
+				sc.setResourceResult(task, common.ResultCodeSyncFailed, "", fmt.Sprintf("failed to compute manifest hash: %v", err))
+				successful = false
+				continue
+			}
+			task.targetObj = task.targetObj.DeepCopy()
+			annotations := task.targetObj.GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[common.AnnotationManifestHash] = hash
+			task.targetObj.SetAnnotations(annotations)
+		}
+
+		if len(sc.commonLabels) > 0 || len(sc.commonAnnotations) > 0 {
+			task.targetObj = task.targetObj.DeepCopy()
+			task.targetObj.SetLabels(mergeCommonMetadata(task.targetObj.GetLabels(), sc.commonLabels, sc.overwriteCommonLabels))
+			task.targetObj.SetAnnotations(mergeCommonMetadata(task.targetObj.GetAnnotations(), sc.commonAnnotations, sc.overwriteCommonAnnotations))
+		}
+
+		if sc.deployID != "" {
+			task.targetObj = task.targetObj.DeepCopy()
+			annotations := task.targetObj.GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[common.AnnotationDeployID] = sc.deployID
+			task.targetObj.SetAnnotations(annotations)
 		}
 	}
 
 	if sc.syncNamespace != nil && sc.namespace != "" {
 		tasks = sc.autoCreateNamespace(tasks)
 	}
+	if sc.autoCreateHookNamespace && sc.hookNamespace != "" && sc.hookNamespace != sc.namespace {
+		tasks = sc.autoCreateHookNamespaceTask(tasks)
+	}
 
 	// enrich task with live obj
 	for _, task := range tasks {
@@ -850,7 +1858,7 @@ func (sc *syncContext) autoCreateNamespace(tasks syncTasks) syncTasks {
 		nsSpec := &v1.Namespace{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: kube.NamespaceKind}, ObjectMeta: metav1.ObjectMeta{Name: sc.namespace}}
 		managedNs, err := kube.ToUnstructured(nsSpec)
 		if err == nil {
-			liveObj, err := sc.kubectl.GetResource(context.TODO(), sc.config, managedNs.GroupVersionKind(), managedNs.GetName(), metav1.NamespaceNone)
+			liveObj, err := sc.kubectl.GetResource(sc.getContext(), sc.config, managedNs.GroupVersionKind(), managedNs.GetName(), metav1.NamespaceNone)
 			if err == nil {
 				nsTask := &syncTask{phase: common.SyncPhasePreSync, targetObj: managedNs, liveObj: liveObj}
 				_, ok := sc.syncRes[nsTask.resultKey()]
@@ -874,6 +1882,30 @@ func (sc *syncContext) autoCreateNamespace(tasks syncTasks) syncTasks {
 	return tasks
 }
 
+// autoCreateHookNamespaceTask adds a PreSync task that creates the hook namespace if there are hooks in that namespace
+// and it neither exists nor is one of the target resources
+func (sc *syncContext) autoCreateHookNamespaceTask(tasks syncTasks) syncTasks {
+	if !tasks.Any(func(t *syncTask) bool { return t.isHook() && t.namespace() == sc.hookNamespace }) ||
+		tasks.Any(func(t *syncTask) bool { return isNamespaceWithName(t.targetObj, sc.hookNamespace) }) {
+		return tasks
+	}
+	nsSpec := &v1.Namespace{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: kube.NamespaceKind}, ObjectMeta: metav1.ObjectMeta{Name: sc.hookNamespace}}
+	ns, err := kube.ToUnstructured(nsSpec)
+	if err != nil {
+		return sc.appendFailedNsTask(tasks, ns, fmt.Errorf("hook namespace auto creation failed: %w", err))
+	}
+	liveObj, err := sc.kubectl.GetResource(sc.getContext(), sc.config, ns.GroupVersionKind(), ns.GetName(), metav1.NamespaceNone)
+	if err != nil && !apierr.IsNotFound(err) {
+		return sc.appendFailedNsTask(tasks, ns, fmt.Errorf("hook namespace auto creation failed: %w", err))
+	}
+	task := &syncTask{phase: common.SyncPhasePreSync, targetObj: ns, liveObj: liveObj}
+	// the task is kept once it ran so its result remains part of the operation
+	if _, ok := sc.syncRes[task.resultKey()]; ok || liveObj == nil {
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
 func (sc *syncContext) appendNsTask(tasks syncTasks, preTask *syncTask, managedNs, liveNs *unstructured.Unstructured) syncTasks {
 	modified, err := sc.syncNamespace(managedNs, liveNs)
 	if err != nil {
@@ -934,8 +1966,8 @@ func (sc *syncContext) setOperationPhase(phase common.OperationPhase, message st
 
 // ensureCRDReady waits until specified CRD is ready (established condition is true).
 func (sc *syncContext) ensureCRDReady(name string) error {
-	return wait.PollUntilContextTimeout(context.Background(), time.Duration(100)*time.Millisecond, crdReadinessTimeout, true, func(ctx context.Context) (bool, error) {
-		crd, err := sc.extensionsclientset.ApiextensionsV1().CustomResourceDefinitions().Get(context.TODO(), name, metav1.GetOptions{})
+	return wait.PollUntilContextTimeout(sc.getContext(), time.Duration(100)*time.Millisecond, crdReadinessTimeout, true, func(ctx context.Context) (bool, error) {
+		crd, err := sc.extensionsclientset.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, name, metav1.GetOptions{})
 		if err != nil {
 			return false, err
 		}
@@ -948,6 +1980,48 @@ func (sc *syncContext) ensureCRDReady(name string) error {
 	})
 }
 
+// getInactiveNamespaces returns the namespaces the given tasks apply resources into, or apply themselves, that exist and
+// are not active, along with their phase. Resources whose API is not known yet are assumed to be namespaced.
+func (sc *syncContext) getInactiveNamespaces(tasks syncTasks) ([]string, error) {
+	namespaces := map[string]bool{}
+	for _, task := range tasks {
+		if task.isPrune() || task.targetObj == nil {
+			continue
+		}
+		if isNamespaceKind(task.targetObj) {
+			namespaces[task.name()] = true
+			continue
+		}
+		if task.namespace() == "" {
+			continue
+		}
+		if serverRes, err := kube.ServerResourceForGroupVersionKind(sc.disco, task.groupVersionKind(), "get"); err == nil && !serverRes.Namespaced {
+			continue
+		}
+		namespaces[task.namespace()] = true
+	}
+	var inactive []string
+	for name := range namespaces {
+		ns, err := sc.kubectl.GetResource(sc.getContext(), sc.config, schema.GroupVersionKind{Version: "v1", Kind: kube.NamespaceKind}, name, metav1.NamespaceNone)
+		if apierr.IsNotFound(err) || (err == nil && ns == nil) {
+			// the namespace is created by the sync or the apply of its resources fails
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		phase, _, _ := unstructured.NestedString(ns.Object, "status", "phase")
+		if phase == "" && ns.GetDeletionTimestamp() != nil {
+			phase = string(v1.NamespaceTerminating)
+		}
+		if phase != "" && phase != string(v1.NamespaceActive) {
+			inactive = append(inactive, fmt.Sprintf("%s (%s)", name, phase))
+		}
+	}
+	sort.Strings(inactive)
+	return inactive, nil
+}
+
 func (sc *syncContext) shouldUseServerSideApply(targetObj *unstructured.Unstructured) bool {
 	// if it is a dry run, disable server side apply, as the goal is to validate only the
 	// yaml correctness of the rendered manifests.
@@ -965,6 +2039,14 @@ func (sc *syncContext) shouldUseServerSideApply(targetObj *unstructured.Unstruct
 	return sc.serverSideApply || resourceutil.HasAnnotationOption(targetObj, common.AnnotationSyncOptions, common.SyncOptionServerSideApply)
 }
 
+// getServerSideApplyManager returns the field manager used to apply the given task
+func (sc *syncContext) getServerSideApplyManager(t *syncTask) string {
+	if manager, ok := sc.serverSideApplyManagersPerWave[t.wave()]; ok {
+		return manager
+	}
+	return sc.serverSideApplyManager
+}
+
 func (sc *syncContext) applyObject(t *syncTask, dryRun, validate bool) (common.ResultCode, string) {
 	dryRunStrategy := cmdutil.DryRunNone
 	if dryRun {
@@ -976,33 +2058,66 @@ func (sc *syncContext) applyObject(t *syncTask, dryRun, validate bool) (common.R
 		dryRunStrategy = cmdutil.DryRunClient
 	}
 
-	var err error
-	var message string
 	shouldReplace := sc.replace || resourceutil.HasAnnotationOption(t.targetObj, common.AnnotationSyncOptions, common.SyncOptionReplace)
 	force := sc.force || resourceutil.HasAnnotationOption(t.targetObj, common.AnnotationSyncOptions, common.SyncOptionForce)
 	serverSideApply := sc.shouldUseServerSideApply(t.targetObj)
-	if shouldReplace {
-		if t.liveObj != nil {
-			// Avoid using `kubectl replace` for CRDs since 'replace' might recreate resource and so delete all CRD instances.
-			// The same thing applies for namespaces, which would delete the namespace as well as everything within it,
-			// so we want to avoid using `kubectl replace` in that case as well.
-			if kube.IsCRD(t.targetObj) || t.targetObj.GetKind() == kubeutil.NamespaceKind {
-				update := t.targetObj.DeepCopy()
-				update.SetResourceVersion(t.liveObj.GetResourceVersion())
-				_, err = sc.resourceOps.UpdateResource(context.TODO(), update, dryRunStrategy)
-				if err == nil {
-					message = fmt.Sprintf("%s/%s updated", t.targetObj.GetKind(), t.targetObj.GetName())
+	apply := func(target *unstructured.Unstructured) (message string, err error) {
+		if shouldReplace {
+			if t.liveObj != nil {
+				// Avoid using `kubectl replace` for CRDs since 'replace' might recreate resource and so delete all CRD instances.
+				// The same thing applies for namespaces, which would delete the namespace as well as everything within it,
+				// so we want to avoid using `kubectl replace` in that case as well.
+				if kube.IsCRD(target) || target.GetKind() == kubeutil.NamespaceKind {
+					update := target.DeepCopy()
+					update.SetResourceVersion(t.liveObj.GetResourceVersion())
+					_, err = sc.resourceOps.UpdateResource(sc.getContext(), update, dryRunStrategy)
+					if err == nil {
+						message = fmt.Sprintf("%s/%s updated", target.GetKind(), target.GetName())
+					} else {
+						message = fmt.Sprintf("error when updating: %v", err.Error())
+					}
 				} else {
-					message = fmt.Sprintf("error when updating: %v", err.Error())
+					message, err = sc.resourceOps.ReplaceResource(sc.getContext(), target, dryRunStrategy, force)
 				}
 			} else {
-				message, err = sc.resourceOps.ReplaceResource(context.TODO(), t.targetObj, dryRunStrategy, force)
+				message, err = sc.resourceOps.CreateResource(sc.getContext(), target, dryRunStrategy, validate)
 			}
 		} else {
-			message, err = sc.resourceOps.CreateResource(context.TODO(), t.targetObj, dryRunStrategy, validate)
+			message, err = sc.resourceOps.ApplyResource(sc.getContext(), target, dryRunStrategy, force, validate, serverSideApply, sc.getServerSideApplyManager(t), false)
+		}
+		if t.liveObj != nil && isNotFoundErr(err) && !isTypeNotServedErr(err) {
+			// the resource has been deleted by another actor since the live state was observed, so create it again
+			recreated := target.DeepCopy()
+			recreated.SetResourceVersion("")
+			message, err = sc.resourceOps.CreateResource(sc.getContext(), recreated, dryRunStrategy, validate)
+			if err == nil {
+				message = fmt.Sprintf("%s/%s re-created (deleted during sync)", target.GetKind(), target.GetName())
+			}
 		}
+		return message, err
+	}
+
+	var message string
+	var err error
+	if sc.optimisticLock && t.liveObj != nil && !dryRun {
+		message, err = sc.applyWithOptimisticLock(t, apply)
 	} else {
-		message, err = sc.resourceOps.ApplyResource(context.TODO(), t.targetObj, dryRunStrategy, force, validate, serverSideApply, sc.serverSideApplyManager, false)
+		message, err = apply(t.targetObj)
+	}
+	if isTypeNotServedErr(err) && !dryRun && sc.hasCRDOfGroupKind(t.group(), t.kind()) {
+		// the API server might not serve the type of a custom resource yet even if its CRD, which is part of the
+		// sync, is already established, so retry for a bounded duration
+		sc.log.WithValues("task", t).V(1).Info("Custom resource type is not served yet, retrying apply")
+		_ = wait.PollUntilContextTimeout(sc.getContext(), crdServedRetryInterval, crdServedTimeout, false, func(ctx context.Context) (bool, error) {
+			select {
+			case <-ctx.Done():
+				// keep the error of the last attempt, the operation is cancelled anyway
+				return false, ctx.Err()
+			default:
+			}
+			message, err = apply(t.targetObj)
+			return !isTypeNotServedErr(err), nil
+		})
 	}
 	if err != nil {
 		return common.ResultCodeSyncFailed, err.Error()
@@ -1016,20 +2131,109 @@ func (sc *syncContext) applyObject(t *syncTask, dryRun, validate bool) (common.R
 	return common.ResultCodeSynced, message
 }
 
+// applyWithOptimisticLock applies the target object with the resource version of the live object, so the update fails
+// with a conflict if the resource has been modified concurrently. Conflicts are retried with the resource version of
+// the current live resource.
+func (sc *syncContext) applyWithOptimisticLock(t *syncTask, apply func(target *unstructured.Unstructured) (string, error)) (message string, err error) {
+	backoff := sc.conflictRetry
+	if backoff.Steps == 0 {
+		backoff = retry.DefaultRetry
+	}
+	resourceVersion := t.liveObj.GetResourceVersion()
+	err = retry.OnError(backoff, isConflictErr, func() error {
+		target := t.targetObj.DeepCopy()
+		target.SetResourceVersion(resourceVersion)
+		message, err = apply(target)
+		if !isConflictErr(err) {
+			return err
+		}
+		live, getErr := sc.kubectl.GetResource(sc.getContext(), sc.config, t.groupVersionKind(), t.name(), t.namespace())
+		if getErr != nil {
+			return fmt.Errorf("failed to get live resource after conflict: %w", getErr)
+		}
+		sc.log.WithValues("task", t, "resourceVersion", resourceVersion, "currentResourceVersion", live.GetResourceVersion()).V(1).Info("Resource was modified concurrently, retrying with fresh state")
+		resourceVersion = live.GetResourceVersion()
+		t.liveObj = live
+		return err
+	})
+	return message, err
+}
+
+// getExpectedLiveConflict returns a message and true if the current resource version of the task's live resource
+// does not match the version expected by WithExpectedLive. Resources without an expected version are not checked.
+func (sc *syncContext) getExpectedLiveConflict(t *syncTask, dryRun bool) (string, bool) {
+	expected, ok := sc.expectedLive[t.resourceKey()]
+	if !ok || dryRun {
+		return "", false
+	}
+	live, err := sc.kubectl.GetResource(sc.getContext(), sc.config, t.groupVersionKind(), t.name(), t.namespace())
+	if err != nil && !apierr.IsNotFound(err) {
+		return fmt.Sprintf("failed to verify live resource version: %v", err), true
+	}
+	actual := ""
+	if err == nil && live != nil {
+		actual = live.GetResourceVersion()
+	}
+	if actual != expected {
+		return fmt.Sprintf("live resource version conflict: expected '%s', found '%s'", expected, actual), true
+	}
+	return "", false
+}
+
+// isPruneAllowed returns true if resources of the given kind may be pruned according to the allowed and denied kinds
+func (sc *syncContext) isPruneAllowed(gk schema.GroupKind) bool {
+	for _, denied := range sc.pruneDeniedKinds {
+		if denied == gk {
+			return false
+		}
+	}
+	if len(sc.pruneAllowedKinds) == 0 {
+		return true
+	}
+	for _, allowed := range sc.pruneAllowedKinds {
+		if allowed == gk {
+			return true
+		}
+	}
+	return false
+}
+
+// isWithinPruneGracePeriod returns true if the given resource was created less than the prune grace period ago
+func (sc *syncContext) isWithinPruneGracePeriod(liveObj *unstructured.Unstructured) bool {
+	created := liveObj.GetCreationTimestamp()
+	return sc.pruneGracePeriod > 0 && !created.IsZero() && time.Since(created.Time) < sc.pruneGracePeriod
+}
+
+// willPrune returns true if the given live resource is pruned by the sync, i.e. it is not skipped by pruneObject
+func (sc *syncContext) willPrune(liveObj *unstructured.Unstructured) bool {
+	return sc.prune && sc.isPruneAllowed(liveObj.GroupVersionKind().GroupKind()) &&
+		!resourceutil.HasAnnotationOption(liveObj, common.AnnotationSyncOptions, common.SyncOptionDisablePrune) &&
+		!sc.isWithinPruneGracePeriod(liveObj)
+}
+
 // pruneObject deletes the object if both prune is true and dryRun is false. Otherwise appropriate message
 func (sc *syncContext) pruneObject(liveObj *unstructured.Unstructured, prune, dryRun bool) (common.ResultCode, string) {
 	if !prune {
 		return common.ResultCodePruneSkipped, "ignored (requires pruning)"
+	} else if !sc.isPruneAllowed(liveObj.GroupVersionKind().GroupKind()) {
+		return common.ResultCodePruneSkipped, "ignored (kind denied)"
 	} else if resourceutil.HasAnnotationOption(liveObj, common.AnnotationSyncOptions, common.SyncOptionDisablePrune) {
 		return common.ResultCodePruneSkipped, "ignored (no prune)"
+	} else if sc.isWithinPruneGracePeriod(liveObj) {
+		return common.ResultCodePruneSkipped, "ignored (within grace period)"
+	} else if sc.softPrune {
+		return sc.markPendingPrune(liveObj, dryRun)
 	} else {
 		if dryRun {
-			return common.ResultCodePruned, "pruned (dry run)"
+			return common.ResultCodePruned, sc.pruneDryRunMessage(liveObj)
 		} else {
 			// Skip deletion if object is already marked for deletion, so we don't cause a resource update hotloop
 			deletionTimestamp := liveObj.GetDeletionTimestamp()
 			if deletionTimestamp == nil || deletionTimestamp.IsZero() {
-				err := sc.kubectl.DeleteResource(context.TODO(), sc.config, liveObj.GroupVersionKind(), liveObj.GetName(), liveObj.GetNamespace(), sc.getDeleteOptions())
+				err := sc.kubectl.DeleteResource(sc.getContext(), sc.config, liveObj.GroupVersionKind(), liveObj.GetName(), liveObj.GetNamespace(), sc.getDeleteOptions())
+				if isNotFoundErr(err) {
+					return common.ResultCodePruned, "pruned (already deleted)"
+				}
 				if err != nil {
 					return common.ResultCodeSyncFailed, err.Error()
 				}
@@ -1039,6 +2243,89 @@ func (sc *syncContext) pruneObject(liveObj *unstructured.Unstructured, prune, dr
 	}
 }
 
+// isPendingPrune returns true if the given resource has been marked for pruning by a sync with soft prune enabled
+func isPendingPrune(obj *unstructured.Unstructured) bool {
+	if obj == nil {
+		return false
+	}
+	_, ok := obj.GetAnnotations()[common.AnnotationPendingPrune]
+	return ok
+}
+
+// markPendingPrune annotates the given resource with the time it was marked for pruning instead of deleting it
+func (sc *syncContext) markPendingPrune(liveObj *unstructured.Unstructured, dryRun bool) (common.ResultCode, string) {
+	if dryRun {
+		return common.ResultCodePruneSkipped, "marked for pruning (dry run)"
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{common.AnnotationPendingPrune: time.Now().UTC().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		return common.ResultCodeSyncFailed, err.Error()
+	}
+	_, err = sc.kubectl.PatchResource(sc.getContext(), sc.config, liveObj.GroupVersionKind(), liveObj.GetName(), liveObj.GetNamespace(), types.MergePatchType, patch)
+	if isNotFoundErr(err) {
+		return common.ResultCodePruned, "pruned (already deleted)"
+	}
+	if err != nil {
+		return common.ResultCodeSyncFailed, err.Error()
+	}
+	return common.ResultCodePruneSkipped, "marked for pruning (pending confirmation)"
+}
+
+// pruneDryRunMessage returns the message of a dry-run prune that includes the reasons the deletion of the given
+// resource would be blocked if the prune finalizer check is enabled
+func (sc *syncContext) pruneDryRunMessage(liveObj *unstructured.Unstructured) string {
+	message := "pruned (dry run)"
+	if !sc.pruneFinalizerCheck {
+		return message
+	}
+	var blockers []string
+	if finalizers := liveObj.GetFinalizers(); len(finalizers) > 0 {
+		blockers = append(blockers, fmt.Sprintf("deletion requires finalizers to run: %s", strings.Join(finalizers, ", ")))
+	}
+	if sc.pruneDryRunDelete {
+		deleteOptions := sc.getDeleteOptions()
+		deleteOptions.DryRun = []string{metav1.DryRunAll}
+		err := sc.kubectl.DeleteResource(sc.getContext(), sc.config, liveObj.GroupVersionKind(), liveObj.GetName(), liveObj.GetNamespace(), deleteOptions)
+		if err != nil && !isNotFoundErr(err) {
+			blockers = append(blockers, fmt.Sprintf("dry-run deletion failed: %v", err))
+		}
+	}
+	if len(blockers) > 0 {
+		message = fmt.Sprintf("%s, %s", message, strings.Join(blockers, "; "))
+	}
+	return message
+}
+
+// isNotFoundErr returns true if the error indicates that the resource does not exist. Errors of kubectl commands
+// are flattened into plain strings, so the kubectl error message is checked as well.
+func isNotFoundErr(err error) bool {
+	return err != nil && (apierr.IsNotFound(err) || strings.Contains(err.Error(), "(NotFound)"))
+}
+
+// isConflictErr returns true if the error indicates that the resource has been modified concurrently
+func isConflictErr(err error) bool {
+	return err != nil && (apierr.IsConflict(err) || strings.Contains(err.Error(), "(Conflict)"))
+}
+
+// isTypeNotServedErr returns true if the error indicates that the API server does not serve the type of the resource.
+// Other not found errors, e.g. of a missing namespace, are not considered.
+func isTypeNotServedErr(err error) bool {
+	return err != nil && (meta.IsNoMatchError(err) || strings.Contains(err.Error(), "no matches for kind") ||
+		strings.Contains(err.Error(), "the server could not find the requested resource"))
+}
+
+// getContext returns the context of the sync operation set using WithContext, or context.Background() if none is set
+func (sc *syncContext) getContext() context.Context {
+	if sc.operationContext == nil {
+		return context.Background()
+	}
+	return sc.operationContext
+}
+
 func (sc *syncContext) getDeleteOptions() metav1.DeleteOptions {
 	propagationPolicy := metav1.DeletePropagationForeground
 	if sc.prunePropagationPolicy != nil {
@@ -1088,6 +2375,9 @@ func (sc *syncContext) hasCRDOfGroupKind(group string, kind string) bool {
 func (sc *syncContext) Terminate() {
 	terminateSuccessful := true
 	sc.log.V(1).Info("terminating")
+	if sc.stateStore != nil && sc.operationID != "" {
+		defer sc.saveState()
+	}
 	tasks, _ := sc.getSyncTasks()
 	for _, task := range tasks {
 		if !task.isHook() || task.liveObj == nil {
@@ -1123,7 +2413,7 @@ func (sc *syncContext) deleteResource(task *syncTask) error {
 	if err != nil {
 		return err
 	}
-	return resIf.Delete(context.TODO(), task.name(), sc.getDeleteOptions())
+	return resIf.Delete(sc.getContext(), task.name(), sc.getDeleteOptions())
 }
 
 func (sc *syncContext) getResourceIf(task *syncTask, verb string) (dynamic.ResourceInterface, error) {
@@ -1141,6 +2431,7 @@ var operationPhases = map[common.ResultCode]common.OperationPhase{
 	common.ResultCodeSyncFailed:   common.OperationFailed,
 	common.ResultCodePruned:       common.OperationSucceeded,
 	common.ResultCodePruneSkipped: common.OperationSucceeded,
+	common.ResultCodeHookSkipped:  common.OperationSucceeded,
 }
 
 // tri-state
@@ -1188,7 +2479,7 @@ func (sc *syncContext) runTasks(tasks syncTasks, dryRun bool) runState {
 			}
 		}
 
-		ss := newStateSync(state)
+		ss := newLimitedStateSync(state, sc.maxConcurrentDeletes)
 		for _, task := range pruneTasks {
 			t := task
 			ss.Go(func(state runState) runState {
@@ -1215,7 +2506,7 @@ func (sc *syncContext) runTasks(tasks syncTasks, dryRun bool) runState {
 	// delete anything that need deleting
 	hooksPendingDeletion := createTasks.Filter(func(t *syncTask) bool { return t.deleteBeforeCreation() })
 	if hooksPendingDeletion.Len() > 0 {
-		ss := newStateSync(state)
+		ss := newLimitedStateSync(state, sc.maxConcurrentDeletes)
 		for _, task := range hooksPendingDeletion {
 			t := task
 			ss.Go(func(state runState) runState {
@@ -1273,10 +2564,20 @@ func (sc *syncContext) processCreateTasks(state runState, tasks syncTasks, dryRu
 			logCtx := sc.log.WithValues("dryRun", dryRun, "task", t)
 			logCtx.V(1).Info("Applying")
 			validate := sc.validate && !resourceutil.HasAnnotationOption(t.targetObj, common.AnnotationSyncOptions, common.SyncOptionsDisableValidation)
-			result, message := sc.applyObject(t, dryRun, validate)
+			var result common.ResultCode
+			var message string
+			if conflict, ok := sc.getExpectedLiveConflict(t, dryRun); ok {
+				result, message = common.ResultCodeSyncFailed, conflict
+			} else if sc.dryRun && sc.serverDryRunOperations && !t.isHook() {
+				result, message = sc.applyServerDryRun(t)
+			} else {
+				result, message = sc.applyObject(t, dryRun, validate)
+			}
 			if result == common.ResultCodeSyncFailed {
 				logCtx.WithValues("message", message).Info("Apply failed")
 				state = failed
+			} else if !dryRun {
+				sc.recordSyncLoopChange(t)
 			}
 			if !dryRun || sc.dryRun || result == common.ResultCodeSyncFailed {
 				phase := operationPhases[result]
@@ -1345,6 +2646,8 @@ type stateSync struct {
 	wg           sync.WaitGroup
 	results      chan runState
 	currentState runState
+	// limits the number of functions running concurrently, nil if unbounded
+	slots chan struct{}
 }
 
 func newStateSync(currentState runState) *stateSync {
@@ -1354,10 +2657,24 @@ func newStateSync(currentState runState) *stateSync {
 	}
 }
 
+// newLimitedStateSync returns a stateSync that runs at most limit functions at the same time, or any number of
+// functions if limit is zero or negative
+func newLimitedStateSync(currentState runState, limit int) *stateSync {
+	s := newStateSync(currentState)
+	if limit > 0 {
+		s.slots = make(chan struct{}, limit)
+	}
+	return s
+}
+
 func (s *stateSync) Go(f func(runState) runState) {
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
+		if s.slots != nil {
+			s.slots <- struct{}{}
+			defer func() { <-s.slots }()
+		}
 		s.results <- f(s.currentState)
 	}()
 }
@@ -8,6 +8,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -16,17 +20,22 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/discovery"
 	fakedisco "k8s.io/client-go/discovery/fake"
 	"k8s.io/client-go/dynamic/fake"
+	fakeclientset "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/rest"
 	testcore "k8s.io/client-go/testing"
 	"k8s.io/klog/v2/textlogger"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
 
 	"github.com/argoproj/gitops-engine/pkg/diff"
 	"github.com/argoproj/gitops-engine/pkg/health"
@@ -46,15 +55,16 @@ func newTestSyncCtx(getResourceFunc *func(ctx context.Context, config *rest.Conf
 		&v1.APIResourceList{
 			GroupVersion: "v1",
 			APIResources: []v1.APIResource{
-				{Kind: "Pod", Group: "", Version: "v1", Namespaced: true, Verbs: standardVerbs},
-				{Kind: "Service", Group: "", Version: "v1", Namespaced: true, Verbs: standardVerbs},
-				{Kind: "Namespace", Group: "", Version: "v1", Namespaced: false, Verbs: standardVerbs},
+				{Kind: "Pod", Name: "pods", Group: "", Version: "v1", Namespaced: true, Verbs: standardVerbs},
+				{Kind: "Service", Name: "services", Group: "", Version: "v1", Namespaced: true, Verbs: standardVerbs},
+				{Kind: "Namespace", Name: "namespaces", Group: "", Version: "v1", Namespaced: false, Verbs: standardVerbs},
+				{Kind: "ConfigMap", Name: "configmaps", Group: "", Version: "v1", Namespaced: true, Verbs: standardVerbs},
 			},
 		},
 		&v1.APIResourceList{
 			GroupVersion: "apps/v1",
 			APIResources: []v1.APIResource{
-				{Kind: "Deployment", Group: "apps", Version: "v1", Namespaced: true, Verbs: standardVerbs},
+				{Kind: "Deployment", Name: "deployments", Group: "apps", Version: "v1", Namespaced: true, Verbs: standardVerbs},
 			},
 		})
 	sc := syncContext{
@@ -121,6 +131,507 @@ func TestSyncNotPermittedNamespace(t *testing.T) {
 	assert.Contains(t, resources[0].Message, "not permitted in project")
 }
 
+func TestSyncPreflightRBAC(t *testing.T) {
+	syncCtx := newTestSyncCtx(nil, WithPreflightRBAC(true))
+	fakeClientset := fakeclientset.NewSimpleClientset()
+	var reviewed []authorizationv1.ResourceAttributes
+	fakeClientset.PrependReactor("create", "selfsubjectaccessreviews", func(action testcore.Action) (bool, runtime.Object, error) {
+		review := action.(testcore.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		reviewed = append(reviewed, *attributes)
+		review.Status.Allowed = !(attributes.Verb == "create" && attributes.Resource == "pods")
+		return true, review, nil
+	})
+	syncCtx.accessReviews = fakeClientset.AuthorizationV1().SelfSubjectAccessReviews()
+
+	pod := NewPod()
+	pod.SetNamespace(FakeArgoCDNamespace)
+	svc := NewService()
+	svc.SetNamespace(FakeArgoCDNamespace)
+	syncCtx.resources = groupResources(ReconciliationResult{
+		Live:   []*unstructured.Unstructured{nil, svc},
+		Target: []*unstructured.Unstructured{pod, svc},
+	})
+
+	syncCtx.Sync()
+
+	phase, message, resources := syncCtx.GetState()
+	assert.Equal(t, synccommon.OperationFailed, phase)
+	assert.Equal(t, "missing permissions: cannot create pods in namespace "+FakeArgoCDNamespace, message)
+	assert.Empty(t, resources)
+	assert.ElementsMatch(t, []authorizationv1.ResourceAttributes{
+		{Verb: "create", Version: "v1", Resource: "pods", Namespace: FakeArgoCDNamespace},
+		{Verb: "patch", Version: "v1", Resource: "services", Namespace: FakeArgoCDNamespace},
+	}, reviewed)
+}
+
+func TestSyncPreflightRBACSkipsPrunesThatAreNotPerformed(t *testing.T) {
+	runSync := func(opts ...SyncOpt) (synccommon.OperationPhase, string) {
+		syncCtx := newTestSyncCtx(nil, append([]SyncOpt{WithPreflightRBAC(true)}, opts...)...)
+		fakeClientset := fakeclientset.NewSimpleClientset()
+		fakeClientset.PrependReactor("create", "selfsubjectaccessreviews", func(action testcore.Action) (bool, runtime.Object, error) {
+			review := action.(testcore.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+			review.Status.Allowed = review.Spec.ResourceAttributes.Verb != "delete"
+			return true, review, nil
+		})
+		syncCtx.accessReviews = fakeClientset.AuthorizationV1().SelfSubjectAccessReviews()
+		pod := NewPod()
+		pod.SetNamespace(FakeArgoCDNamespace)
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{pod},
+			Target: []*unstructured.Unstructured{nil},
+		})
+		syncCtx.Sync()
+		phase, message, _ := syncCtx.GetState()
+		return phase, message
+	}
+
+	t.Run("PruneDisabled", func(t *testing.T) {
+		phase, _ := runSync()
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
+	})
+
+	t.Run("KindDenied", func(t *testing.T) {
+		phase, _ := runSync(WithPrune(true), WithPruneDeniedKinds(schema.GroupKind{Kind: kube.PodKind}))
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
+	})
+
+	t.Run("PruneEnabled", func(t *testing.T) {
+		phase, message := runSync(WithPrune(true))
+		assert.Equal(t, synccommon.OperationFailed, phase)
+		assert.Equal(t, "missing permissions: cannot delete pods in namespace "+FakeArgoCDNamespace, message)
+	})
+}
+
+func TestSyncPreflightRBACChecksAllRequiredVerbs(t *testing.T) {
+	runSync := func(denied string, opts ...SyncOpt) ([]authorizationv1.ResourceAttributes, string) {
+		syncCtx := newTestSyncCtx(nil, append([]SyncOpt{WithPreflightRBAC(true)}, opts...)...)
+		fakeClientset := fakeclientset.NewSimpleClientset()
+		var reviewed []authorizationv1.ResourceAttributes
+		fakeClientset.PrependReactor("create", "selfsubjectaccessreviews", func(action testcore.Action) (bool, runtime.Object, error) {
+			review := action.(testcore.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+			reviewed = append(reviewed, *review.Spec.ResourceAttributes)
+			review.Status.Allowed = review.Spec.ResourceAttributes.Verb != denied
+			return true, review, nil
+		})
+		syncCtx.accessReviews = fakeClientset.AuthorizationV1().SelfSubjectAccessReviews()
+		syncCtx.Sync()
+		_, message, _ := syncCtx.GetState()
+		return reviewed, message
+	}
+
+	t.Run("HookDeletePolicy", func(t *testing.T) {
+		reviewed, message := runSync("delete", func(ctx *syncContext) {
+			ctx.hookNamespace = "hooks"
+			ctx.hooks = []*unstructured.Unstructured{newHook(synccommon.HookTypePreSync)}
+		})
+		assert.Equal(t, "missing permissions: cannot delete pods in namespace hooks", message)
+		assert.ElementsMatch(t, []authorizationv1.ResourceAttributes{
+			{Verb: "create", Version: "v1", Resource: "pods", Namespace: "hooks"},
+			{Verb: "delete", Version: "v1", Resource: "pods", Namespace: "hooks"},
+		}, reviewed)
+	})
+
+	t.Run("ServerSideApply", func(t *testing.T) {
+		reviewed, message := runSync("patch", WithServerSideApply(true), func(ctx *syncContext) {
+			pod := NewPod()
+			pod.SetNamespace(FakeArgoCDNamespace)
+			ctx.resources = groupResources(ReconciliationResult{
+				Live:   []*unstructured.Unstructured{nil},
+				Target: []*unstructured.Unstructured{pod},
+			})
+		})
+		assert.Equal(t, "missing permissions: cannot patch pods in namespace "+FakeArgoCDNamespace, message)
+		assert.ElementsMatch(t, []authorizationv1.ResourceAttributes{
+			{Verb: "patch", Version: "v1", Resource: "pods", Namespace: FakeArgoCDNamespace},
+			{Verb: "create", Version: "v1", Resource: "pods", Namespace: FakeArgoCDNamespace},
+		}, reviewed)
+	})
+
+	t.Run("ForcedReplace", func(t *testing.T) {
+		reviewed, _ := runSync("", WithReplace(true), WithOperationSettings(false, false, true, false), func(ctx *syncContext) {
+			pod := NewPod()
+			pod.SetNamespace(FakeArgoCDNamespace)
+			ctx.resources = groupResources(ReconciliationResult{
+				Live:   []*unstructured.Unstructured{pod},
+				Target: []*unstructured.Unstructured{pod},
+			})
+		})
+		assert.ElementsMatch(t, []authorizationv1.ResourceAttributes{
+			{Verb: "update", Version: "v1", Resource: "pods", Namespace: FakeArgoCDNamespace},
+			{Verb: "delete", Version: "v1", Resource: "pods", Namespace: FakeArgoCDNamespace},
+			{Verb: "create", Version: "v1", Resource: "pods", Namespace: FakeArgoCDNamespace},
+		}, reviewed)
+	})
+}
+
+func TestSyncDeployID(t *testing.T) {
+	syncCtx := newTestSyncCtx(nil, WithDeployID("deploy-1"))
+	pod := NewPod()
+	pod.SetNamespace(FakeArgoCDNamespace)
+	syncCtx.resources = groupResources(ReconciliationResult{
+		Live:   []*unstructured.Unstructured{nil},
+		Target: []*unstructured.Unstructured{pod},
+	})
+
+	syncCtx.Sync()
+
+	phase, _, _ := syncCtx.GetState()
+	assert.Equal(t, synccommon.OperationSucceeded, phase)
+	resourceOps, _ := syncCtx.resourceOps.(*kubetest.MockResourceOps)
+	applied := resourceOps.GetLastResourceObject(kube.GetResourceKey(pod))
+	require.NotNil(t, applied)
+	assert.Equal(t, "deploy-1", applied.GetAnnotations()[synccommon.AnnotationDeployID])
+	// the original target object must not be modified
+	assert.Empty(t, pod.GetAnnotations())
+}
+
+func TestSyncCommonMetadata(t *testing.T) {
+	runSync := func(overwrite bool) (*unstructured.Unstructured, *unstructured.Unstructured) {
+		syncCtx := newTestSyncCtx(nil,
+			WithCommonLabels(map[string]string{"team": "platform", "cost-center": "42"}, overwrite),
+			WithCommonAnnotations(map[string]string{"owner": "platform@example.com"}, overwrite))
+		pod := NewPod()
+		pod.SetNamespace(FakeArgoCDNamespace)
+		pod.SetLabels(map[string]string{"app": "guestbook", "team": "frontend"})
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{nil},
+			Target: []*unstructured.Unstructured{pod},
+		})
+		syncCtx.Sync()
+		phase, _, _ := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
+		resourceOps, _ := syncCtx.resourceOps.(*kubetest.MockResourceOps)
+		return pod, resourceOps.GetLastResourceObject(kube.GetResourceKey(pod))
+	}
+
+	t.Run("Injected", func(t *testing.T) {
+		pod, applied := runSync(false)
+		require.NotNil(t, applied)
+		assert.Equal(t, map[string]string{"app": "guestbook", "team": "frontend", "cost-center": "42"}, applied.GetLabels())
+		assert.Equal(t, map[string]string{"owner": "platform@example.com"}, applied.GetAnnotations())
+		// the original target object must not be modified
+		assert.Equal(t, map[string]string{"app": "guestbook", "team": "frontend"}, pod.GetLabels())
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		_, applied := runSync(true)
+		require.NotNil(t, applied)
+		assert.Equal(t, map[string]string{"app": "guestbook", "team": "platform", "cost-center": "42"}, applied.GetLabels())
+	})
+
+	t.Run("IgnoredByDiff", func(t *testing.T) {
+		syncCtx := newTestSyncCtx(nil, WithCommonLabels(map[string]string{"team": "platform"}, false))
+		applied := NewPod()
+		applied.SetLabels(map[string]string{"team": "platform"})
+		lastApplied, err := json.Marshal(applied)
+		require.NoError(t, err)
+		live := applied.DeepCopy()
+		live.SetAnnotations(map[string]string{corev1.LastAppliedConfigAnnotation: string(lastApplied)})
+
+		res, err := diff.Diff(NewPod(), live)
+		require.NoError(t, err)
+		assert.True(t, res.Modified)
+		res, err = diff.Diff(NewPod(), live, syncCtx.commonMetadataDiffOptions()...)
+		require.NoError(t, err)
+		assert.False(t, res.Modified)
+	})
+}
+
+func TestSyncSkipUnchangedManifests(t *testing.T) {
+	svc := NewService()
+	svc.SetNamespace(FakeArgoCDNamespace)
+	hash, err := manifestHash(svc, nil)
+	require.NoError(t, err)
+	liveWithHash := func(obj *unstructured.Unstructured, hash string) *unstructured.Unstructured {
+		live := obj.DeepCopy()
+		live.SetAnnotations(map[string]string{synccommon.AnnotationManifestHash: hash})
+		return live
+	}
+	runSync := func(live, target *unstructured.Unstructured, opts ...SyncOpt) (synccommon.OperationPhase, *unstructured.Unstructured) {
+		syncCtx := newTestSyncCtx(nil, append([]SyncOpt{WithSkipUnchangedManifests(true)}, opts...)...)
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{live},
+			Target: []*unstructured.Unstructured{target},
+		})
+		syncCtx.Sync()
+		phase, _, _ := syncCtx.GetState()
+		resourceOps, _ := syncCtx.resourceOps.(*kubetest.MockResourceOps)
+		return phase, resourceOps.GetLastResourceObject(kube.GetResourceKey(target))
+	}
+
+	t.Run("HashAnnotationIsApplied", func(t *testing.T) {
+		phase, applied := runSync(nil, svc)
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
+		require.NotNil(t, applied)
+		assert.Equal(t, hash, applied.GetAnnotations()[synccommon.AnnotationManifestHash])
+		// the original target object must not be modified
+		assert.Empty(t, svc.GetAnnotations())
+	})
+
+	t.Run("UnchangedHashIsSkipped", func(t *testing.T) {
+		phase, applied := runSync(liveWithHash(svc, hash), svc)
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
+		assert.Nil(t, applied)
+	})
+
+	t.Run("ChangedHashIsApplied", func(t *testing.T) {
+		phase, applied := runSync(liveWithHash(svc, "outdated"), svc)
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
+		require.NotNil(t, applied)
+		assert.Equal(t, hash, applied.GetAnnotations()[synccommon.AnnotationManifestHash])
+	})
+
+	t.Run("UnhealthyResourceIsApplied", func(t *testing.T) {
+		pod := NewPod()
+		pod.SetNamespace(FakeArgoCDNamespace)
+		podHash, err := manifestHash(pod, nil)
+		require.NoError(t, err)
+		_, applied := runSync(liveWithHash(pod, podHash), pod)
+		require.NotNil(t, applied)
+	})
+
+	t.Run("ResourceWithoutHealthCheckIsSkipped", func(t *testing.T) {
+		cm := Unstructured(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-config
+  namespace: ` + FakeArgoCDNamespace + `
+data:
+  key: value`)
+		cmHash, err := manifestHash(cm, nil)
+		require.NoError(t, err)
+		phase, applied := runSync(liveWithHash(cm, cmHash), cm)
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
+		assert.Nil(t, applied)
+	})
+
+	t.Run("HashIgnoresExistingAnnotation", func(t *testing.T) {
+		targetHash, err := manifestHash(liveWithHash(svc, "outdated"), nil)
+		require.NoError(t, err)
+		assert.Equal(t, hash, targetHash)
+	})
+
+	t.Run("HashIgnoresIgnoredDifferences", func(t *testing.T) {
+		rules := []diff.IgnoreDifference{{Kind: kube.ServiceKind, JSONPointers: []string{"/spec/selector"}}}
+		ignoredHash, err := manifestHash(svc, rules)
+		require.NoError(t, err)
+		target := svc.DeepCopy()
+		require.NoError(t, unstructured.SetNestedStringMap(target.Object, map[string]string{"app": "changed"}, "spec", "selector"))
+		targetHash, err := manifestHash(target, rules)
+		require.NoError(t, err)
+		assert.Equal(t, ignoredHash, targetHash)
+
+		phase, applied := runSync(liveWithHash(svc, ignoredHash), target, WithManifestHashIgnoreDifferences(rules...))
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
+		assert.Nil(t, applied)
+	})
+
+	t.Run("SkipAvoidsDiff", func(t *testing.T) {
+		// the live resource differs from the target, so a diff would report it as modified and the OnChange hook
+		// would run; since the hash matches, the resource is skipped without computing the diff
+		live := liveWithHash(svc, hash)
+		require.NoError(t, unstructured.SetNestedStringMap(live.Object, map[string]string{"app": "drifted"}, "spec", "selector"))
+		res, err := diff.Diff(svc, live)
+		require.NoError(t, err)
+		require.True(t, res.Modified)
+
+		hook := Annotate(newHook(synccommon.HookTypePostSync), synccommon.AnnotationHookRunPolicy, string(synccommon.HookRunPolicyOnChange))
+		hook.SetName("my-hook")
+		hook.SetNamespace(FakeArgoCDNamespace)
+		syncCtx := newTestSyncCtx(nil, WithSkipUnchangedManifests(true))
+		diffs := 0
+		syncCtx.diffFunc = func(config, live *unstructured.Unstructured, opts ...diff.Option) (*diff.DiffResult, error) {
+			diffs++
+			return diff.Diff(config, live, opts...)
+		}
+		syncCtx.hooks = []*unstructured.Unstructured{hook}
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{live},
+			Target: []*unstructured.Unstructured{svc},
+		})
+		syncCtx.Sync()
+		phase, _, resources := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
+		assert.Zero(t, diffs)
+		for _, res := range resources {
+			assert.NotEqual(t, kube.GetResourceKey(svc), res.ResourceKey)
+			if res.HookType == synccommon.HookTypePostSync {
+				assert.Equal(t, "skipped (no resources changed)", res.Message)
+			}
+		}
+	})
+}
+
+func TestSyncOnChangeHook(t *testing.T) {
+	svc := NewService()
+	svc.SetNamespace(FakeArgoCDNamespace)
+	runSync := func(live *unstructured.Unstructured, opts ...SyncOpt) (cmdutil.DryRunStrategy, *synccommon.ResourceSyncResult) {
+		hook := Annotate(newHook(synccommon.HookTypePostSync), synccommon.AnnotationHookRunPolicy, string(synccommon.HookRunPolicyOnChange))
+		hook.SetName("my-hook")
+		hook.SetNamespace(FakeArgoCDNamespace)
+		syncCtx := newTestSyncCtx(nil, opts...)
+		syncCtx.hooks = []*unstructured.Unstructured{hook}
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{live},
+			Target: []*unstructured.Unstructured{svc},
+		})
+		syncCtx.Sync()
+		_, _, resources := syncCtx.GetState()
+		var hookResult *synccommon.ResourceSyncResult
+		for i := range resources {
+			if resources[i].HookType == synccommon.HookTypePostSync {
+				hookResult = &resources[i]
+			}
+		}
+		resourceOps, _ := syncCtx.resourceOps.(*kubetest.MockResourceOps)
+		dryRunStrategy, ok := resourceOps.GetLastResourceDryRunStrategy(kube.GetResourceKey(hook))
+		require.True(t, ok)
+		return dryRunStrategy, hookResult
+	}
+
+	t.Run("NoOpSyncSkipsHook", func(t *testing.T) {
+		// the hook is only applied by the dry run
+		dryRunStrategy, hookResult := runSync(svc.DeepCopy())
+		assert.Equal(t, cmdutil.DryRunClient, dryRunStrategy)
+		require.NotNil(t, hookResult)
+		assert.Equal(t, synccommon.ResultCodeHookSkipped, hookResult.Status)
+		assert.Equal(t, synccommon.OperationSucceeded, hookResult.HookPhase)
+		assert.Equal(t, "skipped (no resources changed)", hookResult.Message)
+	})
+
+	t.Run("ModificationResultIsReused", func(t *testing.T) {
+		diffs := 0
+		countDiffs := func(ctx *syncContext) {
+			ctx.diffFunc = func(config, live *unstructured.Unstructured, opts ...diff.Option) (*diff.DiffResult, error) {
+				diffs++
+				return diff.Diff(config, live, opts...)
+			}
+		}
+		modificationResults := &diff.DiffArrayResults{Results: []*diff.DiffResult{
+			{Kind: svc.GetKind(), Namespace: svc.GetNamespace(), Name: svc.GetName(), Modified: false},
+		}}
+		_, hookResult := runSync(svc.DeepCopy(), countDiffs, WithResourceModificationChecker(false, modificationResults))
+		require.NotNil(t, hookResult)
+		assert.Equal(t, synccommon.ResultCodeHookSkipped, hookResult.Status)
+		assert.Zero(t, diffs)
+	})
+
+	t.Run("ChangedSyncDoesNotSkipHook", func(t *testing.T) {
+		// the hook is pending until the resources of the sync phase are synced
+		dryRunStrategy, hookResult := runSync(nil)
+		assert.Equal(t, cmdutil.DryRunClient, dryRunStrategy)
+		assert.Nil(t, hookResult)
+	})
+}
+
+func TestSyncHookClassifier(t *testing.T) {
+	classifier := func(obj *unstructured.Unstructured) (synccommon.SyncPhase, bool, []synccommon.HookDeletePolicy) {
+		phase, ok := obj.GetAnnotations()["example.com/hook"]
+		return synccommon.SyncPhase(phase), ok, []synccommon.HookDeletePolicy{synccommon.HookDeletePolicyHookSucceeded}
+	}
+	svc := NewService()
+	svc.SetNamespace(FakeArgoCDNamespace)
+
+	t.Run("CustomHookAnnotation", func(t *testing.T) {
+		hook := Annotate(NewPod(), "example.com/hook", string(synccommon.SyncPhasePreSync))
+		hook.SetNamespace(FakeArgoCDNamespace)
+		syncCtx := newTestSyncCtx(nil, WithHookClassifier(classifier))
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{nil, nil},
+			Target: []*unstructured.Unstructured{hook, svc},
+		})
+		syncCtx.Sync()
+		_, _, resources := syncCtx.GetState()
+		// the service is not synced until the hook completes
+		require.Len(t, resources, 1)
+		assert.Equal(t, "my-pod", resources[0].ResourceKey.Name)
+		assert.Equal(t, synccommon.SyncPhase(synccommon.SyncPhasePreSync), resources[0].SyncPhase)
+		assert.Equal(t, synccommon.HookTypePreSync, resources[0].HookType)
+		assert.Equal(t, synccommon.OperationRunning, resources[0].HookPhase)
+
+		task := &syncTask{phase: synccommon.SyncPhasePreSync, targetObj: hook, hookClassifier: classifier}
+		assert.True(t, task.hasHookDeletePolicy(synccommon.HookDeletePolicyHookSucceeded))
+		assert.False(t, task.hasHookDeletePolicy(synccommon.HookDeletePolicyBeforeHookCreation))
+	})
+
+	t.Run("BuiltInHookAnnotationIgnored", func(t *testing.T) {
+		hook := newHook(synccommon.HookTypePreSync)
+		hook.SetNamespace(FakeArgoCDNamespace)
+		syncCtx := newTestSyncCtx(nil, WithHookClassifier(classifier))
+		syncCtx.hooks = []*unstructured.Unstructured{hook}
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{nil},
+			Target: []*unstructured.Unstructured{svc},
+		})
+		syncCtx.Sync()
+		phase, _, resources := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
+		require.Len(t, resources, 2)
+		for _, res := range resources {
+			assert.Equal(t, synccommon.SyncPhase(synccommon.SyncPhaseSync), res.SyncPhase)
+			assert.Empty(t, res.HookType)
+		}
+	})
+}
+
+func TestSyncResourceGenerator(t *testing.T) {
+	pod := NewPod()
+	pod.SetNamespace(FakeArgoCDNamespace)
+	svc := NewService()
+	svc.SetNamespace(FakeArgoCDNamespace)
+
+	t.Run("GeneratedResourceIsApplied", func(t *testing.T) {
+		syncCtx := newTestSyncCtx(nil, WithResourceGenerator(func(live map[kube.ResourceKey]*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+			var names []string
+			for key := range live {
+				names = append(names, key.String())
+			}
+			sort.Strings(names)
+			summary := Unstructured(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: summary
+  namespace: ` + FakeArgoCDNamespace)
+			require.NoError(t, unstructured.SetNestedField(summary.Object, strings.Join(names, ","), "data", "resources"))
+			return []*unstructured.Unstructured{summary}, nil
+		}))
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{pod, nil},
+			Target: []*unstructured.Unstructured{pod, svc},
+		})
+
+		syncCtx.Sync()
+
+		phase, _, resources := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
+		assert.Len(t, resources, 3)
+		resourceOps, _ := syncCtx.resourceOps.(*kubetest.MockResourceOps)
+		summary := resourceOps.GetLastResourceObject(kube.ResourceKey{Kind: "ConfigMap", Namespace: FakeArgoCDNamespace, Name: "summary"})
+		require.NotNil(t, summary)
+		data, _, _ := unstructured.NestedString(summary.Object, "data", "resources")
+		podKey := kube.GetResourceKey(pod)
+		assert.Equal(t, podKey.String(), data)
+	})
+
+	t.Run("GenerationError", func(t *testing.T) {
+		syncCtx := newTestSyncCtx(nil, WithResourceGenerator(func(_ map[kube.ResourceKey]*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+			return nil, errors.New("boom")
+		}))
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{nil},
+			Target: []*unstructured.Unstructured{svc},
+		})
+
+		syncCtx.Sync()
+
+		phase, message, resources := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationError, phase)
+		assert.Equal(t, "failed to generate resources: boom", message)
+		assert.Empty(t, resources)
+	})
+}
+
 func TestSyncCreateInSortedOrder(t *testing.T) {
 	syncCtx := newTestSyncCtx(nil)
 	syncCtx.resources = groupResources(ReconciliationResult{
@@ -245,6 +756,124 @@ func TestSyncCustomResources(t *testing.T) {
 
 }
 
+func TestSyncCustomResourceTypeNotServedYet(t *testing.T) {
+	cr := testingutils.Unstructured(`
+{
+  "apiVersion": "argoproj.io/v1",
+  "kind": "TestCrd",
+  "metadata": {
+    "name": "my-resource"
+  }
+}
+`)
+
+	t.Run("CRDInSameSync", func(t *testing.T) {
+		syncCtx := newTestSyncCtx(nil)
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{nil, nil},
+			Target: []*unstructured.Unstructured{NewCRD(), cr},
+		})
+		var attempts int32
+		resourceOps, _ := syncCtx.resourceOps.(*kubetest.MockResourceOps)
+		resourceOps.WithApplyResourceFunc(func(_ context.Context, obj *unstructured.Unstructured) (string, error) {
+			if obj.GetKind() == "TestCrd" && atomic.AddInt32(&attempts, 1) < 3 {
+				return "", fmt.Errorf(`no matches for kind "TestCrd" in version "argoproj.io/v1"`)
+			}
+			return "applied", nil
+		})
+
+		result, message := syncCtx.applyObject(&syncTask{phase: synccommon.SyncPhaseSync, targetObj: cr}, false, false)
+		assert.Equal(t, synccommon.ResultCodeSynced, result)
+		assert.Equal(t, "applied", message)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+	})
+
+	t.Run("OtherNotFoundErrorsAreNotRetried", func(t *testing.T) {
+		syncCtx := newTestSyncCtx(nil)
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{nil, nil},
+			Target: []*unstructured.Unstructured{NewCRD(), cr},
+		})
+		var attempts int32
+		resourceOps, _ := syncCtx.resourceOps.(*kubetest.MockResourceOps)
+		resourceOps.WithApplyResourceFunc(func(_ context.Context, _ *unstructured.Unstructured) (string, error) {
+			atomic.AddInt32(&attempts, 1)
+			return "", apierrors.NewNotFound(schema.GroupResource{Resource: "namespaces"}, "missing")
+		})
+
+		result, message := syncCtx.applyObject(&syncTask{phase: synccommon.SyncPhaseSync, targetObj: cr}, false, false)
+		assert.Equal(t, synccommon.ResultCodeSyncFailed, result)
+		assert.Contains(t, message, "not found")
+		assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+	})
+
+	t.Run("CancelledContext", func(t *testing.T) {
+		operationContext, cancel := context.WithCancel(context.Background())
+		cancel()
+		syncCtx := newTestSyncCtx(nil, WithContext(operationContext))
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{nil, nil},
+			Target: []*unstructured.Unstructured{NewCRD(), cr},
+		})
+		var attempts int32
+		resourceOps, _ := syncCtx.resourceOps.(*kubetest.MockResourceOps)
+		resourceOps.WithApplyResourceFunc(func(_ context.Context, _ *unstructured.Unstructured) (string, error) {
+			atomic.AddInt32(&attempts, 1)
+			return "", fmt.Errorf(`no matches for kind "TestCrd" in version "argoproj.io/v1"`)
+		})
+
+		result, _ := syncCtx.applyObject(&syncTask{phase: synccommon.SyncPhaseSync, targetObj: cr}, false, false)
+		assert.Equal(t, synccommon.ResultCodeSyncFailed, result)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+	})
+
+	t.Run("CancelledDuringRetries", func(t *testing.T) {
+		type contextKey struct{}
+		operationContext, cancel := context.WithCancel(context.WithValue(context.Background(), contextKey{}, "sync"))
+		defer cancel()
+		syncCtx := newTestSyncCtx(nil, WithContext(operationContext))
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{nil, nil},
+			Target: []*unstructured.Unstructured{NewCRD(), cr},
+		})
+		var attempts int32
+		resourceOps, _ := syncCtx.resourceOps.(*kubetest.MockResourceOps)
+		resourceOps.WithApplyResourceFunc(func(ctx context.Context, _ *unstructured.Unstructured) (string, error) {
+			// every request is sent using the context of the sync operation
+			assert.Equal(t, "sync", ctx.Value(contextKey{}))
+			if atomic.AddInt32(&attempts, 1) == 2 {
+				cancel()
+			}
+			return "", fmt.Errorf(`no matches for kind "TestCrd" in version "argoproj.io/v1"`)
+		})
+
+		start := time.Now()
+		result, _ := syncCtx.applyObject(&syncTask{phase: synccommon.SyncPhaseSync, targetObj: cr}, false, false)
+		assert.Equal(t, synccommon.ResultCodeSyncFailed, result)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+		assert.Less(t, time.Since(start), crdServedTimeout)
+	})
+
+	t.Run("CRDNotInSync", func(t *testing.T) {
+		syncCtx := newTestSyncCtx(nil)
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{nil},
+			Target: []*unstructured.Unstructured{cr},
+		})
+		var attempts int32
+		resourceOps, _ := syncCtx.resourceOps.(*kubetest.MockResourceOps)
+		resourceOps.WithApplyResourceFunc(func(_ context.Context, _ *unstructured.Unstructured) (string, error) {
+			atomic.AddInt32(&attempts, 1)
+			return "", fmt.Errorf(`no matches for kind "TestCrd" in version "argoproj.io/v1"`)
+		})
+
+		result, message := syncCtx.applyObject(&syncTask{phase: synccommon.SyncPhaseSync, targetObj: cr}, false, false)
+		assert.Equal(t, synccommon.ResultCodeSyncFailed, result)
+		assert.Contains(t, message, "no matches for kind")
+		assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+	})
+}
+
 func TestSyncSuccessfully(t *testing.T) {
 	syncCtx := newTestSyncCtx(nil, WithOperationSettings(false, true, false, false))
 	pod := NewPod()
@@ -422,34 +1051,653 @@ func TestSync_ApplyOutOfSyncOnly(t *testing.T) {
 		}
 	})
 
-	t.Run("applyOutOfSyncOnly=true and syncwaves", func(t *testing.T) {
-		syncCtx = newTestSyncCtx(nil, WithResourceModificationChecker(true, diffResultList()))
-		syncCtx.applyOutOfSyncOnly = true
-		syncCtx.prune = true
-		pod1.SetAnnotations(map[string]string{synccommon.AnnotationSyncWave: "2"})
-		pod2.SetAnnotations(map[string]string{synccommon.AnnotationSyncWave: "3"})
-		pod3.SetAnnotations(map[string]string{synccommon.AnnotationSyncWave: "1"})
+	t.Run("applyOutOfSyncOnly=true and syncwaves", func(t *testing.T) {
+		syncCtx = newTestSyncCtx(nil, WithResourceModificationChecker(true, diffResultList()))
+		syncCtx.applyOutOfSyncOnly = true
+		syncCtx.prune = true
+		pod1.SetAnnotations(map[string]string{synccommon.AnnotationSyncWave: "2"})
+		pod2.SetAnnotations(map[string]string{synccommon.AnnotationSyncWave: "3"})
+		pod3.SetAnnotations(map[string]string{synccommon.AnnotationSyncWave: "1"})
+
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{nil, pod2, pod3},
+			Target: []*unstructured.Unstructured{pod1, nil, pod3},
+		})
+
+		syncCtx.Sync()
+		phase, _, resources := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationRunning, phase)
+		assert.Len(t, resources, 1)
+		assert.Equal(t, "pod-1", resources[0].ResourceKey.Name)
+		assert.Equal(t, synccommon.ResultCodeSynced, resources[0].Status)
+		assert.Equal(t, synccommon.OperationRunning, resources[0].HookPhase)
+
+		syncCtx.Sync()
+		phase, _, resources = syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationRunning, phase)
+		assert.Len(t, resources, 1)
+		assert.Equal(t, "pod-1", resources[0].ResourceKey.Name)
+		assert.Equal(t, synccommon.ResultCodeSynced, resources[0].Status)
+		assert.Equal(t, synccommon.OperationRunning, resources[0].HookPhase)
+	})
+}
+
+func TestSyncInPlaceAPIGroupChange(t *testing.T) {
+	liveIngress := Unstructured(`apiVersion: extensions/v1beta1
+kind: Ingress
+metadata:
+  name: my-ingress
+  namespace: ` + FakeArgoCDNamespace)
+	targetIngress := Unstructured(`apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: my-ingress
+  namespace: ` + FakeArgoCDNamespace)
+	newSyncCtx := func(opts ...SyncOpt) *syncContext {
+		syncCtx := newTestSyncCtx(nil, append(opts, WithPrune(true))...)
+		fakeDisco := syncCtx.disco.(*fakedisco.FakeDiscovery)
+		fakeDisco.Resources = append(fakeDisco.Resources,
+			&v1.APIResourceList{
+				GroupVersion: "extensions/v1beta1",
+				APIResources: []v1.APIResource{
+					{Kind: "Ingress", Name: "ingresses", Group: "extensions", Version: "v1beta1", Namespaced: true, Verbs: standardVerbs},
+				},
+			},
+			&v1.APIResourceList{
+				GroupVersion: "networking.k8s.io/v1",
+				APIResources: []v1.APIResource{
+					{Kind: "Ingress", Name: "ingresses", Group: "networking.k8s.io", Version: "v1", Namespaced: true, Verbs: standardVerbs},
+				},
+			})
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{liveIngress, nil},
+			Target: []*unstructured.Unstructured{nil, targetIngress},
+		})
+		return syncCtx
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		syncCtx := newSyncCtx()
+		syncCtx.Sync()
+		_, _, resources := syncCtx.GetState()
+		require.Len(t, resources, 2)
+		codes := []synccommon.ResultCode{resources[0].Status, resources[1].Status}
+		assert.ElementsMatch(t, []synccommon.ResultCode{synccommon.ResultCodeSynced, synccommon.ResultCodePruned}, codes)
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		syncCtx := newSyncCtx(WithInPlaceAPIGroupChange(true))
+		syncCtx.Sync()
+		phase, _, resources := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
+		require.Len(t, resources, 1)
+		assert.Equal(t, synccommon.ResultCodeSynced, resources[0].Status)
+		assert.Equal(t, "networking.k8s.io", resources[0].ResourceKey.Group)
+		resourceOps, _ := syncCtx.resourceOps.(*kubetest.MockResourceOps)
+		applied := resourceOps.GetLastResourceObject(kube.GetResourceKey(targetIngress))
+		require.NotNil(t, applied)
+		assert.Equal(t, "networking.k8s.io/v1", applied.GetAPIVersion())
+	})
+}
+
+func TestSyncPruneFinalizerCheck(t *testing.T) {
+	newPod := func(name string, finalizers ...string) *unstructured.Unstructured {
+		pod := NewPod()
+		pod.SetName(name)
+		pod.SetNamespace(FakeArgoCDNamespace)
+		pod.SetFinalizers(finalizers)
+		return pod
+	}
+	syncCtx := newTestSyncCtx(nil, WithOperationSettings(true, true, false, false), WithPruneFinalizerCheck(true))
+	syncCtx.kubectl = &kubetest.MockKubectlCmd{
+		Commands: map[string]kubetest.KubectlOutput{
+			"webhook-pod": {Err: fmt.Errorf("admission webhook denied the request")},
+		},
+	}
+	syncCtx.resources = groupResources(ReconciliationResult{
+		Live:   []*unstructured.Unstructured{newPod("finalizer-pod", "example.com/cleanup"), newPod("webhook-pod"), newPod("plain-pod")},
+		Target: []*unstructured.Unstructured{nil, nil, nil},
+	})
+
+	syncCtx.Sync()
+	phase, _, resources := syncCtx.GetState()
+	assert.Equal(t, synccommon.OperationSucceeded, phase)
+	messages := map[string]string{}
+	for _, res := range resources {
+		assert.Equal(t, synccommon.ResultCodePruned, res.Status)
+		messages[res.ResourceKey.Name] = res.Message
+	}
+	assert.Equal(t, "pruned (dry run), deletion requires finalizers to run: example.com/cleanup", messages["finalizer-pod"])
+	assert.Equal(t, "pruned (dry run), dry-run deletion failed: admission webhook denied the request", messages["webhook-pod"])
+	assert.Equal(t, "pruned (dry run)", messages["plain-pod"])
+}
+
+func TestSyncMaxConcurrentDeletes(t *testing.T) {
+	syncCtx := newTestSyncCtx(nil, WithOperationSettings(false, true, false, false), WithMaxConcurrentDeletes(2))
+	var inFlight, maxInFlight, deleted atomic.Int32
+	syncCtx.kubectl = (&kubetest.MockKubectlCmd{}).WithDeleteResourceFunc(func(_ context.Context, _ *rest.Config, _ schema.GroupVersionKind, _ string, _ string, _ metav1.DeleteOptions) error {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			maxCurrent := maxInFlight.Load()
+			if current <= maxCurrent || maxInFlight.CompareAndSwap(maxCurrent, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		deleted.Add(1)
+		return nil
+	})
+	var live, target []*unstructured.Unstructured
+	for i := 0; i < 10; i++ {
+		pod := NewPod()
+		pod.SetName(fmt.Sprintf("pod-%d", i))
+		pod.SetNamespace(FakeArgoCDNamespace)
+		live = append(live, pod)
+		target = append(target, nil)
+	}
+	syncCtx.resources = groupResources(ReconciliationResult{Live: live, Target: target})
+
+	syncCtx.Sync()
+	phase, _, resources := syncCtx.GetState()
+	assert.Equal(t, synccommon.OperationSucceeded, phase)
+	assert.Len(t, resources, 10)
+	assert.Equal(t, int32(10), deleted.Load())
+	assert.Equal(t, int32(2), maxInFlight.Load())
+}
+
+func TestSyncOptimisticLock(t *testing.T) {
+	syncCtx := newTestSyncCtx(nil, WithOptimisticLock(true), WithConflictRetry(wait.Backoff{Steps: 3, Duration: time.Millisecond}))
+	pod := NewPod()
+	pod.SetNamespace(FakeArgoCDNamespace)
+	live := pod.DeepCopy()
+	live.SetResourceVersion("1")
+	syncCtx.resources = groupResources(ReconciliationResult{
+		Live:   []*unstructured.Unstructured{live},
+		Target: []*unstructured.Unstructured{pod},
+	})
+	// the pod is modified concurrently after the live state was observed
+	concurrent := pod.DeepCopy()
+	concurrent.SetResourceVersion("2")
+	syncCtx.kubectl = (&kubetest.MockKubectlCmd{}).WithGetResourceFunc(func(_ context.Context, _ *rest.Config, _ schema.GroupVersionKind, _ string, _ string) (*unstructured.Unstructured, error) {
+		return concurrent, nil
+	})
+	var appliedVersions []string
+	resourceOps, _ := syncCtx.resourceOps.(*kubetest.MockResourceOps)
+	resourceOps.WithApplyResourceFunc(func(_ context.Context, obj *unstructured.Unstructured) (string, error) {
+		appliedVersions = append(appliedVersions, obj.GetResourceVersion())
+		if obj.GetResourceVersion() != concurrent.GetResourceVersion() {
+			return "", apierrors.NewConflict(schema.GroupResource{Resource: "pods"}, obj.GetName(), errors.New("the object has been modified"))
+		}
+		return "pod/my-pod configured", nil
+	})
+
+	result, message := syncCtx.applyObject(&syncTask{phase: synccommon.SyncPhaseSync, targetObj: pod, liveObj: live}, false, true)
+	assert.Equal(t, synccommon.ResultCodeSynced, result)
+	assert.Equal(t, "pod/my-pod configured", message)
+	assert.Equal(t, []string{"1", "2"}, appliedVersions)
+	// the target object itself is not modified
+	assert.Empty(t, pod.GetResourceVersion())
+}
+
+func TestSyncSoftPrune(t *testing.T) {
+	newLivePod := func() *unstructured.Unstructured {
+		pod := NewPod()
+		pod.SetNamespace(FakeArgoCDNamespace)
+		return pod
+	}
+	runSync := func(live *unstructured.Unstructured, opts ...SyncOpt) (map[string]string, int32, []synccommon.ResourceSyncResult) {
+		syncCtx := newTestSyncCtx(nil, append([]SyncOpt{WithOperationSettings(false, true, false, false)}, opts...)...)
+		patches := map[string]string{}
+		var deleted atomic.Int32
+		syncCtx.kubectl = (&kubetest.MockKubectlCmd{}).
+			WithDeleteResourceFunc(func(_ context.Context, _ *rest.Config, _ schema.GroupVersionKind, _ string, _ string, _ metav1.DeleteOptions) error {
+				deleted.Add(1)
+				return nil
+			}).
+			WithPatchResourceFunc(func(_ context.Context, _ *rest.Config, _ schema.GroupVersionKind, name string, _ string, _ types.PatchType, patch []byte) (*unstructured.Unstructured, error) {
+				patches[name] = string(patch)
+				return nil, nil
+			})
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{live},
+			Target: []*unstructured.Unstructured{nil},
+		})
+
+		syncCtx.Sync()
+		phase, _, resources := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
+		return patches, deleted.Load(), resources
+	}
+
+	t.Run("Marked", func(t *testing.T) {
+		patches, deleted, resources := runSync(newLivePod(), WithSoftPrune(true))
+		assert.Equal(t, int32(0), deleted)
+		assert.Contains(t, patches["my-pod"], synccommon.AnnotationPendingPrune)
+		require.Len(t, resources, 1)
+		assert.Equal(t, synccommon.ResultCodePruneSkipped, resources[0].Status)
+		assert.Equal(t, "marked for pruning (pending confirmation)", resources[0].Message)
+	})
+
+	t.Run("SecondSyncExcludesMarked", func(t *testing.T) {
+		patches, _, _ := runSync(newLivePod(), WithSoftPrune(true))
+		var patch struct {
+			Metadata metav1.ObjectMeta `json:"metadata"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(patches["my-pod"]), &patch))
+		markedAt := patch.Metadata.Annotations[synccommon.AnnotationPendingPrune]
+		require.NotEmpty(t, markedAt)
+		marked := Annotate(newLivePod(), synccommon.AnnotationPendingPrune, markedAt)
+
+		patches, deleted, resources := runSync(marked, WithSoftPrune(true))
+		assert.Equal(t, int32(0), deleted)
+		assert.Empty(t, patches)
+		assert.Empty(t, resources)
+	})
+
+	t.Run("Confirmed", func(t *testing.T) {
+		live := Annotate(newLivePod(), synccommon.AnnotationPendingPrune, "2024-01-01T00:00:00Z")
+		patches, deleted, resources := runSync(live)
+		assert.Equal(t, int32(1), deleted)
+		assert.Empty(t, patches)
+		require.Len(t, resources, 1)
+		assert.Equal(t, synccommon.ResultCodePruned, resources[0].Status)
+	})
+}
+
+func TestSyncExpectedLive(t *testing.T) {
+	runSync := func(expectedVersion string) (synccommon.OperationPhase, []synccommon.ResourceSyncResult) {
+		pod := NewPod()
+		pod.SetNamespace(FakeArgoCDNamespace)
+		live := pod.DeepCopy()
+		live.SetResourceVersion("2")
+		syncCtx := newTestSyncCtx(nil, WithExpectedLive(map[kube.ResourceKey]string{kube.GetResourceKey(pod): expectedVersion}))
+		syncCtx.kubectl = (&kubetest.MockKubectlCmd{}).WithGetResourceFunc(func(_ context.Context, _ *rest.Config, _ schema.GroupVersionKind, _ string, _ string) (*unstructured.Unstructured, error) {
+			return live, nil
+		})
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{live},
+			Target: []*unstructured.Unstructured{pod},
+		})
+		syncCtx.Sync()
+		phase, _, resources := syncCtx.GetState()
+		return phase, resources
+	}
+
+	t.Run("DriftedResourceVersion", func(t *testing.T) {
+		phase, resources := runSync("1")
+		assert.Equal(t, synccommon.OperationFailed, phase)
+		require.Len(t, resources, 1)
+		assert.Equal(t, synccommon.ResultCodeSyncFailed, resources[0].Status)
+		assert.Equal(t, "live resource version conflict: expected '1', found '2'", resources[0].Message)
+	})
+
+	t.Run("ExpectedResourceVersion", func(t *testing.T) {
+		phase, resources := runSync("2")
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
+		require.Len(t, resources, 1)
+		assert.Equal(t, synccommon.ResultCodeSynced, resources[0].Status)
+	})
+}
+
+func TestSyncPlan(t *testing.T) {
+	pod := NewPod()
+	pod.SetNamespace(FakeArgoCDNamespace)
+	svc := NewService()
+	svc.SetNamespace(FakeArgoCDNamespace)
+	newSyncCtx := func(liveVersion, liveSelector string, opts ...SyncOpt) *syncContext {
+		liveSvc := svc.DeepCopy()
+		liveSvc.SetResourceVersion(liveVersion)
+		require.NoError(t, unstructured.SetNestedField(liveSvc.Object, liveSelector, "spec", "selector", "app"))
+		syncCtx := newTestSyncCtx(nil, opts...)
+		syncCtx.kubectl = (&kubetest.MockKubectlCmd{}).WithGetResourceFunc(func(_ context.Context, _ *rest.Config, _ schema.GroupVersionKind, name string, _ string) (*unstructured.Unstructured, error) {
+			if name == svc.GetName() {
+				return liveSvc, nil
+			}
+			return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, name)
+		})
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{nil, liveSvc},
+			Target: []*unstructured.Unstructured{pod, svc},
+		})
+		return syncCtx
+	}
+
+	plan, err := newSyncCtx("1", "other-service").Plan()
+	require.NoError(t, err)
+	require.Len(t, plan.Tasks, 2)
+	operations := map[string]PlannedOperation{}
+	for _, task := range plan.Tasks {
+		operations[task.ResourceKey.Name] = task.Operation
+		assert.NotEmpty(t, task.Diff)
+	}
+	assert.Equal(t, map[string]PlannedOperation{pod.GetName(): PlannedOperationCreate, svc.GetName(): PlannedOperationUpdate}, operations)
+
+	data, err := json.Marshal(plan)
+	require.NoError(t, err)
+	var approved SyncPlan
+	require.NoError(t, json.Unmarshal(data, &approved))
+
+	t.Run("Execute", func(t *testing.T) {
+		syncCtx := newSyncCtx("1", "other-service")
+		require.NoError(t, syncCtx.ExecutePlan(&approved))
+		phase, _, resources := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
+		assert.Len(t, resources, 2)
+		// the resource versions of the plan are not verified by subsequent steps of the operation
+		assert.Nil(t, syncCtx.expectedLive)
+	})
+
+	t.Run("ServerDryRunsAreNotRepeated", func(t *testing.T) {
+		newDryRunSyncCtx := func() (*syncContext, *int32) {
+			syncCtx := newSyncCtx("1", "other-service", WithServerDryRunOperations(true))
+			var applies int32
+			syncCtx.resourceOps.(*kubetest.MockResourceOps).WithApplyResourceFunc(func(_ context.Context, obj *unstructured.Unstructured) (string, error) {
+				atomic.AddInt32(&applies, 1)
+				data, err := obj.MarshalJSON()
+				return string(data), err
+			})
+			return syncCtx, &applies
+		}
+		syncCtx, applies := newDryRunSyncCtx()
+		plan, err := syncCtx.Plan()
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(applies))
+
+		syncCtx, applies = newDryRunSyncCtx()
+		require.NoError(t, syncCtx.ExecutePlan(plan))
+		phase, _, _ := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
+		// the client-side dry run and the apply of both resources
+		assert.Equal(t, int32(4), atomic.LoadInt32(applies))
+	})
+
+	t.Run("SecretDataIsHidden", func(t *testing.T) {
+		secret := testingutils.Unstructured(`
+apiVersion: v1
+kind: Secret
+metadata:
+  name: my-secret
+  namespace: fake-argocd-ns
+stringData:
+  password: new-password
+`)
+		liveSecret := testingutils.Unstructured(`
+apiVersion: v1
+kind: Secret
+metadata:
+  name: my-secret
+  namespace: fake-argocd-ns
+data:
+  password: b2xkLXBhc3N3b3Jk
+`)
+		syncCtx := newTestSyncCtx(nil)
+		fakeDisco := syncCtx.disco.(*fakedisco.FakeDiscovery)
+		fakeDisco.Resources[0].APIResources = append(fakeDisco.Resources[0].APIResources,
+			v1.APIResource{Kind: "Secret", Name: "secrets", Group: "", Version: "v1", Namespaced: true, Verbs: standardVerbs})
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{liveSecret},
+			Target: []*unstructured.Unstructured{secret},
+		})
+		plan, err := syncCtx.Plan()
+		require.NoError(t, err)
+		require.Len(t, plan.Tasks, 1)
+		assert.Contains(t, plan.Tasks[0].Diff, "data.password")
+		for _, value := range []string{"new-password", "bmV3LXBhc3N3b3Jk", "old-password", "b2xkLXBhc3N3b3Jk"} {
+			assert.NotContains(t, plan.Tasks[0].Diff, value)
+		}
+	})
+
+	t.Run("ExpectedLiveIsKept", func(t *testing.T) {
+		expectedLive := map[kube.ResourceKey]string{kube.GetResourceKey(pod): "5"}
+		syncCtx := newSyncCtx("1", "other-service", WithExpectedLive(expectedLive))
+		require.NoError(t, syncCtx.ExecutePlan(&approved))
+		assert.Equal(t, expectedLive, syncCtx.expectedLive)
+		phase, _, resources := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationFailed, phase)
+		messages := map[string]string{}
+		for _, res := range resources {
+			messages[res.ResourceKey.Name] = res.Message
+		}
+		assert.Equal(t, "live resource version conflict: expected '5', found ''", messages[pod.GetName()])
+	})
+
+	t.Run("Drifted", func(t *testing.T) {
+		syncCtx := newSyncCtx("2", "other-service")
+		require.Error(t, syncCtx.ExecutePlan(&approved))
+		phase, message, resources := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationFailed, phase)
+		assert.Contains(t, message, "resource version of Sync/0 Service/fake-argocd-ns/my-service changed from '1' to '2'")
+		assert.Empty(t, resources)
+	})
+
+	t.Run("DiffDrifted", func(t *testing.T) {
+		// the resource version is unchanged, e.g. because the live state was observed by a stale cache
+		syncCtx := newSyncCtx("1", "drifted-service")
+		require.Error(t, syncCtx.ExecutePlan(&approved))
+		phase, message, _ := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationFailed, phase)
+		assert.Contains(t, message, "planned changes of Sync/0 Service/fake-argocd-ns/my-service changed")
+	})
+}
+
+func TestSyncServerDryRunOperations(t *testing.T) {
+	newPod := NewPod()
+	newPod.SetNamespace(FakeArgoCDNamespace)
+	changedSvc := NewService()
+	changedSvc.SetNamespace(FakeArgoCDNamespace)
+	liveChangedSvc := changedSvc.DeepCopy()
+	liveChangedSvc.SetResourceVersion("1")
+	require.NoError(t, unstructured.SetNestedField(liveChangedSvc.Object, "other-service", "spec", "selector", "app"))
+	unchangedSvc := NewService()
+	unchangedSvc.SetName("unchanged-service")
+	unchangedSvc.SetNamespace(FakeArgoCDNamespace)
+	liveUnchangedSvc := unchangedSvc.DeepCopy()
+	liveUnchangedSvc.SetResourceVersion("2")
+	liveUnchangedSvc.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "kubectl-client-side-apply", Operation: metav1.ManagedFieldsOperationUpdate}})
+
+	newSyncCtx := func(opts ...SyncOpt) *syncContext {
+		syncCtx := newTestSyncCtx(nil, append(opts, WithServerDryRunOperations(true))...)
+		// the dry run returns the target state with the managed fields of the new field manager
+		syncCtx.resourceOps.(*kubetest.MockResourceOps).WithApplyResourceFunc(func(_ context.Context, obj *unstructured.Unstructured) (string, error) {
+			predicted := obj.DeepCopy()
+			predicted.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "argocd-controller", Operation: metav1.ManagedFieldsOperationApply}})
+			data, err := predicted.MarshalJSON()
+			return string(data), err
+		})
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{nil, liveChangedSvc, liveUnchangedSvc},
+			Target: []*unstructured.Unstructured{newPod, changedSvc, unchangedSvc},
+		})
+		return syncCtx
+	}
+
+	t.Run("Plan", func(t *testing.T) {
+		plan, err := newSyncCtx().Plan()
+		require.NoError(t, err)
+		operations := map[string]DryRunOperation{}
+		for _, task := range plan.Tasks {
+			operations[task.ResourceKey.Name] = task.DryRunOperation
+		}
+		assert.Equal(t, map[string]DryRunOperation{
+			newPod.GetName():       DryRunOperationCreated,
+			changedSvc.GetName():   DryRunOperationConfigured,
+			unchangedSvc.GetName(): DryRunOperationUnchanged,
+		}, operations)
+	})
+
+	t.Run("DryRunSync", func(t *testing.T) {
+		syncCtx := newSyncCtx(WithOperationSettings(true, false, false, false))
+		syncCtx.Sync()
+		phase, _, resources := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
+		messages := map[string]string{}
+		for _, res := range resources {
+			messages[res.ResourceKey.Name] = res.Message
+		}
+		assert.Equal(t, map[string]string{
+			newPod.GetName():       "pod/my-pod created (server dry run)",
+			changedSvc.GetName():   "service/my-service configured (server dry run)",
+			unchangedSvc.GetName(): "service/unchanged-service unchanged (server dry run)",
+		}, messages)
+	})
+}
+
+func TestSyncPruneDeniedKinds(t *testing.T) {
+	syncCtx := newTestSyncCtx(nil, WithOperationSettings(false, true, false, false),
+		WithPruneDeniedKinds(schema.GroupKind{Kind: kube.PersistentVolumeClaimKind}))
+	fakeDisco := syncCtx.disco.(*fakedisco.FakeDiscovery)
+	fakeDisco.Resources[0].APIResources = append(fakeDisco.Resources[0].APIResources,
+		v1.APIResource{Kind: kube.PersistentVolumeClaimKind, Name: "persistentvolumeclaims", Group: "", Version: "v1", Namespaced: true, Verbs: standardVerbs})
+	var deleted []string
+	syncCtx.kubectl = (&kubetest.MockKubectlCmd{}).WithDeleteResourceFunc(func(_ context.Context, _ *rest.Config, _ schema.GroupVersionKind, name string, _ string, _ metav1.DeleteOptions) error {
+		deleted = append(deleted, name)
+		return nil
+	})
+	pvc := Unstructured(`
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: my-data
+  namespace: ` + FakeArgoCDNamespace + `
+spec:
+  accessModes:
+  - ReadWriteOnce
+  resources:
+    requests:
+      storage: 1Gi
+`)
+	pod := NewPod()
+	pod.SetNamespace(FakeArgoCDNamespace)
+	syncCtx.resources = groupResources(ReconciliationResult{
+		Live:   []*unstructured.Unstructured{pvc, pod},
+		Target: []*unstructured.Unstructured{nil, nil},
+	})
+
+	syncCtx.Sync()
+	phase, _, resources := syncCtx.GetState()
+	assert.Equal(t, synccommon.OperationSucceeded, phase)
+	require.Len(t, resources, 2)
+	results := map[string]synccommon.ResourceSyncResult{}
+	for _, res := range resources {
+		results[res.ResourceKey.Kind] = res
+	}
+	assert.Equal(t, synccommon.ResultCodePruneSkipped, results[kube.PersistentVolumeClaimKind].Status)
+	assert.Equal(t, "ignored (kind denied)", results[kube.PersistentVolumeClaimKind].Message)
+	assert.Equal(t, synccommon.ResultCodePruned, results[kube.PodKind].Status)
+	assert.Equal(t, []string{pod.GetName()}, deleted)
+}
+
+func TestSyncPruneGracePeriod(t *testing.T) {
+	syncCtx := newTestSyncCtx(nil, WithOperationSettings(false, true, false, false), WithPruneGracePeriod(time.Minute))
+	var deleted []string
+	syncCtx.kubectl = (&kubetest.MockKubectlCmd{}).WithDeleteResourceFunc(func(_ context.Context, _ *rest.Config, _ schema.GroupVersionKind, name string, _ string, _ metav1.DeleteOptions) error {
+		deleted = append(deleted, name)
+		return nil
+	})
+	recent := NewPod()
+	recent.SetName("recent")
+	recent.SetNamespace(FakeArgoCDNamespace)
+	recent.SetCreationTimestamp(metav1.NewTime(time.Now().Add(-10 * time.Second)))
+	old := NewPod()
+	old.SetName("old")
+	old.SetNamespace(FakeArgoCDNamespace)
+	old.SetCreationTimestamp(metav1.NewTime(time.Now().Add(-time.Hour)))
+	syncCtx.resources = groupResources(ReconciliationResult{
+		Live:   []*unstructured.Unstructured{recent, old},
+		Target: []*unstructured.Unstructured{nil, nil},
+	})
+
+	syncCtx.Sync()
+	phase, _, resources := syncCtx.GetState()
+	assert.Equal(t, synccommon.OperationSucceeded, phase)
+	require.Len(t, resources, 2)
+	results := map[string]synccommon.ResourceSyncResult{}
+	for _, res := range resources {
+		results[res.ResourceKey.Name] = res
+	}
+	assert.Equal(t, synccommon.ResultCodePruneSkipped, results["recent"].Status)
+	assert.Equal(t, "ignored (within grace period)", results["recent"].Message)
+	assert.Equal(t, synccommon.ResultCodePruned, results["old"].Status)
+	assert.Equal(t, []string{"old"}, deleted)
+}
 
+func TestSyncWaveAtomic(t *testing.T) {
+	newPod := func(name string, version string) *unstructured.Unstructured {
+		pod := NewPod()
+		pod.SetName(name)
+		pod.SetNamespace(FakeArgoCDNamespace)
+		pod.SetLabels(map[string]string{"version": version})
+		return pod
+	}
+	existingLive, existingTarget := newPod("existing", "v1"), newPod("existing", "v2")
+	existingLive.SetResourceVersion("123")
+	created := newPod("created", "v2")
+	broken := newPod("broken", "v2")
+
+	runSync := func(waveAtomic bool) (*syncContext, []string) {
+		syncCtx := newTestSyncCtx(nil, WithWaveAtomic(waveAtomic))
+		var lock sync.Mutex
+		var deleted []string
+		applies := map[string]int{}
+		syncCtx.resourceOps = (&kubetest.MockResourceOps{}).WithApplyResourceFunc(func(_ context.Context, obj *unstructured.Unstructured) (string, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			applies[obj.GetName()]++
+			// the first apply is the dry run
+			if obj.GetName() == "broken" && applies[obj.GetName()] > 1 {
+				return "", fmt.Errorf("admission webhook denied the request")
+			}
+			return "", nil
+		})
+		syncCtx.kubectl = (&kubetest.MockKubectlCmd{}).WithDeleteResourceFunc(func(_ context.Context, _ *rest.Config, _ schema.GroupVersionKind, name string, _ string, _ metav1.DeleteOptions) error {
+			lock.Lock()
+			defer lock.Unlock()
+			deleted = append(deleted, name)
+			return nil
+		})
 		syncCtx.resources = groupResources(ReconciliationResult{
-			Live:   []*unstructured.Unstructured{nil, pod2, pod3},
-			Target: []*unstructured.Unstructured{pod1, nil, pod3},
+			Live:   []*unstructured.Unstructured{existingLive, nil, nil},
+			Target: []*unstructured.Unstructured{existingTarget, created, broken},
 		})
-
 		syncCtx.Sync()
-		phase, _, resources := syncCtx.GetState()
-		assert.Equal(t, synccommon.OperationRunning, phase)
-		assert.Len(t, resources, 1)
-		assert.Equal(t, "pod-1", resources[0].ResourceKey.Name)
-		assert.Equal(t, synccommon.ResultCodeSynced, resources[0].Status)
-		assert.Equal(t, synccommon.OperationRunning, resources[0].HookPhase)
+		return syncCtx, deleted
+	}
 
-		syncCtx.Sync()
-		phase, _, resources = syncCtx.GetState()
-		assert.Equal(t, synccommon.OperationRunning, phase)
-		assert.Len(t, resources, 1)
-		assert.Equal(t, "pod-1", resources[0].ResourceKey.Name)
-		assert.Equal(t, synccommon.ResultCodeSynced, resources[0].Status)
-		assert.Equal(t, synccommon.OperationRunning, resources[0].HookPhase)
+	t.Run("Reverted", func(t *testing.T) {
+		syncCtx, deleted := runSync(true)
+		phase, message, resources := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationFailed, phase)
+		assert.Contains(t, message, "reverted the applied objects of sync wave 0 of phase Sync")
+		results := map[string]synccommon.ResourceSyncResult{}
+		for _, res := range resources {
+			results[res.ResourceKey.Name] = res
+		}
+		assert.Equal(t, "admission webhook denied the request", results["broken"].Message)
+		for _, name := range []string{"existing", "created"} {
+			assert.Equal(t, synccommon.ResultCodeSyncFailed, results[name].Status)
+			assert.Equal(t, "reverted after another resource of the wave failed", results[name].Message)
+		}
+		// the created pod is deleted and the updated pod is restored
+		assert.Equal(t, []string{"created"}, deleted)
+		resourceOps := syncCtx.resourceOps.(*kubetest.MockResourceOps)
+		assert.Equal(t, "update", resourceOps.GetLastResourceCommand(kube.GetResourceKey(existingLive)))
+		restored := resourceOps.GetLastResourceObject(kube.GetResourceKey(existingLive))
+		require.NotNil(t, restored)
+		assert.Equal(t, "v1", restored.GetLabels()["version"])
+		assert.Empty(t, restored.GetResourceVersion())
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		syncCtx, deleted := runSync(false)
+		phase, _, resources := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationFailed, phase)
+		assert.Empty(t, deleted)
+		for _, res := range resources {
+			if res.ResourceKey.Name != "broken" {
+				assert.Equal(t, synccommon.ResultCodeSynced, res.Status)
+			}
+		}
 	})
 }
 
@@ -753,6 +2001,72 @@ func withReplaceAnnotation(un *unstructured.Unstructured) *unstructured.Unstruct
 	return un
 }
 
+func TestSyncResourceDeletedDuringSync(t *testing.T) {
+	notFound := func(name string) error {
+		return apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, name)
+	}
+
+	runSync := func(t *testing.T, verb string, opts ...SyncOpt) *syncContext {
+		syncCtx := newTestSyncCtx(nil, opts...)
+		pod := NewPod()
+		pod.SetNamespace(FakeArgoCDNamespace)
+		syncCtx.resourceOps = &kubetest.MockResourceOps{
+			CommandsPerVerb: map[string]map[string]kubetest.KubectlOutput{
+				verb: {pod.GetName(): {Err: notFound(pod.GetName())}},
+			},
+		}
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{pod},
+			Target: []*unstructured.Unstructured{pod},
+		})
+
+		syncCtx.Sync()
+
+		_, _, resources := syncCtx.GetState()
+		require.Len(t, resources, 1)
+		assert.Equal(t, synccommon.ResultCodeSynced, resources[0].Status)
+		assert.Equal(t, "Pod/my-pod re-created (deleted during sync)", resources[0].Message)
+		resourceOps, _ := syncCtx.resourceOps.(*kubetest.MockResourceOps)
+		assert.Equal(t, "create", resourceOps.GetLastResourceCommand(kube.GetResourceKey(pod)))
+		return syncCtx
+	}
+
+	t.Run("Replace", func(t *testing.T) {
+		runSync(t, "replace", WithReplace(true))
+	})
+
+	t.Run("Apply", func(t *testing.T) {
+		runSync(t, "apply")
+	})
+
+	t.Run("ServerSideApply", func(t *testing.T) {
+		syncCtx := runSync(t, "apply", WithServerSideApply(true))
+		resourceOps, _ := syncCtx.resourceOps.(*kubetest.MockResourceOps)
+		assert.True(t, resourceOps.GetLastServerSideApply())
+	})
+
+	t.Run("Prune", func(t *testing.T) {
+		syncCtx := newTestSyncCtx(nil, WithOperationSettings(false, true, false, false))
+		pod := NewPod()
+		pod.SetNamespace(FakeArgoCDNamespace)
+		syncCtx.kubectl = &kubetest.MockKubectlCmd{
+			Commands: map[string]kubetest.KubectlOutput{pod.GetName(): {Err: notFound(pod.GetName())}},
+		}
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{pod},
+			Target: []*unstructured.Unstructured{nil},
+		})
+
+		syncCtx.Sync()
+
+		phase, _, resources := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
+		require.Len(t, resources, 1)
+		assert.Equal(t, synccommon.ResultCodePruned, resources[0].Status)
+		assert.Equal(t, "pruned (already deleted)", resources[0].Message)
+	})
+}
+
 func TestSync_Replace(t *testing.T) {
 	testCases := []struct {
 		name        string
@@ -846,6 +2160,42 @@ func TestSync_ServerSideApply(t *testing.T) {
 	}
 }
 
+func TestSync_ServerSideApplyManagerPerWave(t *testing.T) {
+	testCases := []struct {
+		name    string
+		wave    string
+		manager string
+	}{
+		{"ConfiguredWave", "2", "wave-2-manager"},
+		{"UnconfiguredWave", "1", "global-manager"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			syncCtx := newTestSyncCtx(nil,
+				WithServerSideApplyManager("global-manager"),
+				WithServerSideApplyManagerPerWave(map[int]string{2: "wave-2-manager", 3: "wave-3-manager"}))
+			pod := withServerSideApplyAnnotation(NewPod())
+			pod.SetNamespace(FakeArgoCDNamespace)
+			annotations := pod.GetAnnotations()
+			annotations[synccommon.AnnotationSyncWave] = tc.wave
+			pod.SetAnnotations(annotations)
+			syncCtx.resources = groupResources(ReconciliationResult{
+				Live:   []*unstructured.Unstructured{nil},
+				Target: []*unstructured.Unstructured{pod},
+			})
+
+			syncCtx.Sync()
+
+			resourceOps, _ := syncCtx.resourceOps.(*kubetest.MockResourceOps)
+			assert.True(t, resourceOps.GetLastServerSideApply())
+			assert.Equal(t, tc.manager, resourceOps.GetLastServerSideApplyManager())
+		})
+	}
+}
+
 func withForceAnnotation(un *unstructured.Unstructured) *unstructured.Unstructured {
 	un.SetAnnotations(map[string]string{synccommon.AnnotationSyncOptions: synccommon.SyncOptionForce})
 	return un
@@ -1288,6 +2638,35 @@ func (r resourceNameHealthOverride) GetResourceHealth(obj *unstructured.Unstruct
 	return nil, nil
 }
 
+func TestGetOperationPhase_CustomResourceHook(t *testing.T) {
+	hook := Annotate(Unstructured(`
+apiVersion: example.com/v1
+kind: Migration
+metadata:
+  name: migrate-db
+  namespace: `+FakeArgoCDNamespace+`
+spec:
+  version: 2
+`), synccommon.AnnotationKeyHook, string(synccommon.HookTypePreSync))
+	healthOverride := resourceNameHealthOverride(map[string]health.HealthStatusCode{})
+	syncCtx := newTestSyncCtx(nil, WithHealthOverride(healthOverride))
+
+	healthOverride[hook.GetName()] = health.HealthStatusProgressing
+	phase, _, err := syncCtx.getOperationPhase(hook)
+	require.NoError(t, err)
+	assert.Equal(t, synccommon.OperationRunning, phase)
+
+	healthOverride[hook.GetName()] = health.HealthStatusHealthy
+	phase, _, err = syncCtx.getOperationPhase(hook)
+	require.NoError(t, err)
+	assert.Equal(t, synccommon.OperationSucceeded, phase)
+
+	healthOverride[hook.GetName()] = health.HealthStatusDegraded
+	phase, _, err = syncCtx.getOperationPhase(hook)
+	require.NoError(t, err)
+	assert.Equal(t, synccommon.OperationFailed, phase)
+}
+
 func TestRunSync_HooksNotDeletedIfPhaseNotCompleted(t *testing.T) {
 	completedHook := newHook(synccommon.HookTypePreSync)
 	completedHook.SetName("completed-hook")
@@ -1414,99 +2793,349 @@ func TestRunSync_HooksDeletedAfterPhaseCompletedFailed(t *testing.T) {
 		return true, nil, nil
 	})
 	syncCtx.resources = groupResources(ReconciliationResult{
-		Live:   []*unstructured.Unstructured{completedHook1, completedHook2},
-		Target: []*unstructured.Unstructured{nil, nil},
+		Live:   []*unstructured.Unstructured{completedHook1, completedHook2},
+		Target: []*unstructured.Unstructured{nil, nil},
+	})
+	syncCtx.hooks = []*unstructured.Unstructured{completedHook1, completedHook2}
+
+	syncCtx.kubectl = &kubetest.MockKubectlCmd{
+		Commands: map[string]kubetest.KubectlOutput{},
+	}
+
+	syncCtx.Sync()
+
+	assert.Equal(t, synccommon.OperationFailed, syncCtx.phase)
+	assert.Equal(t, 2, deletedCount)
+}
+
+func Test_syncContext_liveObj(t *testing.T) {
+	type fields struct {
+		compareResult ReconciliationResult
+	}
+	type args struct {
+		obj *unstructured.Unstructured
+	}
+	obj := NewPod()
+	obj.SetNamespace("my-ns")
+
+	found := NewPod()
+	foundNoNamespace := NewPod()
+	foundNoNamespace.SetNamespace("")
+
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   *unstructured.Unstructured
+	}{
+		{"None", fields{compareResult: ReconciliationResult{}}, args{obj: &unstructured.Unstructured{}}, nil},
+		{"Found", fields{compareResult: ReconciliationResult{Target: []*unstructured.Unstructured{nil}, Live: []*unstructured.Unstructured{found}}}, args{obj: obj}, found},
+		{"EmptyNamespace", fields{compareResult: ReconciliationResult{Target: []*unstructured.Unstructured{nil}, Live: []*unstructured.Unstructured{foundNoNamespace}}}, args{obj: obj}, found},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := &syncContext{
+				resources: groupResources(tt.fields.compareResult),
+				hooks:     tt.fields.compareResult.Hooks,
+			}
+			if got := sc.liveObj(tt.args.obj); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("syncContext.liveObj() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_syncContext_hasCRDOfGroupKind(t *testing.T) {
+	// target
+	assert.False(t, (&syncContext{resources: groupResources(ReconciliationResult{
+		Live:   []*unstructured.Unstructured{nil},
+		Target: []*unstructured.Unstructured{NewCRD()},
+	})}).hasCRDOfGroupKind("", ""))
+	assert.True(t, (&syncContext{resources: groupResources(ReconciliationResult{
+		Live:   []*unstructured.Unstructured{nil},
+		Target: []*unstructured.Unstructured{NewCRD()},
+	})}).hasCRDOfGroupKind("argoproj.io", "TestCrd"))
+
+	// hook
+	assert.False(t, (&syncContext{hooks: []*unstructured.Unstructured{NewCRD()}}).hasCRDOfGroupKind("", ""))
+	assert.True(t, (&syncContext{hooks: []*unstructured.Unstructured{NewCRD()}}).hasCRDOfGroupKind("argoproj.io", "TestCrd"))
+}
+
+func Test_setRunningPhase_healthyState(t *testing.T) {
+	var sc syncContext
+	sc.log = textlogger.NewLogger(textlogger.NewConfig()).WithValues("application", "fake-app")
+
+	sc.setRunningPhase([]*syncTask{{targetObj: NewPod()}, {targetObj: NewPod()}, {targetObj: NewPod()}}, false)
+
+	assert.Equal(t, "waiting for healthy state of /Pod/my-pod and 2 more resources", sc.message)
+}
+
+func Test_setRunningPhase_runningHooks(t *testing.T) {
+	var sc syncContext
+	sc.log = textlogger.NewLogger(textlogger.NewConfig()).WithValues("application", "fake-app")
+
+	sc.setRunningPhase([]*syncTask{{targetObj: newHook(synccommon.HookTypeSyncFail)}}, false)
+
+	assert.Equal(t, "waiting for completion of hook /Pod/my-pod", sc.message)
+}
+
+func Test_setRunningPhase_pendingDeletion(t *testing.T) {
+	var sc syncContext
+	sc.log = textlogger.NewLogger(textlogger.NewConfig()).WithValues("application", "fake-app")
+
+	sc.setRunningPhase([]*syncTask{{targetObj: NewPod()}, {targetObj: NewPod()}, {targetObj: NewPod()}}, true)
+
+	assert.Equal(t, "waiting for deletion of /Pod/my-pod and 2 more resources", sc.message)
+}
+
+func TestSyncWaveTimeout(t *testing.T) {
+	syncCtx := newTestSyncCtx(nil, WithWaveTimeout(50*time.Millisecond))
+	pod1 := NewPod()
+	pod1.SetName("pod-1")
+	pod1.SetAnnotations(map[string]string{synccommon.AnnotationSyncWave: "-1"})
+	pod2 := NewPod()
+	pod2.SetName("pod-2")
+	syncCtx.resources = groupResources(ReconciliationResult{
+		Live:   []*unstructured.Unstructured{nil, nil},
+		Target: []*unstructured.Unstructured{pod1, pod2},
+	})
+
+	syncCtx.Sync()
+	phase, _, _ := syncCtx.GetState()
+	assert.Equal(t, synccommon.OperationRunning, phase)
+
+	// the wave is still within its timeout
+	syncCtx.Sync()
+	phase, _, _ = syncCtx.GetState()
+	assert.Equal(t, synccommon.OperationRunning, phase)
+
+	time.Sleep(100 * time.Millisecond)
+	syncCtx.Sync()
+	phase, message, results := syncCtx.GetState()
+	assert.Equal(t, synccommon.OperationFailed, phase)
+	assert.Equal(t, "sync wave -1 of phase Sync exceeded timeout of 50ms, incomplete resources: /Pod/pod-1", message)
+	require.Len(t, results, 1)
+	assert.Equal(t, synccommon.OperationFailed, results[0].HookPhase)
+	assert.Equal(t, "did not complete within the sync wave timeout of 50ms", results[0].Message)
+}
+
+func TestSyncResourceHealthTimeout(t *testing.T) {
+	syncCtx := newTestSyncCtx(nil, WithWaveTimeout(time.Hour))
+	pod1 := NewPod()
+	pod1.SetName("pod-1")
+	pod1.SetAnnotations(map[string]string{synccommon.AnnotationSyncWave: "-1", synccommon.AnnotationHealthTimeout: "50ms"})
+	pod2 := NewPod()
+	pod2.SetName("pod-2")
+	pod2.SetAnnotations(map[string]string{synccommon.AnnotationSyncWave: "-1"})
+	pod3 := NewPod()
+	pod3.SetName("pod-3")
+	syncCtx.resources = groupResources(ReconciliationResult{
+		Live:   []*unstructured.Unstructured{nil, nil, nil},
+		Target: []*unstructured.Unstructured{pod1, pod2, pod3},
+	})
+
+	syncCtx.Sync()
+	phase, _, _ := syncCtx.GetState()
+	assert.Equal(t, synccommon.OperationRunning, phase)
+
+	// the resource is still within its own timeout
+	syncCtx.Sync()
+	phase, _, _ = syncCtx.GetState()
+	assert.Equal(t, synccommon.OperationRunning, phase)
+
+	time.Sleep(100 * time.Millisecond)
+	syncCtx.Sync()
+	phase, message, results := syncCtx.GetState()
+	assert.Equal(t, synccommon.OperationFailed, phase)
+	assert.Equal(t, "sync wave -1 of phase Sync failed, resources exceeded their timeout: /Pod/pod-1 (health timeout 50ms)", message)
+	require.Len(t, results, 2)
+	for _, res := range results {
+		if res.ResourceKey.Name == "pod-1" {
+			assert.Equal(t, synccommon.OperationFailed, res.HookPhase)
+			assert.Equal(t, "did not become healthy within its health timeout of 50ms", res.Message)
+		} else {
+			assert.Equal(t, synccommon.OperationRunning, res.HookPhase)
+		}
+	}
+}
+
+func TestSyncReadinessProbe(t *testing.T) {
+	probeReady := false
+	probe := func(obj *unstructured.Unstructured) (bool, string, error) {
+		if obj.GetName() != "pod-1" || probeReady {
+			return true, "", nil
+		}
+		return false, "waiting for endpoint", nil
+	}
+	syncCtx := newTestSyncCtx(nil,
+		WithHealthOverride(resourceNameHealthOverride{"pod-1": health.HealthStatusHealthy, "pod-2": health.HealthStatusHealthy}),
+		WithReadinessProbe(schema.GroupVersionKind{Version: "v1", Kind: kube.PodKind}, probe))
+	pod1 := NewPod()
+	pod1.SetName("pod-1")
+	pod1.SetAnnotations(map[string]string{synccommon.AnnotationSyncWave: "-1"})
+	pod2 := NewPod()
+	pod2.SetName("pod-2")
+	syncCtx.resources = groupResources(ReconciliationResult{
+		Live:   []*unstructured.Unstructured{nil, nil},
+		Target: []*unstructured.Unstructured{pod1, pod2},
 	})
-	syncCtx.hooks = []*unstructured.Unstructured{completedHook1, completedHook2}
 
-	syncCtx.kubectl = &kubetest.MockKubectlCmd{
-		Commands: map[string]kubetest.KubectlOutput{},
-	}
+	syncCtx.Sync()
+	phase, _, results := syncCtx.GetState()
+	assert.Equal(t, synccommon.OperationRunning, phase)
+	require.Len(t, results, 1)
 
+	// the resource is healthy, but the next wave waits for the probe
+	syncCtx.resources = groupResources(ReconciliationResult{
+		Live:   []*unstructured.Unstructured{pod1, nil},
+		Target: []*unstructured.Unstructured{pod1, pod2},
+	})
 	syncCtx.Sync()
+	phase, _, results = syncCtx.GetState()
+	assert.Equal(t, synccommon.OperationRunning, phase)
+	require.Len(t, results, 1)
+	assert.Equal(t, synccommon.OperationRunning, results[0].HookPhase)
+	assert.Equal(t, "waiting for endpoint", results[0].Message)
 
-	assert.Equal(t, synccommon.OperationFailed, syncCtx.phase)
-	assert.Equal(t, 2, deletedCount)
+	probeReady = true
+	syncCtx.Sync()
+	_, _, results = syncCtx.GetState()
+	require.Len(t, results, 2)
+	for _, res := range results {
+		if res.ResourceKey.Name == "pod-1" {
+			assert.Equal(t, synccommon.OperationSucceeded, res.HookPhase)
+		}
+	}
 }
 
-func Test_syncContext_liveObj(t *testing.T) {
-	type fields struct {
-		compareResult ReconciliationResult
+func TestSyncLoopDetection(t *testing.T) {
+	svc := NewService()
+	svc.SetNamespace(FakeArgoCDNamespace)
+	// a mutating webhook keeps changing the selector of the live service
+	mutatedSvc := svc.DeepCopy()
+	require.NoError(t, unstructured.SetNestedField(mutatedSvc.Object, "mutated", "spec", "selector", "app"))
+	detector := NewSyncLoopDetector(2)
+	runSyncWithApplyErr := func(live *unstructured.Unstructured, applyErr error) (synccommon.OperationPhase, string, []synccommon.ResourceSyncResult) {
+		syncCtx := newTestSyncCtx(nil, WithSyncLoopDetector(detector))
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{live},
+			Target: []*unstructured.Unstructured{svc},
+		})
+		syncCtx.resourceOps.(*kubetest.MockResourceOps).WithApplyResourceFunc(func(_ context.Context, _ *unstructured.Unstructured) (string, error) {
+			return "", applyErr
+		})
+		syncCtx.Sync()
+		return syncCtx.GetState()
 	}
-	type args struct {
-		obj *unstructured.Unstructured
+	runSync := func(live *unstructured.Unstructured) (synccommon.OperationPhase, string, []synccommon.ResourceSyncResult) {
+		return runSyncWithApplyErr(live, nil)
 	}
-	obj := NewPod()
-	obj.SetNamespace("my-ns")
-
-	found := NewPod()
-	foundNoNamespace := NewPod()
-	foundNoNamespace.SetNamespace("")
 
-	tests := []struct {
-		name   string
-		fields fields
-		args   args
-		want   *unstructured.Unstructured
-	}{
-		{"None", fields{compareResult: ReconciliationResult{}}, args{obj: &unstructured.Unstructured{}}, nil},
-		{"Found", fields{compareResult: ReconciliationResult{Target: []*unstructured.Unstructured{nil}, Live: []*unstructured.Unstructured{found}}}, args{obj: obj}, found},
-		{"EmptyNamespace", fields{compareResult: ReconciliationResult{Target: []*unstructured.Unstructured{nil}, Live: []*unstructured.Unstructured{foundNoNamespace}}}, args{obj: obj}, found},
+	// failed syncs do not apply the change, so they are not counted
+	for i := 0; i < 3; i++ {
+		phase, message, _ := runSyncWithApplyErr(mutatedSvc, fmt.Errorf("apply failed"))
+		assert.Equal(t, synccommon.OperationFailed, phase)
+		assert.NotContains(t, message, "sync loop detected")
 	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			sc := &syncContext{
-				resources: groupResources(tt.fields.compareResult),
-				hooks:     tt.fields.compareResult.Hooks,
-			}
-			if got := sc.liveObj(tt.args.obj); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("syncContext.liveObj() = %v, want %v", got, tt.want)
-			}
-		})
+
+	for i := 0; i < 2; i++ {
+		phase, _, _ := runSync(mutatedSvc)
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
 	}
-}
 
-func Test_syncContext_hasCRDOfGroupKind(t *testing.T) {
-	// target
-	assert.False(t, (&syncContext{resources: groupResources(ReconciliationResult{
-		Live:   []*unstructured.Unstructured{nil},
-		Target: []*unstructured.Unstructured{NewCRD()},
-	})}).hasCRDOfGroupKind("", ""))
-	assert.True(t, (&syncContext{resources: groupResources(ReconciliationResult{
-		Live:   []*unstructured.Unstructured{nil},
-		Target: []*unstructured.Unstructured{NewCRD()},
-	})}).hasCRDOfGroupKind("argoproj.io", "TestCrd"))
+	phase, message, results := runSync(mutatedSvc)
+	assert.Equal(t, synccommon.OperationFailed, phase)
+	assert.Contains(t, message, "sync loop detected")
+	require.Len(t, results, 1)
+	assert.Equal(t, synccommon.ResultCodeSyncFailed, results[0].Status)
+	assert.Equal(t, "sync loop detected: the same change would be applied by 3 consecutive syncs, fields that keep changing: spec.selector.app", results[0].Message)
 
-	// hook
-	assert.False(t, (&syncContext{hooks: []*unstructured.Unstructured{NewCRD()}}).hasCRDOfGroupKind("", ""))
-	assert.True(t, (&syncContext{hooks: []*unstructured.Unstructured{NewCRD()}}).hasCRDOfGroupKind("argoproj.io", "TestCrd"))
-}
+	// the detection is reset once the resource is in sync
+	phase, _, _ = runSync(svc.DeepCopy())
+	assert.Equal(t, synccommon.OperationSucceeded, phase)
+	phase, _, _ = runSync(mutatedSvc)
+	assert.Equal(t, synccommon.OperationSucceeded, phase)
 
-func Test_setRunningPhase_healthyState(t *testing.T) {
-	var sc syncContext
-	sc.log = textlogger.NewLogger(textlogger.NewConfig()).WithValues("application", "fake-app")
+	t.Run("CommonMetadataIsIgnored", func(t *testing.T) {
+		detector := NewSyncLoopDetector(1)
+		syncCtx := func() *syncContext {
+			syncCtx := newTestSyncCtx(nil, WithSyncLoopDetector(detector), WithCommonLabels(map[string]string{"team": "a"}, false))
+			syncCtx.resources = groupResources(ReconciliationResult{
+				Live:   []*unstructured.Unstructured{svc.DeepCopy()},
+				Target: []*unstructured.Unstructured{svc},
+			})
+			return syncCtx
+		}
+		for i := 0; i < 3; i++ {
+			syncCtx := syncCtx()
+			syncCtx.Sync()
+			phase, _, _ := syncCtx.GetState()
+			assert.Equal(t, synccommon.OperationSucceeded, phase)
+			assert.Empty(t, syncCtx.syncLoopPatches)
+		}
+	})
+}
 
-	sc.setRunningPhase([]*syncTask{{targetObj: NewPod()}, {targetObj: NewPod()}, {targetObj: NewPod()}}, false)
+type fakeStateStore struct {
+	states map[string]SyncState
+}
 
-	assert.Equal(t, "waiting for healthy state of /Pod/my-pod and 2 more resources", sc.message)
+func (s *fakeStateStore) Save(operationID string, state SyncState) error {
+	s.states[operationID] = state
+	return nil
 }
 
-func Test_setRunningPhase_runningHooks(t *testing.T) {
-	var sc syncContext
-	sc.log = textlogger.NewLogger(textlogger.NewConfig()).WithValues("application", "fake-app")
+func (s *fakeStateStore) Load(operationID string) (*SyncState, error) {
+	state, ok := s.states[operationID]
+	if !ok {
+		return nil, nil
+	}
+	return &state, nil
+}
 
-	sc.setRunningPhase([]*syncTask{{targetObj: newHook(synccommon.HookTypeSyncFail)}}, false)
+func TestSyncResume(t *testing.T) {
+	store := &fakeStateStore{states: map[string]SyncState{}}
+	pod1 := NewPod()
+	pod1.SetName("pod-1")
+	pod1.SetNamespace(FakeArgoCDNamespace)
+	pod1.SetAnnotations(map[string]string{synccommon.AnnotationSyncWave: "-1"})
+	pod2 := NewPod()
+	pod2.SetName("pod-2")
+	pod2.SetNamespace(FakeArgoCDNamespace)
 
-	assert.Equal(t, "waiting for completion of hook /Pod/my-pod", sc.message)
-}
+	syncCtx := newTestSyncCtx(nil, WithStateStore(store, "op-1"))
+	syncCtx.resources = groupResources(ReconciliationResult{
+		Live:   []*unstructured.Unstructured{nil, nil},
+		Target: []*unstructured.Unstructured{pod1, pod2},
+	})
+	syncCtx.Sync()
 
-func Test_setRunningPhase_pendingDeletion(t *testing.T) {
-	var sc syncContext
-	sc.log = textlogger.NewLogger(textlogger.NewConfig()).WithValues("application", "fake-app")
+	// wave -1 has been applied and the state has been stored
+	require.Contains(t, store.states, "op-1")
+	assert.Equal(t, synccommon.OperationRunning, store.states["op-1"].Phase)
+	require.Len(t, store.states["op-1"].Results, 1)
+
+	// simulate a crash: the new sync context observes pod-1 as healthy
+	livePod1 := pod1.DeepCopy()
+	require.NoError(t, unstructured.SetNestedField(livePod1.Object, "Succeeded", "status", "phase"))
+	resumedCtx := newTestSyncCtx(nil, WithStateStore(store, ""))
+	resumedCtx.resources = groupResources(ReconciliationResult{
+		Live:   []*unstructured.Unstructured{livePod1, nil},
+		Target: []*unstructured.Unstructured{pod1, pod2},
+	})
+	require.NoError(t, resumedCtx.Resume("op-1"))
+	resumedCtx.Sync()
 
-	sc.setRunningPhase([]*syncTask{{targetObj: NewPod()}, {targetObj: NewPod()}, {targetObj: NewPod()}}, true)
+	resourceOps, _ := resumedCtx.resourceOps.(*kubetest.MockResourceOps)
+	assert.Empty(t, resourceOps.GetLastResourceCommand(kube.GetResourceKey(pod1)), "completed wave must not be applied again")
+	assert.Equal(t, "apply", resourceOps.GetLastResourceCommand(kube.GetResourceKey(pod2)))
+	phase, _, results := resumedCtx.GetState()
+	assert.Equal(t, synccommon.OperationSucceeded, phase)
+	assert.Len(t, results, 2)
+	assert.Equal(t, synccommon.OperationSucceeded, store.states["op-1"].Phase)
 
-	assert.Equal(t, "waiting for deletion of /Pod/my-pod and 2 more resources", sc.message)
+	assert.Error(t, newTestSyncCtx(nil, WithStateStore(store, "")).Resume("unknown"))
+	assert.Error(t, newTestSyncCtx(nil).Resume("op-1"))
 }
 
 func TestSyncWaveHook(t *testing.T) {
@@ -1580,6 +3209,62 @@ func TestSyncWaveHook(t *testing.T) {
 	assert.True(t, called)
 }
 
+func TestSyncHookWavesWithinPhase(t *testing.T) {
+	syncCtx := newTestSyncCtx(nil, WithOperationSettings(false, false, false, false))
+	newPreSyncHook := func(name, wave string) *unstructured.Unstructured {
+		hook := NewPod()
+		hook.SetName(name)
+		hook.SetAnnotations(map[string]string{synccommon.AnnotationKeyHook: "PreSync", synccommon.AnnotationSyncWave: wave})
+		return hook
+	}
+	// declared in reverse order to verify that the waves rather than the order of the hooks are honored
+	syncCtx.hooks = []*unstructured.Unstructured{newPreSyncHook("migrate-2", "-1"), newPreSyncHook("migrate-1", "-2")}
+	syncCtx.resources = groupResources(ReconciliationResult{
+		Live:   []*unstructured.Unstructured{nil},
+		Target: []*unstructured.Unstructured{NewPod()},
+	})
+	type phaseWave struct {
+		phase synccommon.SyncPhase
+		wave  int
+	}
+	var applied []phaseWave
+	syncCtx.syncWaveHook = func(phase synccommon.SyncPhase, wave int, final bool) error {
+		applied = append(applied, phaseWave{phase, wave})
+		return nil
+	}
+	completeResults := func() {
+		_, _, results := syncCtx.GetState()
+		for _, res := range results {
+			res.HookPhase = synccommon.OperationSucceeded
+			syncCtx.syncRes[resourceResultKey(res.ResourceKey, res.SyncPhase)] = res
+		}
+	}
+
+	syncCtx.Sync()
+	_, _, results := syncCtx.GetState()
+	require.Len(t, results, 1)
+	assert.Equal(t, "migrate-1", results[0].ResourceKey.Name)
+
+	// the hook of the next wave is only started once the previous wave has completed
+	syncCtx.Sync()
+	_, _, results = syncCtx.GetState()
+	assert.Len(t, results, 1)
+
+	completeResults()
+	syncCtx.Sync()
+	_, _, results = syncCtx.GetState()
+	require.Len(t, results, 2)
+	assert.Equal(t, "migrate-2", results[1].ResourceKey.Name)
+
+	completeResults()
+	syncCtx.Sync()
+	assert.Equal(t, []phaseWave{
+		{synccommon.SyncPhasePreSync, -2},
+		{synccommon.SyncPhasePreSync, -1},
+		{synccommon.SyncPhaseSync, 0},
+	}, applied)
+}
+
 func TestSyncWaveHookFail(t *testing.T) {
 	syncCtx := newTestSyncCtx(nil, WithOperationSettings(false, false, false, false))
 	pod1 := NewPod()
@@ -1662,7 +3347,7 @@ func TestPruneLast(t *testing.T) {
 	})
 }
 
-func diffResultList() *diff.DiffResultList {
+func diffResultList() *diff.DiffArrayResults {
 	pod1 := NewPod()
 	pod1.SetName("pod-1")
 	pod1.SetNamespace(FakeArgoCDNamespace)
@@ -1673,21 +3358,21 @@ func diffResultList() *diff.DiffResultList {
 	pod3.SetName("pod-3")
 	pod3.SetNamespace(FakeArgoCDNamespace)
 
-	diffResultList := diff.DiffResultList{
+	diffResults := diff.DiffArrayResults{
 		Modified: true,
-		Diffs:    []diff.DiffResult{},
+		Results:  []*diff.DiffResult{},
 	}
 
 	podBytes, _ := json.Marshal(pod1)
-	diffResultList.Diffs = append(diffResultList.Diffs, diff.DiffResult{NormalizedLive: []byte("null"), PredictedLive: podBytes, Modified: true})
+	diffResults.Results = append(diffResults.Results, &diff.DiffResult{NormalizedLive: []byte("null"), PredictedLive: podBytes, Modified: true})
 
 	podBytes, _ = json.Marshal(pod2)
-	diffResultList.Diffs = append(diffResultList.Diffs, diff.DiffResult{NormalizedLive: podBytes, PredictedLive: []byte("null"), Modified: true})
+	diffResults.Results = append(diffResults.Results, &diff.DiffResult{NormalizedLive: podBytes, PredictedLive: []byte("null"), Modified: true})
 
 	podBytes, _ = json.Marshal(pod3)
-	diffResultList.Diffs = append(diffResultList.Diffs, diff.DiffResult{NormalizedLive: podBytes, PredictedLive: podBytes, Modified: false})
+	diffResults.Results = append(diffResults.Results, &diff.DiffResult{Kind: "Pod", Namespace: FakeArgoCDNamespace, Name: "pod-3", NormalizedLive: podBytes, PredictedLive: podBytes, Modified: false})
 
-	return &diffResultList
+	return &diffResults
 }
 
 func TestSyncContext_GetDeleteOptions_Default(t *testing.T) {
@@ -2039,3 +3724,333 @@ func TestWaitForCleanUpBeforeNextWave(t *testing.T) {
 	assert.Equal(t, synccommon.ResultCodePruned, result[2].Status)
 
 }
+
+func TestSyncApplySet(t *testing.T) {
+	parent := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap"}}
+	parent.SetName("my-applyset")
+	parent.SetNamespace(FakeArgoCDNamespace)
+	id := ApplySetID(parent)
+
+	livePod := func(name string, labels map[string]string) *unstructured.Unstructured {
+		pod := NewPod()
+		pod.SetName(name)
+		pod.SetNamespace(FakeArgoCDNamespace)
+		pod.SetLabels(labels)
+		return pod
+	}
+	member := livePod("member", map[string]string{synccommon.LabelApplySetPartOf: id})
+	orphan := livePod("orphan", map[string]string{synccommon.LabelApplySetPartOf: id})
+	unmanaged := livePod("unmanaged", nil)
+	liveParent := parent.DeepCopy()
+	liveParent.SetLabels(map[string]string{synccommon.LabelApplySetID: id})
+	liveParent.SetAnnotations(map[string]string{synccommon.AnnotationApplySetGroupKinds: "Pod"})
+
+	fakeDynamicClient := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		{Version: "v1", Resource: "pods"}:       "PodList",
+		{Version: "v1", Resource: "services"}:   "ServiceList",
+		{Version: "v1", Resource: "configmaps"}: "ConfigMapList",
+	}, liveParent, member, orphan, unmanaged)
+
+	svc := NewService()
+	svc.SetNamespace(FakeArgoCDNamespace)
+	syncCtx := newTestSyncCtx(nil, WithApplySet(parent), WithPrune(true))
+	syncCtx.dynamicIf = fakeDynamicClient
+	// the orphan is missing from the reconciliation result and is only known as a member of the ApplySet
+	syncCtx.resources = groupResources(ReconciliationResult{
+		Live:   []*unstructured.Unstructured{member, nil},
+		Target: []*unstructured.Unstructured{livePod("member", nil), svc},
+	})
+
+	syncCtx.Sync()
+
+	phase, _, resources := syncCtx.GetState()
+	assert.Equal(t, synccommon.OperationSucceeded, phase)
+	results := map[string]synccommon.ResultCode{}
+	for _, res := range resources {
+		results[res.ResourceKey.Name] = res.Status
+	}
+	assert.Equal(t, map[string]synccommon.ResultCode{
+		"member":     synccommon.ResultCodeSynced,
+		"my-service": synccommon.ResultCodeSynced,
+		"orphan":     synccommon.ResultCodePruned,
+	}, results)
+
+	// the targets are labeled as members without modifying the objects passed by the caller
+	assert.Equal(t, id, syncCtx.resources[kube.GetResourceKey(svc)].Target.GetLabels()[synccommon.LabelApplySetPartOf])
+	assert.Empty(t, svc.GetLabels())
+
+	updatedParent, err := fakeDynamicClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}).
+		Namespace(FakeArgoCDNamespace).Get(context.Background(), "my-applyset", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, id, updatedParent.GetLabels()[synccommon.LabelApplySetID])
+	assert.Equal(t, "Pod,Service", updatedParent.GetAnnotations()[synccommon.AnnotationApplySetGroupKinds])
+	assert.Equal(t, "gitops-engine/v1", updatedParent.GetAnnotations()[synccommon.AnnotationApplySetTooling])
+
+	t.Run("ParentOfAnotherApplySet", func(t *testing.T) {
+		otherParent := liveParent.DeepCopy()
+		otherParent.SetLabels(map[string]string{synccommon.LabelApplySetID: "applyset-other-v1"})
+		syncCtx := newTestSyncCtx(nil, WithApplySet(parent))
+		syncCtx.dynamicIf = fake.NewSimpleDynamicClient(runtime.NewScheme(), otherParent)
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{nil},
+			Target: []*unstructured.Unstructured{svc},
+		})
+		syncCtx.Sync()
+		phase, message, _ := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationError, phase)
+		assert.Contains(t, message, "is the parent of another ApplySet")
+	})
+
+	t.Run("CustomHookClassifier", func(t *testing.T) {
+		migration := livePod("migration", nil)
+		syncCtx := newTestSyncCtx(nil, WithApplySet(parent), WithHookClassifier(func(obj *unstructured.Unstructured) (synccommon.SyncPhase, bool, []synccommon.HookDeletePolicy) {
+			return synccommon.SyncPhasePreSync, obj.GetName() == migration.GetName(), nil
+		}))
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{nil, nil},
+			Target: []*unstructured.Unstructured{migration, svc},
+		})
+
+		syncCtx.labelApplySetMembers()
+		groupKinds, _ := syncCtx.getApplySetTargetMembership()
+
+		// hooks are not members of the ApplySet, so they are not pruned as part of it
+		assert.Empty(t, syncCtx.resources[kube.GetResourceKey(migration)].Target.GetLabels())
+		assert.Equal(t, id, syncCtx.resources[kube.GetResourceKey(svc)].Target.GetLabels()[synccommon.LabelApplySetPartOf])
+		assert.Equal(t, map[schema.GroupKind]bool{{Kind: "Service"}: true}, groupKinds)
+	})
+}
+
+func TestSyncPauseGate(t *testing.T) {
+	signal := make(chan struct{})
+	gateCalls := 0
+	syncCtx := newTestSyncCtx(nil, WithPauseGate(synccommon.SyncPhaseSync, func() <-chan struct{} {
+		gateCalls++
+		return signal
+	}))
+	pod1 := NewPod()
+	pod1.SetName("pod-1")
+	pod1.SetNamespace(FakeArgoCDNamespace)
+	pod2 := NewPod()
+	pod2.SetName("pod-2")
+	pod2.SetNamespace(FakeArgoCDNamespace)
+	syncCtx.resources = groupResources(ReconciliationResult{
+		Live:   []*unstructured.Unstructured{nil, nil},
+		Target: []*unstructured.Unstructured{pod1, pod2},
+	})
+
+	// the operation stays paused until the channel returned by the gate is closed
+	for i := 0; i < 2; i++ {
+		syncCtx.Sync()
+		phase, message, resources := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationRunning, phase)
+		assert.Equal(t, "paused before Sync phase: waiting for the signal to resume", message)
+		assert.Empty(t, resources)
+	}
+	assert.Equal(t, 1, gateCalls)
+
+	close(signal)
+	syncCtx.Sync()
+	phase, _, resources := syncCtx.GetState()
+	assert.Equal(t, synccommon.OperationSucceeded, phase)
+	assert.Len(t, resources, 2)
+	assert.Equal(t, 1, gateCalls)
+}
+
+func TestSyncHookNamespace(t *testing.T) {
+	job := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata": map[string]interface{}{
+			"name":        "migrate",
+			"annotations": map[string]interface{}{synccommon.AnnotationKeyHook: string(synccommon.HookTypePreSync)},
+		},
+	}}
+	pod := NewPod()
+
+	runSync := func(autoCreate bool) []synccommon.ResourceSyncResult {
+		syncCtx := newTestSyncCtx(nil, WithHookNamespace("hooks-sandbox", autoCreate))
+		fakeDisco := syncCtx.disco.(*fakedisco.FakeDiscovery)
+		fakeDisco.Resources = append(fakeDisco.Resources, &v1.APIResourceList{
+			GroupVersion: "batch/v1",
+			APIResources: []v1.APIResource{{Kind: "Job", Name: "jobs", Group: "batch", Version: "v1", Namespaced: true, Verbs: standardVerbs}},
+		})
+		syncCtx.hooks = []*unstructured.Unstructured{job}
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{nil},
+			Target: []*unstructured.Unstructured{pod},
+		})
+		syncCtx.Sync()
+		_, _, resources := syncCtx.GetState()
+		return resources
+	}
+
+	t.Run("NamespacelessHook", func(t *testing.T) {
+		resources := runSync(false)
+		require.Len(t, resources, 1)
+		assert.Equal(t, kube.NewResourceKey("batch", "Job", "hooks-sandbox", "migrate"), resources[0].ResourceKey)
+		assert.Empty(t, job.GetNamespace())
+	})
+
+	t.Run("AutoCreate", func(t *testing.T) {
+		resources := runSync(true)
+		keys := map[kube.ResourceKey]synccommon.ResultCode{}
+		for _, res := range resources {
+			keys[res.ResourceKey] = res.Status
+		}
+		assert.Equal(t, map[kube.ResourceKey]synccommon.ResultCode{
+			kube.NewResourceKey("", "Namespace", "", "hooks-sandbox"):       synccommon.ResultCodeSynced,
+			kube.NewResourceKey("batch", "Job", "hooks-sandbox", "migrate"): synccommon.ResultCodeSynced,
+		}, keys)
+	})
+}
+
+func TestSyncNamespaceActiveTimeout(t *testing.T) {
+	newSyncCtx := func(phase *string) *syncContext {
+		getResourceFunc := func(_ context.Context, _ *rest.Config, gvk schema.GroupVersionKind, name string, _ string) (*unstructured.Unstructured, error) {
+			if gvk.Kind != kube.NamespaceKind || name != FakeArgoCDNamespace {
+				return nil, apierrors.NewNotFound(schema.GroupResource{}, name)
+			}
+			ns := NewNamespace()
+			ns.SetName(FakeArgoCDNamespace)
+			require.NoError(t, unstructured.SetNestedField(ns.Object, *phase, "status", "phase"))
+			return ns, nil
+		}
+		syncCtx := newTestSyncCtx(&getResourceFunc, WithNamespaceActiveTimeout(time.Minute))
+		syncCtx.namespace = FakeArgoCDNamespace
+		syncCtx.syncNamespace = func(_, _ *unstructured.Unstructured) (bool, error) {
+			return true, nil
+		}
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{nil},
+			Target: []*unstructured.Unstructured{NewPod()},
+		})
+		return syncCtx
+	}
+
+	t.Run("TerminatingToActive", func(t *testing.T) {
+		phase := string(corev1.NamespaceTerminating)
+		syncCtx := newSyncCtx(&phase)
+
+		syncCtx.Sync()
+		operationPhase, message, resources := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationRunning, operationPhase)
+		assert.Equal(t, "waiting for namespaces to become active: fake-argocd-ns (Terminating)", message)
+		assert.Empty(t, resources)
+
+		phase = string(corev1.NamespaceActive)
+		for i := 0; i < 5 && !operationPhase.Completed(); i++ {
+			syncCtx.Sync()
+			operationPhase, _, resources = syncCtx.GetState()
+		}
+		assert.Equal(t, synccommon.OperationSucceeded, operationPhase)
+		keys := map[kube.ResourceKey]synccommon.ResultCode{}
+		for _, res := range resources {
+			keys[res.ResourceKey] = res.Status
+		}
+		assert.Equal(t, map[kube.ResourceKey]synccommon.ResultCode{
+			kube.NewResourceKey("", "Namespace", "", FakeArgoCDNamespace): synccommon.ResultCodeSynced,
+			kube.NewResourceKey("", "Pod", FakeArgoCDNamespace, "my-pod"): synccommon.ResultCodeSynced,
+		}, keys)
+	})
+
+	t.Run("StuckTerminating", func(t *testing.T) {
+		phase := string(corev1.NamespaceTerminating)
+		syncCtx := newSyncCtx(&phase)
+		syncCtx.Sync()
+		syncCtx.namespaceWaitStartedAt = time.Now().Add(-2 * time.Minute)
+
+		syncCtx.Sync()
+		operationPhase, message, resources := syncCtx.GetState()
+		assert.Equal(t, synccommon.OperationFailed, operationPhase)
+		assert.Equal(t, "namespaces did not become active within 1m0s: fake-argocd-ns (Terminating)", message)
+		assert.Empty(t, resources)
+	})
+}
+
+func TestSyncCRDSchemaChangeCheck(t *testing.T) {
+	newCRD := func(sizeType string, required ...interface{}) *unstructured.Unstructured {
+		spec := map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"size": map[string]interface{}{"type": sizeType}},
+		}
+		if len(required) > 0 {
+			spec["required"] = required
+		}
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apiextensions.k8s.io/v1",
+			"kind":       "CustomResourceDefinition",
+			"metadata":   map[string]interface{}{"name": "widgets.argoproj.io"},
+			"spec": map[string]interface{}{
+				"group": "argoproj.io",
+				"names": map[string]interface{}{"kind": "Widget", "plural": "widgets"},
+				"scope": "Namespaced",
+				"versions": []interface{}{map[string]interface{}{
+					"name":    "v1",
+					"served":  true,
+					"storage": true,
+					"schema": map[string]interface{}{"openAPIV3Schema": map[string]interface{}{
+						"type":       "object",
+						"properties": map[string]interface{}{"spec": spec},
+					}},
+				}},
+			},
+		}}
+	}
+	widget := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "argoproj.io/v1",
+		"kind":       "Widget",
+		"metadata":   map[string]interface{}{"name": "my-widget", "namespace": FakeArgoCDNamespace},
+		"spec":       map[string]interface{}{"size": int64(3)},
+	}}
+
+	runSync := func(block bool, target *unstructured.Unstructured) (synccommon.OperationPhase, string, []synccommon.ResourceSyncResult) {
+		// dry run since the fake clients cannot establish the CRD
+		syncCtx := newTestSyncCtx(nil, WithCRDSchemaChangeCheck(block, nil), WithOperationSettings(true, false, false, false))
+		fakeDisco := syncCtx.disco.(*fakedisco.FakeDiscovery)
+		fakeDisco.Resources = append(fakeDisco.Resources, &v1.APIResourceList{
+			GroupVersion: "apiextensions.k8s.io/v1",
+			APIResources: []v1.APIResource{{Kind: "CustomResourceDefinition", Name: "customresourcedefinitions", Group: "apiextensions.k8s.io", Version: "v1", Namespaced: false, Verbs: standardVerbs}},
+		}, &v1.APIResourceList{
+			GroupVersion: "argoproj.io/v1",
+			APIResources: []v1.APIResource{{Kind: "Widget", Name: "widgets", Group: "argoproj.io", Version: "v1", Namespaced: true, Verbs: standardVerbs}},
+		})
+		syncCtx.resources = groupResources(ReconciliationResult{
+			Live:   []*unstructured.Unstructured{newCRD("integer"), widget},
+			Target: []*unstructured.Unstructured{target, widget.DeepCopy()},
+		})
+		syncCtx.Sync()
+		return syncCtx.GetState()
+	}
+
+	t.Run("CompatibleChange", func(t *testing.T) {
+		phase, _, _ := runSync(true, newCRD("number"))
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		phase, message, resources := runSync(true, newCRD("string", "color"))
+		assert.Equal(t, synccommon.OperationFailed, phase)
+		assert.Contains(t, message, "CRD schema changes invalidate existing custom resources")
+		require.Len(t, resources, 1)
+		assert.Equal(t, "CustomResourceDefinition", resources[0].ResourceKey.Kind)
+		assert.Equal(t, synccommon.ResultCodeSyncFailed, resources[0].Status)
+		assert.Contains(t, resources[0].Message, FakeArgoCDNamespace+"/my-widget: spec.color: Required value")
+		assert.Contains(t, resources[0].Message, FakeArgoCDNamespace+"/my-widget: spec.size: Invalid type, expected string")
+	})
+
+	t.Run("Warn", func(t *testing.T) {
+		phase, _, _ := runSync(false, newCRD("string"))
+		assert.Equal(t, synccommon.OperationSucceeded, phase)
+	})
+
+	t.Run("Lister", func(t *testing.T) {
+		syncCtx := newTestSyncCtx(nil, WithCRDSchemaChangeCheck(true, func(gk schema.GroupKind) ([]*unstructured.Unstructured, error) {
+			assert.Equal(t, schema.GroupKind{Group: "argoproj.io", Kind: "Widget"}, gk)
+			return []*unstructured.Unstructured{widget}, nil
+		}))
+		violations, err := syncCtx.getCRDSchemaViolations(newCRD("string"), newCRD("integer"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{FakeArgoCDNamespace + "/my-widget: spec.size: Invalid type, expected string"}, violations)
+	})
+}
@@ -0,0 +1,122 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/argoproj/gitops-engine/pkg/diff"
+	"github.com/argoproj/gitops-engine/pkg/sync/common"
+	"github.com/argoproj/gitops-engine/pkg/utils/kube"
+)
+
+// SyncLoopDetector detects resources that are changed by every sync operation in the same way, e.g. because a mutating
+// webhook keeps reverting a managed field. The detector must be shared by the sync contexts of consecutive operations.
+type SyncLoopDetector struct {
+	threshold int
+
+	lock    sync.Mutex
+	changes map[kube.ResourceKey]*resourceChange
+}
+
+// resourceChange is the change of a resource applied by consecutive sync operations
+type resourceChange struct {
+	patch string
+	count int
+}
+
+// NewSyncLoopDetector creates a detector that reports a sync loop once the same change of a resource would be applied
+// by more than threshold consecutive sync operations
+func NewSyncLoopDetector(threshold int) *SyncLoopDetector {
+	return &SyncLoopDetector{threshold: threshold, changes: map[kube.ResourceKey]*resourceChange{}}
+}
+
+// check returns the number of consecutive operations that would apply the given change of the resource, including the
+// current one, and true if the same change was applied by the previous threshold operations. An empty patch means
+// that the resource is not changed, which resets the count of the resource.
+func (d *SyncLoopDetector) check(key kube.ResourceKey, patch string) (int, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if patch == "" {
+		delete(d.changes, key)
+		return 0, false
+	}
+	count := 1
+	if change, ok := d.changes[key]; ok && change.patch == patch {
+		count += change.count
+	}
+	return count, count > d.threshold
+}
+
+// record records that the given change of the resource has been applied successfully
+func (d *SyncLoopDetector) record(key kube.ResourceKey, patch string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	change, ok := d.changes[key]
+	if !ok || change.patch != patch {
+		change = &resourceChange{patch: patch}
+		d.changes[key] = change
+	}
+	change.count++
+}
+
+// WithSyncLoopDetector sets a detector that fails the sync operation if a resource is changed in the same way by more
+// than the configured number of consecutive operations. The looping resources are reported as SyncFailed along with
+// the fields that keep changing.
+func WithSyncLoopDetector(detector *SyncLoopDetector) SyncOpt {
+	return func(ctx *syncContext) {
+		ctx.syncLoopDetector = detector
+	}
+}
+
+// detectSyncLoops checks the changes applied by the given tasks and marks the tasks of the resources caught in a sync
+// loop as failed. The changes are only counted once they are applied successfully, see recordSyncLoopChange. Returns
+// false if a sync loop is detected.
+func (sc *syncContext) detectSyncLoops(tasks syncTasks) bool {
+	ok := true
+	sc.syncLoopPatches = map[kube.ResourceKey]string{}
+	for _, task := range tasks {
+		if task.isHook() || task.targetObj == nil || task.liveObj == nil {
+			continue
+		}
+		// the common labels and annotations are not part of the target state, so they must not look like drift
+		res, err := sc.diffResource(task.targetObj, task.liveObj, sc.commonMetadataDiffOptions()...)
+		if err != nil {
+			continue
+		}
+		patch := ""
+		if res.Modified {
+			data, err := res.MergePatch()
+			if err != nil {
+				continue
+			}
+			patch = string(data)
+		}
+		count, detected := sc.syncLoopDetector.check(task.resourceKey(), patch)
+		if !detected {
+			if patch != "" {
+				sc.syncLoopPatches[task.resourceKey()] = patch
+			}
+			continue
+		}
+		paths, err := diff.ChangedPaths(res)
+		if err != nil || len(paths) == 0 {
+			paths = []string{"unknown"}
+		}
+		sc.setResourceResult(task, common.ResultCodeSyncFailed, common.OperationFailed,
+			fmt.Sprintf("sync loop detected: the same change would be applied by %d consecutive syncs, fields that keep changing: %s", count, strings.Join(paths, ", ")))
+		ok = false
+	}
+	return ok
+}
+
+// recordSyncLoopChange counts the change of the given task towards a sync loop once the task has been applied
+// successfully, so that failed or aborted syncs are not mistaken for a loop
+func (sc *syncContext) recordSyncLoopChange(task *syncTask) {
+	if sc.syncLoopDetector == nil || task.isHook() {
+		return
+	}
+	if patch, ok := sc.syncLoopPatches[task.resourceKey()]; ok {
+		sc.syncLoopDetector.record(task.resourceKey(), patch)
+	}
+}
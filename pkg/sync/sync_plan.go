@@ -0,0 +1,264 @@
+package sync
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+
+	"github.com/argoproj/gitops-engine/pkg/diff"
+	"github.com/argoproj/gitops-engine/pkg/sync/common"
+	"github.com/argoproj/gitops-engine/pkg/utils/kube"
+)
+
+// PlannedOperation is the operation a sync plan performs on a resource
+type PlannedOperation string
+
+const (
+	PlannedOperationCreate PlannedOperation = "Create"
+	PlannedOperationUpdate PlannedOperation = "Update"
+	PlannedOperationPrune  PlannedOperation = "Prune"
+)
+
+// DryRunOperation is the operation the API server would perform to apply a resource, as reported by a server-side dry
+// run of the apply
+type DryRunOperation string
+
+const (
+	DryRunOperationCreated    DryRunOperation = "created"
+	DryRunOperationConfigured DryRunOperation = "configured"
+	DryRunOperationUnchanged  DryRunOperation = "unchanged"
+)
+
+// SyncPlan holds the tasks of a sync operation computed by SyncContext.Plan. The plan is serializable, so it can be
+// reviewed, e.g. by an external approval gate, before it is executed using SyncContext.ExecutePlan.
+type SyncPlan struct {
+	Revision string
+	// StartedAt is the start time of the operation, which is used to generate the names of hooks with generateName
+	StartedAt metav1.Time
+	Tasks     []PlannedTask
+}
+
+// PlannedTask is a single task of a sync plan
+type PlannedTask struct {
+	ResourceKey kube.ResourceKey
+	Phase       common.SyncPhase
+	Wave        int
+	Hook        bool
+	Operation   PlannedOperation
+	// LiveResourceVersion is the resource version of the live resource when the plan was computed, empty if the
+	// resource did not exist
+	LiveResourceVersion string
+	// Diff is the formatted diff between the live and the target state, see diff.FormatDiff. Empty for hooks.
+	Diff string
+	// DryRunOperation is the operation reported by the server-side dry run of the apply if enabled using
+	// WithServerDryRunOperations. Empty for hooks and pruned resources.
+	DryRunOperation DryRunOperation
+}
+
+func (t PlannedTask) String() string {
+	return fmt.Sprintf("%s/%d %s/%s/%s", t.Phase, t.Wave, t.ResourceKey.Kind, t.ResourceKey.Namespace, t.ResourceKey.Name)
+}
+
+// Plan computes the tasks of the sync operation without executing them
+func (sc *syncContext) Plan() (*SyncPlan, error) {
+	return sc.plan(sc.serverDryRunOperations)
+}
+
+// plan computes the tasks of the sync operation. The operations the API server would perform are only determined if
+// serverDryRun is true, since every server-side dry run is a request to the API server.
+func (sc *syncContext) plan(serverDryRun bool) (*SyncPlan, error) {
+	if sc.resourceGenerator != nil {
+		if err := sc.generateResources(); err != nil {
+			return nil, fmt.Errorf("failed to generate resources: %w", err)
+		}
+	}
+	// computing the tasks records the results of invalid tasks, which must not mark the operation as started
+	syncRes := make(map[string]common.ResourceSyncResult, len(sc.syncRes))
+	for k, v := range sc.syncRes {
+		syncRes[k] = v
+	}
+	defer func() {
+		sc.syncRes = syncRes
+	}()
+	tasks, ok := sc.getSyncTasks()
+	if !ok {
+		return nil, fmt.Errorf("one or more synchronization tasks are not valid")
+	}
+
+	plan := &SyncPlan{Revision: sc.revision, StartedAt: metav1.NewTime(sc.startedAt)}
+	for _, t := range tasks {
+		task := PlannedTask{
+			ResourceKey: t.resourceKey(),
+			Phase:       t.phase,
+			Wave:        t.wave(),
+			Hook:        t.isHook(),
+			Operation:   PlannedOperationUpdate,
+		}
+		if t.isPrune() {
+			task.Operation = PlannedOperationPrune
+		} else if t.liveObj == nil {
+			task.Operation = PlannedOperationCreate
+		}
+		if t.liveObj != nil {
+			task.LiveResourceVersion = t.liveObj.GetResourceVersion()
+		}
+		if !task.Hook {
+			var err error
+			if task.Diff, err = sc.planDiff(t); err != nil {
+				return nil, fmt.Errorf("failed to diff %s: %w", task, err)
+			}
+			if serverDryRun && !t.isPrune() {
+				if task.DryRunOperation, err = sc.serverDryRunOperation(t); err != nil {
+					return nil, fmt.Errorf("failed to dry run %s: %w", task, err)
+				}
+			}
+		}
+		plan.Tasks = append(plan.Tasks, task)
+	}
+	return plan, nil
+}
+
+// planDiff returns the formatted diff between the live and the target state of the given task. The plan is shared with
+// external approval gates, so the data of Secrets is masked before the resources are compared.
+func (sc *syncContext) planDiff(t *syncTask) (string, error) {
+	target, live := t.targetObj, t.liveObj
+	if gvk := t.groupVersionKind(); gvk.Group == "" && gvk.Kind == kube.SecretKind {
+		var err error
+		if target, live, err = diff.HideSecretData(target, live, nil); err != nil {
+			return "", fmt.Errorf("failed to hide secret data: %w", err)
+		}
+	}
+	res, err := sc.diffResource(target, live, sc.commonMetadataDiffOptions()...)
+	if err != nil {
+		return "", err
+	}
+	return diff.FormatDiff(res)
+}
+
+// serverDryRunOperation returns the operation the API server would perform to apply the target of the given task. The
+// operation is determined by a server-side dry run of the apply: the resource is created if it does not exist, and
+// configured if the result of the dry run differs from the live resource in other fields than the managed fields.
+func (sc *syncContext) serverDryRunOperation(t *syncTask) (DryRunOperation, error) {
+	// the ownership of the fields is forced, since conflicts with other field managers do not affect the operation
+	out, err := sc.resourceOps.ApplyResource(sc.getContext(), t.targetObj, cmdutil.DryRunServer, true, false, true, sc.getServerSideApplyManager(t), true)
+	if err != nil {
+		if t.liveObj == nil && isNotFoundErr(err) {
+			// the namespace of the resource does not exist yet, e.g. because it is created by the sync
+			return DryRunOperationCreated, nil
+		}
+		return "", err
+	}
+	if t.liveObj == nil {
+		return DryRunOperationCreated, nil
+	}
+	predicted := &unstructured.Unstructured{}
+	if err := predicted.UnmarshalJSON([]byte(out)); err != nil {
+		return "", fmt.Errorf("failed to unmarshal dry run result: %w", err)
+	}
+	live := t.liveObj.DeepCopy()
+	for _, obj := range []*unstructured.Unstructured{predicted, live} {
+		obj.SetManagedFields(nil)
+		obj.SetResourceVersion("")
+	}
+	if reflect.DeepEqual(predicted.Object, live.Object) {
+		return DryRunOperationUnchanged, nil
+	}
+	return DryRunOperationConfigured, nil
+}
+
+// applyServerDryRun runs the server-side dry run of the apply of the given task and returns a result reporting the
+// operation the API server would perform
+func (sc *syncContext) applyServerDryRun(t *syncTask) (common.ResultCode, string) {
+	operation, err := sc.serverDryRunOperation(t)
+	if err != nil {
+		return common.ResultCodeSyncFailed, err.Error()
+	}
+	return common.ResultCodeSynced, fmt.Sprintf("%s/%s %s (server dry run)", strings.ToLower(t.kind()), t.name(), operation)
+}
+
+// ExecutePlan starts the sync operation if its tasks and the live state still match the given plan, i.e. no task was
+// added or removed and the operation, wave, live resource version and diff of every task are unchanged. The plan is
+// compared with the current tasks and diffs without running server-side dry runs again. The resource versions of the
+// plan are verified again right before the resources of the first wave are applied, in addition to the expectations
+// set using WithExpectedLive. Later waves are not verified against the plan, since applying the earlier waves may
+// change the resource versions of their resources, e.g. by updating the status of dependent resources.
+func (sc *syncContext) ExecutePlan(plan *SyncPlan) error {
+	if sc.started() {
+		return fmt.Errorf("sync operation has already started")
+	}
+	if plan.Revision != sc.revision {
+		return fmt.Errorf("plan was computed for revision '%s', not '%s'", plan.Revision, sc.revision)
+	}
+	// use the start time of the plan, so the generated hook names match
+	sc.startedAt = plan.StartedAt.Time
+	current, err := sc.plan(false)
+	if err != nil {
+		return err
+	}
+	if drift := planDrift(plan, current); drift != "" {
+		message := fmt.Sprintf("live state has drifted from the plan: %s", drift)
+		sc.setOperationPhase(common.OperationFailed, message)
+		return fmt.Errorf("%s", message)
+	}
+
+	// expectations set using WithExpectedLive take precedence; they are restored once the first wave is applied, so
+	// subsequent steps of the operation are not affected by the plan
+	expectedLive := make(map[kube.ResourceKey]string)
+	for _, t := range plan.Tasks {
+		if !t.Hook && t.Operation != PlannedOperationPrune {
+			expectedLive[t.ResourceKey] = t.LiveResourceVersion
+		}
+	}
+	for key, version := range sc.expectedLive {
+		expectedLive[key] = version
+	}
+	defer func(original map[kube.ResourceKey]string) {
+		sc.expectedLive = original
+	}(sc.expectedLive)
+	sc.expectedLive = expectedLive
+	sc.Sync()
+	return nil
+}
+
+// planDrift returns a description of the first difference between the planned and the current tasks, or an empty
+// string if the tasks match
+func planDrift(planned, current *SyncPlan) string {
+	type taskID struct {
+		key   kube.ResourceKey
+		phase common.SyncPhase
+	}
+	currentTasks := make(map[taskID]PlannedTask)
+	for _, t := range current.Tasks {
+		currentTasks[taskID{t.ResourceKey, t.Phase}] = t
+	}
+	for _, t := range planned.Tasks {
+		id := taskID{t.ResourceKey, t.Phase}
+		c, ok := currentTasks[id]
+		if !ok {
+			return fmt.Sprintf("planned task %s no longer exists", t)
+		}
+		delete(currentTasks, id)
+		if c.Operation != t.Operation {
+			return fmt.Sprintf("operation of %s changed from %s to %s", t, t.Operation, c.Operation)
+		}
+		if c.Wave != t.Wave || c.Hook != t.Hook {
+			return fmt.Sprintf("task %s changed to %s", t, c)
+		}
+		if c.LiveResourceVersion != t.LiveResourceVersion {
+			return fmt.Sprintf("resource version of %s changed from '%s' to '%s'", t, t.LiveResourceVersion, c.LiveResourceVersion)
+		}
+		if c.Diff != t.Diff {
+			return fmt.Sprintf("planned changes of %s changed", t)
+		}
+	}
+	for _, c := range current.Tasks {
+		if _, ok := currentTasks[taskID{c.ResourceKey, c.Phase}]; ok {
+			return fmt.Sprintf("task %s is not part of the plan", c)
+		}
+	}
+	return ""
+}
@@ -2,6 +2,7 @@ package sync
 
 import (
 	"fmt"
+	"time"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -24,6 +25,7 @@ type syncTask struct {
 	operationState common.OperationPhase
 	message        string
 	waveOverride   *int
+	hookClassifier HookClassifier
 }
 
 func ternary(val bool, a, b string) string {
@@ -65,6 +67,10 @@ func (t *syncTask) wave() int {
 }
 
 func (t *syncTask) isHook() bool {
+	if t.hookClassifier != nil {
+		_, isHook, _ := t.hookClassifier(t.obj())
+		return isHook
+	}
 	return hook.IsHook(t.obj())
 }
 
@@ -124,7 +130,7 @@ func (t *syncTask) hasHookDeletePolicy(policy common.HookDeletePolicy) bool {
 	if !t.isHook() {
 		return false
 	}
-	for _, p := range hook.DeletePolicies(t.obj()) {
+	for _, p := range t.hookDeletePolicies() {
 		if p == policy {
 			return true
 		}
@@ -132,6 +138,18 @@ func (t *syncTask) hasHookDeletePolicy(policy common.HookDeletePolicy) bool {
 	return false
 }
 
+func (t *syncTask) hookDeletePolicies() []common.HookDeletePolicy {
+	if t.hookClassifier != nil {
+		_, _, policies := t.hookClassifier(t.obj())
+		return policies
+	}
+	return hook.DeletePolicies(t.obj())
+}
+
+func (t *syncTask) runOnChange() bool {
+	return t.isHook() && hook.RunPolicy(t.obj()) == common.HookRunPolicyOnChange
+}
+
 func (t *syncTask) deleteBeforeCreation() bool {
 	return t.liveObj != nil && t.pending() && t.hasHookDeletePolicy(common.HookDeletePolicyBeforeHookCreation)
 }
@@ -148,6 +166,22 @@ func (t *syncTask) deleteOnPhaseFailed() bool {
 	return t.liveObj != nil && t.hasHookDeletePolicy(common.HookDeletePolicyHookFailed)
 }
 
+// healthTimeout returns the timeout set by the health timeout annotation of the target object, if any
+func (t *syncTask) healthTimeout() (time.Duration, bool) {
+	if t.targetObj == nil {
+		return 0, false
+	}
+	value, ok := t.targetObj.GetAnnotations()[common.AnnotationHealthTimeout]
+	if !ok {
+		return 0, false
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, false
+	}
+	return timeout, true
+}
+
 func (t *syncTask) resourceKey() kube.ResourceKey {
 	return kube.GetResourceKey(t.obj())
 }
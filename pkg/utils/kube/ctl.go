@@ -7,7 +7,9 @@ import (
 	"strings"
 
 	"github.com/go-logr/logr"
+	openapi_v2 "github.com/google/gnostic-models/openapiv2"
 	"golang.org/x/sync/errgroup"
+	protobuf "google.golang.org/protobuf/proto"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -60,10 +62,14 @@ func (k *KubectlCmd) filterAPIResources(config *rest.Config, preferred bool, res
 	if err != nil {
 		return nil, err
 	}
+	return filterAPIResources(disco, config.Host, preferred, resourceFilter, filter, k.Log)
+}
 
+func filterAPIResources(disco discovery.DiscoveryInterface, host string, preferred bool, resourceFilter ResourceFilter, filter filterFunc, log logr.Logger) ([]APIResourceInfo, error) {
 	var serverResources []*metav1.APIResourceList
+	var err error
 	if preferred {
-		serverResources, err = disco.ServerPreferredResources()
+		serverResources, err = discovery.ServerPreferredResources(disco)
 	} else {
 		_, serverResources, err = disco.ServerGroupsAndResources()
 	}
@@ -72,7 +78,7 @@ func (k *KubectlCmd) filterAPIResources(config *rest.Config, preferred bool, res
 		if len(serverResources) == 0 {
 			return nil, err
 		}
-		k.Log.Error(err, "Partial success when performing preferred resource discovery")
+		log.Error(err, "Partial success when performing preferred resource discovery")
 	}
 	apiResIfs := make([]APIResourceInfo, 0)
 	for _, apiResourcesList := range serverResources {
@@ -82,7 +88,7 @@ func (k *KubectlCmd) filterAPIResources(config *rest.Config, preferred bool, res
 		}
 		for _, apiResource := range apiResourcesList.APIResources {
 
-			if resourceFilter.IsExcludedResource(gv.Group, apiResource.Kind, config.Host) {
+			if resourceFilter.IsExcludedResource(gv.Group, apiResource.Kind, host) {
 				continue
 			}
 
@@ -128,13 +134,22 @@ func (k *KubectlCmd) LoadOpenAPISchema(config *rest.Config) (openapi.Resources,
 	if err != nil {
 		return nil, nil, err
 	}
+	return LoadOpenAPISchemaFromDiscovery(disco, k.Log)
+}
 
+// LoadOpenAPISchemaFromDiscovery loads the resource schemas using the given discovery client, see
+// KubectlCmd.LoadOpenAPISchema.
+func LoadOpenAPISchemaFromDiscovery(disco discovery.OpenAPISchemaInterface, log logr.Logger) (openapi.Resources, *managedfields.GvkParser, error) {
 	oapiGetter := openapi.NewOpenAPIGetter(disco)
 	oapiResources, err := openapi.NewOpenAPIParser(oapiGetter).Parse()
 	if err != nil {
 		return nil, nil, fmt.Errorf("error getting openapi resources: %s", err)
 	}
-	gvkParser, err := k.newGVKParser(oapiGetter)
+	doc, err := oapiGetter.OpenAPISchema()
+	if err != nil {
+		return oapiResources, nil, fmt.Errorf("error getting gvk parser: error getting openapi schema: %s", err)
+	}
+	gvkParser, err := newGVKParserFromDocument(doc, log)
 	if err != nil {
 		return oapiResources, nil, fmt.Errorf("error getting gvk parser: %s", err)
 	}
@@ -146,6 +161,24 @@ func (k *KubectlCmd) newGVKParser(oapiGetter discovery.OpenAPISchemaInterface) (
 	if err != nil {
 		return nil, fmt.Errorf("error getting openapi schema: %s", err)
 	}
+	return newGVKParserFromDocument(doc, k.Log)
+}
+
+// NewGVKParserFromOpenAPIV2 returns a GVK parser for the given OpenAPI v2 document, encoded either as protobuf or as
+// JSON/YAML. The document can be downloaded once from the /openapi/v2 endpoint of the API server and bundled with
+// the tooling, which allows schema-aware diffs to be computed without access to a cluster.
+func NewGVKParserFromOpenAPIV2(data []byte, log logr.Logger) (*managedfields.GvkParser, error) {
+	doc := &openapi_v2.Document{}
+	if err := protobuf.Unmarshal(data, doc); err != nil {
+		doc, err = openapi_v2.ParseDocument(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing openapi document: %s", err)
+		}
+	}
+	return newGVKParserFromDocument(doc, log)
+}
+
+func newGVKParserFromDocument(doc *openapi_v2.Document, log logr.Logger) (*managedfields.GvkParser, error) {
 	models, err := proto.NewOpenAPIData(doc)
 	if err != nil {
 		return nil, fmt.Errorf("error getting openapi data: %s", err)
@@ -153,7 +186,7 @@ func (k *KubectlCmd) newGVKParser(oapiGetter discovery.OpenAPISchemaInterface) (
 	var taintedGVKs []schema.GroupVersionKind
 	models, taintedGVKs = newUniqueModels(models)
 	if len(taintedGVKs) > 0 {
-		k.Log.Info("Duplicate GVKs detected in OpenAPI schema. This could cause inaccurate diffs.", "gvks", taintedGVKs)
+		log.Info("Duplicate GVKs detected in OpenAPI schema. This could cause inaccurate diffs.", "gvks", taintedGVKs)
 	}
 	gvkParser, err := managedfields.NewGVKParser(models, false)
 	if err != nil {
@@ -165,15 +198,23 @@ func (k *KubectlCmd) newGVKParser(oapiGetter discovery.OpenAPISchemaInterface) (
 func (k *KubectlCmd) GetAPIResources(config *rest.Config, preferred bool, resourceFilter ResourceFilter) ([]APIResourceInfo, error) {
 	span := k.Tracer.StartSpan("GetAPIResources")
 	defer span.Finish()
-	apiResIfs, err := k.filterAPIResources(config, preferred, resourceFilter, func(apiResource *metav1.APIResource) bool {
-		return isSupportedVerb(apiResource, listVerb) && isSupportedVerb(apiResource, watchVerb)
-	})
+	apiResIfs, err := k.filterAPIResources(config, preferred, resourceFilter, isWatchable)
 	if err != nil {
 		return nil, err
 	}
 	return apiResIfs, err
 }
 
+// GetAPIResourcesFromDiscovery returns the API resources that can be listed and watched using the given discovery
+// client, see KubectlCmd.GetAPIResources. The host is passed to the resource filter.
+func GetAPIResourcesFromDiscovery(disco discovery.DiscoveryInterface, host string, preferred bool, resourceFilter ResourceFilter, log logr.Logger) ([]APIResourceInfo, error) {
+	return filterAPIResources(disco, host, preferred, resourceFilter, isWatchable, log)
+}
+
+func isWatchable(apiResource *metav1.APIResource) bool {
+	return isSupportedVerb(apiResource, listVerb) && isSupportedVerb(apiResource, watchVerb)
+}
+
 // GetResource returns resource
 func (k *KubectlCmd) GetResource(ctx context.Context, config *rest.Config, gvk schema.GroupVersionKind, name string, namespace string) (*unstructured.Unstructured, error) {
 	span := k.Tracer.StartSpan("GetResource")
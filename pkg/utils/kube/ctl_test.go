@@ -7,9 +7,15 @@ import (
 	"github.com/stretchr/testify/require"
 	"testing"
 
+	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/klog/v2/textlogger"
+	"sigs.k8s.io/yaml"
 
+	"github.com/argoproj/gitops-engine/pkg/diff"
+	difftestdata "github.com/argoproj/gitops-engine/pkg/diff/testdata"
 	testingutils "github.com/argoproj/gitops-engine/pkg/utils/testing"
 	"github.com/argoproj/gitops-engine/pkg/utils/tracing"
 )
@@ -108,3 +114,28 @@ func (f *fakeOpenAPIClient) OpenAPISchema() (*openapi_v2.Document, error) {
 	}
 	return document, nil
 }
+
+func TestNewGVKParserFromOpenAPIV2(t *testing.T) {
+	offlineParser, err := NewGVKParserFromOpenAPIV2(difftestdata.OpenAPIV2Doc, logr.Discard())
+	require.NoError(t, err)
+	doc := &openapi_v2.Document{}
+	require.NoError(t, proto.Unmarshal(difftestdata.OpenAPIV2Doc, doc))
+	liveParser, err := newGVKParserFromDocument(doc, logr.Discard())
+	require.NoError(t, err)
+
+	liveState := testingutils.Unstructured(difftestdata.ServiceLiveYAML)
+	desiredState := testingutils.Unstructured(difftestdata.ServiceConfigWithSamePortsYAML)
+
+	offline, err := diff.Diff(desiredState, liveState, diff.WithStructuredMergeDiff(true), diff.WithGVKParser(offlineParser), diff.WithManager("argocd-controller"))
+	require.NoError(t, err)
+	live, err := diff.Diff(desiredState, liveState, diff.WithStructuredMergeDiff(true), diff.WithGVKParser(liveParser), diff.WithManager("argocd-controller"))
+	require.NoError(t, err)
+
+	assert.True(t, offline.Modified)
+	// ports are merged using both the port and the protocol as merge keys
+	svc := corev1.Service{}
+	require.NoError(t, yaml.Unmarshal(offline.PredictedLive, &svc))
+	assert.Len(t, svc.Spec.Ports, 5)
+	assert.Equal(t, string(live.PredictedLive), string(offline.PredictedLive))
+	assert.Equal(t, string(live.NormalizedLive), string(offline.NormalizedLive))
+}
@@ -4,12 +4,14 @@ package kube
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"regexp"
 	"strings"
 	"time"
 
+	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/go-logr/logr"
 
 	v1 "k8s.io/api/core/v1"
@@ -19,12 +21,15 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/apimachinery/pkg/util/wait"
 	kubeyaml "k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	kubescheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
@@ -38,6 +43,7 @@ const (
 
 const (
 	SecretKind                   = "Secret"
+	ConfigMapKind                = "ConfigMap"
 	ServiceKind                  = "Service"
 	ServiceAccountKind           = "ServiceAccount"
 	EndpointsKind                = "Endpoints"
@@ -53,6 +59,10 @@ const (
 	APIServiceKind               = "APIService"
 	NamespaceKind                = "Namespace"
 	HorizontalPodAutoscalerKind  = "HorizontalPodAutoscaler"
+	NodeKind                     = "Node"
+	NetworkPolicyKind            = "NetworkPolicy"
+	ResourceQuotaKind            = "ResourceQuota"
+	LimitRangeKind               = "LimitRange"
 )
 
 type ResourceInfoProvider interface {
@@ -199,6 +209,87 @@ func ServerResourceForGroupVersionKind(disco discovery.DiscoveryInterface, gvk s
 	return nil, retErr
 }
 
+// PreferredVersion returns the GVK of the given kind in the version preferred by the server. If the preferred version
+// of the group does not serve the kind then the first served version of the group that does is returned. This allows
+// manifests using deprecated API versions (e.g. apps/v1beta1) to be mapped to a version that is still served.
+// A NotFound error is returned if no served version of the group serves the kind.
+func PreferredVersion(disco discovery.DiscoveryInterface, gvk schema.GroupVersionKind) (schema.GroupVersionKind, error) {
+	groups, err := disco.ServerGroups()
+	if err != nil {
+		return schema.GroupVersionKind{}, err
+	}
+	for _, group := range groups.Groups {
+		if group.Name != gvk.Group {
+			continue
+		}
+		versions := []string{group.PreferredVersion.Version}
+		for _, v := range group.Versions {
+			if v.Version != group.PreferredVersion.Version {
+				versions = append(versions, v.Version)
+			}
+		}
+		for _, version := range versions {
+			candidate := gvk.GroupKind().WithVersion(version)
+			_, err := ServerResourceForGroupVersionKind(disco, candidate, "")
+			if err == nil {
+				return candidate, nil
+			}
+			if !apierr.IsNotFound(err) {
+				return schema.GroupVersionKind{}, err
+			}
+		}
+	}
+	return schema.GroupVersionKind{}, apierr.NewNotFound(schema.GroupResource{Group: gvk.Group, Resource: gvk.Kind}, "")
+}
+
+// ConvertToPreferred returns a copy of the given object with the apiVersion set to the version preferred by the server.
+// Only the apiVersion is rewritten, fields that differ between the versions are not converted.
+func ConvertToPreferred(disco discovery.DiscoveryInterface, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	gvk, err := PreferredVersion(disco, obj.GroupVersionKind())
+	if err != nil {
+		return nil, err
+	}
+	res := obj.DeepCopy()
+	res.SetAPIVersion(gvk.GroupVersion().String())
+	return res, nil
+}
+
+// CreateStrategicMergePatch returns a patch that transforms the original object into the modified object. A strategic
+// merge patch that honors the patch merge keys and strategies is created for types registered in the scheme and a
+// JSON merge patch is created for other types such as custom resources.
+func CreateStrategicMergePatch(original, modified *unstructured.Unstructured) ([]byte, error) {
+	originalBytes, err := json.Marshal(original)
+	if err != nil {
+		return nil, err
+	}
+	modifiedBytes, err := json.Marshal(modified)
+	if err != nil {
+		return nil, err
+	}
+	if DeterminePatchType(modified.GroupVersionKind(), false) == types.StrategicMergePatchType {
+		versionedObject, err := kubescheme.Scheme.New(modified.GroupVersionKind())
+		if err != nil {
+			return nil, err
+		}
+		return strategicpatch.CreateTwoWayMergePatch(originalBytes, modifiedBytes, versionedObject)
+	}
+	return jsonpatch.CreateMergePatch(originalBytes, modifiedBytes)
+}
+
+// DeterminePatchType returns the patch mechanism that should be used to update resources of the given kind. Built-in
+// types registered in the scheme carry the strategic merge metadata and are patched using a strategic merge patch.
+// Other types, such as custom resources, are patched using server-side apply if the server knows their schema
+// (hasSchema) and using a JSON merge patch otherwise.
+func DeterminePatchType(gvk schema.GroupVersionKind, hasSchema bool) types.PatchType {
+	if kubescheme.Scheme.Recognizes(gvk) {
+		return types.StrategicMergePatchType
+	}
+	if hasSchema {
+		return types.ApplyPatchType
+	}
+	return types.MergePatchType
+}
+
 var (
 	kubectlErrOutRegexp = regexp.MustCompile(`^(error: )?(error validating|error when creating|error when creating) "\S+": `)
 
@@ -11,6 +11,7 @@ import (
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	fakedisco "k8s.io/client-go/discovery/fake"
 	"k8s.io/client-go/rest"
 	testcore "k8s.io/client-go/testing"
@@ -221,3 +222,122 @@ func TestServerResourceGroupForGroupVersionKind(t *testing.T) {
 		}
 	})
 }
+
+func TestPreferredVersion(t *testing.T) {
+	fakeDisco := &fakedisco.FakeDiscovery{Fake: &testcore.Fake{}}
+	fakeDisco.Resources = append(make([]*v1.APIResourceList, 0),
+		&v1.APIResourceList{
+			GroupVersion: "apps/v1",
+			APIResources: []v1.APIResource{
+				{Kind: "Deployment", Group: "apps", Version: "v1", Namespaced: true, Verbs: standardVerbs},
+			},
+		},
+		&v1.APIResourceList{
+			GroupVersion: "test.argoproj.io/v1",
+			APIResources: []v1.APIResource{
+				{Kind: "TestNew", Group: "test.argoproj.io", Version: "v1", Namespaced: true, Verbs: standardVerbs},
+			},
+		},
+		&v1.APIResourceList{
+			GroupVersion: "test.argoproj.io/v1alpha1",
+			APIResources: []v1.APIResource{
+				{Kind: "TestOld", Group: "test.argoproj.io", Version: "v1alpha1", Namespaced: true, Verbs: standardVerbs},
+			},
+		})
+
+	t.Run("Deprecated version is mapped to preferred version", func(t *testing.T) {
+		gvk, err := PreferredVersion(fakeDisco, schema.FromAPIVersionAndKind("apps/v1beta1", "Deployment"))
+		require.NoError(t, err)
+		assert.Equal(t, schema.FromAPIVersionAndKind("apps/v1", "Deployment"), gvk)
+	})
+	t.Run("Kind not served by preferred version", func(t *testing.T) {
+		gvk, err := PreferredVersion(fakeDisco, schema.FromAPIVersionAndKind("test.argoproj.io/v1beta1", "TestOld"))
+		require.NoError(t, err)
+		assert.Equal(t, schema.FromAPIVersionAndKind("test.argoproj.io/v1alpha1", "TestOld"), gvk)
+	})
+	t.Run("Group not served", func(t *testing.T) {
+		_, err := PreferredVersion(fakeDisco, schema.FromAPIVersionAndKind("extensions/v1beta1", "Ingress"))
+		assert.True(t, apierr.IsNotFound(err))
+	})
+	t.Run("Convert object", func(t *testing.T) {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("apps/v1beta1")
+		obj.SetKind("Deployment")
+		obj.SetName("my-deploy")
+
+		converted, err := ConvertToPreferred(fakeDisco, obj)
+		require.NoError(t, err)
+		assert.Equal(t, "apps/v1", converted.GetAPIVersion())
+		assert.Equal(t, "my-deploy", converted.GetName())
+		assert.Equal(t, "apps/v1beta1", obj.GetAPIVersion())
+	})
+}
+
+func TestDeterminePatchType(t *testing.T) {
+	deployment := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	crd := schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}
+
+	assert.Equal(t, types.StrategicMergePatchType, DeterminePatchType(deployment, false))
+	assert.Equal(t, types.StrategicMergePatchType, DeterminePatchType(deployment, true))
+	assert.Equal(t, types.MergePatchType, DeterminePatchType(crd, false))
+	assert.Equal(t, types.ApplyPatchType, DeterminePatchType(crd, true))
+}
+
+func TestCreateStrategicMergePatch(t *testing.T) {
+	t.Run("BuiltInType", func(t *testing.T) {
+		original := unstructuredFromYAML(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:v1
+      - name: sidecar
+        image: sidecar:v1`)
+		modified := original.DeepCopy()
+		containers, _, err := unstructured.NestedSlice(modified.Object, "spec", "template", "spec", "containers")
+		require.NoError(t, err)
+		containers[1].(map[string]interface{})["image"] = "sidecar:v2"
+		require.NoError(t, unstructured.SetNestedSlice(modified.Object, containers, "spec", "template", "spec", "containers"))
+
+		patch, err := CreateStrategicMergePatch(original, modified)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"spec":{"template":{"spec":{
+			"$setElementOrder/containers":[{"name":"app"},{"name":"sidecar"}],
+			"containers":[{"name":"sidecar","image":"sidecar:v2"}]}}}}`, string(patch))
+	})
+
+	t.Run("CustomResource", func(t *testing.T) {
+		original := unstructuredFromYAML(t, `
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: my-widget
+spec:
+  items:
+  - name: a
+    value: "1"
+  - name: b
+    value: "1"`)
+		modified := original.DeepCopy()
+		items, _, err := unstructured.NestedSlice(modified.Object, "spec", "items")
+		require.NoError(t, err)
+		items[1].(map[string]interface{})["value"] = "2"
+		require.NoError(t, unstructured.SetNestedSlice(modified.Object, items, "spec", "items"))
+
+		patch, err := CreateStrategicMergePatch(original, modified)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"spec":{"items":[{"name":"a","value":"1"},{"name":"b","value":"2"}]}}`, string(patch))
+	})
+}
+
+func unstructuredFromYAML(t *testing.T, manifest string) *unstructured.Unstructured {
+	t.Helper()
+	obj := &unstructured.Unstructured{}
+	require.NoError(t, yaml.Unmarshal([]byte(manifest), &obj.Object))
+	return obj
+}
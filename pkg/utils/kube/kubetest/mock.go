@@ -30,6 +30,8 @@ type MockKubectlCmd struct {
 
 	convertToVersionFunc *func(obj *unstructured.Unstructured, group, version string) (*unstructured.Unstructured, error)
 	getResourceFunc      *func(ctx context.Context, config *rest.Config, gvk schema.GroupVersionKind, name string, namespace string) (*unstructured.Unstructured, error)
+	deleteResourceFunc   *func(ctx context.Context, config *rest.Config, gvk schema.GroupVersionKind, name string, namespace string, deleteOptions metav1.DeleteOptions) error
+	patchResourceFunc    *func(ctx context.Context, config *rest.Config, gvk schema.GroupVersionKind, name string, namespace string, patchType types.PatchType, patchBytes []byte) (*unstructured.Unstructured, error)
 }
 
 // WithConvertToVersionFunc overrides the default ConvertToVersion behavior.
@@ -44,6 +46,18 @@ func (k *MockKubectlCmd) WithGetResourceFunc(getResourcefunc func(context.Contex
 	return k
 }
 
+// WithDeleteResourceFunc overrides the default DeleteResource behavior.
+func (k *MockKubectlCmd) WithDeleteResourceFunc(deleteResourceFunc func(context.Context, *rest.Config, schema.GroupVersionKind, string, string, metav1.DeleteOptions) error) *MockKubectlCmd {
+	k.deleteResourceFunc = &deleteResourceFunc
+	return k
+}
+
+// WithPatchResourceFunc overrides the default PatchResource behavior.
+func (k *MockKubectlCmd) WithPatchResourceFunc(patchResourceFunc func(context.Context, *rest.Config, schema.GroupVersionKind, string, string, types.PatchType, []byte) (*unstructured.Unstructured, error)) *MockKubectlCmd {
+	k.patchResourceFunc = &patchResourceFunc
+	return k
+}
+
 func (k *MockKubectlCmd) NewDynamicClient(config *rest.Config) (dynamic.Interface, error) {
 	return k.DynamicClient, nil
 }
@@ -61,10 +75,16 @@ func (k *MockKubectlCmd) GetResource(ctx context.Context, config *rest.Config, g
 }
 
 func (k *MockKubectlCmd) PatchResource(ctx context.Context, config *rest.Config, gvk schema.GroupVersionKind, name string, namespace string, patchType types.PatchType, patchBytes []byte, subresources ...string) (*unstructured.Unstructured, error) {
+	if k.patchResourceFunc != nil {
+		return (*k.patchResourceFunc)(ctx, config, gvk, name, namespace, patchType, patchBytes)
+	}
 	return nil, nil
 }
 
 func (k *MockKubectlCmd) DeleteResource(ctx context.Context, config *rest.Config, gvk schema.GroupVersionKind, name string, namespace string, deleteOptions metav1.DeleteOptions) error {
+	if k.deleteResourceFunc != nil {
+		return (*k.deleteResourceFunc)(ctx, config, gvk, name, namespace, deleteOptions)
+	}
 	command, ok := k.Commands[name]
 	if !ok {
 		return nil
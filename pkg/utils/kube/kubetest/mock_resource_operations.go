@@ -17,8 +17,12 @@ type MockResourceOps struct {
 	Commands      map[string]KubectlOutput
 	Events        chan watch.Event
 	DynamicClient dynamic.Interface
+	// CommandsPerVerb overrides Commands for a specific verb (apply, replace, update or create)
+	CommandsPerVerb map[string]map[string]KubectlOutput
 
 	lastCommandPerResource map[kube.ResourceKey]string
+	lastObjPerResource     map[kube.ResourceKey]*unstructured.Unstructured
+	lastDryRunPerResource  map[kube.ResourceKey]cmdutil.DryRunStrategy
 	lastValidate           bool
 	serverSideApply        bool
 	serverSideApplyManager string
@@ -26,7 +30,8 @@ type MockResourceOps struct {
 
 	recordLock sync.RWMutex
 
-	getResourceFunc *func(ctx context.Context, config *rest.Config, gvk schema.GroupVersionKind, name string, namespace string) (*unstructured.Unstructured, error)
+	getResourceFunc   *func(ctx context.Context, config *rest.Config, gvk schema.GroupVersionKind, name string, namespace string) (*unstructured.Unstructured, error)
+	applyResourceFunc *func(ctx context.Context, obj *unstructured.Unstructured) (string, error)
 }
 
 // WithGetResourceFunc overrides the default ConvertToVersion behavior.
@@ -35,6 +40,12 @@ func (r *MockResourceOps) WithGetResourceFunc(getResourcefunc func(context.Conte
 	return r
 }
 
+// WithApplyResourceFunc overrides the result of ApplyResource, which is otherwise taken from Commands.
+func (r *MockResourceOps) WithApplyResourceFunc(applyResourceFunc func(context.Context, *unstructured.Unstructured) (string, error)) *MockResourceOps {
+	r.applyResourceFunc = &applyResourceFunc
+	return r
+}
+
 func (r *MockResourceOps) SetLastValidate(validate bool) {
 	r.recordLock.Lock()
 	r.lastValidate = validate
@@ -105,13 +116,61 @@ func (r *MockResourceOps) GetLastResourceCommand(key kube.ResourceKey) string {
 	return r.lastCommandPerResource[key]
 }
 
+func (r *MockResourceOps) SetLastResourceObject(obj *unstructured.Unstructured) {
+	r.recordLock.Lock()
+	if r.lastObjPerResource == nil {
+		r.lastObjPerResource = map[kube.ResourceKey]*unstructured.Unstructured{}
+	}
+	r.lastObjPerResource[kube.GetResourceKey(obj)] = obj.DeepCopy()
+	r.recordLock.Unlock()
+}
+
+func (r *MockResourceOps) GetLastResourceObject(key kube.ResourceKey) *unstructured.Unstructured {
+	r.recordLock.Lock()
+	defer r.recordLock.Unlock()
+	if r.lastObjPerResource == nil {
+		return nil
+	}
+	return r.lastObjPerResource[key]
+}
+
+func (r *MockResourceOps) SetLastResourceDryRunStrategy(key kube.ResourceKey, dryRunStrategy cmdutil.DryRunStrategy) {
+	r.recordLock.Lock()
+	if r.lastDryRunPerResource == nil {
+		r.lastDryRunPerResource = map[kube.ResourceKey]cmdutil.DryRunStrategy{}
+	}
+	r.lastDryRunPerResource[key] = dryRunStrategy
+	r.recordLock.Unlock()
+}
+
+// GetLastResourceDryRunStrategy returns the dry run strategy of the last command executed for the given resource
+func (r *MockResourceOps) GetLastResourceDryRunStrategy(key kube.ResourceKey) (cmdutil.DryRunStrategy, bool) {
+	r.recordLock.Lock()
+	defer r.recordLock.Unlock()
+	dryRunStrategy, ok := r.lastDryRunPerResource[key]
+	return dryRunStrategy, ok
+}
+
+func (r *MockResourceOps) getCommand(verb, name string) (KubectlOutput, bool) {
+	if command, ok := r.CommandsPerVerb[verb][name]; ok {
+		return command, true
+	}
+	command, ok := r.Commands[name]
+	return command, ok
+}
+
 func (r *MockResourceOps) ApplyResource(ctx context.Context, obj *unstructured.Unstructured, dryRunStrategy cmdutil.DryRunStrategy, force, validate, serverSideApply bool, manager string, serverSideDiff bool) (string, error) {
 	r.SetLastValidate(validate)
 	r.SetLastServerSideApply(serverSideApply)
 	r.SetLastServerSideApplyManager(manager)
 	r.SetLastForce(force)
 	r.SetLastResourceCommand(kube.GetResourceKey(obj), "apply")
-	command, ok := r.Commands[obj.GetName()]
+	r.SetLastResourceDryRunStrategy(kube.GetResourceKey(obj), dryRunStrategy)
+	r.SetLastResourceObject(obj)
+	if r.applyResourceFunc != nil {
+		return (*r.applyResourceFunc)(ctx, obj)
+	}
+	command, ok := r.getCommand("apply", obj.GetName())
 	if !ok {
 		return "", nil
 	}
@@ -121,8 +180,10 @@ func (r *MockResourceOps) ApplyResource(ctx context.Context, obj *unstructured.U
 
 func (r *MockResourceOps) ReplaceResource(ctx context.Context, obj *unstructured.Unstructured, dryRunStrategy cmdutil.DryRunStrategy, force bool) (string, error) {
 	r.SetLastForce(force)
-	command, ok := r.Commands[obj.GetName()]
+	command, ok := r.getCommand("replace", obj.GetName())
 	r.SetLastResourceCommand(kube.GetResourceKey(obj), "replace")
+	r.SetLastResourceDryRunStrategy(kube.GetResourceKey(obj), dryRunStrategy)
+	r.SetLastResourceObject(obj)
 	if !ok {
 		return "", nil
 	}
@@ -132,7 +193,8 @@ func (r *MockResourceOps) ReplaceResource(ctx context.Context, obj *unstructured
 
 func (r *MockResourceOps) UpdateResource(ctx context.Context, obj *unstructured.Unstructured, dryRunStrategy cmdutil.DryRunStrategy) (*unstructured.Unstructured, error) {
 	r.SetLastResourceCommand(kube.GetResourceKey(obj), "update")
-	command, ok := r.Commands[obj.GetName()]
+	r.SetLastResourceObject(obj)
+	command, ok := r.getCommand("update", obj.GetName())
 	if !ok {
 		return obj, nil
 	}
@@ -143,7 +205,9 @@ func (r *MockResourceOps) UpdateResource(ctx context.Context, obj *unstructured.
 func (r *MockResourceOps) CreateResource(ctx context.Context, obj *unstructured.Unstructured, dryRunStrategy cmdutil.DryRunStrategy, validate bool) (string, error) {
 
 	r.SetLastResourceCommand(kube.GetResourceKey(obj), "create")
-	command, ok := r.Commands[obj.GetName()]
+	r.SetLastResourceDryRunStrategy(kube.GetResourceKey(obj), dryRunStrategy)
+	r.SetLastResourceObject(obj)
+	command, ok := r.getCommand("create", obj.GetName())
 	if !ok {
 		return "", nil
 	}